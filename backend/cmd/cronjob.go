@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
@@ -56,8 +55,10 @@ Examples:
 		db := database.GetDB()
 
 		// Initialize Redis (optional, can be nil for some handlers)
-		var rdb *redis.Client
-		// You can initialize Redis here if needed for your handlers
+		rdb, err := database.NewRedisClient()
+		if err != nil {
+			sugar.Fatalf("Failed to initialize Redis: %v", err)
+		}
 
 		// Initialize JobManager with proper dependencies
 		jm := jobs.NewJobManager(sugar.Desugar(), rdb, db)
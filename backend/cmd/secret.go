@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/secret"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
+)
+
+var (
+	rotateBatchSize int
+	rotateDryRun    bool
+
+	secretRealm          string
+	bundleFile           string
+	bundlePassphraseFile string
+	bundleImportDryRun   bool
+
+	renderTemplateFile string
+	renderOutputFile   string
+	renderData         map[string]string
+)
+
+// bundlePassphraseEnv names the environment variable read when no passphrase file is given
+const bundlePassphraseEnv = "SECRET_BUNDLE_PASSPHRASE"
+
+// secretCmd groups the secret management commands
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage encrypted secrets",
+	Long:  `Maintenance commands for the envelope-encrypted secret store.`,
+}
+
+var secretRotateKEKCmd = &cobra.Command{
+	Use:   "rotate-kek",
+	Short: "Rewrap every secret version's DEK with the current KEK",
+	Long: `Rewrap the data encryption key (DEK) of every secret version with the current
+key encryption key (KEK) of the configured key provider (secret.key_provider.type
+or KEK_PROVIDER):
+
+  env    the current KEK is selected by KEK_VERSION and loaded from KEK_BASE64_<n>
+         or KEK_<n>
+  file   the current KEK is the current_version of the keyring file
+         (secret.key_provider.keyring_file or KEK_KEYRING_FILE)
+  vault  the current KEK is the latest version of the Vault Transit key
+         (secret.key_provider.vault.* or VAULT_ADDR, VAULT_TOKEN, VAULT_TRANSIT_KEY)
+
+Every older KEK still referenced by a version must stay available to the provider.
+Versions protected by a custom KEK are skipped. Ciphertexts are not modified.
+
+Examples:
+  # Check that every version can be rewrapped without writing anything
+  KEK_VERSION=2 ./lazy-rabbit-secretary secret rotate-kek --dry-run
+
+  # Rewrap onto the current_version of a keyring file, 200 versions per transaction
+  KEK_PROVIDER=file KEK_KEYRING_FILE=./certs/keyring.yaml \
+    ./lazy-rabbit-secretary secret rotate-kek --batch-size 200
+
+  # Rewrap onto the latest Vault Transit key version after rotating the key in Vault
+  vault write -f transit/keys/lazy-rabbit-secretary/rotate
+  KEK_PROVIDER=vault VAULT_ADDR=https://vault.example.com:8200 VAULT_TRANSIT_KEY=lazy-rabbit-secretary \
+    ./lazy-rabbit-secretary secret rotate-kek`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRotateKEK()
+	},
+}
+
+func runRotateKEK() error {
+	if err := database.InitDB(); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer database.CloseDB()
+
+	keyProvider, err := secret.NewKeyProviderFromConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize secret key provider: %w", err)
+	}
+	service := secret.NewSecretService(secret.NewSecretRepository(), keyProvider)
+
+	if rotateDryRun {
+		fmt.Println("Dry run: no changes will be written")
+	}
+
+	result, err := service.RotateKEK(secret.RotateKEKRequest{
+		BatchSize: rotateBatchSize,
+		DryRun:    rotateDryRun,
+	}, func(p secret.RotateKEKProgress) {
+		fmt.Printf("Batch %d: processed %d/%d, rewrapped %d, failed %d\n",
+			p.Batch, p.Processed, p.Total, p.Rewrapped, p.Failed)
+	})
+	if err != nil {
+		return fmt.Errorf("KEK rotation failed: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("Target KEK version: %d\n", result.TargetKEKVersion)
+	fmt.Printf("Versions processed: %d\n", result.Processed)
+	fmt.Printf("Versions rewrapped: %d\n", result.Rewrapped)
+	fmt.Printf("Versions failed:    %d\n", result.Failed)
+	fmt.Printf("Duration:           %s\n", result.FinishedAt.Sub(result.StartedAt))
+
+	for _, f := range result.Failures {
+		fmt.Printf("  ❌ secret %s v%d (KEK %d): %s\n", f.SecretID, f.Version, f.KEKVersion, f.Error)
+	}
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d versions could not be rewrapped", result.Failed)
+	}
+	return nil
+}
+
+var secretExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all secrets of a realm into an encrypted bundle",
+	Long: `Export every secret of a realm, with its metadata and full version history, into
+one archive encrypted with a key derived from a passphrase (scrypt + AES-256-GCM).
+Data encryption keys are unwrapped with the configured KEKs and stored inside the
+encrypted archive, so the importing instance can rewrap them under its own KEK.
+Versions protected by a custom KEK are exported as-is.
+
+The passphrase is read from --passphrase-file or the ` + bundlePassphraseEnv + ` environment variable.
+
+Examples:
+  # Export the default realm of the SQLite dev instance
+  SECRET_BUNDLE_PASSPHRASE='correct horse battery staple' \
+    ./lazy-rabbit-secretary secret export --realm default --output secrets.bundle`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSecretExport()
+	},
+}
+
+var secretImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import secrets from an encrypted bundle",
+	Long: `Import an archive written by "secret export" into a realm of this instance.
+Every DEK is rewrapped with the current KEK of this instance; ciphertexts, version
+numbers and version pointers are kept. Secrets whose name already exists in the
+target realm are skipped.
+
+Examples:
+  # Check the archive and passphrase without writing anything
+  ./lazy-rabbit-secretary secret import --realm production --input secrets.bundle --dry-run
+
+  # Import into the production realm
+  ./lazy-rabbit-secretary secret import --realm production --input secrets.bundle --passphrase-file ./bundle.pass`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSecretImport()
+	},
+}
+
+var secretRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render a config template that references secrets by path",
+	Long: `Render a Go text/template in which {{ secret "db/prod/password" }} is replaced with
+the decrypted current value of the secret at that path, and {{ .key }} with values
+given by --data. A reference matches a secret's path exactly, or "<path>/<name>".
+Every secret read is recorded in the secret access audit log.
+
+Examples:
+  # Render an env file for a deployment
+  ./lazy-rabbit-secretary secret render --realm production \
+    --template deploy/app.env.tmpl --data env=prod --output deploy/app.env`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSecretRender()
+	},
+}
+
+func runSecretRender() error {
+	text, err := os.ReadFile(renderTemplateFile)
+	if err != nil {
+		return fmt.Errorf("failed to read template %s: %w", renderTemplateFile, err)
+	}
+
+	service, realmID, err := initSecretRealmService()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	actor := secret.AccessActor{RealmID: realmID, Username: "cli"}
+	if u, err := user.Current(); err == nil {
+		actor.Username = "cli:" + u.Username
+	}
+
+	content, err := service.RenderTemplate(actor, string(text), renderData)
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	if renderOutputFile == "" {
+		fmt.Print(content)
+		return nil
+	}
+	if err := os.WriteFile(renderOutputFile, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", renderOutputFile, err)
+	}
+	fmt.Printf("Rendered %s to %s\n", renderTemplateFile, renderOutputFile)
+	return nil
+}
+
+func runSecretExport() error {
+	passphrase, err := readBundlePassphrase()
+	if err != nil {
+		return fmt.Errorf("failed to read bundle passphrase: %w", err)
+	}
+
+	service, realmID, err := initSecretRealmService()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	bundle, err := service.ExportRealm(realmID)
+	if err != nil {
+		return fmt.Errorf("failed to export secrets: %w", err)
+	}
+
+	data, err := secret.SealBundle(bundle, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to seal bundle: %w", err)
+	}
+	if err := os.WriteFile(bundleFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write bundle %s: %w", bundleFile, err)
+	}
+
+	versions := 0
+	for _, s := range bundle.Secrets {
+		versions += len(s.Versions)
+	}
+	fmt.Printf("Exported %d secrets (%d versions) of realm %s to %s\n", len(bundle.Secrets), versions, realmID, bundleFile)
+	return nil
+}
+
+func runSecretImport() error {
+	passphrase, err := readBundlePassphrase()
+	if err != nil {
+		return fmt.Errorf("failed to read bundle passphrase: %w", err)
+	}
+	data, err := os.ReadFile(bundleFile)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle %s: %w", bundleFile, err)
+	}
+	bundle, err := secret.OpenBundle(data, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	service, realmID, err := initSecretRealmService()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	if bundleImportDryRun {
+		fmt.Println("Dry run: no changes will be written")
+	}
+	fmt.Printf("Importing %d secrets exported from realm %s at %s\n",
+		len(bundle.Secrets), bundle.SourceRealmID, bundle.ExportedAt.Format("2006-01-02 15:04:05"))
+
+	result, err := service.ImportBundle(bundle, realmID, bundleImportDryRun)
+	if result != nil {
+		fmt.Printf("Secrets imported: %d (%d versions)\n", result.Imported, result.Versions)
+		for _, name := range result.Skipped {
+			fmt.Printf("  ⏭️  %s already exists, skipped\n", name)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	return nil
+}
+
+// initSecretRealmService connects to the database and resolves the --realm flag. The caller
+// closes the database once it succeeded.
+func initSecretRealmService() (*secret.SecretService, string, error) {
+	if err := database.InitDB(); err != nil {
+		return nil, "", fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	keyProvider, err := secret.NewKeyProviderFromConfig()
+	if err != nil {
+		database.CloseDB()
+		return nil, "", fmt.Errorf("failed to initialize secret key provider: %w", err)
+	}
+	repo := secret.NewSecretRepository()
+
+	realmID, err := repo.ResolveRealmID(secretRealm)
+	if err != nil {
+		database.CloseDB()
+		return nil, "", fmt.Errorf("realm %s not found: %w", secretRealm, err)
+	}
+	return secret.NewSecretService(repo, keyProvider), realmID, nil
+}
+
+func readBundlePassphrase() (string, error) {
+	if bundlePassphraseFile != "" {
+		data, err := os.ReadFile(bundlePassphraseFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase := os.Getenv(bundlePassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("set %s or use --passphrase-file", bundlePassphraseEnv)
+}
+
+func init() {
+	secretRotateKEKCmd.Flags().IntVar(&rotateBatchSize, "batch-size", 100, "Number of versions rewrapped per transaction")
+	secretRotateKEKCmd.Flags().BoolVar(&rotateDryRun, "dry-run", false, "Unwrap and rewrap in memory only, without writing to the database")
+
+	for _, c := range []*cobra.Command{secretExportCmd, secretImportCmd, secretRenderCmd} {
+		c.Flags().StringVar(&secretRealm, "realm", "", "Realm ID or name")
+		c.MarkFlagRequired("realm")
+	}
+	for _, c := range []*cobra.Command{secretExportCmd, secretImportCmd} {
+		c.Flags().StringVar(&bundlePassphraseFile, "passphrase-file", "", "File containing the bundle passphrase (default: $"+bundlePassphraseEnv+")")
+	}
+	secretExportCmd.Flags().StringVarP(&bundleFile, "output", "o", "secrets.bundle", "Bundle file to write")
+	secretImportCmd.Flags().StringVarP(&bundleFile, "input", "i", "secrets.bundle", "Bundle file to read")
+	secretImportCmd.Flags().BoolVar(&bundleImportDryRun, "dry-run", false, "Validate and rewrap in memory only, without writing to the database")
+
+	secretRenderCmd.Flags().StringVarP(&renderTemplateFile, "template", "t", "", "Template file to render")
+	secretRenderCmd.Flags().StringVarP(&renderOutputFile, "output", "o", "", "File to write (default: stdout)")
+	secretRenderCmd.Flags().StringToStringVar(&renderData, "data", nil, "Template values as key=value, available as {{ .key }}")
+	secretRenderCmd.MarkFlagRequired("template")
+
+	secretCmd.AddCommand(secretRotateKEKCmd)
+	secretCmd.AddCommand(secretExportCmd)
+	secretCmd.AddCommand(secretImportCmd)
+	secretCmd.AddCommand(secretRenderCmd)
+	rootCmd.AddCommand(secretCmd)
+}
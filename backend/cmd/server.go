@@ -43,13 +43,20 @@ var serverCmd = &cobra.Command{
 		// Initialize Auth service
 		authService := initAuth(logger)
 
+		// Create the job manager before the web service, which exposes its admin API
+		db := database.GetDB()
+		// Redis is optional; without it the scheduler's leader lease is kept in the database
+		rdb, err := database.NewRedisClient()
+		if err != nil {
+			logger.Fatal("Failed to initialize Redis", zap.Error(err))
+		}
+		tm := jobs.NewJobManager(logger, rdb, db)
+
 		logger.Info("Starting HTTP service...")
-		webService := api.NewWebApiService(logger, authService)
+		webService := api.NewWebApiService(logger, authService, tm)
 		go webService.Run()
 
 		logger.Info("Starting Job Manager...")
-		db := database.GetDB()
-		tm := jobs.NewJobManager(logger, nil, db) // Pass nil for Redis client
 		go tm.CheckTasks()
 
 		signalChan := make(chan os.Signal, 1)
@@ -58,6 +65,7 @@ var serverCmd = &cobra.Command{
 		logger.Info("Server is running. Press Ctrl+C to stop.")
 		<-signalChan
 		logger.Info("Received shutdown signal, shutting down.")
+		tm.Stop()
 	},
 }
 
@@ -16,19 +16,26 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/board"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/book"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/bookmark"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/calendar"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/command"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/daily"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/diagram"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/image"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/inbox"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/jobs"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/news"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/pomodoro"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/post"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/preference"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/prompt"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/reminder"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/scheduler"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/secret"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/task"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/webhook"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/wiki"
 	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
 	"github.com/walterfan/lazy-rabbit-secretary/pkg/metrics"
@@ -59,13 +66,15 @@ func (w *responseWriter) WriteString(s string) (int, error) {
 type WebApiService struct {
 	logger      *zap.Logger
 	authService *auth.AuthService
+	jobManager  *jobs.JobManager
 }
 
 // NewWebApiService creates a new instance of WebApiService with the required dependencies.
-func NewWebApiService(logger *zap.Logger, authService *auth.AuthService) *WebApiService {
+func NewWebApiService(logger *zap.Logger, authService *auth.AuthService, jobManager *jobs.JobManager) *WebApiService {
 	return &WebApiService{
 		logger:      logger,
 		authService: authService,
+		jobManager:  jobManager,
 	}
 }
 
@@ -109,7 +118,11 @@ func (thiz *WebApiService) Run() {
 
 	// Register secret routes
 	repo := secret.NewSecretRepository()
-	secretService := secret.NewSecretService(repo)
+	keyProvider, err := secret.NewKeyProviderFromConfig()
+	if err != nil {
+		thiz.logger.Fatal("Failed to initialize secret key provider", zap.Error(err))
+	}
+	secretService := secret.NewSecretService(repo, keyProvider)
 	secret.RegisterRoutes(r, secretService, authMiddleware)
 
 	// Register reminder routes first (needed by task service)
@@ -117,11 +130,39 @@ func (thiz *WebApiService) Run() {
 	reminderService := reminder.NewReminderService(reminderRepo)
 	reminder.RegisterRoutes(r, reminderService, authMiddleware)
 
+	// Register webhook endpoint and delivery log routes
+	webhookService := webhook.NewWebhookService(database.GetDB())
+	webhook.RegisterWebhookRoutes(r, webhookService, authMiddleware)
+
+	// Register notification preference routes
+	preferenceService := preference.NewPreferenceService(database.GetDB())
+	preference.RegisterPreferenceRoutes(r, preferenceService, authMiddleware)
+
+	// Register scheduled job admin routes
+	if thiz.jobManager != nil {
+		jobs.RegisterJobRoutes(r, thiz.jobManager, authMiddleware)
+	}
+
 	// Register task routes (with reminder service dependency)
 	taskRepo := task.NewTaskRepository()
 	taskService := task.NewTaskService(taskRepo, reminderService)
 	task.RegisterRoutes(r, taskService, authMiddleware)
 
+	// Register calendar feed and import routes
+	calendarRepo := calendar.NewCalendarRepository()
+	calendarService := calendar.NewCalendarService(calendarRepo, taskService)
+	calendar.RegisterRoutes(r, calendarService, authMiddleware)
+
+	// Register automatic scheduling routes
+	schedulerRepo := scheduler.NewSchedulerRepository()
+	schedulerService := scheduler.NewSchedulerService(schedulerRepo, taskService)
+	scheduler.RegisterRoutes(r, schedulerService, authMiddleware)
+
+	// Register kanban board routes
+	boardRepo := board.NewBoardRepository()
+	boardService := board.NewBoardService(boardRepo, taskService)
+	board.RegisterRoutes(r, boardService, authMiddleware)
+
 	// Register prompt routes
 	promptRoutes := prompt.NewPromptRoutes(database.GetDB())
 	promptRoutes.RegisterRoutes(r, authMiddleware)
@@ -160,6 +201,8 @@ func (thiz *WebApiService) Run() {
 
 	dailyService := daily.NewDailyService(database.GetDB())
 	daily.RegisterDailyRoutes(r, dailyService, authMiddleware)
+	pomodoroService := pomodoro.NewPomodoroService(database.GetDB())
+	pomodoro.RegisterPomodoroRoutes(r, pomodoroService, authMiddleware)
 
 	// Setup static routes BEFORE the SPA fallback
 	thiz.setupPublicRoutes(r)
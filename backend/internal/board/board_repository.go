@@ -0,0 +1,175 @@
+package board
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BoardRepository provides data access for boards, their columns and card positions
+type BoardRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardRepository() *BoardRepository {
+	return &BoardRepository{db: database.GetDB()}
+}
+
+// Create creates a board with its columns
+func (r *BoardRepository) Create(board *models.Board) error {
+	return r.db.Create(board).Error
+}
+
+// GetByID returns a board of the realm with its columns in order
+func (r *BoardRepository) GetByID(realmID, id string) (*models.Board, error) {
+	var board models.Board
+	err := r.db.Preload("Columns", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).First(&board, "id = ? AND realm_id = ?", id, realmID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// List returns the boards of a realm with their columns
+func (r *BoardRepository) List(realmID string) ([]models.Board, error) {
+	var boards []models.Board
+	err := r.db.Preload("Columns", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Where("realm_id = ?", realmID).Order("name ASC").Find(&boards).Error
+	return boards, err
+}
+
+// Update saves a board and replaces its columns. Cards in removed columns are deleted, so
+// their tasks fall back to the first column of their status.
+func (r *BoardRepository) Update(board *models.Board, removedColumnIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(removedColumnIDs) > 0 {
+			if err := tx.Where("board_id = ? AND column_id IN ?", board.ID, removedColumnIDs).Delete(&models.BoardCard{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("board_id = ? AND id IN ?", board.ID, removedColumnIDs).Delete(&models.BoardColumn{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range board.Columns {
+			if err := tx.Save(&board.Columns[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Columns").Save(board).Error
+	})
+}
+
+// Delete deletes a board with its columns and cards
+func (r *BoardRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board_id = ?", id).Delete(&models.BoardCard{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("board_id = ?", id).Delete(&models.BoardColumn{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Board{}, "id = ?", id).Error
+	})
+}
+
+// GetCards returns the card positions of a board
+func (r *BoardRepository) GetCards(boardID string) ([]models.BoardCard, error) {
+	var cards []models.BoardCard
+	err := r.db.Where("board_id = ?", boardID).Find(&cards).Error
+	return cards, err
+}
+
+// SaveColumnOrder records taskIDs as the content of a column, in order
+func (r *BoardRepository) SaveColumnOrder(boardID, columnID string, taskIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("board_id = ? AND column_id = ?", boardID, columnID)
+		if len(taskIDs) > 0 {
+			q = tx.Where("board_id = ? AND (column_id = ? OR task_id IN ?)", boardID, columnID, taskIDs)
+		}
+		if err := q.Delete(&models.BoardCard{}).Error; err != nil {
+			return err
+		}
+		if len(taskIDs) == 0 {
+			return nil
+		}
+
+		cards := make([]models.BoardCard, 0, len(taskIDs))
+		for i, taskID := range taskIDs {
+			cards = append(cards, models.BoardCard{
+				ID:       uuid.NewString(),
+				BoardID:  boardID,
+				TaskID:   taskID,
+				ColumnID: columnID,
+				Position: i,
+			})
+		}
+		return tx.Create(&cards).Error
+	})
+}
+
+// MoveLocked runs move in a transaction that holds the board's row lock, so moves on one board
+// are checked and applied one at a time
+func (r *BoardRepository) MoveLocked(boardID string, move func(repo *BoardRepository, tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var board models.Board
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", boardID).
+			First(&board).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock board %s: %w", boardID, err)
+		}
+		return move(&BoardRepository{db: tx}, tx)
+	})
+}
+
+// CountColumnTasks counts the tasks a column shows, leaving out excludeTaskID. A column shows
+// the tasks of its status whose card is in it; the first column of a status also shows the
+// tasks of that status without a card in another column of that status.
+func (r *BoardRepository) CountColumnTasks(board *models.Board, columnID, excludeTaskID string) (int64, error) {
+	var column *models.BoardColumn
+	for i := range board.Columns {
+		if board.Columns[i].ID == columnID {
+			column = &board.Columns[i]
+			break
+		}
+	}
+	if column == nil {
+		return 0, fmt.Errorf("column %s: %w", columnID, gorm.ErrRecordNotFound)
+	}
+	first := ""
+	var others []string
+	for _, c := range board.Columns {
+		if c.Status != column.Status {
+			continue
+		}
+		if first == "" {
+			first = c.ID
+		}
+		if c.ID != column.ID {
+			others = append(others, c.ID)
+		}
+	}
+
+	q := r.db.Model(&models.Task{}).
+		Where("realm_id = ? AND status = ? AND id <> ?", board.RealmID, column.Status, excludeTaskID)
+	if first == columnID && len(others) > 0 {
+		q = q.Where("id NOT IN (?)", r.db.Model(&models.BoardCard{}).
+			Select("task_id").
+			Where("board_id = ? AND column_id IN ?", board.ID, others))
+	} else if first != columnID {
+		q = q.Where("id IN (?)", r.db.Model(&models.BoardCard{}).
+			Select("task_id").
+			Where("board_id = ? AND column_id = ?", board.ID, columnID))
+	}
+	var count int64
+	err := q.Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,111 @@
+package board
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutes registers HTTP endpoints for kanban boards
+func RegisterRoutes(router *gin.Engine, service *BoardService, middleware *auth.AuthMiddleware) {
+	group := router.Group("/api/v1/boards")
+	group.Use(middleware.Authenticate())
+
+	// GET /api/v1/boards - List the realm's boards
+	group.GET("", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		items, err := service.ListBoards(realmID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "total": len(items)})
+	})
+
+	// POST /api/v1/boards - Create a board
+	group.POST("", func(c *gin.Context) {
+		var req BoardRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		creator, _ := auth.GetCurrentUsername(c)
+		created, err := service.CreateBoard(req, realmID, creator)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	})
+
+	// GET /api/v1/boards/:id - Get a board with the tasks of each column
+	group.GET("/:id", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		view, err := service.GetBoardView(c.Param("id"), realmID)
+		if err != nil {
+			c.AbortWithStatusJSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, view)
+	})
+
+	// PUT /api/v1/boards/:id - Update a board and its columns
+	group.PUT("/:id", func(c *gin.Context) {
+		var req BoardRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		updater, _ := auth.GetCurrentUsername(c)
+		updated, err := service.UpdateBoard(c.Param("id"), req, realmID, updater)
+		if err != nil {
+			c.AbortWithStatusJSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	})
+
+	// DELETE /api/v1/boards/:id - Delete a board, its tasks are kept
+	group.DELETE("/:id", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		if err := service.DeleteBoard(c.Param("id"), realmID); err != nil {
+			c.AbortWithStatusJSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// POST /api/v1/boards/:id/move - Move a task to a position in a column
+	group.POST("/:id/move", func(c *gin.Context) {
+		var req MoveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		mover, _ := auth.GetCurrentUsername(c)
+		view, err := service.MoveTask(c.Param("id"), req, realmID, mover)
+		if err != nil {
+			c.AbortWithStatusJSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, view)
+	})
+}
+
+// boardErrorStatus maps service errors to HTTP status codes
+func boardErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrWIPLimitReached):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,380 @@
+package board
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/task"
+	"gorm.io/gorm"
+)
+
+const (
+	maxColumns = 20
+	// maxTasksPerStatus is the page size used to load the tasks of each status of a board
+	maxTasksPerStatus = 100
+)
+
+var (
+	// ErrWIPLimitReached is returned when a move would exceed the work-in-progress limit of a column
+	ErrWIPLimitReached = errors.New("work-in-progress limit of the column is reached")
+
+	validStatuses = map[models.TaskStatus]bool{
+		models.TaskStatusPending:   true,
+		models.TaskStatusRunning:   true,
+		models.TaskStatusPaused:    true,
+		models.TaskStatusCompleted: true,
+		models.TaskStatusFailed:    true,
+	}
+)
+
+// BoardService manages kanban boards on top of the task status machine
+type BoardService struct {
+	repo        *BoardRepository
+	taskService *task.TaskService
+}
+
+func NewBoardService(repo *BoardRepository, taskService *task.TaskService) *BoardService {
+	return &BoardService{repo: repo, taskService: taskService}
+}
+
+// ColumnRequest defines a board column; columns are ordered as given
+type ColumnRequest struct {
+	ID       string            `json:"id"` // keeps an existing column when updating a board
+	Name     string            `json:"name" binding:"required"`
+	Status   models.TaskStatus `json:"status" binding:"required"`
+	WIPLimit int               `json:"wip_limit"`
+}
+
+// BoardRequest defines the allowed input for creating and updating a board
+type BoardRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description"`
+	Columns     []ColumnRequest `json:"columns"` // defaults to To Do, In Progress, Paused and Done
+}
+
+// MoveRequest moves a task to a position in a column; position 0 is the top
+type MoveRequest struct {
+	TaskID   string `json:"task_id" binding:"required"`
+	ColumnID string `json:"column_id" binding:"required"`
+	Position *int   `json:"position"` // defaults to the bottom of the column
+}
+
+// ColumnView is a column with its tasks in board order
+type ColumnView struct {
+	models.BoardColumn
+	Tasks     []models.Task `json:"tasks"`
+	Count     int           `json:"count"`
+	OverLimit bool          `json:"over_limit"` // more tasks than the WIP limit, e.g. after a limit was lowered
+}
+
+// BoardView is a board with the tasks of each column
+type BoardView struct {
+	Board     *models.Board `json:"board"`
+	Columns   []ColumnView  `json:"columns"`
+	Truncated bool          `json:"truncated"` // a status had more tasks than are shown
+}
+
+var defaultColumns = []ColumnRequest{
+	{Name: "To Do", Status: models.TaskStatusPending},
+	{Name: "In Progress", Status: models.TaskStatusRunning},
+	{Name: "Paused", Status: models.TaskStatusPaused},
+	{Name: "Done", Status: models.TaskStatusCompleted},
+}
+
+// CreateBoard creates a board for the realm
+func (s *BoardService) CreateBoard(req BoardRequest, realmID, createdBy string) (*models.Board, error) {
+	if strings.TrimSpace(realmID) == "" || strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("realm_id and name are required")
+	}
+	if len(req.Columns) == 0 {
+		req.Columns = defaultColumns
+	}
+
+	board := &models.Board{
+		ID:          uuid.NewString(),
+		RealmID:     realmID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+		UpdatedBy:   createdBy,
+		UpdatedAt:   time.Now(),
+	}
+	columns, _, err := buildColumns(board.ID, req.Columns, nil)
+	if err != nil {
+		return nil, err
+	}
+	board.Columns = columns
+
+	if err := s.repo.Create(board); err != nil {
+		return nil, fmt.Errorf("failed to create board: %w", err)
+	}
+	return board, nil
+}
+
+// UpdateBoard renames a board and replaces its columns. Columns given with their ID keep their
+// task order; columns left out are removed.
+func (s *BoardService) UpdateBoard(id string, req BoardRequest, realmID, updatedBy string) (*models.Board, error) {
+	board, err := s.repo.GetByID(realmID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Columns) == 0 {
+		return nil, errors.New("a board needs at least one column")
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		board.Name = name
+	}
+	board.Description = req.Description
+
+	columns, removed, err := buildColumns(board.ID, req.Columns, board.Columns)
+	if err != nil {
+		return nil, err
+	}
+	board.Columns = columns
+	board.UpdatedBy = updatedBy
+	board.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(board, removed); err != nil {
+		return nil, fmt.Errorf("failed to update board: %w", err)
+	}
+	return board, nil
+}
+
+// buildColumns validates column definitions and returns the columns to save and the IDs of
+// existing columns that are no longer part of the board
+func buildColumns(boardID string, reqs []ColumnRequest, existing []models.BoardColumn) ([]models.BoardColumn, []string, error) {
+	if len(reqs) > maxColumns {
+		return nil, nil, fmt.Errorf("a board can have at most %d columns", maxColumns)
+	}
+
+	kept := make(map[string]bool, len(existing))
+	for _, column := range existing {
+		kept[column.ID] = false
+	}
+
+	columns := make([]models.BoardColumn, 0, len(reqs))
+	for i, req := range reqs {
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("column %d needs a name", i+1)
+		}
+		if !validStatuses[req.Status] {
+			return nil, nil, fmt.Errorf("column %q has invalid status %q", name, req.Status)
+		}
+		if req.WIPLimit < 0 {
+			return nil, nil, fmt.Errorf("column %q has a negative wip_limit", name)
+		}
+
+		id := req.ID
+		if id == "" {
+			id = uuid.NewString()
+		} else if used, ok := kept[id]; !ok || used {
+			return nil, nil, fmt.Errorf("column %s does not belong to the board", id)
+		}
+		kept[id] = true
+
+		columns = append(columns, models.BoardColumn{
+			ID:       id,
+			BoardID:  boardID,
+			Name:     name,
+			Status:   req.Status,
+			Position: i,
+			WIPLimit: req.WIPLimit,
+		})
+	}
+
+	var removed []string
+	for _, column := range existing {
+		if !kept[column.ID] {
+			removed = append(removed, column.ID)
+		}
+	}
+	return columns, removed, nil
+}
+
+// ListBoards returns the realm's boards
+func (s *BoardService) ListBoards(realmID string) ([]models.Board, error) {
+	return s.repo.List(realmID)
+}
+
+// DeleteBoard deletes a board; its tasks are not touched
+func (s *BoardService) DeleteBoard(id, realmID string) error {
+	if _, err := s.repo.GetByID(realmID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// GetBoardView returns a board with the tasks of each column
+func (s *BoardService) GetBoardView(id, realmID string) (*BoardView, error) {
+	board, err := s.repo.GetByID(realmID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(board)
+}
+
+func (s *BoardService) view(board *models.Board) (*BoardView, error) {
+	result := &BoardView{Board: board}
+
+	var tasks []models.Task
+	loaded := make(map[models.TaskStatus]bool)
+	for _, column := range board.Columns {
+		if loaded[column.Status] {
+			continue
+		}
+		loaded[column.Status] = true
+		items, total, err := s.taskService.GetTasksByStatus(board.RealmID, column.Status, 1, maxTasksPerStatus)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s tasks: %w", column.Status, err)
+		}
+		if total > int64(len(items)) {
+			result.Truncated = true
+		}
+		tasks = append(tasks, items...)
+	}
+
+	cards, err := s.repo.GetCards(board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board cards: %w", err)
+	}
+	result.Columns = arrange(board.Columns, tasks, cards)
+	return result, nil
+}
+
+// arrange puts each task into the column its card points to, as long as that column still
+// matches the task's status, or else into the first column of its status. Within a column,
+// ordered tasks come first, then the rest by schedule time.
+func arrange(columns []models.BoardColumn, tasks []models.Task, cards []models.BoardCard) []ColumnView {
+	views := make([]ColumnView, len(columns))
+	index := make(map[string]int, len(columns))
+	firstOfStatus := make(map[models.TaskStatus]int)
+	for i, column := range columns {
+		views[i] = ColumnView{BoardColumn: column, Tasks: []models.Task{}}
+		index[column.ID] = i
+		if _, ok := firstOfStatus[column.Status]; !ok {
+			firstOfStatus[column.Status] = i
+		}
+	}
+
+	cardOf := make(map[string]models.BoardCard, len(cards))
+	for _, card := range cards {
+		cardOf[card.TaskID] = card
+	}
+
+	positioned := make(map[string]bool)
+	for _, t := range tasks {
+		i, ok := firstOfStatus[t.Status]
+		if !ok {
+			continue
+		}
+		if card, found := cardOf[t.ID]; found {
+			if j, exists := index[card.ColumnID]; exists && columns[j].Status == t.Status {
+				i = j
+				positioned[t.ID] = true
+			}
+		}
+		views[i].Tasks = append(views[i].Tasks, t)
+	}
+
+	for i := range views {
+		column := views[i].Tasks
+		sort.SliceStable(column, func(a, b int) bool {
+			ta, tb := column[a], column[b]
+			if positioned[ta.ID] != positioned[tb.ID] {
+				return positioned[ta.ID]
+			}
+			if positioned[ta.ID] && cardOf[ta.ID].Position != cardOf[tb.ID].Position {
+				return cardOf[ta.ID].Position < cardOf[tb.ID].Position
+			}
+			if !ta.ScheduleTime.Equal(tb.ScheduleTime) {
+				return ta.ScheduleTime.Before(tb.ScheduleTime)
+			}
+			return ta.ID < tb.ID
+		})
+		views[i].Count = len(column)
+		views[i].OverLimit = views[i].WIPLimit > 0 && views[i].Count > views[i].WIPLimit
+	}
+	return views
+}
+
+// MoveTask moves a task to a position in a column. Moving to a column of another status changes
+// the task's status through the task status machine, so invalid transitions and tasks blocked
+// by predecessors are rejected; a column at its WIP limit accepts no further tasks.
+func (s *BoardService) MoveTask(boardID string, req MoveRequest, realmID, movedBy string) (*BoardView, error) {
+	board, err := s.repo.GetByID(realmID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	target := -1
+	for i, column := range board.Columns {
+		if column.ID == req.ColumnID {
+			target = i
+			break
+		}
+	}
+	if target < 0 {
+		return nil, fmt.Errorf("column %s: %w", req.ColumnID, gorm.ErrRecordNotFound)
+	}
+	column := board.Columns[target]
+
+	t, err := s.taskService.GetTask(req.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if t.RealmID != realmID {
+		return nil, fmt.Errorf("task %s: %w", req.TaskID, gorm.ErrRecordNotFound)
+	}
+
+	// The WIP check, the status change and the new order are applied under the board's lock,
+	// so concurrent moves cannot overfill a column
+	err = s.repo.MoveLocked(board.ID, func(repo *BoardRepository, tx *gorm.DB) error {
+		locked := &BoardService{repo: repo, taskService: s.taskService.WithTx(tx)}
+		if column.WIPLimit > 0 {
+			count, err := repo.CountColumnTasks(board, column.ID, t.ID)
+			if err != nil {
+				return fmt.Errorf("failed to count column tasks: %w", err)
+			}
+			if count >= int64(column.WIPLimit) {
+				return fmt.Errorf("%w: %q allows %d tasks", ErrWIPLimitReached, column.Name, column.WIPLimit)
+			}
+		}
+
+		current, err := locked.view(board)
+		if err != nil {
+			return err
+		}
+		var order []string
+		for _, other := range current.Columns[target].Tasks {
+			if other.ID != t.ID {
+				order = append(order, other.ID)
+			}
+		}
+
+		if t.Status != column.Status {
+			if _, err := locked.taskService.UpdateTask(t.ID, task.UpdateTaskRequest{Status: column.Status}, movedBy); err != nil {
+				return err
+			}
+		}
+
+		position := len(order)
+		if req.Position != nil && *req.Position >= 0 && *req.Position < position {
+			position = *req.Position
+		}
+		order = append(order[:position], append([]string{t.ID}, order[position:]...)...)
+		if err := repo.SaveColumnOrder(board.ID, column.ID, order); err != nil {
+			return fmt.Errorf("failed to save column order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.view(board)
+}
@@ -0,0 +1,124 @@
+package board
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/task"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func TestArrange(t *testing.T) {
+	columns := []models.BoardColumn{
+		{ID: "todo", Name: "To Do", Status: models.TaskStatusPending},
+		{ID: "doing", Name: "Doing", Status: models.TaskStatusRunning, WIPLimit: 1},
+		{ID: "review", Name: "Review", Status: models.TaskStatusRunning},
+	}
+	at := func(hour int) time.Time { return time.Date(2025, 3, 10, hour, 0, 0, 0, time.UTC) }
+	tasks := []models.Task{
+		{ID: "a", Status: models.TaskStatusPending, ScheduleTime: at(9)},
+		{ID: "b", Status: models.TaskStatusPending, ScheduleTime: at(8)},
+		{ID: "c", Status: models.TaskStatusPending, ScheduleTime: at(10)},
+		{ID: "d", Status: models.TaskStatusRunning, ScheduleTime: at(9)},
+		{ID: "e", Status: models.TaskStatusRunning, ScheduleTime: at(9)},
+		{ID: "f", Status: models.TaskStatusRunning, ScheduleTime: at(9)},
+		{ID: "g", Status: models.TaskStatusFailed, ScheduleTime: at(9)}, // no column for failed
+	}
+	cards := []models.BoardCard{
+		{TaskID: "c", ColumnID: "todo", Position: 0},
+		{TaskID: "e", ColumnID: "review", Position: 0},
+		// Stale card: the task was completed elsewhere and went back to pending
+		{TaskID: "a", ColumnID: "review", Position: 1},
+	}
+
+	views := arrange(columns, tasks, cards)
+	require.Len(t, views, 3)
+
+	ids := func(view ColumnView) []string {
+		out := []string{}
+		for _, task := range view.Tasks {
+			out = append(out, task.ID)
+		}
+		return out
+	}
+	// Ordered cards first, the rest by schedule time
+	assert.Equal(t, []string{"c", "b", "a"}, ids(views[0]))
+	assert.Equal(t, []string{"d", "f"}, ids(views[1]))
+	assert.True(t, views[1].OverLimit)
+	assert.Equal(t, []string{"e"}, ids(views[2]))
+	assert.False(t, views[2].OverLimit)
+}
+
+func TestBuildColumns(t *testing.T) {
+	existing := []models.BoardColumn{{ID: "todo"}, {ID: "done"}}
+
+	columns, removed, err := buildColumns("board", []ColumnRequest{
+		{ID: "todo", Name: " Backlog ", Status: models.TaskStatusPending},
+		{Name: "Paused", Status: models.TaskStatusPaused, WIPLimit: 3},
+	}, existing)
+	require.NoError(t, err)
+	require.Len(t, columns, 2)
+	assert.Equal(t, "Backlog", columns[0].Name)
+	assert.Equal(t, 1, columns[1].Position)
+	assert.NotEmpty(t, columns[1].ID)
+	assert.Equal(t, []string{"done"}, removed)
+
+	_, _, err = buildColumns("board", []ColumnRequest{{Name: "Archive", Status: "archived"}}, nil)
+	assert.ErrorContains(t, err, `invalid status "archived"`)
+	_, _, err = buildColumns("board", []ColumnRequest{{ID: "other", Name: "To Do", Status: models.TaskStatusPending}}, existing)
+	assert.ErrorContains(t, err, "does not belong to the board")
+	_, _, err = buildColumns("board", []ColumnRequest{
+		{ID: "todo", Name: "To Do", Status: models.TaskStatusPending},
+		{ID: "todo", Name: "Again", Status: models.TaskStatusPending},
+	}, existing)
+	assert.Error(t, err)
+}
+
+func TestMoveTask_WIPLimit(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Task{}, &models.TaskDependency{}, &models.TaskWorkLog{},
+		&models.Board{}, &models.BoardColumn{}, &models.BoardCard{})
+	addTask := func(id string, status models.TaskStatus) {
+		require.NoError(t, db.Create(&models.Task{
+			ID: id, RealmID: "realm", Name: id, Status: status,
+			ScheduleTime: time.Now(), Deadline: time.Now().Add(time.Hour),
+		}).Error)
+	}
+	// More running tasks than a board loads per status, so the limit needs a real count
+	for i := 0; i < maxTasksPerStatus+1; i++ {
+		addTask(fmt.Sprintf("running-%03d", i), models.TaskStatusRunning)
+	}
+	addTask("todo-1", models.TaskStatusPending)
+	addTask("todo-2", models.TaskStatusPending)
+
+	s := NewBoardService(&BoardRepository{db: db}, task.NewTaskService(task.NewTaskRepositoryWithDB(db), nil))
+	board, err := s.CreateBoard(BoardRequest{Name: "Sprint", Columns: []ColumnRequest{
+		{Name: "To Do", Status: models.TaskStatusPending},
+		{Name: "Doing", Status: models.TaskStatusRunning, WIPLimit: maxTasksPerStatus + 1},
+		{Name: "Review", Status: models.TaskStatusRunning, WIPLimit: 1},
+	}}, "realm", "alice")
+	require.NoError(t, err)
+	doing, review := board.Columns[1].ID, board.Columns[2].ID
+
+	_, err = s.MoveTask(board.ID, MoveRequest{TaskID: "todo-1", ColumnID: doing}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrWIPLimitReached)
+
+	// Moving a running task to review frees a place in doing
+	_, err = s.MoveTask(board.ID, MoveRequest{TaskID: "running-000", ColumnID: review}, "realm", "alice")
+	require.NoError(t, err)
+	_, err = s.MoveTask(board.ID, MoveRequest{TaskID: "running-001", ColumnID: review}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrWIPLimitReached)
+	_, err = s.MoveTask(board.ID, MoveRequest{TaskID: "todo-1", ColumnID: doing}, "realm", "alice")
+	require.NoError(t, err)
+	_, err = s.MoveTask(board.ID, MoveRequest{TaskID: "todo-2", ColumnID: doing}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrWIPLimitReached)
+
+	var moved, rejected models.Task
+	require.NoError(t, db.First(&moved, "id = ?", "todo-1").Error)
+	assert.Equal(t, models.TaskStatusRunning, moved.Status)
+	require.NoError(t, db.First(&rejected, "id = ?", "todo-2").Error)
+	assert.Equal(t, models.TaskStatusPending, rejected.Status)
+}
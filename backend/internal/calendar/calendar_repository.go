@@ -0,0 +1,88 @@
+package calendar
+
+import (
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
+	"gorm.io/gorm"
+)
+
+// CalendarRepository provides data access for calendar feed tokens and the feed's tasks and reminders
+type CalendarRepository struct {
+	db *gorm.DB
+}
+
+func NewCalendarRepository() *CalendarRepository {
+	return &CalendarRepository{db: database.GetDB()}
+}
+
+func (r *CalendarRepository) CreateToken(token *models.CalendarFeedToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetTokenByHash returns the feed token with the given hash
+func (r *CalendarRepository) GetTokenByHash(tokenHash string) (*models.CalendarFeedToken, error) {
+	var token models.CalendarFeedToken
+	if err := r.db.First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListTokens returns a user's feed tokens, newest first
+func (r *CalendarRepository) ListTokens(realmID, userID string) ([]models.CalendarFeedToken, error) {
+	var tokens []models.CalendarFeedToken
+	err := r.db.Where("realm_id = ? AND user_id = ?", realmID, userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteToken revokes one of a user's feed tokens
+func (r *CalendarRepository) DeleteToken(realmID, userID, id string) error {
+	result := r.db.Where("realm_id = ? AND user_id = ?", realmID, userID).
+		Delete(&models.CalendarFeedToken{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *CalendarRepository) TouchToken(id string, usedAt time.Time) error {
+	return r.db.Model(&models.CalendarFeedToken{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
+
+// FindTasks returns a user's tasks scheduled or due within a time range
+func (r *CalendarRepository) FindTasks(realmID, userID string, from, to time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.Where("realm_id = ? AND created_by = ?", realmID, userID).
+		Where("schedule_time <= ? AND deadline >= ?", to, from).
+		Order("schedule_time ASC").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// FindReminders returns a user's reminders within a time range
+func (r *CalendarRepository) FindReminders(realmID, userID string, from, to time.Time, limit int) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Where("realm_id = ? AND created_by = ?", realmID, userID).
+		Where("remind_time BETWEEN ? AND ?", from, to).
+		Order("remind_time ASC").
+		Limit(limit).
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// ExternalUIDExists reports whether the user already imported a task from the calendar event with this UID
+func (r *CalendarRepository) ExternalUIDExists(realmID, userID, uid string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Task{}).
+		Where("realm_id = ? AND created_by = ? AND external_uid = ?", realmID, userID, uid).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,142 @@
+package calendar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"gorm.io/gorm"
+)
+
+// maxImportSize caps the size of an uploaded .ics file
+const maxImportSize = 2 << 20
+
+// RegisterRoutes registers HTTP endpoints for calendar feeds and imports
+func RegisterRoutes(router *gin.Engine, service *CalendarService, middleware *auth.AuthMiddleware) {
+	// GET /api/v1/calendar/:token.ics - Public feed, the token in the URL is the credential
+	router.GET("/api/v1/calendar/:token", func(c *gin.Context) {
+		token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrFeedNotFound.Error()})
+			return
+		}
+
+		// Render into a buffer so that a failure halfway does not produce a truncated calendar
+		var buf bytes.Buffer
+		if err := service.WriteFeed(&buf, token); err != nil {
+			if errors.Is(err, ErrFeedNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "private, max-age=300")
+		c.Header("Content-Disposition", `inline; filename="lazy-rabbit-secretary.ics"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+	})
+
+	group := router.Group("/api/v1/calendar")
+	group.Use(middleware.Authenticate())
+
+	// GET /api/v1/calendar/tokens - List the current user's feed tokens
+	group.GET("/tokens", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		tokens, err := service.ListFeedTokens(realmID, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": tokens, "total": len(tokens)})
+	})
+
+	// POST /api/v1/calendar/tokens - Create a feed token; the token is only returned once
+	group.POST("/tokens", func(c *gin.Context) {
+		var req CreateFeedTokenRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		created, err := service.CreateFeedToken(realmID, userID, req)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"token":    created,
+			"feed_url": "/api/v1/calendar/" + created.Token + ".ics",
+		})
+	})
+
+	// DELETE /api/v1/calendar/tokens/:id - Revoke a feed token
+	group.DELETE("/tokens/:id", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		if err := service.RevokeFeedToken(realmID, userID, c.Param("id")); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed token not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// POST /api/v1/calendar/import - Import the events of an .ics file as tasks.
+	// Accepts a multipart "file" field or a raw text/calendar body; ?dry_run=true only reports,
+	// ?timezone=Europe/Berlin sets the zone of floating times.
+	group.POST("/import", func(c *gin.Context) {
+		opts := ImportOptions{DryRun: c.Query("dry_run") == "true", Location: time.UTC}
+		if tz := c.Query("timezone"); tz != "" {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid timezone: " + tz})
+				return
+			}
+			opts.Location = loc
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+		var body io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			fileHeader, err := c.FormFile("file")
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+				return
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		result, err := service.ImportICS(body, realmID, userID, opts)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := http.StatusCreated
+		if opts.DryRun {
+			status = http.StatusOK
+		}
+		c.JSON(status, result)
+	})
+}
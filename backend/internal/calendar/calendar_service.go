@@ -0,0 +1,484 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/task"
+	"gorm.io/gorm"
+)
+
+const (
+	// The feed covers recent history and the upcoming year, which is what calendar apps display
+	feedPastDays   = 30
+	feedFutureDays = 365
+	feedMaxItems   = 1000
+
+	feedTokenBytes = 32
+	prodID         = "-//Lazy Rabbit Secretary//Calendar Feed//EN"
+	uidDomain      = "lazy-rabbit-secretary"
+
+	// defaultImportMinutes is used for events without DTEND or DURATION
+	defaultImportMinutes = 30
+	importTag            = "calendar-import"
+)
+
+// ErrFeedNotFound is returned for unknown and revoked feed tokens alike
+var ErrFeedNotFound = errors.New("calendar feed not found")
+
+// CalendarService renders iCalendar feeds and imports iCalendar events as tasks
+type CalendarService struct {
+	repo        *CalendarRepository
+	taskService *task.TaskService
+}
+
+func NewCalendarService(repo *CalendarRepository, taskService *task.TaskService) *CalendarService {
+	return &CalendarService{repo: repo, taskService: taskService}
+}
+
+// CreateFeedTokenRequest names a feed token, e.g. after the device or app it is used on
+type CreateFeedTokenRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateFeedTokenResponse carries the feed token, which is only ever returned here
+type CreateFeedTokenResponse struct {
+	models.CalendarFeedToken
+	Token string `json:"token"`
+}
+
+// CreateFeedToken creates a token for a user's feed at /api/v1/calendar/<token>.ics
+func (s *CalendarService) CreateFeedToken(realmID, userID string, req CreateFeedTokenRequest) (*CreateFeedTokenResponse, error) {
+	if strings.TrimSpace(realmID) == "" || strings.TrimSpace(userID) == "" {
+		return nil, errors.New("realm_id and user_id are required")
+	}
+
+	raw := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate feed token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	feedToken := models.CalendarFeedToken{
+		ID:        uuid.NewString(),
+		RealmID:   realmID,
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: hashFeedToken(token),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateToken(&feedToken); err != nil {
+		return nil, fmt.Errorf("failed to create feed token: %w", err)
+	}
+	return &CreateFeedTokenResponse{CalendarFeedToken: feedToken, Token: token}, nil
+}
+
+// ListFeedTokens lists a user's feed tokens
+func (s *CalendarService) ListFeedTokens(realmID, userID string) ([]models.CalendarFeedToken, error) {
+	return s.repo.ListTokens(realmID, userID)
+}
+
+// RevokeFeedToken revokes one of a user's feed tokens; calendar apps using it stop receiving updates
+func (s *CalendarService) RevokeFeedToken(realmID, userID, id string) error {
+	return s.repo.DeleteToken(realmID, userID, id)
+}
+
+// WriteFeed writes the iCalendar feed of the token's user: tasks as VEVENTs ending at their
+// deadline, and reminders as VTODOs with a VALARM at the remind time
+func (s *CalendarService) WriteFeed(w io.Writer, token string) error {
+	if token == "" {
+		return ErrFeedNotFound
+	}
+	feedToken, err := s.repo.GetTokenByHash(hashFeedToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrFeedNotFound
+		}
+		return fmt.Errorf("failed to get feed token: %w", err)
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -feedPastDays)
+	to := now.AddDate(0, 0, feedFutureDays)
+
+	tasks, err := s.repo.FindTasks(feedToken.RealmID, feedToken.UserID, from, to, feedMaxItems)
+	if err != nil {
+		return fmt.Errorf("failed to get tasks: %w", err)
+	}
+	reminders, err := s.repo.FindReminders(feedToken.RealmID, feedToken.UserID, from, to, feedMaxItems)
+	if err != nil {
+		return fmt.Errorf("failed to get reminders: %w", err)
+	}
+
+	// Feed reads are frequent and best effort to track, so a failed update does not fail the feed
+	_ = s.repo.TouchToken(feedToken.ID, now)
+
+	iw := newICSWriter(w)
+	iw.Line("BEGIN", "VCALENDAR")
+	iw.Line("VERSION", "2.0")
+	iw.Line("PRODID", prodID)
+	iw.Line("CALSCALE", "GREGORIAN")
+	iw.Line("METHOD", "PUBLISH")
+	iw.Text("X-WR-CALNAME", "Lazy Rabbit Secretary")
+	for i := range tasks {
+		// Unscheduled tasks have no start to put in a calendar yet
+		if tasks[i].IsScheduled() {
+			writeTaskEvent(iw, &tasks[i], now)
+		}
+	}
+	for i := range reminders {
+		writeReminderTodo(iw, &reminders[i], now)
+	}
+	iw.Line("END", "VCALENDAR")
+	return iw.Flush()
+}
+
+func writeTaskEvent(iw *icsWriter, t *models.Task, now time.Time) {
+	end := t.Deadline
+	if !end.After(t.ScheduleTime) {
+		end = t.ScheduleTime.Add(time.Duration(t.Minutes) * time.Minute)
+	}
+
+	iw.Line("BEGIN", "VEVENT")
+	iw.Text("UID", taskUID(t))
+	iw.Time("DTSTAMP", now)
+	iw.Time("DTSTART", t.ScheduleTime)
+	iw.Time("DTEND", end)
+	iw.Text("SUMMARY", t.Name)
+
+	description := t.Description
+	if description != "" {
+		description += "\n\n"
+	}
+	description += fmt.Sprintf("Status: %s\nEstimated: %d minutes\nDeadline: %s\nPriority: %d/5",
+		t.Status, t.Minutes, t.Deadline.Format(time.RFC3339), t.Priority)
+	iw.Text("DESCRIPTION", description)
+
+	if categories := splitTags(t.Tags); len(categories) > 0 {
+		escaped := make([]string, len(categories))
+		for i, category := range categories {
+			escaped[i] = escapeICSText(category)
+		}
+		iw.Line("CATEGORIES", strings.Join(escaped, ","))
+	}
+	if t.Status == models.TaskStatusFailed {
+		iw.Line("STATUS", "CANCELLED")
+	} else {
+		iw.Line("STATUS", "CONFIRMED")
+	}
+	if !t.UpdatedAt.IsZero() {
+		iw.Time("LAST-MODIFIED", t.UpdatedAt)
+	}
+	iw.Line("END", "VEVENT")
+}
+
+func writeReminderTodo(iw *icsWriter, r *models.Reminder, now time.Time) {
+	iw.Line("BEGIN", "VTODO")
+	iw.Text("UID", fmt.Sprintf("reminder-%s@%s", r.ID, uidDomain))
+	iw.Time("DTSTAMP", now)
+	iw.Time("DTSTART", r.RemindTime)
+	iw.Time("DUE", r.RemindTime)
+	iw.Text("SUMMARY", r.Name)
+	iw.Text("DESCRIPTION", r.Content)
+	switch r.Status {
+	case "completed":
+		iw.Line("STATUS", "COMPLETED")
+	case "cancelled":
+		iw.Line("STATUS", "CANCELLED")
+	case "active":
+		iw.Line("STATUS", "IN-PROCESS")
+	default:
+		iw.Line("STATUS", "NEEDS-ACTION")
+	}
+	if !r.UpdatedAt.IsZero() {
+		iw.Time("LAST-MODIFIED", r.UpdatedAt)
+	}
+
+	iw.Line("BEGIN", "VALARM")
+	iw.Line("ACTION", "DISPLAY")
+	iw.Line("TRIGGER;VALUE=DATE-TIME", r.RemindTime.UTC().Format(icsDateTimeUTC))
+	iw.Text("DESCRIPTION", r.Name)
+	iw.Line("END", "VALARM")
+	iw.Line("END", "VTODO")
+}
+
+// taskUID keeps the UID of imported events so that a round trip does not duplicate them
+func taskUID(t *models.Task) string {
+	if t.ExternalUID != "" {
+		return t.ExternalUID
+	}
+	return fmt.Sprintf("task-%s@%s", t.ID, uidDomain)
+}
+
+// ImportOptions controls ImportICS
+type ImportOptions struct {
+	DryRun   bool           // validate and report without creating tasks
+	Location *time.Location // zone for floating times, defaults to UTC
+}
+
+// ImportedEvent describes an event that was (or in a dry run would be) imported as a task
+type ImportedEvent struct {
+	UID           string    `json:"uid"`
+	Summary       string    `json:"summary"`
+	TaskID        string    `json:"task_id,omitempty"`
+	ScheduleTime  time.Time `json:"schedule_time"`
+	Deadline      time.Time `json:"deadline"`
+	RepeatPattern string    `json:"repeat_pattern,omitempty"`
+}
+
+// SkippedEvent describes an event that was not imported
+type SkippedEvent struct {
+	UID     string `json:"uid"`
+	Summary string `json:"summary"`
+	Reason  string `json:"reason"`
+}
+
+// ImportResult summarizes an ICS import
+type ImportResult struct {
+	DryRun   bool            `json:"dry_run"`
+	Imported []ImportedEvent `json:"imported"`
+	Skipped  []SkippedEvent  `json:"skipped"`
+}
+
+// ImportICS turns the VEVENTs of an iCalendar file into tasks owned by the user. Events the user
+// imported before (same UID), cancelled events and recurrence overrides are skipped; RRULE and
+// EXDATE become the task's repeat settings.
+func (s *CalendarService) ImportICS(r io.Reader, realmID, userID string, opts ImportOptions) (*ImportResult, error) {
+	if strings.TrimSpace(realmID) == "" || strings.TrimSpace(userID) == "" {
+		return nil, errors.New("realm_id and user_id are required")
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	roots, err := parseICS(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid iCalendar data: %w", err)
+	}
+
+	var events []*icsComponent
+	for _, root := range roots {
+		if root.Name != "VCALENDAR" {
+			continue
+		}
+		for _, component := range root.Components {
+			if component.Name == "VEVENT" {
+				events = append(events, component)
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no VEVENT found in iCalendar data")
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, Imported: []ImportedEvent{}, Skipped: []SkippedEvent{}}
+	seen := make(map[string]bool)
+	for _, event := range events {
+		uid := propertyText(event, "UID")
+		summary := propertyText(event, "SUMMARY")
+		skip := func(reason string) {
+			result.Skipped = append(result.Skipped, SkippedEvent{UID: uid, Summary: summary, Reason: reason})
+		}
+
+		if _, ok := event.Get("RECURRENCE-ID"); ok {
+			skip("recurrence overrides are not supported")
+			continue
+		}
+		if strings.EqualFold(propertyText(event, "STATUS"), "CANCELLED") {
+			skip("event is cancelled")
+			continue
+		}
+		if uid != "" {
+			if seen[uid] {
+				skip("duplicate UID in file")
+				continue
+			}
+			seen[uid] = true
+
+			exists, err := s.repo.ExternalUIDExists(realmID, userID, uid)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check imported events: %w", err)
+			}
+			if exists {
+				skip("already imported")
+				continue
+			}
+		}
+
+		req, err := eventToTaskRequest(event, opts.Location)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+
+		imported := ImportedEvent{
+			UID:           uid,
+			Summary:       req.Name,
+			ScheduleTime:  req.ScheduleTime,
+			Deadline:      req.Deadline,
+			RepeatPattern: req.RepeatPattern,
+		}
+		if !opts.DryRun {
+			created, err := s.taskService.CreateFromInput(*req, realmID, userID)
+			if err != nil {
+				skip(err.Error())
+				continue
+			}
+			imported.TaskID = created.ID
+		}
+		result.Imported = append(result.Imported, imported)
+	}
+
+	return result, nil
+}
+
+// eventToTaskRequest maps a VEVENT onto a task: DTSTART is the schedule time, DTEND (or
+// DTSTART + DURATION) the deadline, and the event length the estimated minutes
+func eventToTaskRequest(event *icsComponent, loc *time.Location) (*task.CreateTaskRequest, error) {
+	dtstart, ok := event.Get("DTSTART")
+	if !ok {
+		return nil, errors.New("missing DTSTART")
+	}
+	start, allDay, err := dtstart.Time(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var end time.Time
+	if dtend, ok := event.Get("DTEND"); ok {
+		if end, _, err = dtend.Time(loc); err != nil {
+			return nil, err
+		}
+	} else if duration, ok := event.Get("DURATION"); ok {
+		d, err := parseICSDuration(duration.Value)
+		if err != nil {
+			return nil, err
+		}
+		end = start.Add(d)
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	if end.Before(start) {
+		return nil, errors.New("DTEND is before DTSTART")
+	}
+
+	minutes := int(end.Sub(start).Round(time.Minute) / time.Minute)
+	if minutes <= 0 {
+		minutes = defaultImportMinutes
+		end = start.Add(defaultImportMinutes * time.Minute)
+	}
+
+	name := propertyText(event, "SUMMARY")
+	if name == "" {
+		name = "Untitled event"
+	}
+	description := propertyText(event, "DESCRIPTION")
+	if location := propertyText(event, "LOCATION"); location != "" {
+		if description != "" {
+			description += "\n\n"
+		}
+		description += "Location: " + location
+	}
+
+	tags := []string{importTag}
+	for _, prop := range event.GetAll("CATEGORIES") {
+		for _, category := range splitICSList(prop.Value) {
+			if category = strings.TrimSpace(category); category != "" {
+				tags = append(tags, category)
+			}
+		}
+	}
+
+	req := &task.CreateTaskRequest{
+		Name:         name,
+		Description:  description,
+		ScheduleTime: start,
+		Minutes:      minutes,
+		Deadline:     end,
+		Tags:         strings.Join(tags, ","),
+		ExternalUID:  propertyText(event, "UID"),
+	}
+
+	if rrule, ok := event.Get("RRULE"); ok {
+		if _, err := models.ParseRRule(rrule.Value); err != nil {
+			return nil, err
+		}
+		req.IsRepeating = true
+		req.RepeatPattern = rrule.Value
+		req.RepeatInterval = 1
+		if tzid := dtstart.Params["TZID"]; tzid != "" {
+			if _, err := time.LoadLocation(tzid); err == nil {
+				req.RepeatTimezone = tzid
+			}
+		}
+
+		var exdates []string
+		for _, prop := range event.GetAll("EXDATE") {
+			for _, value := range strings.Split(prop.Value, ",") {
+				exprop := icsProperty{Name: prop.Name, Params: prop.Params, Value: value}
+				t, dateOnly, err := exprop.Time(loc)
+				if err != nil {
+					return nil, err
+				}
+				if dateOnly {
+					exdates = append(exdates, t.Format("2006-01-02"))
+				} else {
+					exdates = append(exdates, t.Format(time.RFC3339))
+				}
+			}
+		}
+		req.RepeatExDates = strings.Join(exdates, ",")
+	}
+
+	return req, nil
+}
+
+// propertyText returns the unescaped value of a TEXT property, or "" when it is absent
+func propertyText(component *icsComponent, name string) string {
+	prop, ok := component.Get(name)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(unescapeICSText(prop.Value))
+}
+
+// splitICSList splits a comma-separated TEXT list, honouring escaped commas
+func splitICSList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == ',' {
+			items = append(items, unescapeICSText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, unescapeICSText(value[start:]))
+}
+
+func splitTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,280 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsDateTimeUTC = "20060102T150405Z"
+	icsDateTime    = "20060102T150405"
+	icsDate        = "20060102"
+
+	// icsLineLimit is the maximum line length in octets before folding (RFC 5545 section 3.1)
+	icsLineLimit = 75
+)
+
+var icsDurationRegex = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// icsWriter writes iCalendar content lines with CRLF endings and line folding
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newICSWriter(w io.Writer) *icsWriter {
+	return &icsWriter{w: bufio.NewWriter(w)}
+}
+
+// Line writes a property whose value is already encoded
+func (iw *icsWriter) Line(name, value string) {
+	if iw.err != nil {
+		return
+	}
+	line := name + ":" + value
+	limit := icsLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, iw.err = iw.w.WriteString(line[:cut] + "\r\n "); iw.err != nil {
+			return
+		}
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = icsLineLimit - 1
+	}
+	_, iw.err = iw.w.WriteString(line + "\r\n")
+}
+
+// Text writes a TEXT property, escaping its value
+func (iw *icsWriter) Text(name, value string) {
+	iw.Line(name, escapeICSText(value))
+}
+
+// Time writes a DATE-TIME property in UTC
+func (iw *icsWriter) Time(name string, t time.Time) {
+	iw.Line(name, t.UTC().Format(icsDateTimeUTC))
+}
+
+func (iw *icsWriter) Flush() error {
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+func escapeICSText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return replacer.Replace(value)
+}
+
+func unescapeICSText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// icsProperty is a parsed content line such as DTSTART;TZID=Europe/Berlin:20250101T090000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icsComponent is a parsed component such as VEVENT with its direct properties and sub-components
+type icsComponent struct {
+	Name       string
+	Properties []icsProperty
+	Components []*icsComponent
+}
+
+// Get returns the first property with the given name
+func (c *icsComponent) Get(name string) (icsProperty, bool) {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+// GetAll returns every property with the given name
+func (c *icsComponent) GetAll(name string) []icsProperty {
+	var props []icsProperty
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// parseICS parses iCalendar data into its top-level components, usually a single VCALENDAR
+func parseICS(r io.Reader) ([]*icsComponent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*icsComponent
+	var stack []*icsComponent
+	for n, line := range lines {
+		prop, err := parseICSLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			component := &icsComponent{Name: strings.ToUpper(prop.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			} else {
+				roots = append(roots, component)
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", n+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property %s outside of a component", n+1, prop.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return roots, nil
+}
+
+// unfoldICSLines joins folded lines; a line starting with a space or tab continues the previous one
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseICSLine(line string) (icsProperty, error) {
+	// The value starts at the first colon outside of a quoted parameter value
+	quoted := false
+	colon := -1
+	for i, ch := range line {
+		if ch == '"' {
+			quoted = !quoted
+		} else if ch == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icsProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	prop := icsProperty{Name: strings.ToUpper(parts[0]), Params: make(map[string]string), Value: value}
+	for _, param := range parts[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		prop.Params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	if prop.Name == "" {
+		return icsProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+	return prop, nil
+}
+
+// Time parses a DATE or DATE-TIME property. Floating times and unknown TZIDs use loc.
+// The returned bool is true for DATE values, i.e. all-day events.
+func (p icsProperty) Time(loc *time.Location) (time.Time, bool, error) {
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+
+	value := strings.TrimSpace(p.Value)
+	if p.Params["VALUE"] == "DATE" || len(value) == len(icsDate) {
+		t, err := time.ParseInLocation(icsDate, value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s date %q", p.Name, value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTimeUTC, value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s date-time %q", p.Name, value)
+		}
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(icsDateTime, value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s date-time %q", p.Name, value)
+	}
+	return t, false, nil
+}
+
+// parseICSDuration parses a DURATION value such as PT1H30M or P1D
+func parseICSDuration(value string) (time.Duration, error) {
+	m := icsDurationRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,85 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup-1@example.com\r\n" +
+	"DTSTART;TZID=America/New_York:20251103T090000\r\n" +
+	"DURATION:PT15M\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR\r\n" +
+	"EXDATE;TZID=America/New_York:20251105T090000,20251107T090000\r\n" +
+	"SUMMARY:Team standup\\, daily\r\n" +
+	"DESCRIPTION:Line one\\nLine two that is long enough to be folded by the cale\r\n" +
+	" ndar app\r\n" +
+	"CATEGORIES:work,meeting\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT5M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20251225\r\n" +
+	"SUMMARY:Holiday\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestEventToTaskRequest(t *testing.T) {
+	roots, err := parseICS(strings.NewReader(sampleICS))
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	require.Len(t, roots[0].Components, 2)
+
+	t.Run("Recurring event with timezone and exdates", func(t *testing.T) {
+		req, err := eventToTaskRequest(roots[0].Components[0], time.UTC)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Team standup, daily", req.Name)
+		assert.Equal(t, "Line one\nLine two that is long enough to be folded by the calendar app", req.Description)
+		assert.Equal(t, "calendar-import,work,meeting", req.Tags)
+		assert.Equal(t, "standup-1@example.com", req.ExternalUID)
+		assert.True(t, req.ScheduleTime.Equal(time.Date(2025, 11, 3, 14, 0, 0, 0, time.UTC)))
+		assert.Equal(t, 15, req.Minutes)
+		assert.True(t, req.Deadline.Equal(req.ScheduleTime.Add(15*time.Minute)))
+
+		assert.True(t, req.IsRepeating)
+		assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,WE,FR", req.RepeatPattern)
+		assert.Equal(t, "America/New_York", req.RepeatTimezone)
+		assert.Equal(t, "2025-11-05T09:00:00-05:00,2025-11-07T09:00:00-05:00", req.RepeatExDates)
+	})
+
+	t.Run("All-day event without end", func(t *testing.T) {
+		req, err := eventToTaskRequest(roots[0].Components[1], time.UTC)
+		require.NoError(t, err)
+
+		assert.Equal(t, time.Date(2025, 12, 25, 0, 0, 0, 0, time.UTC), req.ScheduleTime)
+		assert.Equal(t, 24*60, req.Minutes)
+		assert.False(t, req.IsRepeating)
+	})
+}
+
+func TestICSWriter_FoldsAndEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	iw := newICSWriter(&buf)
+	iw.Text("SUMMARY", strings.Repeat("日本語; ", 20))
+	require.NoError(t, iw.Flush())
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), icsLineLimit)
+	}
+
+	roots, err := parseICS(strings.NewReader("BEGIN:VCALENDAR\r\n" + buf.String() + "END:VCALENDAR\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("日本語; ", 20), unescapeICSText(roots[0].Properties[0].Value))
+}
@@ -19,6 +19,11 @@ type BlogWriteHandler struct {
 	jobManager *JobManager
 }
 
+// withJobManager returns the handler bound to jm
+func (h *BlogWriteHandler) withJobManager(jm *JobManager) JobHandler {
+	return &BlogWriteHandler{jobManager: jm}
+}
+
 // PromptConfig represents the prompt configuration
 type PromptConfig struct {
 	// Add fields as needed for prompt configuration
@@ -16,6 +16,11 @@ type CalendarGenerateHandler struct {
 	jobManager *JobManager
 }
 
+// withJobManager returns the handler bound to jm
+func (h *CalendarGenerateHandler) withJobManager(jm *JobManager) JobHandler {
+	return &CalendarGenerateHandler{jobManager: jm}
+}
+
 // Execute generates daily calendar content
 func (h *CalendarGenerateHandler) Execute(params string) error {
 	h.jobManager.logger.Info("Generating calendar content...")
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+// DigestQueryService handles database queries for the email digests in job processing
+type DigestQueryService struct {
+	db *gorm.DB
+}
+
+// NewDigestQueryService creates a new digest query service
+func NewDigestQueryService(db *gorm.DB) *DigestQueryService {
+	return &DigestQueryService{
+		db: db,
+	}
+}
+
+// DigestStats holds the completion statistics of a weekly digest
+type DigestStats struct {
+	TasksCompleted     int64
+	TasksFailed        int64
+	ChecklistPlanned   int64
+	ChecklistCompleted int64
+	CompletionRate     int // percent of the planned checklist items that were completed
+	InboxAdded         int64
+}
+
+// FindDigestPreferences retrieves the preferences of users who want one of the given email digests
+func (dqs *DigestQueryService) FindDigestPreferences(modes ...string) ([]*models.NotificationPreference, error) {
+	var prefs []*models.NotificationPreference
+	err := dqs.db.Where("email_digest IN ?", modes).Find(&prefs).Error
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// MarkDigestSent records the day, in the user's time zone, on which a user's email digest was sent
+func (dqs *DigestQueryService) MarkDigestSent(prefID, day string) error {
+	return dqs.db.Model(&models.NotificationPreference{}).
+		Where("id = ?", prefID).
+		UpdateColumn("digest_sent_on", day).Error
+}
+
+// FindChecklistItems retrieves a user's daily checklist items for a day
+func (dqs *DigestQueryService) FindChecklistItems(realmID, userID string, day time.Time) ([]models.DailyChecklistItem, error) {
+	var items []models.DailyChecklistItem
+	err := dqs.db.Where("realm_id = ? AND created_by = ? AND date = ?", realmID, userID, day.Format("2006-01-02")).
+		Order("priority ASC, created_at ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// FindUpcomingTasks retrieves a user's open tasks scheduled in [from, to), like
+// TaskRepository.GetUpcoming does for a realm
+func (dqs *DigestQueryService) FindUpcomingTasks(realmID, userID string, from, to time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	err := dqs.db.Where("realm_id = ? AND created_by = ? AND status IN (?, ?) AND schedule_time >= ? AND schedule_time < ?",
+		realmID, userID, models.TaskStatusPending, models.TaskStatusRunning, from, to).
+		Order("schedule_time ASC").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// FindOverdueTasks retrieves a user's pending tasks past their deadline, like
+// TaskRepository.GetOverdue does for a realm
+func (dqs *DigestQueryService) FindOverdueTasks(realmID, userID string, now time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	err := dqs.db.Where("realm_id = ? AND created_by = ? AND status = ? AND deadline < ?",
+		realmID, userID, models.TaskStatusPending, now).
+		Order("deadline ASC").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// FindDueReminders retrieves a user's reminders that are due before a time or wait to be acknowledged
+func (dqs *DigestQueryService) FindDueReminders(userID string, before time.Time) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := dqs.db.Where("created_by = ? AND ((status = ? AND remind_time < ?) OR status = ?)",
+		userID, "pending", before, "active").
+		Order("remind_time ASC").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// CountPendingInboxItems counts a user's inbox items that have not been processed yet
+func (dqs *DigestQueryService) CountPendingInboxItems(realmID, userID string) (int64, error) {
+	var count int64
+	err := dqs.db.Model(&models.InboxItem{}).
+		Where("realm_id = ? AND created_by = ? AND status = ?", realmID, userID, "pending").
+		Count(&count).Error
+	return count, err
+}
+
+// GetCompletionStats computes a user's completion statistics for the days in [from, to)
+func (dqs *DigestQueryService) GetCompletionStats(realmID, userID string, from, to time.Time) (*DigestStats, error) {
+	stats := &DigestStats{}
+	tasks := dqs.db.Model(&models.Task{}).Where("realm_id = ? AND created_by = ? AND end_time >= ? AND end_time < ?", realmID, userID, from, to)
+	if err := tasks.Session(&gorm.Session{}).Where("status = ?", models.TaskStatusCompleted).Count(&stats.TasksCompleted).Error; err != nil {
+		return nil, err
+	}
+	if err := tasks.Session(&gorm.Session{}).Where("status = ?", models.TaskStatusFailed).Count(&stats.TasksFailed).Error; err != nil {
+		return nil, err
+	}
+
+	checklist := dqs.db.Model(&models.DailyChecklistItem{}).Where("realm_id = ? AND created_by = ? AND date >= ? AND date < ?",
+		realmID, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err := checklist.Session(&gorm.Session{}).Count(&stats.ChecklistPlanned).Error; err != nil {
+		return nil, err
+	}
+	if err := checklist.Session(&gorm.Session{}).Where("status = ?", "completed").Count(&stats.ChecklistCompleted).Error; err != nil {
+		return nil, err
+	}
+	if stats.ChecklistPlanned > 0 {
+		stats.CompletionRate = int(stats.ChecklistCompleted * 100 / stats.ChecklistPlanned)
+	}
+
+	err := dqs.db.Model(&models.InboxItem{}).
+		Where("realm_id = ? AND created_by = ? AND created_at >= ? AND created_at < ?", realmID, userID, from, to).
+		Count(&stats.InboxAdded).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,249 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/email"
+)
+
+const (
+	// defaultDigestSchedule checks every hour for users whose local digest hour has come
+	defaultDigestSchedule = "0 0 * * * *"
+	// defaultDigestHour is the hour of the day, in each user's time zone, the digests are sent at
+	defaultDigestHour = 7
+	// digestTemplate is the email template of the digests
+	digestTemplate = "task_digest"
+	// digestTaskLimit caps the upcoming and overdue tasks listed in a digest
+	digestTaskLimit = 10
+)
+
+// digestItem is a line of a digest, formatted in the user's time zone
+type digestItem struct {
+	Title  string
+	Time   string
+	Detail string
+}
+
+// sendEmailDigests emails each subscribed user a summary of their day: the checklist, upcoming
+// and overdue tasks, due reminders and the pending inbox. On the weekly day the summary also
+// has the completion statistics of the past week, and users who only want the weekly digest
+// get theirs. It fails when any digest could not be sent or recorded.
+func (jm *JobManager) sendEmailDigests() error {
+	if jm.digestQueryService == nil || jm.reminderQueryService == nil {
+		jm.logger.Warn("Digest or reminder query service not initialized, skipping email digests")
+		return nil
+	}
+	if jm.emailSender == nil {
+		jm.logger.Warn("Email sender not available, skipping email digests")
+		return nil
+	}
+	templates := email.GetGlobalTemplateManager()
+	if templates == nil {
+		jm.logger.Warn("Email template manager not initialized, skipping email digests")
+		return nil
+	}
+
+	return jm.sendDueEmailDigests(templates, jm.emailSender.SendEmail, time.Now())
+}
+
+// sendDueEmailDigests sends the digests of the users whose local time is in the digest hour and
+// who did not get one today yet. The job runs every hour, so every time zone gets its digest in
+// the morning; the day of the last digest keeps a rerun within the hour from sending it twice.
+// The other users still get their digests when one fails; the failures are returned together.
+func (jm *JobManager) sendDueEmailDigests(templates *email.EmailTemplateManager, send func(*email.EmailMessage) error, now time.Time) error {
+	prefs, err := jm.digestQueryService.FindDigestPreferences(models.EmailDigestDaily, models.EmailDigestWeekly)
+	if err != nil {
+		return fmt.Errorf("failed to fetch email digest subscribers: %w", err)
+	}
+
+	hour := digestHour()
+	weekday := digestWeekday()
+	sentCount := 0
+	var errs []error
+	for _, pref := range prefs {
+		local := now.In(pref.Location())
+		today := local.Format("2006-01-02")
+		if local.Hour() != hour || pref.DigestSentOn == today {
+			continue
+		}
+		weekly := local.Weekday() == weekday
+		if pref.EmailDigest == models.EmailDigestWeekly && !weekly {
+			continue
+		}
+		sent, err := jm.sendEmailDigest(templates, send, pref, now, weekly)
+		if err != nil {
+			jm.logger.Errorf("Failed to send email digest to user %s: %v", pref.UserID, err)
+			errs = append(errs, fmt.Errorf("user %s: %w", pref.UserID, err))
+			continue
+		}
+		// Days with nothing to report count as done too
+		if err := jm.digestQueryService.MarkDigestSent(pref.ID, today); err != nil {
+			jm.logger.Errorf("Failed to record the email digest of user %s: %v", pref.UserID, err)
+			errs = append(errs, fmt.Errorf("user %s: failed to record the digest: %w", pref.UserID, err))
+		}
+		if sent {
+			sentCount++
+		}
+	}
+
+	jm.logger.Infof("Sent %d email digests", sentCount)
+	if len(errs) > 0 {
+		return fmt.Errorf("%d email digests failed: %w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
+
+// sendEmailDigest sends the digest of one user; it reports false when there was nothing to send
+func (jm *JobManager) sendEmailDigest(templates *email.EmailTemplateManager, send func(*email.EmailMessage) error, pref *models.NotificationPreference, now time.Time, weekly bool) (bool, error) {
+	user, err := jm.reminderQueryService.GetUserByID(pref.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive || user.Email == "" {
+		return false, nil
+	}
+
+	data, empty, err := jm.collectDigestData(user, pref.Location(), now, weekly)
+	if err != nil {
+		return false, err
+	}
+	if empty && !weekly {
+		jm.logger.Debugf("Nothing to report for %s, skipping email digest", user.Username)
+		return false, nil
+	}
+
+	message, err := templates.RenderTemplate(digestTemplate, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to render digest template: %w", err)
+	}
+	if err := send(message); err != nil {
+		return false, fmt.Errorf("failed to send email: %w", err)
+	}
+	jm.logger.Infof("Sent email digest to %s", user.Email)
+	return true, nil
+}
+
+// collectDigestData gathers the template data of a user's digest and reports whether it is empty
+func (jm *JobManager) collectDigestData(user *models.User, loc *time.Location, now time.Time, weekly bool) (map[string]interface{}, bool, error) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	horizon := today.AddDate(0, 0, 2)
+	if weekly {
+		horizon = today.AddDate(0, 0, 7)
+	}
+	qs := jm.digestQueryService
+
+	checklist, err := qs.FindChecklistItems(user.RealmID, user.ID, today)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get checklist items: %w", err)
+	}
+	upcoming, err := qs.FindUpcomingTasks(user.RealmID, user.ID, now, horizon, digestTaskLimit)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get upcoming tasks: %w", err)
+	}
+	overdue, err := qs.FindOverdueTasks(user.RealmID, user.ID, now, digestTaskLimit)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get overdue tasks: %w", err)
+	}
+	reminders, err := qs.FindDueReminders(user.ID, tomorrow)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get due reminders: %w", err)
+	}
+	inboxCount, err := qs.CountPendingInboxItems(user.RealmID, user.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to count inbox items: %w", err)
+	}
+
+	data := map[string]interface{}{
+		"Username":          user.Username,
+		"Date":              local.Format("Monday, January 2, 2006"),
+		"Weekly":            weekly,
+		"ChecklistItems":    formatChecklistItems(checklist, loc),
+		"UpcomingTasks":     formatTasks(upcoming, loc, false),
+		"OverdueTasks":      formatTasks(overdue, loc, true),
+		"DueReminders":      formatReminders(reminders, loc),
+		"PendingInboxCount": inboxCount,
+		"ToAddr":            []string{user.Email},
+	}
+	if weekly {
+		stats, err := qs.GetCompletionStats(user.RealmID, user.ID, today.AddDate(0, 0, -7), today)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get completion stats: %w", err)
+		}
+		data["Stats"] = stats
+	}
+
+	empty := len(checklist) == 0 && len(upcoming) == 0 && len(overdue) == 0 && len(reminders) == 0 && inboxCount == 0
+	return data, empty, nil
+}
+
+func formatChecklistItems(items []models.DailyChecklistItem, loc *time.Location) []digestItem {
+	lines := make([]digestItem, 0, len(items))
+	for _, item := range items {
+		line := digestItem{Title: item.Title, Detail: item.Priority}
+		if item.Deadline != nil {
+			line.Time = item.Deadline.In(loc).Format("15:04")
+		}
+		if item.Status != "pending" {
+			line.Detail += ", " + strings.ReplaceAll(item.Status, "_", " ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func formatTasks(tasks []models.Task, loc *time.Location, overdue bool) []digestItem {
+	lines := make([]digestItem, 0, len(tasks))
+	for _, task := range tasks {
+		when := task.ScheduleTime
+		if overdue {
+			when = task.Deadline
+		}
+		lines = append(lines, digestItem{
+			Title:  task.Name,
+			Time:   when.In(loc).Format("Mon Jan 2 15:04"),
+			Detail: fmt.Sprintf("priority %d", task.Priority),
+		})
+	}
+	return lines
+}
+
+func formatReminders(reminders []models.Reminder, loc *time.Location) []digestItem {
+	lines := make([]digestItem, 0, len(reminders))
+	for _, reminder := range reminders {
+		line := digestItem{Title: reminder.Name, Time: reminder.RemindTime.In(loc).Format("Mon Jan 2 15:04")}
+		if reminder.Status == "active" {
+			line.Detail = "not acknowledged"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// digestHour returns the local hour of the day at which the digests are sent
+func digestHour() int {
+	if viper.IsSet("digest.hour") {
+		if hour := viper.GetInt("digest.hour"); hour >= 0 && hour <= 23 {
+			return hour
+		}
+	}
+	return defaultDigestHour
+}
+
+// digestWeekday returns the day on which the weekly digests are sent
+func digestWeekday() time.Weekday {
+	name := viper.GetString("digest.weekly_day")
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day
+		}
+	}
+	return time.Monday
+}
@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/email"
+)
+
+func newDigestTestJobManager(t *testing.T) (*JobManager, *gorm.DB) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.NotificationPreference{}, &models.Task{},
+		&models.Reminder{}, &models.InboxItem{}, &models.DailyChecklistItem{})
+	jm := &JobManager{
+		logger:               zap.NewNop().Sugar(),
+		reminderQueryService: NewReminderQueryService(db),
+		digestQueryService:   NewDigestQueryService(db),
+	}
+	return jm, db
+}
+
+func createDigestUser(t *testing.T, db *gorm.DB, id, timezone string) {
+	require.NoError(t, db.Create(&models.User{ID: id, RealmID: "realm", Username: id, Email: id + "@example.com",
+		HashedPassword: "x", IsActive: true}).Error)
+	require.NoError(t, db.Create(&models.NotificationPreference{ID: "pref-" + id, RealmID: "realm", UserID: id,
+		Timezone: timezone, EmailDigest: models.EmailDigestDaily}).Error)
+}
+
+func TestCollectDigestData(t *testing.T) {
+	jm, db := newDigestTestJobManager(t)
+	createDigestUser(t, db, "alice", "UTC")
+	user, err := jm.reminderQueryService.GetUserByID("alice")
+	require.NoError(t, err)
+	now := time.Date(2025, 3, 11, 7, 0, 0, 0, time.UTC)
+
+	_, empty, err := jm.collectDigestData(user, time.UTC, now, false)
+	require.NoError(t, err)
+	assert.True(t, empty)
+
+	require.NoError(t, db.Create(&models.Task{ID: "t1", RealmID: "realm", Name: "Review", Status: models.TaskStatusPending,
+		ScheduleTime: now.Add(2 * time.Hour), Minutes: 30, Deadline: now.Add(3 * time.Hour), CreatedBy: "alice"}).Error)
+	require.NoError(t, db.Create(&models.Task{ID: "t2", RealmID: "realm", Name: "Report", Status: models.TaskStatusPending,
+		ScheduleTime: now.Add(-48 * time.Hour), Minutes: 30, Deadline: now.Add(-24 * time.Hour), CreatedBy: "alice"}).Error)
+	require.NoError(t, db.Create(&models.Reminder{ID: "r1", RealmID: "realm", Name: "Standup", Content: "Standup",
+		RemindTime: now.Add(3 * time.Hour), Status: "pending", CreatedBy: "alice"}).Error)
+	require.NoError(t, db.Create(&models.Reminder{ID: "r2", RealmID: "realm", Name: "Next week", Content: "Later",
+		RemindTime: now.Add(7 * 24 * time.Hour), Status: "pending", CreatedBy: "alice"}).Error)
+	require.NoError(t, db.Create(&models.InboxItem{ID: "i1", RealmID: "realm", Title: "Idea", Status: "pending", CreatedBy: "alice"}).Error)
+
+	data, empty, err := jm.collectDigestData(user, time.UTC, now, false)
+	require.NoError(t, err)
+	assert.False(t, empty)
+	assert.Equal(t, "Tuesday, March 11, 2025", data["Date"])
+	assert.Equal(t, []digestItem{{Title: "Review", Time: "Tue Mar 11 09:00", Detail: "priority 2"}}, data["UpcomingTasks"])
+	assert.Equal(t, []digestItem{{Title: "Report", Time: "Mon Mar 10 07:00", Detail: "priority 2"}}, data["OverdueTasks"])
+	assert.Equal(t, []digestItem{{Title: "Standup", Time: "Tue Mar 11 10:00"}}, data["DueReminders"])
+	assert.Equal(t, int64(1), data["PendingInboxCount"])
+	assert.NotContains(t, data, "Stats")
+}
+
+func TestSendDueEmailDigests(t *testing.T) {
+	jm, db := newDigestTestJobManager(t)
+	templates, err := email.NewEmailTemplateManager("../../config/email_template.yaml")
+	require.NoError(t, err)
+	createDigestUser(t, db, "alice", "UTC")
+	createDigestUser(t, db, "bob", "Asia/Tokyo")
+	for _, user := range []string{"alice", "bob"} {
+		require.NoError(t, db.Create(&models.InboxItem{ID: "inbox-" + user, RealmID: "realm", Title: "Idea", Status: "pending", CreatedBy: user}).Error)
+	}
+
+	var sent []string
+	send := func(message *email.EmailMessage) error {
+		sent = append(sent, message.ToAddr...)
+		return nil
+	}
+
+	// 07:00 in London is 16:00 in Tokyo; a rerun within the hour sends nothing more
+	morning := time.Date(2025, 3, 11, 7, 0, 0, 0, time.UTC)
+	require.NoError(t, jm.sendDueEmailDigests(templates, send, morning))
+	require.NoError(t, jm.sendDueEmailDigests(templates, send, morning.Add(30*time.Minute)))
+	assert.Equal(t, []string{"alice@example.com"}, sent)
+
+	var pref models.NotificationPreference
+	require.NoError(t, db.First(&pref, "user_id = ?", "alice").Error)
+	assert.Equal(t, "2025-03-11", pref.DigestSentOn)
+
+	// Tokyo's morning comes at 22:00 UTC
+	sent = nil
+	require.NoError(t, jm.sendDueEmailDigests(templates, send, time.Date(2025, 3, 11, 22, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"bob@example.com"}, sent)
+	pref = models.NotificationPreference{}
+	require.NoError(t, db.First(&pref, "user_id = ?", "bob").Error)
+	assert.Equal(t, "2025-03-12", pref.DigestSentOn)
+}
+
+func TestSendDueEmailDigests_Failure(t *testing.T) {
+	jm, db := newDigestTestJobManager(t)
+	templates, err := email.NewEmailTemplateManager("../../config/email_template.yaml")
+	require.NoError(t, err)
+	createDigestUser(t, db, "alice", "UTC")
+	createDigestUser(t, db, "bob", "UTC")
+	for _, user := range []string{"alice", "bob"} {
+		require.NoError(t, db.Create(&models.InboxItem{ID: "inbox-" + user, RealmID: "realm", Title: "Idea", Status: "pending", CreatedBy: user}).Error)
+	}
+
+	var sent []string
+	send := func(message *email.EmailMessage) error {
+		if message.ToAddr[0] == "alice@example.com" {
+			return errors.New("mailbox unavailable")
+		}
+		sent = append(sent, message.ToAddr...)
+		return nil
+	}
+
+	// The other digests are still sent; the failed one fails the run and is retried on the next one
+	morning := time.Date(2025, 3, 11, 7, 0, 0, 0, time.UTC)
+	err = jm.sendDueEmailDigests(templates, send, morning)
+	assert.ErrorContains(t, err, "user alice: ")
+	assert.ErrorContains(t, err, "mailbox unavailable")
+	assert.Equal(t, []string{"bob@example.com"}, sent)
+
+	var pref models.NotificationPreference
+	require.NoError(t, db.First(&pref, "user_id = ?", "alice").Error)
+	assert.Empty(t, pref.DigestSentOn)
+}
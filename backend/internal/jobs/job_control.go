@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+var (
+	// ErrJobNotFound is returned for a job name the scheduler does not know
+	ErrJobNotFound = errors.New("job not found")
+	// ErrNotLeader is returned when a job is triggered on a process that does not run the jobs
+	ErrNotLeader = errors.New("this instance is not the scheduler leader, retry the request")
+	// ErrJobRunning is returned when an exclusive job is triggered while it is still running
+	ErrJobRunning = errors.New("job is still running")
+)
+
+// scheduledJob is a job that the cron scheduler runs
+type scheduledJob struct {
+	Name      string
+	Function  string
+	Schedule  string
+	System    bool // built in, rather than from the jobs config
+	Exclusive bool // skipped while its previous run is still going
+	entryID   cron.EntryID
+	running   sync.Mutex // held while an exclusive job runs
+	run       func(jm *JobManager) error
+}
+
+// jobRegistry keeps the scheduled jobs by name, in the order they were added
+type jobRegistry struct {
+	mu    sync.RWMutex
+	jobs  map[string]*scheduledJob
+	names []string
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*scheduledJob)}
+}
+
+func (r *jobRegistry) get(name string) (*scheduledJob, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[name]
+	return job, ok
+}
+
+func (r *jobRegistry) list() []*scheduledJob {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	jobs := make([]*scheduledJob, 0, len(r.names))
+	for _, name := range r.names {
+		jobs = append(jobs, r.jobs[name])
+	}
+	return jobs
+}
+
+// JobInfo describes a scheduled job
+type JobInfo struct {
+	Name     string         `json:"name"`
+	Function string         `json:"function"`
+	Schedule string         `json:"schedule"`
+	System   bool           `json:"system"`
+	Paused   bool           `json:"paused"`
+	NextRun  *time.Time     `json:"next_run,omitempty"`
+	PrevRun  *time.Time     `json:"prev_run,omitempty"`
+	LastRun  *models.JobRun `json:"last_run,omitempty"`
+}
+
+// scheduleJob adds a job to the cron scheduler
+func (jm *JobManager) scheduleJob(c *cron.Cron, job *scheduledJob) error {
+	jm.jobs.mu.Lock()
+	defer jm.jobs.mu.Unlock()
+	if _, exists := jm.jobs.jobs[job.Name]; exists {
+		return fmt.Errorf("a job named %q is already scheduled", job.Name)
+	}
+
+	entryID, err := c.AddFunc(job.Schedule, func() {
+		jm.runScheduledJob(job)
+	})
+	if err != nil {
+		return err
+	}
+	job.entryID = entryID
+	jm.jobs.jobs[job.Name] = job
+	jm.jobs.names = append(jm.jobs.names, job.Name)
+	return nil
+}
+
+// ListJobs returns the scheduled jobs with their next fire times and last runs
+func (jm *JobManager) ListJobs() ([]JobInfo, error) {
+	paused, err := jm.jobRunQueryService.GetPausedJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paused jobs: %w", err)
+	}
+
+	jobs := jm.jobs.list()
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		info, err := jm.jobInfo(job, paused[job.Name])
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// GetJob returns a scheduled job
+func (jm *JobManager) GetJob(name string) (*JobInfo, error) {
+	job, ok := jm.jobs.get(name)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	paused, err := jm.jobRunQueryService.IsPaused(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job state: %w", err)
+	}
+	return jm.jobInfo(job, paused)
+}
+
+func (jm *JobManager) jobInfo(job *scheduledJob, paused bool) (*JobInfo, error) {
+	info := &JobInfo{
+		Name:     job.Name,
+		Function: job.Function,
+		Schedule: job.Schedule,
+		System:   job.System,
+		Paused:   paused,
+	}
+	if jm.cronScheduler != nil {
+		entry := jm.cronScheduler.Entry(job.entryID)
+		if !entry.Next.IsZero() {
+			info.NextRun = &entry.Next
+		}
+		if !entry.Prev.IsZero() {
+			info.PrevRun = &entry.Prev
+		}
+	}
+	lastRun, err := jm.jobRunQueryService.GetLastRun(job.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last run of job %s: %w", job.Name, err)
+	}
+	info.LastRun = lastRun
+	return info, nil
+}
+
+// TriggerJob runs a job now, in the background, even if the job is paused. Like scheduled runs,
+// manual ones only run on the leader and not while an exclusive job is still running, so a
+// trigger sent to another process fails with ErrNotLeader and one that overlaps a run with
+// ErrJobRunning. Configured jobs run their function through ExecuteFunction.
+func (jm *JobManager) TriggerJob(name, triggeredBy string) (*models.JobRun, error) {
+	job, ok := jm.jobs.get(name)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	release, err := jm.beginRun(job)
+	if err != nil {
+		return nil, err
+	}
+	jm.logger.Infof("Job %s triggered by %s", name, triggeredBy)
+	run := jm.startRun(job, models.JobTriggerManual, triggeredBy)
+	go func() {
+		defer release()
+		jm.executeRun(job, run)
+	}()
+	return run, nil
+}
+
+// beginRun checks that this process may run a job now, and for an exclusive job takes its
+// running lock. The returned function ends the run.
+func (jm *JobManager) beginRun(job *scheduledJob) (func(), error) {
+	if jm.leaderElector != nil && !jm.leaderElector.IsLeader() {
+		return nil, ErrNotLeader
+	}
+	if !job.Exclusive {
+		return func() {}, nil
+	}
+	if !job.running.TryLock() {
+		return nil, ErrJobRunning
+	}
+	return job.running.Unlock, nil
+}
+
+// PauseJob stops the scheduled runs of a job on all processes until it is resumed
+func (jm *JobManager) PauseJob(name, updatedBy string) (*JobInfo, error) {
+	return jm.setPaused(name, true, updatedBy)
+}
+
+// ResumeJob resumes the scheduled runs of a paused job
+func (jm *JobManager) ResumeJob(name, updatedBy string) (*JobInfo, error) {
+	return jm.setPaused(name, false, updatedBy)
+}
+
+func (jm *JobManager) setPaused(name string, paused bool, updatedBy string) (*JobInfo, error) {
+	if _, ok := jm.jobs.get(name); !ok {
+		return nil, ErrJobNotFound
+	}
+	if err := jm.jobRunQueryService.SetPaused(name, paused, updatedBy); err != nil {
+		return nil, fmt.Errorf("failed to update job state: %w", err)
+	}
+	jm.logger.Infof("Job %s paused=%t by %s", name, paused, updatedBy)
+	return jm.GetJob(name)
+}
+
+// ListJobRuns returns the run history of the jobs
+func (jm *JobManager) ListJobRuns(params JobRunParams) ([]models.JobRun, int64, error) {
+	return jm.jobRunQueryService.ListRuns(params)
+}
+
+// GetJobRun returns a job run with its log
+func (jm *JobManager) GetJobRun(id string) (*models.JobRun, error) {
+	return jm.jobRunQueryService.GetRun(id)
+}
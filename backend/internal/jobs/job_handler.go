@@ -5,6 +5,13 @@ type JobHandler interface {
 	Execute(params string) error
 }
 
+// boundJobHandler is a job handler that works on behalf of a job manager; ExecuteFunction
+// binds it to the job manager of the run, so that the run's log is kept
+type boundJobHandler interface {
+	JobHandler
+	withJobManager(jm *JobManager) JobHandler
+}
+
 // JobHandlers registry stores all registered job handlers
 var JobHandlers = make(map[string]JobHandler)
 
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+// Lease is a named lock that one owner holds until it expires or is released
+type Lease interface {
+	// Acquire takes the lease, or extends it if owner already holds it, and reports whether
+	// owner holds it now
+	Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// Release gives the lease up if owner holds it
+	Release(ctx context.Context, name, owner string) error
+}
+
+// NewLease returns a Redis lease if a Redis client is configured, and a database lease otherwise
+func NewLease(rdb *redis.Client, db *gorm.DB) Lease {
+	if rdb != nil {
+		return &RedisLease{rdb: rdb}
+	}
+	return &DBLease{db: db}
+}
+
+// redisAcquireScript extends the lease of its owner or takes a free one
+var redisAcquireScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0`)
+
+// redisReleaseScript deletes the lease only if it is still held by its owner
+var redisReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisLease keeps leases as Redis keys that expire with the lease
+type RedisLease struct {
+	rdb *redis.Client
+}
+
+// Acquire takes or extends the lease
+func (l *RedisLease) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	held, err := redisAcquireScript.Run(ctx, l.rdb, []string{redisLeaseKey(name)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return held == 1, nil
+}
+
+// Release gives the lease up
+func (l *RedisLease) Release(ctx context.Context, name, owner string) error {
+	return redisReleaseScript.Run(ctx, l.rdb, []string{redisLeaseKey(name)}, owner).Err()
+}
+
+func redisLeaseKey(name string) string {
+	return "lease:" + name
+}
+
+// DBLease keeps leases in the job_leases table. Expiry is checked against the clock of the
+// process that acquires the lease, so the clocks of the processes must be in sync.
+type DBLease struct {
+	db *gorm.DB
+}
+
+// Acquire takes or extends the lease
+func (l *DBLease) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result := l.db.WithContext(ctx).Model(&models.JobLease{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", name, owner, now).
+		Updates(map[string]interface{}{
+			"owner":      owner,
+			"expires_at": now.Add(ttl),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// Either nobody has held the lease yet, or somebody else holds it
+	result = l.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.JobLease{
+		Name:      name,
+		Owner:     owner,
+		ExpiresAt: now.Add(ttl),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Release gives the lease up
+func (l *DBLease) Release(ctx context.Context, name, owner string) error {
+	return l.db.WithContext(ctx).Where("name = ? AND owner = ?", name, owner).Delete(&models.JobLease{}).Error
+}
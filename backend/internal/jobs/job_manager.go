@@ -3,9 +3,12 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
@@ -13,6 +16,8 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/webhook"
 	"github.com/walterfan/lazy-rabbit-secretary/pkg/email"
 	"gorm.io/gorm"
 )
@@ -40,26 +45,53 @@ type Config struct {
 
 // JobManager manages scheduled tasks and reminders
 type JobManager struct {
-	// Core dependencies
-	config      *Config
+	// Core dependencies; the config is replaced by reloads while the scheduler reads it, and
+	// shared with the copies that withLogger makes
+	config      *atomic.Pointer[Config]
 	logger      *zap.SugaredLogger
 	ctx         context.Context
 	rdb         *redis.Client
 	db          *gorm.DB
 	emailSender *email.EmailSender
 
+	// Webhook delivery with signing, retries and a delivery log
+	webhookSender *webhook.Sender
+	// Chat notifications through incoming webhooks (Slack, DingTalk, WeCom, Feishu)
+	chatNotifier *notifier.Notifier
+
 	// Query services to avoid import cycles
 	reminderQueryService *ReminderQueryService
 	taskQueryService     *TaskQueryService
+	secretQueryService   *SecretQueryService
+	digestQueryService   *DigestQueryService
+
+	// Notifications queued with the reminder status changes that cause them
+	outboxQueryService *OutboxQueryService
+	outbox             outboxSettings
+
+	// Runs of scheduled jobs, and the leader election that keeps replicas from running them twice
+	jobRunQueryService *JobRunQueryService
+	jobRunRetention    time.Duration
+	leaderElector      *LeaderElector
+	instanceID         string
+	jobs               *jobRegistry
 
 	// Runtime state
 	cronScheduler *cron.Cron
+	configWatcher *fsnotify.Watcher
 }
 
 // =============================================================================
 // CONSTRUCTOR AND INITIALIZATION
 // =============================================================================
 
+const (
+	// schedulerLeaseName is the lease held by the process that runs the scheduled jobs
+	schedulerLeaseName = "scheduler-leader"
+	// defaultLeaseTTL is how long the leader's lease lasts without being renewed
+	defaultLeaseTTL = 30 * time.Second
+)
+
 // NewJobManager creates a new JobManager instance
 func NewJobManager(logger *zap.Logger, redisClient *redis.Client, db *gorm.DB) *JobManager {
 	// Initialize email sender with graceful degradation
@@ -67,16 +99,40 @@ func NewJobManager(logger *zap.Logger, redisClient *redis.Client, db *gorm.DB) *
 	if err != nil {
 		logger.Sugar().Warnf("Failed to initialize email sender: %v. Email notifications will be disabled.", err)
 	}
+	chatNotifier, err := notifier.NewNotifier()
+	if err != nil {
+		logger.Sugar().Warnf("Failed to initialize chat notifier: %v. Chat notifications will be disabled.", err)
+	}
 
 	jm := &JobManager{
-		config:               nil, // Will be loaded later
+		config:               new(atomic.Pointer[Config]), // Will be loaded later
 		logger:               logger.Sugar(),
 		ctx:                  context.Background(),
 		rdb:                  redisClient,
 		db:                   db,
 		emailSender:          emailSender,
+		webhookSender:        webhook.NewSender(db, logger.Sugar()),
+		chatNotifier:         chatNotifier,
 		reminderQueryService: NewReminderQueryService(db),
 		taskQueryService:     NewTaskQueryService(db),
+		secretQueryService:   NewSecretQueryService(db),
+		digestQueryService:   NewDigestQueryService(db),
+		outboxQueryService:   NewOutboxQueryService(db),
+		outbox:               loadOutboxSettings(),
+		jobRunQueryService:   NewJobRunQueryService(db),
+		jobRunRetention:      defaultJobRunRetention,
+		instanceID:           instanceID(),
+		jobs:                 newJobRegistry(),
+	}
+	if retention := viper.GetDuration("scheduler.run_retention"); retention > 0 {
+		jm.jobRunRetention = retention
+	}
+	if !viper.IsSet("scheduler.leader_election") || viper.GetBool("scheduler.leader_election") {
+		ttl := viper.GetDuration("scheduler.lease_ttl")
+		if ttl <= 0 {
+			ttl = defaultLeaseTTL
+		}
+		jm.leaderElector = NewLeaderElector(NewLease(redisClient, db), schedulerLeaseName, jm.instanceID, ttl, jm.logger)
 	}
 
 	err = jm.loadConfig()
@@ -99,7 +155,7 @@ func (jm *JobManager) loadConfig() error {
 		return fmt.Errorf("unable to decode config into struct: %w", err)
 	}
 
-	jm.config = &config
+	jm.config.Store(&config)
 	jm.logger.Infof("Loaded %d tasks from configuration", len(config.Jobs))
 
 	// register job handlers: checkTask, writeBlog, generateCalendar
@@ -128,6 +184,9 @@ func (jm *JobManager) ExecuteFunction(functionName, param string) error {
 		jm.logger.Warnf("No handler found for function: %s", functionName)
 		return fmt.Errorf("no handler found for function: %s", functionName)
 	}
+	if bound, ok := handler.(boundJobHandler); ok {
+		handler = bound.withJobManager(jm)
+	}
 
 	if err := handler.Execute(param); err != nil {
 		jm.logger.Errorf("Error executing plugin %s: %v", functionName, err)
@@ -137,12 +196,6 @@ func (jm *JobManager) ExecuteFunction(functionName, param string) error {
 	return nil
 }
 
-// executeFunction maps function names to actual Go functions and executes them (private method)
-func (jm *JobManager) executeFunction(functionName, param string) {
-	// Use the public method but ignore the error (for backward compatibility)
-	jm.ExecuteFunction(functionName, param)
-}
-
 // =============================================================================
 // REDIS TASK MANAGEMENT
 // =============================================================================
@@ -216,7 +269,7 @@ func (jm *JobManager) checkTaskExpiry() {
 // REMINDER MANAGEMENT
 // =============================================================================
 
-// checkReminders finds and processes due reminders
+// checkReminders sends due reminders and escalates unacknowledged ones
 func (jm *JobManager) checkReminders() error {
 	if jm.reminderQueryService == nil {
 		jm.logger.Warn("Reminder query service not initialized, skipping reminder check")
@@ -224,113 +277,8 @@ func (jm *JobManager) checkReminders() error {
 	}
 
 	jm.logger.Debug("Checking for due reminders...")
-
-	// Query for due reminders using the query service
-	now := time.Now().UTC()
-	dueReminders, err := jm.reminderQueryService.FindDueReminders(now)
-	if err != nil {
-		jm.logger.Errorf("Failed to fetch due reminders: %v", err)
-		return fmt.Errorf("failed to fetch due reminders: %w", err)
-	}
-
-	if len(dueReminders) == 0 {
-		jm.logger.Debug("No due reminders found")
-		return nil
-	}
-
-	jm.logger.Infof("Found %d due reminders to process", len(dueReminders))
-
-	// Process each reminder
-	successCount := 0
-	for _, reminder := range dueReminders {
-		if err := jm.processReminder(reminder); err != nil {
-			jm.logger.Errorf("Failed to process reminder %s (%s): %v", reminder.ID, reminder.Name, err)
-		} else {
-			successCount++
-		}
-	}
-
-	jm.logger.Infof("Successfully processed %d/%d reminders", successCount, len(dueReminders))
-	return nil
-}
-
-// processReminder handles a single reminder: sends email and updates status
-func (jm *JobManager) processReminder(reminder *models.Reminder) error {
-	jm.logger.Infof("Processing reminder: %s - %s", reminder.ID, reminder.Name)
-
-	// Get user information using query service
-	user, err := jm.reminderQueryService.GetUserByID(reminder.CreatedBy)
-	if err != nil {
-		return fmt.Errorf("failed to get user info for %s reminder %s: %w", reminder.CreatedBy, reminder.ID, err)
-	}
-
-	// Handle case where user has no email
-	if user.Email == "" {
-		jm.logger.Warnf("User %s has no email address, marking reminder %s as active without notification", user.ID, reminder.ID)
-		return fmt.Errorf("user %s has no email address, marking reminder %s as active without notification", user.ID, reminder.ID)
-	}
-
-	// Send email notification
-	if jm.emailSender != nil {
-		if err := jm.sendReminderEmail(reminder, user); err != nil {
-			jm.logger.Errorf("Failed to send reminder email for %s: %v", reminder.ID, err)
-			// Continue to mark as active even if email fails
-			return fmt.Errorf("failed to send reminder email for %s: %w", reminder.ID, err)
-		} else {
-			jm.logger.Infof("Successfully sent reminder email for %s to %s", reminder.ID, user.Email)
-			// Update reminder status
-			return jm.markReminderAsActive(reminder)
-		}
-	} else {
-		jm.logger.Warn("Email sender not available, skipping email notification")
-		return fmt.Errorf("email sender not available, skipping email notification")
-	}
-}
-
-// sendReminderEmail sends a formatted email notification for a reminder
-func (jm *JobManager) sendReminderEmail(reminder *models.Reminder, user *models.User) error {
-	subject := fmt.Sprintf("🔔 Reminder: %s", reminder.Name)
-
-	body := jm.formatReminderEmailBody(reminder, user)
-
-	return jm.emailSender.SendEmailToRecipients(subject, body, []string{user.Email}, nil)
-}
-
-// formatReminderEmailBody creates the email body content
-func (jm *JobManager) formatReminderEmailBody(reminder *models.Reminder, user *models.User) string {
-	return fmt.Sprintf(`Hello %s,
-
-This is your scheduled reminder:
-
-📋 Title: %s
-📝 Content: %s
-⏰ Scheduled Time: %s
-🏷️ Tags: %s
-📬 Notification Methods: %s
-🎯 Targets: %s
-
----
-Sent by Lazy Rabbit Secretary System
-Time: %s`,
-		user.Username,
-		reminder.Name,
-		reminder.Content,
-		reminder.RemindTime.Format("2006-01-02 15:04:05"),
-		reminder.Tags,
-		reminder.RemindMethods,
-		reminder.RemindTargets,
-		time.Now().Format("2006-01-02 15:04:05"))
-}
-
-// markReminderAsActive updates a reminder's status to 'active'
-func (jm *JobManager) markReminderAsActive(reminder *models.Reminder) error {
-	err := jm.reminderQueryService.UpdateReminderStatus(reminder, "active", reminder.CreatedBy)
-	if err != nil {
-		return fmt.Errorf("failed to update reminder status: %w", err)
-	}
-
-	jm.logger.Infof("Marked reminder %s as active", reminder.ID)
-	return nil
+	handler := &TaskRemindHandler{jobManager: jm}
+	return handler.remindTask()
 }
 
 // generateRepeatTaskInstances creates new instances for repeating tasks
@@ -567,43 +515,69 @@ func (jm *JobManager) setupCronScheduler() *cron.Cron {
 
 // addSystemCronJobs adds built-in system cron jobs
 func (jm *JobManager) addSystemCronJobs(c *cron.Cron) {
-	// Task expiry check (every minute)
-	_, err := c.AddFunc("@every 1m", jm.checkTaskExpiry)
-	if err != nil {
-		jm.logger.Fatalf("Failed to add task expiry check cron job: %v", err)
-	} else {
-		jm.logger.Info("Scheduled task expiry check (every minute)")
-	}
-
-	// Reminder check (every minute)
-	_, err = c.AddFunc("@every 1m", func() {
-		if err := jm.checkReminders(); err != nil {
-			jm.logger.Errorf("Reminder check failed: %v", err)
+	digestSchedule := viper.GetString("digest.schedule")
+	if digestSchedule == "" {
+		digestSchedule = defaultDigestSchedule
+	}
+
+	outboxSchedule := viper.GetString("outbox.schedule")
+	if outboxSchedule == "" {
+		outboxSchedule = defaultOutboxSchedule
+	}
+
+	systemJobs := []*scheduledJob{
+		// Task expiry check (every minute)
+		{Name: "task expiry check", Function: "checkTaskExpiry", Schedule: "@every 1m", run: func(jm *JobManager) error {
+			jm.checkTaskExpiry()
+			return nil
+		}},
+		// Reminder check (every minute)
+		{Name: "reminder check", Function: "checkReminders", Schedule: "@every 1m", run: (*JobManager).checkReminders},
+		// Repeat task instance generation (every hour)
+		{Name: "repeat task generation", Function: "generateRepeatTaskInstances", Schedule: "@every 1h", run: func(jm *JobManager) error {
+			jm.generateRepeatTaskInstances()
+			return nil
+		}},
+		// Secret expiry and rotation reminders (every hour)
+		{Name: "secret expiry check", Function: "checkSecretExpiry", Schedule: "@every 1h", run: func(jm *JobManager) error {
+			jm.checkSecretExpiry()
+			return nil
+		}},
+		// Email digests of tasks, reminders and inbox (checked every hour, sent in each user's morning)
+		{Name: "email digest", Function: "sendEmailDigests", Schedule: digestSchedule, run: (*JobManager).sendEmailDigests},
+		// Delivery and retries of queued notifications (every 15 seconds by default)
+		{Name: "notification dispatch", Function: "dispatchNotifications", Schedule: outboxSchedule, Exclusive: true, run: (*JobManager).dispatchNotifications},
+		// Job run cleanup (every day)
+		{Name: "job run cleanup", Function: "pruneJobRuns", Schedule: "@daily", run: (*JobManager).pruneJobRuns},
+		// Delivered notification cleanup (every day)
+		{Name: "notification outbox cleanup", Function: "pruneOutbox", Schedule: "@daily", run: (*JobManager).pruneOutbox},
+	}
+
+	for _, job := range systemJobs {
+		job.System = true
+		if err := jm.scheduleJob(c, job); err != nil {
+			jm.logger.Errorf("Failed to add %s cron job with schedule '%s': %v", job.Name, job.Schedule, err)
+		} else {
+			jm.logger.Infof("Scheduled %s (%s)", job.Name, job.Schedule)
 		}
-	})
-	if err != nil {
-		jm.logger.Fatalf("Failed to add reminder check cron job: %v", err)
-	} else {
-		jm.logger.Info("Scheduled reminder check (every minute)")
-	}
-
-	// Repeat task instance generation (every hour)
-	_, err = c.AddFunc("@every 1h", jm.generateRepeatTaskInstances)
-	if err != nil {
-		jm.logger.Fatalf("Failed to add repeat task generation cron job: %v", err)
-	} else {
-		jm.logger.Info("Scheduled repeat task instance generation (every hour)")
 	}
 }
 
-// addConfiguredTasks adds tasks from configuration to the scheduler
+// addConfiguredTasks adds tasks from configuration to the scheduler. The jobs are validated like
+// on a reload; when any is invalid none is scheduled, and a fixed config file adds them all.
 func (jm *JobManager) addConfiguredTasks(c *cron.Cron) {
-	if jm.config == nil {
+	config := jm.config.Load()
+	if config == nil {
 		jm.logger.Warn("No configuration loaded, skipping configured tasks")
 		return
 	}
+	if err := jm.validateJobs(config.Jobs, config.Jobs); err != nil {
+		jm.logger.Errorf("Invalid jobs config, skipping configured tasks: %v", err)
+		jm.config.Store(&Config{})
+		return
+	}
 
-	for _, task := range jm.config.Jobs {
+	for _, task := range config.Jobs {
 		jm.addSingleTask(c, task)
 		jm.setTaskDeadline(task)
 	}
@@ -619,9 +593,14 @@ func (jm *JobManager) addSingleTask(c *cron.Cron, theJob CronJob) {
 	// Parse function and parameters
 	functionName, param := jm.parseFunctionCall(theJob.Function)
 
-	// Create the cron job
-	_, err := c.AddFunc(theJob.Schedule, func() {
-		jm.executeFunction(functionName, param)
+	// Create the cron job; each run calls the function through the run's job manager
+	err := jm.scheduleJob(c, &scheduledJob{
+		Name:     theJob.Name,
+		Function: functionName,
+		Schedule: theJob.Schedule,
+		run: func(jm *JobManager) error {
+			return jm.ExecuteFunction(functionName, param)
+		},
 	})
 
 	if err != nil {
@@ -687,17 +666,40 @@ func (jm *JobManager) setTaskDeadline(theJob CronJob) {
 func (jm *JobManager) CheckTasks() {
 	jm.logger.Info("Starting Job Manager...")
 
+	// Campaign for leadership before the first jobs fire
+	if jm.leaderElector != nil {
+		jm.leaderElector.Start()
+	}
+
 	// Setup and start cron scheduler
 	jm.cronScheduler = jm.setupCronScheduler()
 	jm.cronScheduler.Start()
 
+	// Apply changes of the configured jobs without a restart
+	if !viper.IsSet("scheduler.watch_config") || viper.GetBool("scheduler.watch_config") {
+		path := viper.ConfigFileUsed()
+		if path == "" {
+			jm.logger.Warn("No config file in use, job changes need a restart")
+		} else if abs, err := filepath.Abs(path); err != nil {
+			jm.logger.Warnf("Failed to resolve config file %s, job changes need a restart: %v", path, err)
+		} else if err := jm.watchConfig(abs); err != nil {
+			jm.logger.Warnf("Failed to watch config file %s, job changes need a restart: %v", abs, err)
+		}
+	}
+
 	jm.logger.Info("Job Manager started successfully")
 }
 
 // Stop gracefully stops the job manager
 func (jm *JobManager) Stop() {
 	if jm.cronScheduler != nil {
-		jm.cronScheduler.Stop()
+		<-jm.cronScheduler.Stop().Done()
 		jm.logger.Info("Job Manager stopped")
 	}
+	if jm.leaderElector != nil {
+		jm.leaderElector.Stop()
+	}
+	if jm.configWatcher != nil {
+		jm.configWatcher.Close()
+	}
 }
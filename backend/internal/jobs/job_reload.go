@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+)
+
+// cronParser parses schedules like the scheduler does, with seconds
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// watchConfig applies changes of the configured jobs in the config file at path while the
+// scheduler runs. The file is watched with fsnotify and read into a viper instance of its own,
+// since the global one is not safe to reload while other goroutines read it.
+func (jm *JobManager) watchConfig(path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory, as editors often replace the file rather than write to it
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	jm.configWatcher = watcher
+	go jm.watchJobsFile(watcher, path)
+	return nil
+}
+
+// watchJobsFile reloads the jobs when the file at path changes, until the watcher is closed.
+// Reloads run on this goroutine only, so they do not overlap.
+func (jm *JobManager) watchJobsFile(watcher *fsnotify.Watcher, path string) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			jm.logger.Infof("Config file %s changed, reloading jobs", path)
+			config, err := readJobsFile(path)
+			if err != nil {
+				jm.logger.Errorf("Failed to read changed config, keeping the current jobs: %v", err)
+				continue
+			}
+			if err := jm.reloadJobs(config); err != nil {
+				jm.logger.Errorf("Rejected changed jobs config, keeping the current jobs: %v", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			jm.logger.Warnf("Failed to watch config file %s: %v", path, err)
+		}
+	}
+}
+
+// readJobsFile reads the jobs of a config file
+func readJobsFile(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("unable to decode config into struct: %w", err)
+	}
+	return &config, nil
+}
+
+// reloadJobs reschedules the configured jobs to match config: jobs that are gone are removed,
+// new ones are added and changed ones are rescheduled. Nothing is applied when a new or changed
+// job is invalid.
+func (jm *JobManager) reloadJobs(config *Config) error {
+	var current []CronJob
+	if config := jm.config.Load(); config != nil {
+		current = config.Jobs
+	}
+	added, changed, removed := diffJobs(current, config.Jobs)
+	if len(added)+len(changed)+len(removed) == 0 {
+		return nil
+	}
+
+	if err := jm.validateJobs(config.Jobs, append(added, changed...)); err != nil {
+		return err
+	}
+
+	for _, job := range removed {
+		jm.removeConfiguredJob(job.Name)
+	}
+	for _, job := range changed {
+		jm.removeConfiguredJob(job.Name)
+		jm.addSingleTask(jm.cronScheduler, job)
+		jm.setTaskDeadline(job)
+	}
+	for _, job := range added {
+		jm.addSingleTask(jm.cronScheduler, job)
+		jm.setTaskDeadline(job)
+	}
+	jm.config.Store(config)
+	jm.logger.Infof("Reloaded jobs: %d added, %d rescheduled, %d removed", len(added), len(changed), len(removed))
+	return nil
+}
+
+// diffJobs compares two job lists by name
+func diffJobs(current, next []CronJob) (added, changed, removed []CronJob) {
+	old := make(map[string]CronJob, len(current))
+	for _, job := range current {
+		old[job.Name] = job
+	}
+	seen := make(map[string]bool, len(next))
+	for _, job := range next {
+		seen[job.Name] = true
+		previous, ok := old[job.Name]
+		switch {
+		case !ok:
+			added = append(added, job)
+		case previous != job:
+			changed = append(changed, job)
+		}
+	}
+	for _, job := range current {
+		if !seen[job.Name] {
+			removed = append(removed, job)
+		}
+	}
+	return added, changed, removed
+}
+
+// validateJobs checks the jobs that are about to be scheduled: their names must be unique and
+// not taken by a built-in job, their schedules must parse and their functions must have a handler
+func (jm *JobManager) validateJobs(all, toSchedule []CronJob) error {
+	var problems []string
+	names := make(map[string]bool, len(all))
+	for _, job := range all {
+		if names[job.Name] {
+			problems = append(problems, fmt.Sprintf("job %q is configured twice", job.Name))
+		}
+		names[job.Name] = true
+	}
+
+	for _, job := range toSchedule {
+		if strings.TrimSpace(job.Name) == "" {
+			problems = append(problems, "a job has no name")
+			continue
+		}
+		if existing, ok := jm.jobs.get(job.Name); ok && existing.System {
+			problems = append(problems, fmt.Sprintf("job %q has the name of a built-in job", job.Name))
+		}
+		if _, err := cronParser.Parse(job.Schedule); err != nil {
+			problems = append(problems, fmt.Sprintf("job %q has an invalid schedule %q: %v", job.Name, job.Schedule, err))
+		}
+		functionName, _ := jm.parseFunctionCall(job.Function)
+		functionName = strings.TrimSuffix(functionName, "()")
+		if _, ok := JobHandlers[functionName]; !ok {
+			problems = append(problems, fmt.Sprintf("job %q calls unknown function %q", job.Name, functionName))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// removeConfiguredJob removes a job that came from the config from the scheduler
+func (jm *JobManager) removeConfiguredJob(name string) {
+	jm.jobs.mu.Lock()
+	defer jm.jobs.mu.Unlock()
+	job, ok := jm.jobs.jobs[name]
+	if !ok || job.System {
+		return
+	}
+	if jm.cronScheduler != nil {
+		jm.cronScheduler.Remove(job.entryID)
+	}
+	delete(jm.jobs.jobs, name)
+	for i, n := range jm.jobs.names {
+		if n == name {
+			jm.jobs.names = append(jm.jobs.names[:i], jm.jobs.names[i+1:]...)
+			break
+		}
+	}
+	jm.logger.Infof("Removed job '%s'", name)
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type noopHandler struct{}
+
+func (noopHandler) Execute(string) error { return nil }
+
+func TestReloadJobs(t *testing.T) {
+	RegisterJobHandler("reloadTestJob", noopHandler{})
+	defer delete(JobHandlers, "reloadTestJob")
+
+	jm := &JobManager{
+		config:        new(atomic.Pointer[Config]),
+		logger:        zap.NewNop().Sugar(),
+		jobs:          newJobRegistry(),
+		cronScheduler: cron.New(cron.WithSeconds()),
+	}
+	require.NoError(t, jm.scheduleJob(jm.cronScheduler, &scheduledJob{
+		Name: "reminder check", Schedule: "@every 1m", System: true, run: (*JobManager).checkReminders,
+	}))
+	require.NoError(t, jm.reloadJobs(&Config{Jobs: []CronJob{
+		{Name: "write blog", Schedule: "0 0 21 * * *", Function: "reloadTestJob"},
+		{Name: "generate calendar", Schedule: "0 0 9 * * *", Function: "reloadTestJob"},
+	}}))
+	blog, ok := jm.jobs.get("write blog")
+	require.True(t, ok)
+	oldEntry := blog.entryID
+
+	// Reschedule one job and remove the other
+	require.NoError(t, jm.reloadJobs(&Config{Jobs: []CronJob{
+		{Name: "write blog", Schedule: "0 30 22 * * *", Function: "reloadTestJob(weekly)"},
+	}}))
+	blog, ok = jm.jobs.get("write blog")
+	require.True(t, ok)
+	assert.Equal(t, "0 30 22 * * *", blog.Schedule)
+	assert.NotEqual(t, oldEntry, blog.entryID)
+	_, ok = jm.jobs.get("generate calendar")
+	assert.False(t, ok)
+	assert.Len(t, jm.cronScheduler.Entries(), 2)
+
+	// Invalid changes are rejected as a whole
+	for _, jobs := range [][]CronJob{
+		{{Name: "write blog", Schedule: "every day", Function: "reloadTestJob"}},
+		{{Name: "write blog", Schedule: "0 30 22 * * *", Function: "noSuchFunction"}},
+		{{Name: "reminder check", Schedule: "0 30 22 * * *", Function: "reloadTestJob"}},
+		{{Name: "news", Schedule: "@hourly", Function: "reloadTestJob"}, {Name: "news", Schedule: "@daily", Function: "reloadTestJob"}},
+	} {
+		assert.Error(t, jm.reloadJobs(&Config{Jobs: jobs}))
+	}
+	blog, _ = jm.jobs.get("write blog")
+	assert.Equal(t, "0 30 22 * * *", blog.Schedule)
+	assert.Len(t, jm.jobs.list(), 2)
+	assert.Len(t, jm.cronScheduler.Entries(), 2)
+}
+
+func TestWatchConfig(t *testing.T) {
+	RegisterJobHandler("watchTestJob", noopHandler{})
+	defer delete(JobHandlers, "watchTestJob")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  port: 8080\n"), 0o644))
+	jm := &JobManager{
+		config:        new(atomic.Pointer[Config]),
+		logger:        zap.NewNop().Sugar(),
+		jobs:          newJobRegistry(),
+		cronScheduler: cron.New(cron.WithSeconds()),
+	}
+	require.NoError(t, jm.watchConfig(path))
+	defer jm.configWatcher.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte(`server:
+  port: 8080
+jobs:
+  - name: write blog
+    schedule: "0 0 21 * * *"
+    function: watchTestJob
+`), 0o644))
+	assert.Eventually(t, func() bool {
+		_, ok := jm.jobs.get("write blog")
+		return ok
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestAddConfiguredTasks_Invalid(t *testing.T) {
+	RegisterJobHandler("startupTestJob", noopHandler{})
+	defer delete(JobHandlers, "startupTestJob")
+
+	jm := &JobManager{
+		config:        new(atomic.Pointer[Config]),
+		logger:        zap.NewNop().Sugar(),
+		jobs:          newJobRegistry(),
+		cronScheduler: cron.New(cron.WithSeconds()),
+	}
+	jm.config.Store(&Config{Jobs: []CronJob{
+		{Name: "write blog", Schedule: "0 0 21 * * *", Function: "startupTestJob"},
+		{Name: "generate calendar", Schedule: "every day", Function: "startupTestJob"},
+	}})
+
+	// An invalid job at startup schedules none of the configured jobs
+	jm.addConfiguredTasks(jm.cronScheduler)
+	assert.Empty(t, jm.jobs.list())
+
+	// Once the config is fixed, the reload adds them all
+	require.NoError(t, jm.reloadJobs(&Config{Jobs: []CronJob{
+		{Name: "write blog", Schedule: "0 0 21 * * *", Function: "startupTestJob"},
+		{Name: "generate calendar", Schedule: "0 0 9 * * *", Function: "startupTestJob"},
+	}}))
+	assert.Len(t, jm.jobs.list(), 2)
+}
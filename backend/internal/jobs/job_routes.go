@@ -0,0 +1,199 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+)
+
+// RegisterJobRoutes registers the admin endpoints of the scheduled jobs. The jobs run for all
+// realms, so only super admins may manage them.
+func RegisterJobRoutes(router *gin.Engine, jm *JobManager, middleware *auth.AuthMiddleware) {
+	group := router.Group("/api/v1/jobs")
+	group.Use(middleware.Authenticate())
+	group.Use(middleware.RequireRole("super_admin"))
+
+	registerOutboxRoutes(router, jm, middleware)
+
+	// GET /api/v1/jobs - List the jobs with their next fire times and last runs
+	group.GET("", func(c *gin.Context) {
+		jobs, err := jm.ListJobs()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": jobs, "total": len(jobs)})
+	})
+
+	// GET /api/v1/jobs/runs - Query the run history
+	group.GET("/runs", func(c *gin.Context) {
+		params := JobRunParams{
+			JobName:  c.Query("job"),
+			Status:   c.Query("status"),
+			Trigger:  c.Query("trigger"),
+			Page:     parseIntDefault(c.Query("page"), 1),
+			PageSize: parseIntDefault(c.Query("page_size"), 20),
+		}
+		runs, total, err := jm.ListJobRuns(params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items":     runs,
+			"total":     total,
+			"page":      params.Page,
+			"page_size": params.PageSize,
+		})
+	})
+
+	// GET /api/v1/jobs/runs/:id - Get a run with its log
+	group.GET("/runs/:id", func(c *gin.Context) {
+		run, err := jm.GetJobRun(c.Param("id"))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job run not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, run)
+	})
+
+	// POST /api/v1/jobs/:name/run - Run a job now
+	group.POST("/:name/run", func(c *gin.Context) {
+		username, _ := auth.GetCurrentUsername(c)
+		run, err := jm.TriggerJob(c.Param("name"), username)
+		if err != nil {
+			c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, run)
+	})
+
+	// POST /api/v1/jobs/:name/pause - Pause the scheduled runs of a job
+	group.POST("/:name/pause", func(c *gin.Context) {
+		username, _ := auth.GetCurrentUsername(c)
+		job, err := jm.PauseJob(c.Param("name"), username)
+		if err != nil {
+			c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	// POST /api/v1/jobs/:name/resume - Resume the scheduled runs of a job
+	group.POST("/:name/resume", func(c *gin.Context) {
+		username, _ := auth.GetCurrentUsername(c)
+		job, err := jm.ResumeJob(c.Param("name"), username)
+		if err != nil {
+			c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+}
+
+// registerOutboxRoutes registers the endpoints of the notification outbox. Realm admins see
+// and requeue the entries of their realm; super admins those of all realms.
+func registerOutboxRoutes(router *gin.Engine, jm *JobManager, middleware *auth.AuthMiddleware) {
+	outbox := router.Group("/api/v1/jobs/outbox")
+	outbox.Use(middleware.Authenticate())
+	outbox.Use(middleware.RequireRole("admin", "super_admin"))
+
+	// GET /api/v1/jobs/outbox - Query the notification outbox, with the number of entries of each status
+	outbox.GET("", func(c *gin.Context) {
+		realmID, ok := outboxRealm(c)
+		if !ok {
+			return
+		}
+		params := OutboxParams{
+			RealmID:    realmID,
+			Status:     c.Query("status"),
+			Method:     c.Query("method"),
+			ReminderID: c.Query("reminder_id"),
+			Stuck:      c.Query("stuck") == "true",
+			Page:       parseIntDefault(c.Query("page"), 1),
+			PageSize:   parseIntDefault(c.Query("page_size"), 20),
+		}
+		entries, total, err := jm.ListOutbox(params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		counts, err := jm.CountOutbox(params.RealmID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items":     entries,
+			"total":     total,
+			"counts":    counts,
+			"page":      params.Page,
+			"page_size": params.PageSize,
+		})
+	})
+
+	// POST /api/v1/jobs/outbox/:id/retry - Deliver a dead or pending notification now
+	outbox.POST("/:id/retry", func(c *gin.Context) {
+		realmID, ok := outboxRealm(c)
+		if !ok {
+			return
+		}
+		username, _ := auth.GetCurrentUsername(c)
+		entry, err := jm.RetryOutboxEntry(c.Param("id"), realmID, username)
+		if err != nil {
+			c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	})
+}
+
+// outboxRealm returns the realm whose outbox entries the caller may see, empty for all realms.
+// It aborts the request for realm admins without a realm.
+func outboxRealm(c *gin.Context) (string, bool) {
+	roles, _ := auth.GetCurrentRoles(c)
+	for _, role := range roles {
+		if role == "super_admin" {
+			return "", true
+		}
+	}
+	realmID, _ := auth.GetCurrentRealm(c)
+	if realmID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no realm in the current session"})
+		return "", false
+	}
+	return realmID, true
+}
+
+func jobErrorStatus(err error) int {
+	if errors.Is(err, ErrJobNotFound) || errors.Is(err, ErrOutboxEntryNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, ErrOutboxEntryNotRetryable) || errors.Is(err, ErrJobRunning) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, ErrNotLeader) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func parseIntDefault(value string, defaultVal int) int {
+	if value == "" {
+		return defaultVal
+	}
+	out, err := strconv.Atoi(value)
+	if err != nil || out <= 0 {
+		return defaultVal
+	}
+	return out
+}
@@ -0,0 +1,292 @@
+package jobs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+const (
+	// defaultJobRunRetention is how long job runs are kept
+	defaultJobRunRetention = 7 * 24 * time.Hour
+	// maxRunLogBytes caps the log kept for a job run
+	maxRunLogBytes = 64 * 1024
+)
+
+// JobRunQueryService handles database queries for the runs and states of scheduled jobs
+type JobRunQueryService struct {
+	db *gorm.DB
+}
+
+// NewJobRunQueryService creates a new job run query service
+func NewJobRunQueryService(db *gorm.DB) *JobRunQueryService {
+	return &JobRunQueryService{
+		db: db,
+	}
+}
+
+// JobRunParams are the filters of the job run history
+type JobRunParams struct {
+	JobName  string
+	Status   string
+	Trigger  string
+	Page     int
+	PageSize int
+}
+
+// CreateRun records the start of a job run
+func (jrs *JobRunQueryService) CreateRun(run *models.JobRun) error {
+	return jrs.db.Create(run).Error
+}
+
+// FinishRun records the outcome of a job run
+func (jrs *JobRunQueryService) FinishRun(run *models.JobRun) error {
+	return jrs.db.Model(&models.JobRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"status":      run.Status,
+		"error":       run.Error,
+		"log":         run.Log,
+		"finished_at": run.FinishedAt,
+		"duration_ms": run.DurationMs,
+	}).Error
+}
+
+// ListRuns returns the runs matching the filters, newest first and without their logs
+func (jrs *JobRunQueryService) ListRuns(params JobRunParams) ([]models.JobRun, int64, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.PageSize <= 0 || params.PageSize > 100 {
+		params.PageSize = 20
+	}
+
+	q := jrs.db.Model(&models.JobRun{})
+	if params.JobName != "" {
+		q = q.Where("job_name = ?", params.JobName)
+	}
+	if params.Status != "" {
+		q = q.Where("status = ?", params.Status)
+	}
+	if params.Trigger != "" {
+		q = q.Where("trigger_type = ?", params.Trigger)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var runs []models.JobRun
+	err := q.Omit("log").
+		Order("started_at DESC").
+		Offset((params.Page - 1) * params.PageSize).
+		Limit(params.PageSize).
+		Find(&runs).Error
+	return runs, total, err
+}
+
+// GetRun returns a run with its log
+func (jrs *JobRunQueryService) GetRun(id string) (*models.JobRun, error) {
+	var run models.JobRun
+	if err := jrs.db.Where("id = ?", id).First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetLastRun returns the latest run of a job, or nil if it has not run yet
+func (jrs *JobRunQueryService) GetLastRun(jobName string) (*models.JobRun, error) {
+	var run models.JobRun
+	err := jrs.db.Omit("log").Where("job_name = ?", jobName).Order("started_at DESC").First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// DeleteRunsBefore deletes the runs started before a time
+func (jrs *JobRunQueryService) DeleteRunsBefore(before time.Time) (int64, error) {
+	result := jrs.db.Where("started_at < ?", before).Delete(&models.JobRun{})
+	return result.RowsAffected, result.Error
+}
+
+// IsPaused reports whether a job is paused
+func (jrs *JobRunQueryService) IsPaused(jobName string) (bool, error) {
+	var count int64
+	err := jrs.db.Model(&models.JobState{}).Where("job_name = ? AND paused = ?", jobName, true).Count(&count).Error
+	return count > 0, err
+}
+
+// GetPausedJobs returns the names of the paused jobs
+func (jrs *JobRunQueryService) GetPausedJobs() (map[string]bool, error) {
+	var names []string
+	if err := jrs.db.Model(&models.JobState{}).Where("paused = ?", true).Pluck("job_name", &names).Error; err != nil {
+		return nil, err
+	}
+	paused := make(map[string]bool, len(names))
+	for _, name := range names {
+		paused[name] = true
+	}
+	return paused, nil
+}
+
+// SetPaused pauses or resumes a job
+func (jrs *JobRunQueryService) SetPaused(jobName string, paused bool, updatedBy string) error {
+	state := &models.JobState{JobName: jobName, Paused: paused, UpdatedBy: updatedBy, UpdatedAt: time.Now()}
+	return jrs.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "job_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"paused", "updated_by", "updated_at"}),
+	}).Create(state).Error
+}
+
+// runScheduledJob runs a job when the scheduler fires it. When leader election is on, only
+// the leader runs scheduled jobs, so that replicas do not run them twice; paused jobs, and
+// exclusive ones whose previous run is still going, are skipped.
+func (jm *JobManager) runScheduledJob(job *scheduledJob) {
+	release, err := jm.beginRun(job)
+	if errors.Is(err, ErrNotLeader) {
+		jm.logger.Debugf("Not the leader, skipping job %s", job.Name)
+		return
+	}
+	if err != nil {
+		jm.logger.Infof("Job %s is still running, skipping this run", job.Name)
+		return
+	}
+	defer release()
+
+	paused, err := jm.jobRunQueryService.IsPaused(job.Name)
+	if err != nil {
+		jm.logger.Warnf("Failed to check whether job %s is paused: %v", job.Name, err)
+	}
+	if paused {
+		jm.logger.Debugf("Job %s is paused, skipping it", job.Name)
+		return
+	}
+
+	run := jm.startRun(job, models.JobTriggerSchedule, "")
+	jm.executeRun(job, run)
+}
+
+// startRun records the start of a job run
+func (jm *JobManager) startRun(job *scheduledJob, trigger, triggeredBy string) *models.JobRun {
+	run := &models.JobRun{
+		ID:          uuid.NewString(),
+		JobName:     job.Name,
+		Function:    job.Function,
+		Instance:    jm.instanceID,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Status:      models.JobRunStatusRunning,
+		StartedAt:   time.Now(),
+	}
+	if err := jm.jobRunQueryService.CreateRun(run); err != nil {
+		jm.logger.Warnf("Failed to record start of job %s: %v", job.Name, err)
+	}
+	return run
+}
+
+// executeRun runs a job with a logger that also keeps what the job logs, and records the outcome
+func (jm *JobManager) executeRun(job *scheduledJob, run *models.JobRun) {
+	log := &runLog{}
+	err := callJob(func() error {
+		return job.run(jm.withLogger(jm.runLogger(log)))
+	})
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status = models.JobRunStatusSucceeded
+	if err != nil {
+		run.Status = models.JobRunStatusFailed
+		run.Error = err.Error()
+		jm.logger.Errorf("Job %s failed: %v", job.Name, err)
+	}
+	run.Log = log.String()
+	if err := jm.jobRunQueryService.FinishRun(run); err != nil {
+		jm.logger.Warnf("Failed to record end of job %s: %v", job.Name, err)
+	}
+}
+
+// callJob calls a job function and turns a panic into an error, so one job cannot take down
+// the scheduler
+func callJob(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// withLogger returns a copy of the job manager that logs to logger
+func (jm *JobManager) withLogger(logger *zap.SugaredLogger) *JobManager {
+	copied := *jm
+	copied.logger = logger
+	return &copied
+}
+
+// runLogger returns a logger that logs like the job manager's and also writes to log
+func (jm *JobManager) runLogger(log *runLog) *zap.SugaredLogger {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	core := zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), log, zapcore.InfoLevel)
+	return jm.logger.Desugar().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	})).Sugar()
+}
+
+// runLog keeps the log of a job run, up to maxRunLogBytes
+type runLog struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+// Write appends to the log, dropping what does not fit
+func (l *runLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buf.Len()+len(p) > maxRunLogBytes {
+		l.truncated = true
+		return len(p), nil
+	}
+	return l.buf.Write(p)
+}
+
+// Sync implements zapcore.WriteSyncer
+func (l *runLog) Sync() error {
+	return nil
+}
+
+// String returns the log
+func (l *runLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.truncated {
+		return l.buf.String() + "... (log truncated)\n"
+	}
+	return l.buf.String()
+}
+
+// pruneJobRuns deletes job runs older than the retention period
+func (jm *JobManager) pruneJobRuns() error {
+	deleted, err := jm.jobRunQueryService.DeleteRunsBefore(time.Now().Add(-jm.jobRunRetention))
+	if err != nil {
+		return fmt.Errorf("failed to delete old job runs: %w", err)
+	}
+	if deleted > 0 {
+		jm.logger.Infof("Deleted %d old job runs", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCallJob(t *testing.T) {
+	assert.NoError(t, callJob(func() error { return nil }))
+	assert.EqualError(t, callJob(func() error { return errors.New("boom") }), "boom")
+	assert.EqualError(t, callJob(func() error { panic("nil map") }), "panic: nil map")
+}
+
+func TestRunLogger(t *testing.T) {
+	jm := &JobManager{logger: zap.NewNop().Sugar()}
+	log := &runLog{}
+	runJM := jm.withLogger(jm.runLogger(log))
+
+	runJM.logger.Debug("not kept")
+	runJM.logger.Infof("Processed %d reminders", 3)
+	runJM.logger.Warn("Email sender not available")
+	assert.NotContains(t, log.String(), "not kept")
+	assert.Contains(t, log.String(), "Processed 3 reminders")
+	assert.Contains(t, log.String(), "Email sender not available")
+
+	// The job manager itself keeps its logger
+	jm.logger.Info("not in the run log")
+	assert.NotContains(t, log.String(), "not in the run log")
+
+	runJM.logger.Info(strings.Repeat("x", maxRunLogBytes))
+	assert.True(t, strings.HasSuffix(log.String(), "... (log truncated)\n"))
+	assert.LessOrEqual(t, len(log.String()), maxRunLogBytes+len("... (log truncated)\n"))
+}
+
+func TestBeginRun(t *testing.T) {
+	lease := &memoryLease{}
+	elector := NewLeaderElector(lease, schedulerLeaseName, "replica-1", time.Minute, zap.NewNop().Sugar())
+	jm := &JobManager{logger: zap.NewNop().Sugar(), jobs: newJobRegistry(), leaderElector: elector}
+	ran := 0
+	job := &scheduledJob{Name: "dispatch", Exclusive: true, run: func(*JobManager) error {
+		ran++
+		return nil
+	}}
+	jm.jobs.jobs[job.Name] = job
+	jm.jobs.names = append(jm.jobs.names, job.Name)
+
+	// Neither scheduled nor manual runs happen off the leader
+	_, err := jm.TriggerJob("dispatch", "alice")
+	assert.ErrorIs(t, err, ErrNotLeader)
+	jm.runScheduledJob(job)
+	assert.Zero(t, ran)
+
+	// On the leader, neither kind of run overlaps a run of an exclusive job
+	elector.Start()
+	defer elector.Stop()
+	release, err := jm.beginRun(job)
+	require.NoError(t, err)
+	_, err = jm.TriggerJob("dispatch", "alice")
+	assert.ErrorIs(t, err, ErrJobRunning)
+	jm.runScheduledJob(job)
+	assert.Zero(t, ran)
+
+	release()
+	release, err = jm.beginRun(job)
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// LeaderElector elects the one process that runs the scheduled jobs when several replicas of
+// the server run. The leader renews its lease three times per TTL; when it stops or dies, the
+// lease expires and another process takes over.
+type LeaderElector struct {
+	lease  Lease
+	name   string
+	owner  string
+	ttl    time.Duration
+	logger *zap.SugaredLogger
+
+	leader atomic.Bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewLeaderElector creates a leader elector for the named lease
+func NewLeaderElector(lease Lease, name, owner string, ttl time.Duration, logger *zap.SugaredLogger) *LeaderElector {
+	return &LeaderElector{
+		lease:  lease,
+		name:   name,
+		owner:  owner,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// Start tries to become the leader right away and then keeps trying, or renewing, in the background
+func (e *LeaderElector) Start() {
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	e.campaign()
+
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.campaign()
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops campaigning and gives the lease up, so another process can take over at once
+func (e *LeaderElector) Stop() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+
+	e.leader.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	if err := e.lease.Release(ctx, e.name, e.owner); err != nil {
+		e.logger.Warnf("Failed to release lease %s: %v", e.name, err)
+	}
+}
+
+// IsLeader reports whether this process is the leader
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// campaign takes or renews the lease. A process that cannot reach the lease store steps down,
+// since another process may take over once its lease expires.
+func (e *LeaderElector) campaign() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+
+	held, err := e.lease.Acquire(ctx, e.name, e.owner, e.ttl)
+	if err != nil {
+		e.logger.Errorf("Failed to acquire lease %s: %v", e.name, err)
+		held = false
+	}
+	if was := e.leader.Swap(held); was != held {
+		if held {
+			e.logger.Infof("Became the leader for scheduled jobs (%s)", e.owner)
+		} else {
+			e.logger.Infof("No longer the leader for scheduled jobs (%s)", e.owner)
+		}
+	}
+}
+
+// instanceID identifies this process among the replicas
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8])
+}
@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// memoryLease is a Lease kept in memory
+type memoryLease struct {
+	mu      sync.Mutex
+	owner   string
+	expires time.Time
+	err     error
+}
+
+func (l *memoryLease) Acquire(_ context.Context, _, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	if l.owner != owner && time.Now().Before(l.expires) {
+		return false, nil
+	}
+	l.owner = owner
+	l.expires = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (l *memoryLease) Release(_ context.Context, _, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.owner == owner {
+		l.owner = ""
+		l.expires = time.Time{}
+	}
+	return nil
+}
+
+func TestLeaderElector(t *testing.T) {
+	lease := &memoryLease{}
+	logger := zap.NewNop().Sugar()
+	first := NewLeaderElector(lease, schedulerLeaseName, "first", time.Minute, logger)
+	second := NewLeaderElector(lease, schedulerLeaseName, "second", time.Minute, logger)
+
+	first.Start()
+	second.Start()
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	// The lease is given up on stop, so the other process takes over on its next campaign
+	first.Stop()
+	assert.False(t, first.IsLeader())
+	second.campaign()
+	assert.True(t, second.IsLeader())
+
+	// A leader that cannot reach the lease store steps down
+	lease.err = errors.New("connection refused")
+	second.campaign()
+	assert.False(t, second.IsLeader())
+	second.Stop()
+}
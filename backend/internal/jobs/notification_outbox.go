@@ -0,0 +1,513 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/webhook"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/netguard"
+)
+
+const (
+	defaultOutboxMaxAttempts    = 8
+	defaultOutboxInitialBackoff = 30 * time.Second
+	defaultOutboxLockTimeout    = 5 * time.Minute
+	defaultOutboxBatchSize      = 50
+	defaultOutboxRetention      = 7 * 24 * time.Hour
+	defaultOutboxSchedule       = "@every 15s"
+	// maxOutboxBackoff caps the wait between two attempts of an entry
+	maxOutboxBackoff = time.Hour
+)
+
+var (
+	// ErrOutboxEntryNotFound is returned for an outbox entry that does not exist
+	ErrOutboxEntryNotFound = errors.New("outbox entry not found")
+	// ErrOutboxEntryNotRetryable is returned when retrying an entry that is delivered or being delivered
+	ErrOutboxEntryNotRetryable = errors.New("only pending and dead outbox entries can be retried")
+)
+
+// outboxSettings configure the delivery of the notification outbox
+type outboxSettings struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	lockTimeout    time.Duration // how long a dispatcher may take to send an entry before it is claimed again
+	batchSize      int           // entries sent per dispatcher run
+	retention      time.Duration // how long delivered entries are kept
+}
+
+func loadOutboxSettings() outboxSettings {
+	settings := outboxSettings{
+		maxAttempts:    viper.GetInt("outbox.max_attempts"),
+		initialBackoff: viper.GetDuration("outbox.initial_backoff"),
+		lockTimeout:    viper.GetDuration("outbox.lock_timeout"),
+		batchSize:      viper.GetInt("outbox.batch_size"),
+		retention:      viper.GetDuration("outbox.retention"),
+	}
+	if settings.maxAttempts <= 0 {
+		settings.maxAttempts = defaultOutboxMaxAttempts
+	}
+	if settings.initialBackoff <= 0 {
+		settings.initialBackoff = defaultOutboxInitialBackoff
+	}
+	if settings.lockTimeout <= 0 {
+		settings.lockTimeout = defaultOutboxLockTimeout
+	}
+	if settings.batchSize <= 0 {
+		settings.batchSize = defaultOutboxBatchSize
+	}
+	if settings.retention <= 0 {
+		settings.retention = defaultOutboxRetention
+	}
+	return settings
+}
+
+// outboxBackoff returns how long to wait after the given number of failed attempts
+func outboxBackoff(initial time.Duration, attempts int) time.Duration {
+	backoff := initial
+	for i := 1; i < attempts && backoff < maxOutboxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxOutboxBackoff {
+		return maxOutboxBackoff
+	}
+	return backoff
+}
+
+// outboxPayload is the notification kept in an outbox entry
+type outboxPayload struct {
+	Reminder models.Reminder `json:"reminder"`
+	User     outboxRecipient `json:"user"`
+	Target   outboxTarget    `json:"target"`
+}
+
+// outboxRecipient is the part of a user that notifications need
+type outboxRecipient struct {
+	ID       string `json:"id"`
+	RealmID  string `json:"realm_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// outboxTarget is where an outbox entry is sent: an email address, or a webhook or chat app.
+// Endpoints are kept by ID and looked up when the entry is sent; only the URLs given in a
+// reminder's targets are kept with their secret.
+type outboxTarget struct {
+	Channel    string `json:"channel"` // email, webhook or a chat app
+	Email      string `json:"email,omitempty"`
+	EndpointID string `json:"endpoint_id,omitempty"`
+	Host       string `json:"host,omitempty"` // host of the endpoint's URL when it was queued
+	URL        string `json:"url,omitempty"`
+	Secret     string `json:"secret,omitempty"`
+}
+
+func newWebhookOutboxTarget(target webhook.Target) outboxTarget {
+	if target.EndpointID != "" {
+		return outboxTarget{Channel: target.Channel, EndpointID: target.EndpointID, Host: netguard.Host(target.URL)}
+	}
+	return outboxTarget{Channel: target.Channel, URL: target.URL, Secret: target.Secret}
+}
+
+// key identifies the target in idempotency keys; URLs are hashed, as they may hold tokens
+func (t outboxTarget) key() string {
+	if t.Channel == "email" {
+		return "email:" + t.Email
+	}
+	if t.EndpointID != "" {
+		return t.Channel + ":endpoint:" + t.EndpointID
+	}
+	sum := sha256.Sum256([]byte(t.URL))
+	return t.Channel + ":" + hex.EncodeToString(sum[:8])
+}
+
+// String describes the target without the path and query of its URL, which may hold tokens
+func (t outboxTarget) String() string {
+	if t.Channel == "email" {
+		return t.Email
+	}
+	host := t.Host
+	if host == "" {
+		host = netguard.Host(t.URL)
+	}
+	if host != "" {
+		return t.Channel + " " + host
+	}
+	return t.Channel
+}
+
+// newOutboxEntry returns an entry that sends reminder to user through method, at target
+func newOutboxEntry(reminder *models.Reminder, user *models.User, method string, target outboxTarget, key string) (*models.NotificationOutbox, error) {
+	payload, err := json.Marshal(outboxPayload{
+		Reminder: *reminder,
+		User:     outboxRecipient{ID: user.ID, RealmID: user.RealmID, Username: user.Username, Email: user.Email},
+		Target:   target,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notification: %w", err)
+	}
+	recipient := user.Username
+	if recipient == "" {
+		recipient = user.Email
+	}
+	return &models.NotificationOutbox{
+		RealmID:        reminder.RealmID,
+		ReminderID:     reminder.ID,
+		UserID:         user.ID,
+		Recipient:      recipient,
+		Method:         method,
+		Target:         target.String(),
+		IdempotencyKey: key,
+		Payload:        string(payload),
+	}, nil
+}
+
+// enqueueNotifications adds entries to the outbox. Entries whose idempotency key is already
+// queued are skipped.
+func enqueueNotifications(tx *gorm.DB, entries []*models.NotificationOutbox) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.ID == "" {
+			entry.ID = uuid.NewString()
+		}
+		entry.Status = models.OutboxStatusPending
+		if entry.NextAttemptAt.IsZero() {
+			entry.NextAttemptAt = now
+		}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoNothing: true,
+	}).Create(&entries).Error
+}
+
+// OutboxQueryService handles database queries for the notification outbox
+type OutboxQueryService struct {
+	db *gorm.DB
+}
+
+// NewOutboxQueryService creates a new outbox query service
+func NewOutboxQueryService(db *gorm.DB) *OutboxQueryService {
+	return &OutboxQueryService{
+		db: db,
+	}
+}
+
+// OutboxParams are the filters of the outbox list
+type OutboxParams struct {
+	RealmID    string // empty for all realms
+	Status     string
+	Method     string
+	ReminderID string
+	Stuck      bool // entries whose dispatcher did not finish in time
+	Page       int
+	PageSize   int
+}
+
+// ClaimNext locks the next entry that is due, or whose dispatcher did not finish before its
+// lock expired, for owner. Each claim counts as an attempt. It returns nil when nothing is due.
+func (oqs *OutboxQueryService) ClaimNext(owner string, now time.Time, lockFor time.Duration) (*models.NotificationOutbox, error) {
+	for {
+		var entry models.NotificationOutbox
+		err := oqs.db.
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.OutboxStatusPending, now, models.OutboxStatusDelivering, now).
+			Order("next_attempt_at").
+			First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The attempts work as a version, so that only one dispatcher claims an entry
+		lockedUntil := now.Add(lockFor)
+		result := oqs.db.Model(&models.NotificationOutbox{}).
+			Where("id = ? AND status = ? AND attempts = ?", entry.ID, entry.Status, entry.Attempts).
+			Updates(map[string]interface{}{
+				"status":       models.OutboxStatusDelivering,
+				"attempts":     entry.Attempts + 1,
+				"locked_by":    owner,
+				"locked_until": lockedUntil,
+				"updated_at":   now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Another dispatcher claimed it first
+			continue
+		}
+		entry.Status = models.OutboxStatusDelivering
+		entry.Attempts++
+		entry.LockedBy = owner
+		entry.LockedUntil = &lockedUntil
+		return &entry, nil
+	}
+}
+
+// MarkDelivered records that a claimed entry was delivered
+func (oqs *OutboxQueryService) MarkDelivered(entry *models.NotificationOutbox, deliveredAt time.Time) error {
+	return oqs.release(entry, map[string]interface{}{
+		"status":       models.OutboxStatusDelivered,
+		"delivered_at": deliveredAt,
+		"last_error":   "",
+	})
+}
+
+// MarkFailed records a failed attempt of a claimed entry and when to try again
+func (oqs *OutboxQueryService) MarkFailed(entry *models.NotificationOutbox, lastError string, nextAttemptAt time.Time) error {
+	return oqs.release(entry, map[string]interface{}{
+		"status":          models.OutboxStatusPending,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	})
+}
+
+// MarkDead records that a claimed entry will not be tried again
+func (oqs *OutboxQueryService) MarkDead(entry *models.NotificationOutbox, lastError string) error {
+	return oqs.release(entry, map[string]interface{}{
+		"status":     models.OutboxStatusDead,
+		"last_error": lastError,
+	})
+}
+
+// release unlocks a claimed entry with updates, unless another dispatcher claimed it since
+func (oqs *OutboxQueryService) release(entry *models.NotificationOutbox, updates map[string]interface{}) error {
+	updates["locked_by"] = ""
+	updates["locked_until"] = nil
+	updates["updated_at"] = time.Now()
+	result := oqs.db.Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status = ? AND attempts = ? AND locked_by = ?",
+			entry.ID, models.OutboxStatusDelivering, entry.Attempts, entry.LockedBy).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("outbox entry %s was claimed again while it was being delivered", entry.ID)
+	}
+	return nil
+}
+
+// List returns the entries matching the filters, newest first
+func (oqs *OutboxQueryService) List(params OutboxParams) ([]models.NotificationOutbox, int64, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.PageSize <= 0 || params.PageSize > 100 {
+		params.PageSize = 20
+	}
+
+	q := oqs.db.Model(&models.NotificationOutbox{})
+	if params.RealmID != "" {
+		q = q.Where("realm_id = ?", params.RealmID)
+	}
+	if params.Status != "" {
+		q = q.Where("status = ?", params.Status)
+	}
+	if params.Method != "" {
+		q = q.Where("method = ?", params.Method)
+	}
+	if params.ReminderID != "" {
+		q = q.Where("reminder_id = ?", params.ReminderID)
+	}
+	if params.Stuck {
+		q = q.Where("status = ? AND locked_until < ?", models.OutboxStatusDelivering, time.Now())
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.NotificationOutbox
+	err := q.Order("created_at DESC").
+		Offset((params.Page - 1) * params.PageSize).
+		Limit(params.PageSize).
+		Find(&entries).Error
+	return entries, total, err
+}
+
+// CountByStatus returns the number of entries of each status in a realm, or in all realms
+// when realmID is empty
+func (oqs *OutboxQueryService) CountByStatus(realmID string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	q := oqs.db.Model(&models.NotificationOutbox{})
+	if realmID != "" {
+		q = q.Where("realm_id = ?", realmID)
+	}
+	err := q.Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// Retry queues a pending or dead entry of a realm, or of any realm when realmID is empty, to
+// be delivered now, with its attempts reset
+func (oqs *OutboxQueryService) Retry(id, realmID string) (*models.NotificationOutbox, error) {
+	var entry models.NotificationOutbox
+	q := oqs.db.Where("id = ?", id)
+	if realmID != "" {
+		q = q.Where("realm_id = ?", realmID)
+	}
+	err := q.First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOutboxEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := oqs.db.Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status IN ?", id, []string{models.OutboxStatusPending, models.OutboxStatusDead}).
+		Updates(map[string]interface{}{
+			"status":          models.OutboxStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+			"updated_at":      time.Now(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrOutboxEntryNotRetryable
+	}
+	if err := oqs.db.Where("id = ?", id).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// DeleteDeliveredBefore deletes the entries delivered before a time
+func (oqs *OutboxQueryService) DeleteDeliveredBefore(before time.Time) (int64, error) {
+	result := oqs.db.Where("status = ? AND delivered_at < ?", models.OutboxStatusDelivered, before).
+		Delete(&models.NotificationOutbox{})
+	return result.RowsAffected, result.Error
+}
+
+// dispatchNotifications delivers the due entries of the notification outbox, up to a batch per
+// run. Each entry is claimed right before it is sent, so its lock only has to outlast one send.
+// Failed entries are tried again with exponential backoff until they are dead-lettered.
+func (jm *JobManager) dispatchNotifications() error {
+	claimed, delivered := 0, 0
+	for claimed < jm.outbox.batchSize {
+		entry, err := jm.outboxQueryService.ClaimNext(jm.instanceID, time.Now(), jm.outbox.lockTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to claim outbox entry: %w", err)
+		}
+		if entry == nil {
+			break
+		}
+		claimed++
+		if jm.deliverOutboxEntry(entry) {
+			delivered++
+		}
+	}
+	if claimed > 0 {
+		jm.logger.Infof("Delivered %d/%d queued notifications", delivered, claimed)
+	}
+	return nil
+}
+
+// deliverOutboxEntry sends a claimed entry and records the outcome
+func (jm *JobManager) deliverOutboxEntry(entry *models.NotificationOutbox) bool {
+	dead := entry.Attempts >= jm.outbox.maxAttempts
+	var payload outboxPayload
+	err := json.Unmarshal([]byte(entry.Payload), &payload)
+	if err == nil {
+		user := &models.User{
+			ID:       payload.User.ID,
+			RealmID:  payload.User.RealmID,
+			Username: payload.User.Username,
+			Email:    payload.User.Email,
+		}
+		h := &TaskRemindHandler{jobManager: jm}
+		err = h.sendToTarget(&payload.Reminder, user, payload.Target, entry.IdempotencyKey)
+		if errors.Is(err, webhook.ErrEndpointUnavailable) {
+			// The user removed the endpoint or turned it off
+			dead = true
+		}
+	} else {
+		// A payload that cannot be read will not get better
+		dead = true
+		err = fmt.Errorf("invalid payload: %w", err)
+	}
+
+	if err == nil {
+		if err := jm.outboxQueryService.MarkDelivered(entry, time.Now()); err != nil {
+			jm.logger.Warnf("Failed to record delivery of outbox entry %s: %v", entry.ID, err)
+		}
+		return true
+	}
+
+	if dead {
+		jm.logger.Errorf("Giving up on %s notification %s after %d attempts: %v", entry.Method, entry.IdempotencyKey, entry.Attempts, err)
+		if markErr := jm.outboxQueryService.MarkDead(entry, err.Error()); markErr != nil {
+			jm.logger.Warnf("Failed to dead-letter outbox entry %s: %v", entry.ID, markErr)
+		}
+		return false
+	}
+	retryAt := time.Now().Add(outboxBackoff(jm.outbox.initialBackoff, entry.Attempts))
+	jm.logger.Warnf("Failed to send %s notification %s (attempt %d), retrying at %s: %v",
+		entry.Method, entry.IdempotencyKey, entry.Attempts, retryAt.Format(time.RFC3339), err)
+	if markErr := jm.outboxQueryService.MarkFailed(entry, err.Error(), retryAt); markErr != nil {
+		jm.logger.Warnf("Failed to record failure of outbox entry %s: %v", entry.ID, markErr)
+	}
+	return false
+}
+
+// pruneOutbox deletes delivered outbox entries older than the retention period
+func (jm *JobManager) pruneOutbox() error {
+	deleted, err := jm.outboxQueryService.DeleteDeliveredBefore(time.Now().Add(-jm.outbox.retention))
+	if err != nil {
+		return fmt.Errorf("failed to delete delivered outbox entries: %w", err)
+	}
+	if deleted > 0 {
+		jm.logger.Infof("Deleted %d delivered outbox entries", deleted)
+	}
+	return nil
+}
+
+// ListOutbox returns the entries of the notification outbox
+func (jm *JobManager) ListOutbox(params OutboxParams) ([]models.NotificationOutbox, int64, error) {
+	return jm.outboxQueryService.List(params)
+}
+
+// CountOutbox returns the number of outbox entries of each status in a realm, or in all realms
+// when realmID is empty
+func (jm *JobManager) CountOutbox(realmID string) (map[string]int64, error) {
+	return jm.outboxQueryService.CountByStatus(realmID)
+}
+
+// RetryOutboxEntry queues a dead or pending outbox entry of a realm, or of any realm when
+// realmID is empty, to be delivered now
+func (jm *JobManager) RetryOutboxEntry(id, realmID, requestedBy string) (*models.NotificationOutbox, error) {
+	entry, err := jm.outboxQueryService.Retry(id, realmID)
+	if err != nil {
+		return nil, err
+	}
+	jm.logger.Infof("Outbox entry %s requeued by %s", id, requestedBy)
+	return entry, nil
+}
@@ -0,0 +1,216 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/webhook"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, outboxBackoff(30*time.Second, 1))
+	assert.Equal(t, 60*time.Second, outboxBackoff(30*time.Second, 2))
+	assert.Equal(t, 4*time.Minute, outboxBackoff(30*time.Second, 4))
+	assert.Equal(t, maxOutboxBackoff, outboxBackoff(30*time.Second, 20))
+}
+
+func TestOutboxEntries(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	h := &TaskRemindHandler{jobManager: &JobManager{logger: logger, webhookSender: webhook.NewSender(nil, logger)}}
+	reminder := &models.Reminder{
+		ID:            "r1",
+		RealmID:       "realm",
+		Name:          "Standup",
+		RemindMethods: "email, im,slack,email,fax",
+		RemindTargets: `{"webhooks": [{"channel": "slack", "url": "https://hooks.slack.com/a"}, {"channel": "feishu", "url": "https://open.feishu.cn/b"}]}`,
+	}
+	user := &models.User{ID: "u1", RealmID: "realm", Username: "alice", Email: "alice@example.com"}
+
+	entries, err := h.outboxEntries(reminder, user, "reminder:r1:100")
+	require.NoError(t, err)
+
+	// One entry per target; targets named twice and unknown methods are dropped
+	require.Len(t, entries, 3)
+	assert.Equal(t, "email", entries[0].Method)
+	assert.Equal(t, "reminder:r1:100:email:alice@example.com", entries[0].IdempotencyKey)
+	assert.Equal(t, "alice@example.com", entries[0].Target)
+	assert.Equal(t, "im", entries[1].Method)
+	assert.Equal(t, "slack hooks.slack.com", entries[1].Target)
+	assert.Equal(t, "feishu open.feishu.cn", entries[2].Target)
+	assert.NotEqual(t, entries[1].IdempotencyKey, entries[2].IdempotencyKey)
+	assert.Equal(t, "alice", entries[0].Recipient)
+
+	var payload outboxPayload
+	require.NoError(t, json.Unmarshal([]byte(entries[1].Payload), &payload))
+	assert.Equal(t, "Standup", payload.Reminder.Name)
+	assert.Equal(t, "alice@example.com", payload.User.Email)
+	assert.Equal(t, "https://hooks.slack.com/a", payload.Target.URL)
+}
+
+func newOutboxTestDB(t *testing.T) *gorm.DB {
+	return testutil.NewTestDB(t, &models.Reminder{}, &models.NotificationOutbox{})
+}
+
+func TestClaimNext(t *testing.T) {
+	db := newOutboxTestDB(t)
+	oqs := NewOutboxQueryService(db)
+	now := time.Now()
+	require.NoError(t, enqueueNotifications(db, []*models.NotificationOutbox{
+		{IdempotencyKey: "a", Method: "email", NextAttemptAt: now.Add(-time.Minute)},
+		{IdempotencyKey: "b", Method: "email", NextAttemptAt: now.Add(-time.Second)},
+	}))
+
+	// Each entry is claimed by one dispatcher only
+	first, err := oqs.ClaimNext("one", now, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.Equal(t, "a", first.IdempotencyKey)
+	assert.Equal(t, 1, first.Attempts)
+	second, err := oqs.ClaimNext("two", now, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, second)
+	assert.Equal(t, "b", second.IdempotencyKey)
+	none, err := oqs.ClaimNext("two", now, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	// An entry whose lock expired is claimed again, and the late dispatcher cannot release it
+	reclaimed, err := oqs.ClaimNext("two", now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, reclaimed)
+	assert.Equal(t, 2, reclaimed.Attempts)
+	assert.Error(t, oqs.MarkDelivered(first, now))
+	require.NoError(t, oqs.MarkDelivered(reclaimed, now))
+
+	// Failed entries wait for their next attempt
+	require.NoError(t, oqs.MarkFailed(second, "timeout", now.Add(time.Hour)))
+	none, err = oqs.ClaimNext("one", now.Add(3*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestOutboxRealmScope(t *testing.T) {
+	db := newOutboxTestDB(t)
+	oqs := NewOutboxQueryService(db)
+	require.NoError(t, enqueueNotifications(db, []*models.NotificationOutbox{
+		{ID: "a1", RealmID: "a", IdempotencyKey: "a1", Method: "email"},
+		{ID: "b1", RealmID: "b", IdempotencyKey: "b1", Method: "email"},
+		{ID: "b2", RealmID: "b", IdempotencyKey: "b2", Method: "email"},
+	}))
+	require.NoError(t, db.Model(&models.NotificationOutbox{}).Where("id IN ?", []string{"a1", "b1"}).
+		Update("status", models.OutboxStatusDead).Error)
+
+	entries, total, err := oqs.List(OutboxParams{RealmID: "a"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a1", entries[0].ID)
+	_, total, err = oqs.List(OutboxParams{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+
+	counts, err := oqs.CountByStatus("b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{models.OutboxStatusDead: 1, models.OutboxStatusPending: 1}, counts)
+
+	// Entries of other realms cannot be requeued
+	_, err = oqs.Retry("b1", "a")
+	assert.ErrorIs(t, err, ErrOutboxEntryNotFound)
+	entry, err := oqs.Retry("b1", "b")
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxStatusPending, entry.Status)
+}
+
+func TestDeliverOutboxEntry_EndpointDisabled(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.NotificationOutbox{}, &models.WebhookEndpoint{}, &models.WebhookDelivery{})
+	logger := zap.NewNop().Sugar()
+	jm := &JobManager{
+		logger:             logger,
+		webhookSender:      webhook.NewSender(db, logger),
+		outboxQueryService: NewOutboxQueryService(db),
+		outbox:             outboxSettings{maxAttempts: 8, initialBackoff: time.Minute},
+	}
+	endpoint := &models.WebhookEndpoint{ID: "e1", RealmID: "realm", UserID: "u1", Name: "ops",
+		Channel: models.WebhookChannelGeneric, URL: "https://hooks.example.com/T0K3N", Secret: "s3cret", Enabled: true}
+	require.NoError(t, db.Create(endpoint).Error)
+	reminder := &models.Reminder{ID: "r1", RealmID: "realm", Name: "Standup", RemindMethods: "webhook"}
+	user := &models.User{ID: "u1", RealmID: "realm", Username: "alice"}
+
+	h := &TaskRemindHandler{jobManager: jm}
+	entries, err := h.outboxEntries(reminder, user, "reminder:r1:100")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "webhook hooks.example.com", entries[0].Target)
+	assert.Equal(t, "reminder:r1:100:webhook:endpoint:e1", entries[0].IdempotencyKey)
+
+	// The endpoint is kept by ID, so its URL and secret are not copied into the outbox
+	var payload outboxPayload
+	require.NoError(t, json.Unmarshal([]byte(entries[0].Payload), &payload))
+	assert.Equal(t, "e1", payload.Target.EndpointID)
+	assert.Empty(t, payload.Target.URL)
+	assert.Empty(t, payload.Target.Secret)
+	assert.NotContains(t, entries[0].Payload, "s3cret")
+
+	// Once the endpoint is turned off, its queued entries are given up at once
+	require.NoError(t, enqueueNotifications(db, entries))
+	require.NoError(t, db.Model(endpoint).Update("enabled", false).Error)
+	entry, err := jm.outboxQueryService.ClaimNext("one", time.Now(), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.False(t, jm.deliverOutboxEntry(entry))
+
+	var stored models.NotificationOutbox
+	require.NoError(t, db.First(&stored, "id = ?", entry.ID).Error)
+	assert.Equal(t, models.OutboxStatusDead, stored.Status)
+	assert.Contains(t, stored.LastError, "deleted or disabled")
+	var deliveries int64
+	require.NoError(t, db.Model(&models.WebhookDelivery{}).Count(&deliveries).Error)
+	assert.Zero(t, deliveries)
+}
+
+func TestDeliverOutboxEntry_RedactsURL(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.NotificationOutbox{}, &models.WebhookDelivery{})
+	logger := zap.NewNop().Sugar()
+	chat, err := notifier.NewNotifier()
+	require.NoError(t, err)
+	jm := &JobManager{
+		logger:             logger,
+		webhookSender:      webhook.NewSender(db, logger),
+		chatNotifier:       chat,
+		outboxQueryService: NewOutboxQueryService(db),
+		outbox:             outboxSettings{maxAttempts: 8, initialBackoff: time.Minute},
+	}
+	// The host does not resolve, so the requests fail with an error that carries the URL
+	reminder := &models.Reminder{ID: "r1", RealmID: "realm", Name: "Standup", RemindMethods: "webhook,dingtalk",
+		RemindTargets: `{"webhooks": [
+			{"url": "https://hooks.example.invalid/services/T0K3N?key=s3cret"},
+			{"channel": "dingtalk", "url": "https://oapi.example.invalid/robot/send?access_token=s3cret"}
+		]}`}
+	user := &models.User{ID: "u1", RealmID: "realm", Username: "alice"}
+	entries, err := (&TaskRemindHandler{jobManager: jm}).outboxEntries(reminder, user, "reminder:r1:100")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, enqueueNotifications(db, entries))
+
+	for range entries {
+		entry, err := jm.outboxQueryService.ClaimNext("one", time.Now(), time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		assert.False(t, jm.deliverOutboxEntry(entry))
+
+		var stored models.NotificationOutbox
+		require.NoError(t, db.First(&stored, "id = ?", entry.ID).Error)
+		assert.Contains(t, stored.LastError, "example.invalid")
+		assert.NotContains(t, stored.LastError, "T0K3N")
+		assert.NotContains(t, stored.LastError, "s3cret")
+	}
+}
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"errors"
 	"time"
 
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
@@ -20,18 +21,100 @@ func NewReminderQueryService(db *gorm.DB) *ReminderQueryService {
 	}
 }
 
-// FindDueReminders retrieves reminders that are due for processing
+// FindDueReminders retrieves reminders that are due and have not been sent yet, leaving out
+// those held back by a notification preference until later
 func (rqs *ReminderQueryService) FindDueReminders(beforeTime time.Time) ([]*models.Reminder, error) {
 	var reminders []*models.Reminder
 	// Convert beforeTime to UTC for consistent database queries
 	//beforeTimeUTC := beforeTime.UTC()
-	err := rqs.db.Where("status != ? AND remind_time <= ?", "completed", beforeTime).Find(&reminders).Error
+	err := rqs.db.Where("status = ? AND remind_time <= ? AND (deliver_after IS NULL OR deliver_after <= ?)",
+		"pending", beforeTime, beforeTime).Find(&reminders).Error
 	if err != nil {
 		return nil, err
 	}
 	return reminders, nil
 }
 
+// FindUnacknowledgedReminders retrieves sent reminders that have escalation steps and are
+// still waiting to be acknowledged
+func (rqs *ReminderQueryService) FindUnacknowledgedReminders() ([]*models.Reminder, error) {
+	var reminders []*models.Reminder
+	err := rqs.db.Where("status = ? AND escalation IS NOT NULL AND escalation != ''", "active").Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// errReminderChanged rolls back a transaction when a reminder was changed in the meantime
+var errReminderChanged = errors.New("reminder was changed")
+
+// NotifyReminders marks pending reminders as active until they are acknowledged and queues
+// their notifications, in one transaction. It returns false, and changes nothing, when one of
+// the reminders was changed in the meantime.
+func (rqs *ReminderQueryService) NotifyReminders(reminders []*models.Reminder, notifiedAt time.Time, entries []*models.NotificationOutbox) (bool, error) {
+	err := rqs.db.Transaction(func(tx *gorm.DB) error {
+		for _, reminder := range reminders {
+			result := tx.Model(&models.Reminder{}).
+				Where("id = ? AND status = ?", reminder.ID, "pending").
+				Updates(map[string]interface{}{
+					"status":      "active",
+					"notified_at": notifiedAt,
+					"escalated":   0,
+					"updated_by":  reminder.CreatedBy,
+					"updated_at":  time.Now(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errReminderChanged
+			}
+		}
+		return enqueueNotifications(tx, entries)
+	})
+	if errors.Is(err, errReminderChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, reminder := range reminders {
+		reminder.Status = "active"
+		reminder.NotifiedAt = &notifiedAt
+		reminder.Escalated = 0
+	}
+	return true, nil
+}
+
+// EscalateReminder records that the next escalation step of an active reminder was taken and
+// queues its notifications, in one transaction. It returns false when the step was already taken.
+func (rqs *ReminderQueryService) EscalateReminder(reminder *models.Reminder, entries []*models.NotificationOutbox) (bool, error) {
+	err := rqs.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Reminder{}).
+			Where("id = ? AND status = ? AND escalated = ?", reminder.ID, "active", reminder.Escalated).
+			Updates(map[string]interface{}{
+				"escalated":  reminder.Escalated + 1,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errReminderChanged
+		}
+		return enqueueNotifications(tx, entries)
+	})
+	if errors.Is(err, errReminderChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	reminder.Escalated++
+	return true, nil
+}
+
 // UpdateReminderStatus updates the status of a reminder
 func (rqs *ReminderQueryService) UpdateReminderStatus(reminder *models.Reminder, status string, updatedBy string) error {
 	reminder.Status = status
@@ -40,6 +123,36 @@ func (rqs *ReminderQueryService) UpdateReminderStatus(reminder *models.Reminder,
 	return rqs.db.Save(reminder).Error
 }
 
+// DeferReminder holds a pending reminder back until a later time; its remind time stays as it
+// is. It returns false when the reminder was changed in the meantime.
+func (rqs *ReminderQueryService) DeferReminder(reminder *models.Reminder, until time.Time) (bool, error) {
+	result := rqs.db.Model(&models.Reminder{}).
+		Where("id = ? AND status = ?", reminder.ID, "pending").
+		Updates(map[string]interface{}{
+			"deliver_after": until,
+			"updated_at":    time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	reminder.DeliverAfter = &until
+	return result.RowsAffected > 0, nil
+}
+
+// GetNotificationPreference retrieves the notification preference of a user, or nil if the
+// user has none
+func (rqs *ReminderQueryService) GetNotificationPreference(userID string) (*models.NotificationPreference, error) {
+	var pref models.NotificationPreference
+	err := rqs.db.Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
 // GetUserByID retrieves a user by ID
 func (rqs *ReminderQueryService) GetUserByID(userID string) (*models.User, error) {
 	var user models.User
@@ -50,6 +163,29 @@ func (rqs *ReminderQueryService) GetUserByID(userID string) (*models.User, error
 	return &user, nil
 }
 
+// GetUserByUsername retrieves a user of a realm by username
+func (rqs *ReminderQueryService) GetUserByUsername(realmID, username string) (*models.User, error) {
+	var user models.User
+	err := rqs.db.Where("realm_id = ? AND username = ?", realmID, username).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetRealmAdmins retrieves the users of a realm with the admin or super_admin role
+func (rqs *ReminderQueryService) GetRealmAdmins(realmID string) ([]*models.User, error) {
+	admins := rqs.db.Table("user_roles").Select("user_roles.user_id").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("roles.name IN ? AND roles.deleted_at IS NULL", []string{"admin", "super_admin"})
+	var users []*models.User
+	err := rqs.db.Where("realm_id = ? AND id IN (?)", realmID, admins).Order("username").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // CreateReminder creates a new reminder
 func (rqs *ReminderQueryService) CreateReminder(reminder *models.Reminder) error {
 	return rqs.db.Create(reminder).Error
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/webhook"
+)
+
+func TestDeferReminder(t *testing.T) {
+	db := newOutboxTestDB(t)
+	rqs := NewReminderQueryService(db)
+	remindAt := time.Date(2025, 3, 10, 23, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 3, 11, 7, 30, 0, 0, time.UTC)
+	reminder := &models.Reminder{ID: "r1", RealmID: "realm", Name: "Backup", Content: "Check the backup",
+		RemindTime: remindAt, Status: "pending", RemindMethods: "email", CreatedBy: "u1"}
+	require.NoError(t, db.Create(reminder).Error)
+
+	deferred, err := rqs.DeferReminder(reminder, until)
+	require.NoError(t, err)
+	assert.True(t, deferred)
+
+	// The reminder is held back until then, and keeps its remind time
+	due, err := rqs.FindDueReminders(remindAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	due, err = rqs.FindDueReminders(until)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.True(t, due[0].RemindTime.Equal(remindAt))
+
+	// A digest lists the reminder at its remind time, not at the time it was held back until
+	logger := zap.NewNop().Sugar()
+	h := &TaskRemindHandler{jobManager: &JobManager{logger: logger, webhookSender: webhook.NewSender(nil, logger), reminderQueryService: rqs}}
+	user := &models.User{ID: "u1", RealmID: "realm", Username: "alice", Email: "alice@example.com"}
+	pref := &models.NotificationPreference{Timezone: "UTC", Channels: "email"}
+	require.NoError(t, h.sendDigest(&reminderDigest{user: user, pref: pref, reminders: due}, until))
+
+	var entry models.NotificationOutbox
+	require.NoError(t, db.First(&entry).Error)
+	var payload outboxPayload
+	require.NoError(t, json.Unmarshal([]byte(entry.Payload), &payload))
+	assert.Contains(t, payload.Reminder.Content, "Backup (2025-03-10 23:00)")
+}
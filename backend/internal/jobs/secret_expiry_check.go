@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+// defaultSecretRemindDaysBefore is how many days ahead of a secret's deadline its owner is reminded
+const defaultSecretRemindDaysBefore = 7
+
+// checkSecretExpiry creates email reminders for secrets that expire or are due for rotation soon.
+// Each deadline is reminded once; rotating the secret or changing its expiry moves the deadline
+// and re-arms the reminder.
+func (jm *JobManager) checkSecretExpiry() {
+	if jm.secretQueryService == nil || jm.reminderQueryService == nil {
+		jm.logger.Warn("Secret or reminder query service not initialized, skipping secret expiry check")
+		return
+	}
+
+	jm.logger.Debug("Checking for expiring secrets...")
+
+	secrets, err := jm.secretQueryService.FindSecretsWithExpiryPolicy()
+	if err != nil {
+		jm.logger.Errorf("Failed to fetch secrets with expiry policy: %v", err)
+		return
+	}
+
+	remindDays := viper.GetInt("secret.expiry.remind_days_before")
+	if remindDays <= 0 {
+		remindDays = defaultSecretRemindDaysBefore
+	}
+	now := time.Now()
+	horizon := now.AddDate(0, 0, remindDays)
+
+	createdCount := 0
+	for _, secret := range secrets {
+		deadline := secret.NextDeadline()
+		if deadline == nil || deadline.After(horizon) {
+			continue
+		}
+		if secret.ExpiryRemindedFor != nil && secret.ExpiryRemindedFor.Sub(*deadline).Abs() < time.Second {
+			continue
+		}
+
+		if err := jm.createSecretExpiryReminder(secret, *deadline, now); err != nil {
+			jm.logger.Errorf("Failed to create expiry reminder for secret %s (%s): %v", secret.ID, secret.Name, err)
+			continue
+		}
+		createdCount++
+	}
+
+	if createdCount > 0 {
+		jm.logger.Infof("Created %d secret expiry reminders", createdCount)
+	}
+}
+
+// createSecretExpiryReminder creates a due email reminder for the owner of a secret. When the
+// owner is no longer a user of the realm, the realm admins are reminded instead; when there is
+// nobody to remind, the deadline is marked as reminded so that it is not retried every hour.
+func (jm *JobManager) createSecretExpiryReminder(secret *models.Secret, deadline, now time.Time) error {
+	recipients := make([]*models.User, 0, 1)
+	owner, err := jm.reminderQueryService.GetUserByUsername(secret.RealmID, secret.CreatedBy)
+	switch {
+	case err == nil:
+		recipients = append(recipients, owner)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		admins, err := jm.reminderQueryService.GetRealmAdmins(secret.RealmID)
+		if err != nil {
+			return fmt.Errorf("owner %s not found, failed to find realm admins: %w", secret.CreatedBy, err)
+		}
+		recipients = append(recipients, admins...)
+	default:
+		return fmt.Errorf("failed to find owner %s: %w", secret.CreatedBy, err)
+	}
+	if len(recipients) == 0 {
+		jm.logger.Errorf("Secret %s (%s) reaches its deadline %s, but neither its owner %s nor a realm admin can be reminded",
+			secret.ID, secret.Name, deadline.Format(time.RFC3339), secret.CreatedBy)
+		if err := jm.secretQueryService.MarkExpiryReminded(secret, deadline); err != nil {
+			return fmt.Errorf("failed to mark secret as reminded: %w", err)
+		}
+		return nil
+	}
+
+	overdue := !deadline.After(now)
+	var name string
+	switch {
+	case secret.ExpiresAt != nil && secret.ExpiresAt.Equal(deadline) && overdue:
+		name = fmt.Sprintf("Secret %s has expired", secret.Name)
+	case secret.ExpiresAt != nil && secret.ExpiresAt.Equal(deadline):
+		name = fmt.Sprintf("Secret %s expires soon", secret.Name)
+	case overdue:
+		name = fmt.Sprintf("Secret %s is overdue for rotation", secret.Name)
+	default:
+		name = fmt.Sprintf("Secret %s is due for rotation", secret.Name)
+	}
+
+	content := jm.formatSecretExpiryContent(secret, deadline, owner != nil)
+	for _, recipient := range recipients {
+		reminder := &models.Reminder{
+			ID:            uuid.NewString(),
+			RealmID:       secret.RealmID,
+			Name:          name,
+			Content:       content,
+			RemindTime:    now,
+			Status:        "pending",
+			Tags:          "secret,auto-generated,secret-expiry",
+			RemindMethods: "email",
+			RemindTargets: recipient.Email,
+			CreatedBy:     recipient.ID,
+			CreatedAt:     now,
+			UpdatedBy:     recipient.ID,
+			UpdatedAt:     now,
+		}
+
+		if err := jm.reminderQueryService.CreateReminder(reminder); err != nil {
+			return fmt.Errorf("failed to create reminder: %w", err)
+		}
+		jm.logger.Infof("Created expiry reminder %s for secret %s to %s", reminder.ID, secret.ID, recipient.Username)
+	}
+	if err := jm.secretQueryService.MarkExpiryReminded(secret, deadline); err != nil {
+		return fmt.Errorf("failed to mark secret as reminded: %w", err)
+	}
+	return nil
+}
+
+// formatSecretExpiryContent creates formatted content for secret expiry reminders, for the owner
+// of the secret or for the realm admins when the owner is gone
+func (jm *JobManager) formatSecretExpiryContent(secret *models.Secret, deadline time.Time, toOwner bool) string {
+	intro := "A secret you own needs attention:"
+	if !toOwner {
+		intro = fmt.Sprintf("A secret of %s, who is no longer a user of this realm, needs attention:", secret.CreatedBy)
+	}
+	content := fmt.Sprintf(`%s
+
+🔑 Secret: %s
+📁 Group: %s
+📍 Path: %s
+🔢 Current Version: %d
+📅 Deadline: %s`,
+		intro,
+		secret.Name,
+		secret.Group,
+		secret.Path,
+		secret.CurrentVersion,
+		deadline.Format("2006-01-02 15:04:05"))
+
+	if secret.ExpiresAt != nil {
+		content += fmt.Sprintf("\n⌛ Expires: %s", secret.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	if due := secret.RotationDueAt(); due != nil {
+		content += fmt.Sprintf("\n🔄 Rotation due: %s (every %d days)", due.Format("2006-01-02 15:04:05"), secret.RotationIntervalDays)
+	}
+
+	content += "\n\nPlease rotate the secret and update its expiry.\n\n---\nGenerated automatically by Lazy Rabbit Secretary System"
+
+	return content
+}
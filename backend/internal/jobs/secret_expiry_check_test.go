@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func TestCreateSecretExpiryReminder(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Role{}, &models.UserRole{}, &models.Secret{}, &models.Reminder{})
+	jm := &JobManager{
+		logger:               zap.NewNop().Sugar(),
+		reminderQueryService: NewReminderQueryService(db),
+		secretQueryService:   NewSecretQueryService(db),
+	}
+	now := time.Now()
+	deadline := now.Add(48 * time.Hour)
+	reminded := func(secret *models.Secret) []models.Reminder {
+		var reminders []models.Reminder
+		require.NoError(t, db.Where("name LIKE ?", "Secret "+secret.Name+" %").Order("created_by").Find(&reminders).Error)
+		var stored models.Secret
+		require.NoError(t, db.First(&stored, "id = ?", secret.ID).Error)
+		require.NotNil(t, stored.ExpiryRemindedFor)
+		assert.WithinDuration(t, deadline, *stored.ExpiryRemindedFor, time.Second)
+		return reminders
+	}
+
+	// The owner of a secret in a realm without admins is gone: nobody is reminded, but the
+	// deadline is not retried either
+	orphan := &models.Secret{ID: "s1", RealmID: "other", Name: "orphan", CreatedBy: "bob", ExpiresAt: &deadline}
+	require.NoError(t, db.Create(orphan).Error)
+	require.NoError(t, jm.createSecretExpiryReminder(orphan, deadline, now))
+	assert.Empty(t, reminded(orphan))
+
+	// The realm admins are reminded instead of the owner that is gone
+	require.NoError(t, db.Create(&models.User{ID: "u1", RealmID: "realm", Username: "alice", Email: "alice@example.com"}).Error)
+	require.NoError(t, db.Create(&models.User{ID: "u2", RealmID: "realm", Username: "carol", Email: "carol@example.com"}).Error)
+	require.NoError(t, db.Create(&models.Role{ID: "r1", RealmID: "realm", Name: "admin"}).Error)
+	require.NoError(t, db.Create(&models.UserRole{UserID: "u1", RoleID: "r1"}).Error)
+	secret := &models.Secret{ID: "s2", RealmID: "realm", Name: "token", CreatedBy: "bob", ExpiresAt: &deadline}
+	require.NoError(t, db.Create(secret).Error)
+	require.NoError(t, jm.createSecretExpiryReminder(secret, deadline, now))
+	reminders := reminded(secret)
+	require.Len(t, reminders, 1)
+	assert.Equal(t, "u1", reminders[0].CreatedBy)
+	assert.Contains(t, reminders[0].Content, "A secret of bob")
+
+	// The owner is reminded while they exist
+	owned := &models.Secret{ID: "s3", RealmID: "realm", Name: "key", CreatedBy: "carol", ExpiresAt: &deadline}
+	require.NoError(t, db.Create(owned).Error)
+	require.NoError(t, jm.createSecretExpiryReminder(owned, deadline, now))
+	reminders = reminded(owned)
+	require.Len(t, reminders, 1)
+	assert.Equal(t, "u2", reminders[0].CreatedBy)
+	assert.Contains(t, reminders[0].Content, "A secret you own")
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+// SecretQueryService handles database queries for secret expiry and rotation checks
+type SecretQueryService struct {
+	db *gorm.DB
+}
+
+// NewSecretQueryService creates a new secret query service
+func NewSecretQueryService(db *gorm.DB) *SecretQueryService {
+	return &SecretQueryService{
+		db: db,
+	}
+}
+
+// FindSecretsWithExpiryPolicy retrieves secrets that have an expiry time or a rotation interval
+func (sqs *SecretQueryService) FindSecretsWithExpiryPolicy() ([]*models.Secret, error) {
+	var secrets []*models.Secret
+	err := sqs.db.Where("expires_at IS NOT NULL OR rotation_interval_days > 0").Find(&secrets).Error
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// MarkExpiryReminded records the deadline an expiry reminder was created for
+func (sqs *SecretQueryService) MarkExpiryReminded(secret *models.Secret, deadline time.Time) error {
+	secret.ExpiryRemindedFor = &deadline
+	return sqs.db.Model(&models.Secret{}).Where("id = ?", secret.ID).
+		UpdateColumn("expiry_reminded_for", deadline).Error
+}
@@ -14,6 +14,11 @@ type TaskCheckHandler struct {
 	jobManager *JobManager
 }
 
+// withJobManager returns the handler bound to jm
+func (h *TaskCheckHandler) withJobManager(jm *JobManager) JobHandler {
+	return &TaskCheckHandler{jobManager: jm}
+}
+
 // Execute checks tasks for reminder generation
 func (h *TaskCheckHandler) Execute(params string) error {
 	if h.jobManager.taskQueryService == nil {
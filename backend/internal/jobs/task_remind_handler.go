@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/preference"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/webhook"
 )
 
 // TaskRemindHandler implements JobHandler for processing reminders
@@ -13,6 +16,11 @@ type TaskRemindHandler struct {
 	jobManager *JobManager
 }
 
+// withJobManager returns the handler bound to jm
+func (h *TaskRemindHandler) withJobManager(jm *JobManager) JobHandler {
+	return &TaskRemindHandler{jobManager: jm}
+}
+
 // Execute processes due reminders and sends notifications
 func (h *TaskRemindHandler) Execute(params string) error {
 	if h.jobManager.reminderQueryService == nil {
@@ -22,12 +30,10 @@ func (h *TaskRemindHandler) Execute(params string) error {
 
 	h.jobManager.logger.Info("Processing due reminders...")
 
-	// This will delegate to the existing checkReminders functionality in JobManager
-	// which is already implemented and working
 	return h.remindTask()
 }
 
-// remindTask processes due reminders with proper implementation
+// remindTask sends due reminders and escalates the ones that were not acknowledged in time
 func (h *TaskRemindHandler) remindTask() error {
 	h.jobManager.logger.Info("Checking and processing due reminders...")
 
@@ -36,6 +42,13 @@ func (h *TaskRemindHandler) remindTask() error {
 		return fmt.Errorf("reminder query service not initialized")
 	}
 
+	// Deliver what gets queued right away, without waiting for the dispatcher
+	defer func() {
+		if err := h.jobManager.dispatchNotifications(); err != nil {
+			h.jobManager.logger.Errorf("Failed to dispatch notifications: %v", err)
+		}
+	}()
+
 	// Find due reminders
 	now := time.Now()
 	dueReminders, err := h.jobManager.reminderQueryService.FindDueReminders(now)
@@ -46,27 +59,138 @@ func (h *TaskRemindHandler) remindTask() error {
 
 	if len(dueReminders) == 0 {
 		h.jobManager.logger.Info("No due reminders found")
-		return nil
+		return h.escalateReminders(now)
 	}
 
 	h.jobManager.logger.Infof("Found %d due reminders to process", len(dueReminders))
 
-	// Process each reminder
+	// Process each reminder; reminders for digests are collected per user and sent afterwards
 	successCount := 0
+	digests := make(map[string]*reminderDigest)
 	for _, reminder := range dueReminders {
-		if err := h.processReminder(reminder); err != nil {
+		if err := h.processReminder(reminder, now, digests); err != nil {
 			h.jobManager.logger.Errorf("Failed to process reminder %s (%s): %v", reminder.ID, reminder.Name, err)
 		} else {
 			successCount++
 		}
 	}
+	for _, digest := range digests {
+		if err := h.sendDigest(digest, now); err != nil {
+			h.jobManager.logger.Errorf("Failed to send reminder digest to %s: %v", digest.user.Username, err)
+		}
+	}
 
 	h.jobManager.logger.Infof("Successfully processed %d/%d reminders", successCount, len(dueReminders))
+	return h.escalateReminders(now)
+}
+
+// reminderDigest collects the due reminders of a user in daily digest mode
+type reminderDigest struct {
+	user      *models.User
+	pref      *models.NotificationPreference
+	reminders []*models.Reminder
+}
+
+// escalateReminders sends the due escalation steps of reminders that were not acknowledged
+func (h *TaskRemindHandler) escalateReminders(now time.Time) error {
+	reminders, err := h.jobManager.reminderQueryService.FindUnacknowledgedReminders()
+	if err != nil {
+		h.jobManager.logger.Errorf("Failed to fetch unacknowledged reminders: %v", err)
+		return fmt.Errorf("failed to fetch unacknowledged reminders: %w", err)
+	}
+
+	for _, reminder := range reminders {
+		step, due := reminder.NextEscalation(now)
+		if !due {
+			continue
+		}
+		if err := h.escalateReminder(reminder, step); err != nil {
+			h.jobManager.logger.Errorf("Failed to escalate reminder %s (%s): %v", reminder.ID, reminder.Name, err)
+		}
+	}
 	return nil
 }
 
-// processReminder handles a single reminder: sends notifications and updates status
-func (h *TaskRemindHandler) processReminder(reminder *models.Reminder) error {
+// escalateReminder queues the notifications of an escalation step, through its methods and to
+// its targets, together with taking the step
+func (h *TaskRemindHandler) escalateReminder(reminder *models.Reminder, step *models.EscalationStep) error {
+	escalated := *reminder
+	escalated.Escalated = reminder.Escalated + 1
+	escalated.Name = fmt.Sprintf("[Escalation %d] %s", escalated.Escalated, reminder.Name)
+	if step.Methods != "" {
+		escalated.RemindMethods = step.Methods
+	}
+	if step.Targets != "" {
+		escalated.RemindTargets = step.Targets
+	}
+
+	users, recipientErr := h.escalationRecipients(reminder, step.Targets)
+	var entries []*models.NotificationOutbox
+	for _, user := range users {
+		recipient := user.ID
+		if recipient == "" {
+			recipient = user.Email
+		}
+		keyPrefix := fmt.Sprintf("escalation:%s:%d:%d:%s", reminder.ID, reminder.RemindTime.Unix(), escalated.Escalated, recipient)
+		userEntries, err := h.outboxEntries(&escalated, user, keyPrefix)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, userEntries...)
+	}
+
+	// The step is taken even when some targets are unknown, so that it is not retried forever
+	claimed, err := h.jobManager.reminderQueryService.EscalateReminder(reminder, entries)
+	if err != nil {
+		return fmt.Errorf("failed to escalate reminder: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+	h.jobManager.logger.Infof("Escalating reminder %s (step %d) via %s", reminder.ID, reminder.Escalated, escalated.RemindMethods)
+	return recipientErr
+}
+
+// escalationRecipients resolves the targets of an escalation step: email addresses are used as
+// they are, other targets are usernames of the reminder's realm. Without targets the creator of
+// the reminder is notified again.
+func (h *TaskRemindHandler) escalationRecipients(reminder *models.Reminder, targets string) ([]*models.User, error) {
+	if strings.TrimSpace(targets) == "" {
+		user, err := h.jobManager.reminderQueryService.GetUserByID(reminder.CreatedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user info for reminder %s: %w", reminder.ID, err)
+		}
+		return []*models.User{user}, nil
+	}
+
+	var users []*models.User
+	var missing []string
+	for _, target := range strings.Split(targets, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		if strings.Contains(target, "@") {
+			users = append(users, &models.User{Username: target, Email: target})
+			continue
+		}
+		user, err := h.jobManager.reminderQueryService.GetUserByUsername(reminder.RealmID, target)
+		if err != nil {
+			missing = append(missing, target)
+			continue
+		}
+		users = append(users, user)
+	}
+	if len(missing) > 0 {
+		return users, fmt.Errorf("unknown escalation targets: %s", strings.Join(missing, ", "))
+	}
+	return users, nil
+}
+
+// processReminder handles a single reminder: applies the user's notification preference, sends
+// notifications and updates status. Reminders held back by the preference are deferred or
+// added to the user's digest.
+func (h *TaskRemindHandler) processReminder(reminder *models.Reminder, now time.Time, digests map[string]*reminderDigest) error {
 	h.jobManager.logger.Infof("Processing reminder: %s - %s", reminder.ID, reminder.Name)
 
 	// Get user information using query service
@@ -74,48 +198,178 @@ func (h *TaskRemindHandler) processReminder(reminder *models.Reminder) error {
 	if err != nil {
 		return fmt.Errorf("failed to get user info for reminder %s: %w", reminder.ID, err)
 	}
+	pref, err := h.jobManager.reminderQueryService.GetNotificationPreference(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get notification preference of %s: %w", user.Username, err)
+	}
+
+	delivery := preference.Decide(pref, reminder, now)
+	switch delivery.Action {
+	case preference.Defer:
+		if _, err := h.jobManager.reminderQueryService.DeferReminder(reminder, delivery.At); err != nil {
+			return fmt.Errorf("failed to defer reminder %s: %w", reminder.ID, err)
+		}
+		h.jobManager.logger.Infof("Deferred reminder %s of %s until %s", reminder.ID, user.Username, delivery.At.Format(time.RFC3339))
+		return nil
+	case preference.Digest:
+		digest, ok := digests[user.ID]
+		if !ok {
+			digest = &reminderDigest{user: user, pref: pref}
+			digests[user.ID] = digest
+		}
+		digest.reminders = append(digest.reminders, reminder)
+		return nil
+	}
+
+	// Queue the notifications together with the status change, so that they are sent once
+	toSend := *reminder
+	toSend.RemindMethods = delivery.Methods
+	keyPrefix := fmt.Sprintf("reminder:%s:%d", reminder.ID, reminder.RemindTime.Unix())
+	entries, err := h.outboxEntries(&toSend, user, keyPrefix)
+	if err != nil {
+		return err
+	}
+	// The reminder stays active until it is acknowledged, or escalated if it is not
+	return h.notify([]*models.Reminder{reminder}, entries)
+}
+
+// sendDigest queues the collected reminders of a user as one notification
+func (h *TaskRemindHandler) sendDigest(digest *reminderDigest, now time.Time) error {
+	loc := digest.pref.Location()
+	var lines []string
+	for _, reminder := range digest.reminders {
+		lines = append(lines, fmt.Sprintf("- %s (%s)\n  %s", reminder.Name, reminder.RemindTime.In(loc).Format("2006-01-02 15:04"), reminder.Content))
+	}
+	methods := digest.pref.Channels
+	if strings.TrimSpace(methods) == "" {
+		methods = digest.reminders[0].RemindMethods
+	}
+	combined := &models.Reminder{
+		RealmID:       digest.user.RealmID,
+		Name:          fmt.Sprintf("Digest: %d reminders", len(digest.reminders)),
+		Content:       strings.Join(lines, "\n"),
+		RemindTime:    now,
+		Status:        "pending",
+		RemindMethods: methods,
+		CreatedBy:     digest.user.ID,
+	}
 
-	// Process different reminder methods
-	if err := h.sendNotifications(reminder, user); err != nil {
-		h.jobManager.logger.Errorf("Failed to send notifications for reminder %s: %v", reminder.ID, err)
-		// Continue to mark as processed even if notifications fail
-		return fmt.Errorf("failed to send notifications for reminder %s: %w", reminder.ID, err)
-	} else {
-		h.jobManager.logger.Infof("Successfully sent notifications for reminder %s", reminder.ID)
-		// Update reminder status to 'active' (updated by the original creator)
-		return h.jobManager.reminderQueryService.UpdateReminderStatus(reminder, "completed", reminder.CreatedBy)
+	h.jobManager.logger.Infof("Queueing digest of %d reminders for %s", len(digest.reminders), digest.user.Username)
+	keyPrefix := fmt.Sprintf("digest:%s:%d", digest.user.ID, now.Unix())
+	entries, err := h.outboxEntries(combined, digest.user, keyPrefix)
+	if err != nil {
+		return err
 	}
+	return h.notify(digest.reminders, entries)
 }
 
-// sendNotifications sends notifications based on reminder methods
-func (h *TaskRemindHandler) sendNotifications(reminder *models.Reminder, user *models.User) error {
-	methods := strings.Split(reminder.RemindMethods, ",")
-	var errors []string
+// notify marks sent reminders as active until they are acknowledged and queues their notifications
+func (h *TaskRemindHandler) notify(reminders []*models.Reminder, entries []*models.NotificationOutbox) error {
+	updated, err := h.jobManager.reminderQueryService.NotifyReminders(reminders, time.Now(), entries)
+	if err != nil {
+		return fmt.Errorf("failed to update reminder status: %w", err)
+	}
+	if !updated {
+		h.jobManager.logger.Warnf("Reminder %s was changed while it was being sent", reminders[0].ID)
+	}
+	return nil
+}
 
-	for _, method := range methods {
+// outboxEntries returns an outbox entry for each target of each known method of a reminder:
+// the user's email address, or a webhook or chat app. The idempotency key of an entry is
+// keyPrefix and its target, so that a failing target is retried without resending to the others.
+func (h *TaskRemindHandler) outboxEntries(reminder *models.Reminder, user *models.User, keyPrefix string) ([]*models.NotificationOutbox, error) {
+	var entries []*models.NotificationOutbox
+	seen := make(map[string]bool)
+	for _, method := range strings.Split(reminder.RemindMethods, ",") {
 		method = strings.TrimSpace(method)
-		switch method {
-		case "email":
-			if err := h.sendEmailNotification(reminder, user); err != nil {
-				errors = append(errors, fmt.Sprintf("email: %v", err))
-			}
-		case "webhook":
-			if err := h.sendWebhookNotification(reminder, user); err != nil {
-				errors = append(errors, fmt.Sprintf("webhook: %v", err))
+		if method == "" {
+			continue
+		}
+		if !notifier.IsMethod(method) {
+			h.jobManager.logger.Warnf("Unknown reminder method: %s", method)
+			continue
+		}
+		targets, err := h.methodTargets(reminder, user, method)
+		if err != nil {
+			return nil, err
+		}
+		if len(targets) == 0 {
+			h.jobManager.logger.Warnf("No %s target for reminder %s of %s, skipping it", method, reminder.ID, user.Username)
+			continue
+		}
+		for _, target := range targets {
+			key := keyPrefix + ":" + target.key()
+			if seen[key] {
+				continue
 			}
-		case "im", "message":
-			if err := h.sendIMNotification(reminder, user); err != nil {
-				errors = append(errors, fmt.Sprintf("im: %v", err))
+			seen[key] = true
+			entry, err := newOutboxEntry(reminder, user, method, target, key)
+			if err != nil {
+				return nil, err
 			}
-		default:
-			h.jobManager.logger.Warnf("Unknown reminder method: %s", method)
+			entries = append(entries, entry)
 		}
 	}
+	return entries, nil
+}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("notification errors: %s", strings.Join(errors, ", "))
+// methodTargets returns where a reminder is sent through method
+func (h *TaskRemindHandler) methodTargets(reminder *models.Reminder, user *models.User, method string) ([]outboxTarget, error) {
+	if method == "email" {
+		if user.Email == "" {
+			return nil, nil
+		}
+		return []outboxTarget{{Channel: "email", Email: user.Email}}, nil
 	}
-	return nil
+
+	channels := []string{method}
+	switch method {
+	case "webhook":
+		channels = []string{models.WebhookChannelGeneric}
+	case "im", "message":
+		// All chat channels the user has set up
+		channels = notifier.ChannelNames()
+	}
+	if h.jobManager.webhookSender == nil {
+		return nil, nil
+	}
+	webhookTargets, err := h.jobManager.webhookSender.Targets(reminder, user, channels...)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]outboxTarget, 0, len(webhookTargets))
+	for _, target := range webhookTargets {
+		targets = append(targets, newWebhookOutboxTarget(target))
+	}
+	return targets, nil
+}
+
+// sendToTarget sends a reminder to one target. The delivery ID is passed on to webhooks.
+func (h *TaskRemindHandler) sendToTarget(reminder *models.Reminder, user *models.User, target outboxTarget, deliveryID string) error {
+	if target.Channel == "email" {
+		return h.sendEmailNotification(reminder, user)
+	}
+	webhookTarget, err := h.webhookTarget(target, user)
+	if err != nil {
+		return err
+	}
+	if target.Channel == models.WebhookChannelGeneric {
+		return h.sendWebhookNotification(reminder, user, webhookTarget, deliveryID)
+	}
+	return h.sendIMNotification(reminder, user, webhookTarget)
+}
+
+// webhookTarget returns the webhook a target is sent to: the endpoint as it is now, or the URL
+// given in the reminder's targets
+func (h *TaskRemindHandler) webhookTarget(target outboxTarget, user *models.User) (webhook.Target, error) {
+	if target.EndpointID == "" {
+		return webhook.Target{Channel: target.Channel, URL: target.URL, Secret: target.Secret}, nil
+	}
+	if h.jobManager.webhookSender == nil {
+		return webhook.Target{}, fmt.Errorf("webhook sender not available, skipping %s notification", target.Channel)
+	}
+	return h.jobManager.webhookSender.EndpointTarget(user.ID, target.EndpointID)
 }
 
 // sendEmailNotification sends email notification
@@ -138,17 +392,31 @@ func (h *TaskRemindHandler) sendEmailNotification(reminder *models.Reminder, use
 }
 
 // sendWebhookNotification sends webhook notification
-func (h *TaskRemindHandler) sendWebhookNotification(reminder *models.Reminder, user *models.User) error {
-	// TODO: Implement webhook notification
-	h.jobManager.logger.Infof("Webhook notification for reminder %s (not implemented yet)", reminder.ID)
-	return nil
+func (h *TaskRemindHandler) sendWebhookNotification(reminder *models.Reminder, user *models.User, target webhook.Target, deliveryID string) error {
+	if h.jobManager.webhookSender == nil {
+		return fmt.Errorf("webhook sender not available, skipping webhook notification")
+	}
+	h.jobManager.logger.Infof("Sending webhook notification for reminder %s", reminder.ID)
+	// One attempt, the outbox retries with backoff
+	return h.jobManager.webhookSender.WithMaxAttempts(1).SendReminderTo(target, reminder, user, deliveryID)
 }
 
-// sendIMNotification sends instant message notification
-func (h *TaskRemindHandler) sendIMNotification(reminder *models.Reminder, user *models.User) error {
-	// TODO: Implement IM notification
-	h.jobManager.logger.Infof("IM notification for reminder %s (not implemented yet)", reminder.ID)
-	return nil
+// sendIMNotification sends a chat message through the incoming webhook of a chat app
+func (h *TaskRemindHandler) sendIMNotification(reminder *models.Reminder, user *models.User, target webhook.Target) error {
+	if h.jobManager.chatNotifier == nil {
+		return fmt.Errorf("chat notifier not available, skipping chat notification")
+	}
+
+	data := map[string]interface{}{
+		"Name":       reminder.Name,
+		"Content":    reminder.Content,
+		"RemindTime": reminder.RemindTime.Format("2006-01-02 15:04"),
+		"Tags":       reminder.Tags,
+		"Username":   user.Username,
+		"Escalation": reminder.Escalated,
+	}
+	h.jobManager.logger.Infof("Sending %s notification for reminder %s", target.Channel, reminder.ID)
+	return h.jobManager.chatNotifier.Notify(target.Channel, target.URL, target.Secret, data)
 }
 
 // formatReminderEmailBody creates the email body content
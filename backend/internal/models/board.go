@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Board is a kanban view of a realm's tasks. Each column shows the tasks of one status; several
+// columns may share a status, e.g. "Doing" and "Review" for running tasks.
+type Board struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:text"`
+	RealmID     string         `json:"realm_id" gorm:"not null;type:text;index"`
+	Name        string         `json:"name" gorm:"not null;type:text"`
+	Description string         `json:"description" gorm:"type:text"`
+	Columns     []BoardColumn  `json:"columns" gorm:"foreignKey:BoardID"`
+	CreatedBy   string         `json:"created_by" gorm:"type:text"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedBy   string         `json:"updated_by" gorm:"type:text"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName returns the table name for Board
+func (Board) TableName() string {
+	return "boards"
+}
+
+// BoardColumn is a column of a board, mapped onto a task status
+type BoardColumn struct {
+	ID       string     `json:"id" gorm:"primaryKey;type:text"`
+	BoardID  string     `json:"board_id" gorm:"not null;type:text;index"`
+	Name     string     `json:"name" gorm:"not null;type:text"`
+	Status   TaskStatus `json:"status" gorm:"not null;type:text"`
+	Position int        `json:"position" gorm:"not null;default:0"`
+	WIPLimit int        `json:"wip_limit" gorm:"default:0"` // max tasks in the column, 0=unlimited
+}
+
+// TableName returns the table name for BoardColumn
+func (BoardColumn) TableName() string {
+	return "board_columns"
+}
+
+// BoardCard records the column and the position of a task on a board. Tasks without a card
+// are shown in the first column of their status, after the ordered ones.
+type BoardCard struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:text"`
+	BoardID   string    `json:"board_id" gorm:"not null;type:text;uniqueIndex:idx_board_card_task"`
+	TaskID    string    `json:"task_id" gorm:"not null;type:text;uniqueIndex:idx_board_card_task"`
+	ColumnID  string    `json:"column_id" gorm:"not null;type:text;index"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for BoardCard
+func (BoardCard) TableName() string {
+	return "board_cards"
+}
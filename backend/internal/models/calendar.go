@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeedToken grants read access to a user's iCalendar feed. Only a hash of the
+// token is stored; the token itself is part of the feed URL handed to calendar apps.
+type CalendarFeedToken struct {
+	ID         string         `json:"id" gorm:"primaryKey;type:text"`
+	RealmID    string         `json:"realm_id" gorm:"not null;type:text;index"`
+	UserID     string         `json:"user_id" gorm:"not null;type:text;index"`
+	Name       string         `json:"name" gorm:"type:text"`
+	TokenHash  string         `json:"-" gorm:"not null;type:text;uniqueIndex"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (CalendarFeedToken) TableName() string {
+	return "calendar_feed_tokens"
+}
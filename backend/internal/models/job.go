@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Statuses of a job run
+const (
+	JobRunStatusRunning   = "running"
+	JobRunStatusSucceeded = "succeeded"
+	JobRunStatusFailed    = "failed"
+)
+
+// How a job run was started
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun records one execution of a scheduled job
+type JobRun struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:text"`
+	JobName     string     `json:"job_name" gorm:"not null;type:text;index"`
+	Function    string     `json:"function" gorm:"type:text"`
+	Instance    string     `json:"instance" gorm:"type:text"` // the process that ran the job
+	Trigger     string     `json:"trigger" gorm:"column:trigger_type;type:text;default:'schedule'"`
+	TriggeredBy string     `json:"triggered_by,omitempty" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"type:text;index"`
+	Error       string     `json:"error" gorm:"type:text"`
+	Log         string     `json:"log,omitempty" gorm:"type:text"` // what the job logged, at info level and above
+	StartedAt   time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	DurationMs  int64      `json:"duration_ms"`
+}
+
+// TableName returns the table name for JobRun
+func (JobRun) TableName() string {
+	return "job_runs"
+}
+
+// JobState keeps the state of a scheduled job that is shared by all processes
+type JobState struct {
+	JobName   string    `json:"job_name" gorm:"primaryKey;type:text"`
+	Paused    bool      `json:"paused"`
+	UpdatedBy string    `json:"updated_by" gorm:"type:text"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for JobState
+func (JobState) TableName() string {
+	return "job_states"
+}
+
+// JobLease is a named lock held by one process until it expires, used to elect the process
+// that runs the scheduled jobs when Redis is not configured
+type JobLease struct {
+	Name      string    `json:"name" gorm:"primaryKey;type:text"`
+	Owner     string    `json:"owner" gorm:"not null;type:text"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for JobLease
+func (JobLease) TableName() string {
+	return "job_leases"
+}
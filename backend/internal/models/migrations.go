@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// secretVersionIndex is the unique index on the version numbers of a secret
+const secretVersionIndex = "idx_secret_versions_secret_version"
+
+// PrepareMigration fixes up existing rows that the schema AutoMigrate is about to apply would
+// reject. It runs before AutoMigrate and does nothing on a new or already migrated database.
+func PrepareMigration(db *gorm.DB) error {
+	if err := renumberDuplicateSecretVersions(db); err != nil {
+		return fmt.Errorf("failed to renumber duplicate secret versions: %w", err)
+	}
+	return nil
+}
+
+// renumberDuplicateSecretVersions makes (secret_id, version) unique before its unique index is
+// created. Concurrent updates used to give two versions of a secret the same number; the newest
+// one keeps the number the version pointers refer to, and the older ones are moved above the
+// secret's max version as deprecated versions, so that no ciphertext is lost.
+func renumberDuplicateSecretVersions(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&SecretVersion{}) || migrator.HasIndex(&SecretVersion{}, secretVersionIndex) {
+		return nil
+	}
+
+	var duplicates []struct {
+		SecretID string
+		Version  int
+	}
+	if err := db.Unscoped().Model(&SecretVersion{}).
+		Select("secret_id, version").
+		Group("secret_id, version").
+		Having("COUNT(*) > 1").
+		Scan(&duplicates).Error; err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var versions []SecretVersion
+			if err := tx.Unscoped().Where("secret_id = ? AND version = ?", d.SecretID, d.Version).
+				Order("created_at DESC, id DESC").Find(&versions).Error; err != nil {
+				return err
+			}
+
+			// Number the older versions after both the highest stored version and max_version
+			var next int
+			if err := tx.Unscoped().Model(&SecretVersion{}).Where("secret_id = ?", d.SecretID).
+				Select("COALESCE(MAX(version), 0)").Scan(&next).Error; err != nil {
+				return err
+			}
+			var secret Secret
+			err := tx.Unscoped().Where("id = ?", d.SecretID).Limit(1).Find(&secret).Error
+			if err != nil {
+				return err
+			}
+			if secret.MaxVersion > next {
+				next = secret.MaxVersion
+			}
+
+			for _, v := range versions[1:] {
+				next++
+				if err := tx.Unscoped().Model(&SecretVersion{}).Where("id = ?", v.ID).
+					Updates(map[string]interface{}{"version": next, "status": "deprecated"}).Error; err != nil {
+					return err
+				}
+			}
+			if secret.ID == "" {
+				return nil
+			}
+			return tx.Unscoped().Model(&Secret{}).Where("id = ?", secret.ID).Update("max_version", next).Error
+		})
+		if err != nil {
+			return fmt.Errorf("secret %s version %d: %w", d.SecretID, d.Version, err)
+		}
+	}
+	return nil
+}
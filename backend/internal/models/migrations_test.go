@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+// legacySecretVersion is the secret_versions table before the unique version index
+type legacySecretVersion struct {
+	ID         string `gorm:"primaryKey;type:text"`
+	SecretID   string `gorm:"not null;type:text;index"`
+	Version    int    `gorm:"not null;index"`
+	CipherAlg  string `gorm:"not null;type:text"`
+	CipherText string `gorm:"not null;type:text"`
+	Nonce      string `gorm:"not null;type:text"`
+	AuthTag    string `gorm:"not null;type:text"`
+	WrappedDEK string `gorm:"not null;type:text"`
+	KEKVersion int    `gorm:"not null"`
+	Status     string `gorm:"not null;type:text"`
+	CreatedBy  string `gorm:"type:text"`
+	CreatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacySecretVersion) TableName() string { return "secret_versions" }
+
+func TestPrepareMigration_DuplicateSecretVersions(t *testing.T) {
+	db := testutil.NewTestDB(t, &Secret{}, &legacySecretVersion{})
+	now := time.Now()
+	require.NoError(t, db.Create(&Secret{ID: "s1", RealmID: "realm", Name: "db", CurrentVersion: 2, PreviousVersion: 1, MaxVersion: 2}).Error)
+	require.NoError(t, db.Create([]legacySecretVersion{
+		{ID: "v1", SecretID: "s1", Version: 1, Status: "deprecated", CreatedAt: now.Add(-3 * time.Minute)},
+		{ID: "v2-old", SecretID: "s1", Version: 2, Status: "active", CreatedAt: now.Add(-2 * time.Minute)},
+		{ID: "v2-new", SecretID: "s1", Version: 2, Status: "active", CreatedAt: now.Add(-time.Minute)},
+	}).Error)
+
+	require.NoError(t, PrepareMigration(db))
+	require.NoError(t, db.AutoMigrate(&SecretVersion{}))
+	assert.True(t, db.Migrator().HasIndex(&SecretVersion{}, secretVersionIndex))
+
+	versions := make(map[string]legacySecretVersion)
+	var rows []legacySecretVersion
+	require.NoError(t, db.Find(&rows).Error)
+	for _, v := range rows {
+		versions[v.ID] = v
+	}
+	assert.Equal(t, 2, versions["v2-new"].Version)
+	assert.Equal(t, "active", versions["v2-new"].Status)
+	assert.Equal(t, 3, versions["v2-old"].Version)
+	assert.Equal(t, "deprecated", versions["v2-old"].Status)
+	var secret Secret
+	require.NoError(t, db.First(&secret, "id = ?", "s1").Error)
+	assert.Equal(t, 3, secret.MaxVersion)
+	assert.Equal(t, 2, secret.CurrentVersion)
+
+	// Once the index exists, nothing is done
+	require.NoError(t, PrepareMigration(db))
+}
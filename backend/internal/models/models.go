@@ -115,15 +115,34 @@ func GetAllModels() []interface{} {
 		&Prompt{},
 		&Secret{},
 		&SecretVersion{},
+		&SecretAccessEvent{},
+		&SecretShare{},
+		&SecretVersionTransition{},
 
 		// Task & Reminder System
 		&Task{},
 		&Reminder{},
 		&TaskReminder{},
+		&TaskDependency{},
+		&TaskWorkLog{},
+		&CalendarFeedToken{},
+		&Board{},
+		&BoardColumn{},
+		&BoardCard{},
+		&WebhookEndpoint{},
+		&WebhookDelivery{},
+		&NotificationPreference{},
+		&NotificationOutbox{},
+
+		// Scheduled Jobs
+		&JobRun{},
+		&JobState{},
+		&JobLease{},
 
 		// GTD System
 		&InboxItem{},
 		&DailyChecklistItem{},
+		&PomodoroSession{},
 
 		// Blog & CMS (WordPress-style)
 		&Post{},
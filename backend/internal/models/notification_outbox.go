@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Statuses of a notification outbox entry
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusDelivering = "delivering"
+	OutboxStatusDelivered  = "delivered"
+	OutboxStatusDead       = "dead" // gave up after the maximum number of attempts
+)
+
+// NotificationOutbox is a notification waiting to be delivered to one target of a method. Entries are
+// written in the same transaction as the reminder status change that causes them, and are
+// delivered by the dispatcher job. The idempotency key keeps a notification from being queued
+// twice and is passed on to receivers that can deduplicate, e.g. webhooks.
+type NotificationOutbox struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:text"`
+	RealmID        string     `json:"realm_id" gorm:"type:text;index"`
+	ReminderID     string     `json:"reminder_id" gorm:"type:text;index"`
+	UserID         string     `json:"user_id" gorm:"type:text;index"` // empty for recipients given by email address
+	Recipient      string     `json:"recipient" gorm:"type:text"`
+	Method         string     `json:"method" gorm:"not null;type:text"`
+	Target         string     `json:"target" gorm:"type:text"` // the email address, or the channel and host of the webhook
+	IdempotencyKey string     `json:"idempotency_key" gorm:"not null;type:text;uniqueIndex"`
+	Payload        string     `json:"-" gorm:"type:text"` // the notification as JSON
+	Status         string     `json:"status" gorm:"not null;type:text;default:'pending';index"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	LockedBy       string     `json:"locked_by,omitempty" gorm:"type:text"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for NotificationOutbox
+func (NotificationOutbox) TableName() string {
+	return "notification_outbox"
+}
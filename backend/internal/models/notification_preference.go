@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Digest modes of a notification preference
+const (
+	DigestModeOff   = "off"
+	DigestModeDaily = "daily"
+)
+
+// Email digests of a notification preference; daily digests become weekly ones on the weekly day
+const (
+	EmailDigestOff    = "off"
+	EmailDigestDaily  = "daily"
+	EmailDigestWeekly = "weekly"
+)
+
+// NotificationPreference is how a user wants to receive reminders. Reminders that are not
+// urgent are held back during quiet hours, and in daily digest mode they are batched into one
+// notification at the digest time.
+type NotificationPreference struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:text"`
+	RealmID      string    `json:"realm_id" gorm:"not null;type:text;index"`
+	UserID       string    `json:"user_id" gorm:"not null;type:text;uniqueIndex"`
+	Channels     string    `json:"channels" gorm:"type:text"`                   // Comma-separated methods for reminders that name none
+	Timezone     string    `json:"timezone" gorm:"type:text"`                   // IANA name, defaults to the server's zone
+	QuietStart   string    `json:"quiet_start" gorm:"type:text"`                // HH:MM, empty for no quiet hours
+	QuietEnd     string    `json:"quiet_end" gorm:"type:text"`                  // HH:MM, may be before QuietStart to span midnight
+	DigestMode   string    `json:"digest_mode" gorm:"type:text;default:'off'"`  // off or daily
+	DigestTime   string    `json:"digest_time" gorm:"type:text"`                // HH:MM, defaults to 08:00
+	TagOverrides string    `json:"tag_overrides" gorm:"type:text"`              // JSON array of TagOverride
+	EmailDigest  string    `json:"email_digest" gorm:"type:text;default:'off'"` // off, daily or weekly summary email
+	DigestSentOn string    `json:"digest_sent_on" gorm:"type:text"`             // YYYY-MM-DD in Timezone, the day the last email digest was sent
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for NotificationPreference
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// TagOverride changes the delivery of reminders with a tag
+type TagOverride struct {
+	Tag     string `json:"tag"`
+	Methods string `json:"methods"` // Comma-separated, replaces the reminder's methods
+	Urgent  bool   `json:"urgent"`  // delivered right away, even in quiet hours
+}
+
+// GetTagOverrides returns the tag overrides of the preference
+func (p *NotificationPreference) GetTagOverrides() ([]TagOverride, error) {
+	if p.TagOverrides == "" {
+		return nil, nil
+	}
+	var overrides []TagOverride
+	if err := json.Unmarshal([]byte(p.TagOverrides), &overrides); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// SetTagOverrides sets the tag overrides of the preference
+func (p *NotificationPreference) SetTagOverrides(overrides []TagOverride) error {
+	if len(overrides) == 0 {
+		p.TagOverrides = ""
+		return nil
+	}
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	p.TagOverrides = string(data)
+	return nil
+}
+
+// Location returns the time zone of the preference
+func (p *NotificationPreference) Location() *time.Location {
+	if p.Timezone != "" {
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+)
+
+// Pomodoro session statuses
+const (
+	PomodoroStatusRunning     = "running"
+	PomodoroStatusPaused      = "paused"
+	PomodoroStatusCompleted   = "completed"
+	PomodoroStatusInterrupted = "interrupted"
+)
+
+// Pomodoro interruption types, following the technique's split between
+// interruptions you cause yourself and interruptions by others
+const (
+	PomodoroInterruptionInternal = "internal"
+	PomodoroInterruptionExternal = "external"
+)
+
+// PomodoroSession is one focus session, optionally linked to a Task or a DailyChecklistItem
+type PomodoroSession struct {
+	ID               string     `json:"id" gorm:"primaryKey;type:text"`
+	RealmID          string     `json:"realm_id" gorm:"not null;type:text;index;uniqueIndex:idx_pomodoro_active_session"`
+	UserID           string     `json:"user_id" gorm:"not null;type:text;index;uniqueIndex:idx_pomodoro_active_session"`
+	TaskID           *string    `json:"task_id" gorm:"type:text;index"`
+	DailyItemID      *string    `json:"daily_item_id" gorm:"type:text;index"`
+	PlannedMinutes   int        `json:"planned_minutes" gorm:"not null;default:25"`
+	Status           string     `json:"status" gorm:"not null;type:text;index"`
+	StartedAt        time.Time  `json:"started_at" gorm:"not null;index"`
+	PausedAt         *time.Time `json:"paused_at,omitempty"`
+	PausedSeconds    int        `json:"paused_seconds" gorm:"not null;default:0"` // accumulated pause time
+	EndedAt          *time.Time `json:"ended_at,omitempty"`
+	FocusSeconds     int        `json:"focus_seconds" gorm:"not null;default:0"` // time spent, without pauses, set when the session ends
+	InterruptionType string     `json:"interruption_type,omitempty" gorm:"type:text"`
+	InterruptReason  string     `json:"interrupt_reason,omitempty" gorm:"type:text"`
+	Notes            string     `json:"notes" gorm:"type:text"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Active is true while the session is running or paused and NULL once it ended. NULLs never
+	// collide in a unique index, so the index allows one active session per user on every
+	// supported database, like a partial index would.
+	Active *bool `json:"-" gorm:"uniqueIndex:idx_pomodoro_active_session"`
+}
+
+// TableName specifies the table name for GORM
+func (PomodoroSession) TableName() string {
+	return "pomodoro_sessions"
+}
+
+// IsActive reports whether the session is still running or paused
+func (p *PomodoroSession) IsActive() bool {
+	return p.Status == PomodoroStatusRunning || p.Status == PomodoroStatusPaused
+}
+
+// ActiveFlag returns the value of the Active column for the session's status
+func (p *PomodoroSession) ActiveFlag() *bool {
+	if !p.IsActive() {
+		return nil
+	}
+	active := true
+	return &active
+}
+
+// FocusTime returns the time spent in the session up to now, excluding pauses
+func (p *PomodoroSession) FocusTime(now time.Time) time.Duration {
+	if !p.IsActive() {
+		return time.Duration(p.FocusSeconds) * time.Second
+	}
+	end := now
+	if p.Status == PomodoroStatusPaused && p.PausedAt != nil {
+		end = *p.PausedAt
+	}
+	focus := end.Sub(p.StartedAt) - time.Duration(p.PausedSeconds)*time.Second
+	if focus < 0 {
+		return 0
+	}
+	return focus
+}
@@ -0,0 +1,502 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RFC 5545 recurrence frequencies supported by RRule
+const (
+	RRuleFreqDaily   = "DAILY"
+	RRuleFreqWeekly  = "WEEKLY"
+	RRuleFreqMonthly = "MONTHLY"
+	RRuleFreqYearly  = "YEARLY"
+)
+
+// maxRRulePeriods bounds the periods in a row without a candidate day, so the expansion of
+// rules that never match, e.g. BYMONTH=2;BYMONTHDAY=30, ends
+const maxRRulePeriods = 10000
+
+var (
+	rruleWeekdays = map[string]time.Weekday{
+		"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+		"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+	}
+	rruleByDayRegex = regexp.MustCompile(`^([+-]?\d{1,2})?(SU|MO|TU|WE|TH|FR|SA)$`)
+)
+
+// RRuleWeekday is a BYDAY entry such as FR, -1FR (last Friday) or 2MO (second Monday)
+type RRuleWeekday struct {
+	N       int // ordinal within the month or year, 0 means every such weekday
+	Weekday time.Weekday
+}
+
+// RRule is a parsed RFC 5545 recurrence rule. DTSTART is not part of the rule: occurrences
+// are expanded from a start time whose location and wall clock are kept, so "09:00 every
+// Monday" stays at 09:00 across DST changes.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []RRuleWeekday
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	untilFloating bool // UNTIL without "Z" is a local time in the start time's location
+}
+
+// IsRRule reports whether a repeat pattern is an RRULE rather than a simple keyword like "daily"
+func IsRRule(pattern string) bool {
+	p := strings.ToUpper(strings.TrimSpace(pattern))
+	return strings.HasPrefix(p, "RRULE:") || strings.Contains(p, "FREQ=")
+}
+
+// ParseRRule parses an RRULE value such as "FREQ=MONTHLY;BYDAY=-1FR", with or without the "RRULE:" prefix
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	rule := &RRule{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case RRuleFreqDaily, RRuleFreqWeekly, RRuleFreqMonthly, RRuleFreqYearly:
+				rule.Freq = val
+			default:
+				return nil, fmt.Errorf("unsupported RRULE FREQ %q, use DAILY, WEEKLY, MONTHLY or YEARLY", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseRRuleInt(key, val, 1, 1<<16)
+		case "COUNT":
+			rule.Count, err = parseRRuleInt(key, val, 1, 1<<16)
+		case "UNTIL":
+			err = rule.parseUntil(val)
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				m := rruleByDayRegex.FindStringSubmatch(item)
+				if m == nil {
+					return nil, fmt.Errorf("invalid RRULE BYDAY value %q", item)
+				}
+				weekday := RRuleWeekday{Weekday: rruleWeekdays[m[2]]}
+				if m[1] != "" {
+					weekday.N, _ = strconv.Atoi(m[1])
+					if weekday.N == 0 || weekday.N < -53 || weekday.N > 53 {
+						return nil, fmt.Errorf("invalid RRULE BYDAY ordinal %q", item)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseRRuleIntList(key, val, 31, true)
+		case "BYMONTH":
+			rule.ByMonth, err = parseRRuleIntList(key, val, 12, false)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseRRuleIntList(key, val, 366, true)
+		case "WKST":
+			weekday, ok := rruleWeekdays[val]
+			if !ok {
+				return nil, fmt.Errorf("invalid RRULE WKST %q", val)
+			}
+			rule.WeekStart = weekday
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("RRULE requires FREQ")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("RRULE cannot have both COUNT and UNTIL")
+	}
+	if rule.Freq == RRuleFreqDaily || rule.Freq == RRuleFreqWeekly {
+		for _, weekday := range rule.ByDay {
+			if weekday.N != 0 {
+				return nil, fmt.Errorf("RRULE BYDAY ordinals are only allowed with MONTHLY or YEARLY")
+			}
+		}
+	}
+	return rule, nil
+}
+
+// Iterate calls fn with every occurrence in chronological order, until fn returns false or the
+// rule's COUNT or UNTIL is reached. Like DTSTART, start is the first occurrence even when it
+// does not match the rule. Occurrences are in start's location.
+func (r *RRule) Iterate(start time.Time, fn func(time.Time) bool) {
+	r.iterate(start, start, fn)
+}
+
+// After returns the first occurrence strictly after the given time, or nil when the rule has ended
+func (r *RRule) After(start, after time.Time) *time.Time {
+	var next *time.Time
+	r.iterate(start, after, func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			next = &occurrence
+			return false
+		}
+		return true
+	})
+	return next
+}
+
+// iterate is Iterate for the occurrences from the given time on. Without COUNT the periods
+// before from are skipped, so a rule with an old start is expanded as quickly as a new one;
+// COUNT is counted from start, so those rules are expanded from their start.
+func (r *RRule) iterate(start, from time.Time, fn func(time.Time) bool) {
+	loc := start.Location()
+	until := r.Until
+	if until != nil && r.untilFloating {
+		u := time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+		until = &u
+	}
+	if until != nil && start.After(*until) {
+		return
+	}
+
+	emitted := 1
+	if !start.Before(from) && !fn(start) {
+		return
+	}
+	if r.Count > 0 && emitted >= r.Count {
+		return
+	}
+
+	period := 0
+	if r.Count == 0 && from.After(start) {
+		// One period earlier than from's, as BYSETPOS and week starts shift days across periods
+		period = max(r.periodOf(start, from)-1, 0)
+	}
+	for empty := 0; empty < maxRRulePeriods; period++ {
+		days := r.periodDays(start, period)
+		if len(days) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+		for _, day := range days {
+			occurrence := time.Date(day.Year(), day.Month(), day.Day(),
+				start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+			if !occurrence.After(start) {
+				continue
+			}
+			if until != nil && occurrence.After(*until) {
+				return
+			}
+			emitted++
+			if !occurrence.Before(from) && !fn(occurrence) {
+				return
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// periodOf returns the index of the period after start that contains the day of t
+func (r *RRule) periodOf(start, t time.Time) int {
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	local := t.In(start.Location())
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch r.Freq {
+	case RRuleFreqDaily:
+		return int(day.Sub(first).Hours()/24) / r.Interval
+	case RRuleFreqWeekly:
+		offset := (int(first.Weekday()) - int(r.WeekStart) + 7) % 7
+		return (int(day.Sub(first).Hours()/24) + offset) / 7 / r.Interval
+	case RRuleFreqMonthly:
+		return ((day.Year()-first.Year())*12 + int(day.Month()) - int(first.Month())) / r.Interval
+	default:
+		return (day.Year() - first.Year()) / r.Interval
+	}
+}
+
+// periodDays returns the sorted candidate days (UTC midnight) of the n-th period after start
+func (r *RRule) periodDays(start time.Time, n int) []time.Time {
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	var days []time.Time
+
+	switch r.Freq {
+	case RRuleFreqDaily:
+		day := first.AddDate(0, 0, n*r.Interval)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+	case RRuleFreqWeekly:
+		offset := (int(first.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := first.AddDate(0, 0, -offset+7*n*r.Interval)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			matches := day.Weekday() == start.Weekday()
+			if len(r.ByDay) > 0 {
+				matches = r.matchesWeekday(day)
+			}
+			if matches && r.matchesMonth(day) {
+				days = append(days, day)
+			}
+		}
+	case RRuleFreqMonthly:
+		month := time.Date(first.Year(), first.Month()+time.Month(n*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(month) {
+			days = r.monthDays(month, start.Day())
+		}
+	case RRuleFreqYearly:
+		year := first.Year() + n*r.Interval
+		if len(r.ByDay) > 0 && len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 {
+			days = r.yearDays(year)
+			break
+		}
+		months := r.ByMonth
+		if len(months) == 0 && len(r.ByMonthDay) > 0 {
+			// BYMONTHDAY alone picks its days in every month of the year
+			months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+		} else if len(months) == 0 {
+			months = []int{int(start.Month())}
+		}
+		sortedMonths := append([]int(nil), months...)
+		sort.Ints(sortedMonths)
+		for _, m := range sortedMonths {
+			days = append(days, r.monthDays(time.Date(year, time.Month(m), 1, 0, 0, 0, 0, time.UTC), start.Day())...)
+		}
+	}
+
+	return r.applySetPos(days)
+}
+
+// monthDays returns the days of a month matching BYMONTHDAY and BYDAY; without either the start's day is used
+func (r *RRule) monthDays(month time.Time, defaultDay int) []time.Time {
+	length := daysIn(month.Year(), month.Month())
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if defaultDay > length {
+			return nil
+		}
+		return []time.Time{month.AddDate(0, 0, defaultDay-1)}
+	}
+
+	var days []time.Time
+	for d := 1; d <= length; d++ {
+		day := month.AddDate(0, 0, d-1)
+		if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(day) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !r.matchesOrdinalWeekday(day.Weekday(), d, length) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// yearDays returns the days of a year matching BYDAY, with ordinals counted within the year
+func (r *RRule) yearDays(year int) []time.Time {
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	length := first.AddDate(1, 0, -1).YearDay()
+
+	var days []time.Time
+	for d := 1; d <= length; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if r.matchesOrdinalWeekday(day.Weekday(), d, length) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// matchesOrdinalWeekday checks a day at 1-based position pos of a period with the given length against BYDAY
+func (r *RRule) matchesOrdinalWeekday(weekday time.Weekday, pos, length int) bool {
+	forward := (pos-1)/7 + 1
+	backward := -((length-pos)/7 + 1)
+	for _, wd := range r.ByDay {
+		if wd.Weekday == weekday && (wd.N == 0 || wd.N == forward || wd.N == backward) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := daysIn(day.Year(), day.Month())
+	for _, md := range r.ByMonthDay {
+		if md == day.Day() || (md < 0 && length+md+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySetPos keeps the BYSETPOS positions of a period's sorted candidate days
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+
+	selected := make(map[int]bool)
+	for _, pos := range r.BySetPos {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(days) + pos
+		}
+		if idx >= 0 && idx < len(days) {
+			selected[idx] = true
+		}
+	}
+
+	var result []time.Time
+	for i, day := range days {
+		if selected[i] {
+			result = append(result, day)
+		}
+	}
+	return result
+}
+
+func (r *RRule) parseUntil(value string) error {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if layout == "20060102" {
+			// A date UNTIL includes the whole day
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		r.Until = &t
+		r.untilFloating = !strings.HasSuffix(value, "Z")
+		return nil
+	}
+	return fmt.Errorf("invalid RRULE UNTIL %q", value)
+}
+
+func parseRRuleInt(key, value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("invalid RRULE %s %q", key, value)
+	}
+	return n, nil
+}
+
+func parseRRuleIntList(key, value string, max int, allowNegative bool) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		min := 1
+		if allowNegative {
+			min = -max
+		}
+		n, err := parseRRuleInt(key, item, min, max)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid RRULE %s %q", key, item)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// ExDate is an excluded occurrence; a date-only ExDate excludes every occurrence on that local day
+type ExDate struct {
+	Time     time.Time
+	DateOnly bool
+}
+
+// ParseExDates parses a comma-separated EXDATE list. Entries are RFC3339 timestamps, iCalendar
+// date-times (20250101T090000Z, or floating 20250101T090000 in loc) or dates (2025-01-01, 20250101).
+func ParseExDates(value string, loc *time.Location) ([]ExDate, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "EXDATE:")
+	if value == "" {
+		return nil, nil
+	}
+
+	var exdates []ExDate
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		exdate, err := parseExDate(item, loc)
+		if err != nil {
+			return nil, err
+		}
+		exdates = append(exdates, exdate)
+	}
+	return exdates, nil
+}
+
+func parseExDate(value string, loc *time.Location) (ExDate, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return ExDate{Time: t}, nil
+	}
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return ExDate{Time: t}, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return ExDate{Time: t}, nil
+	}
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return ExDate{Time: t, DateOnly: true}, nil
+		}
+	}
+	return ExDate{}, fmt.Errorf("invalid exdate %q, use RFC3339, 20060102T150405Z or 2006-01-02", value)
+}
+
+// Excludes reports whether an occurrence is excluded by this ExDate
+func (e ExDate) Excludes(occurrence time.Time) bool {
+	if !e.DateOnly {
+		return occurrence.Equal(e.Time)
+	}
+	local := occurrence.In(e.Time.Location())
+	y1, m1, d1 := local.Date()
+	y2, m2, d2 := e.Time.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
@@ -81,6 +81,12 @@ type Secret struct {
     PendingVersion  int            `json:"pending_version" gorm:"not null;default:0"`
     MaxVersion      int            `json:"max_version" gorm:"not null;default:0"`
 
+    // --- Expiry and rotation policy ---
+    ExpiresAt            *time.Time `json:"expires_at,omitempty" gorm:"index"`
+    RotationIntervalDays int        `json:"rotation_interval_days" gorm:"not null;default:0"` // 0 disables rotation reminders
+    RotatedAt            *time.Time `json:"rotated_at,omitempty"`                             // when the current version became current
+    ExpiryRemindedFor    *time.Time `json:"-"`                                                // deadline the last expiry reminder was created for
+
     CreatedBy       string         `json:"created_by" gorm:"type:text"`
     CreatedAt       time.Time      `json:"created_at" gorm:"autoCreateTime"`
     UpdatedBy       string         `json:"updated_by" gorm:"type:text"`
@@ -89,11 +95,37 @@ type Secret struct {
 }
 
 
+// RotationDueAt returns when the current version should be rotated, or nil without a rotation policy
+func (s *Secret) RotationDueAt() *time.Time {
+    if s.RotationIntervalDays <= 0 {
+        return nil
+    }
+    since := s.CreatedAt
+    if s.RotatedAt != nil {
+        since = *s.RotatedAt
+    }
+    due := since.AddDate(0, 0, s.RotationIntervalDays)
+    return &due
+}
+
+// NextDeadline returns the earlier of the expiry time and the rotation due time, or nil if neither is set
+func (s *Secret) NextDeadline() *time.Time {
+    deadline := s.ExpiresAt
+    if due := s.RotationDueAt(); due != nil && (deadline == nil || due.Before(*deadline)) {
+        deadline = due
+    }
+    return deadline
+}
+
+// IsExpired reports whether the secret is past its expiry time
+func (s *Secret) IsExpired(now time.Time) bool {
+    return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
 
 type SecretVersion struct {
     ID         string         `json:"id" gorm:"primaryKey;type:text"`
-    SecretID   string         `json:"secret_id" gorm:"not null;type:text;index"`
-    Version    int            `json:"version" gorm:"not null;index"` // secret version
+    SecretID   string         `json:"secret_id" gorm:"not null;type:text;index;uniqueIndex:idx_secret_versions_secret_version"`
+    Version    int            `json:"version" gorm:"not null;index;uniqueIndex:idx_secret_versions_secret_version"` // secret version
     CipherAlg  string         `json:"cipher_alg" gorm:"not null;type:text"`
     CipherText string         `json:"cipher_text" gorm:"not null;type:text"`
     Nonce      string         `json:"nonce" gorm:"not null;type:text"`
@@ -105,3 +137,79 @@ type SecretVersion struct {
     CreatedAt  time.Time      `json:"created_at" gorm:"autoCreateTime"`
     DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// Secret access audit actions
+const (
+    SecretActionCreate         = "create"
+    SecretActionUpdate         = "update"
+    SecretActionDelete         = "delete"
+    SecretActionRead           = "read"
+    SecretActionActivate       = "activate"
+    SecretActionCreatePending  = "create_pending"
+    SecretActionDeleteVersion  = "delete_version"
+    SecretActionPromote        = "promote"
+    SecretActionRollback       = "rollback"
+    SecretActionDiscardPending = "discard_pending"
+    SecretActionShare          = "share"
+    SecretActionShareReveal    = "share_reveal"
+    SecretActionShareRevoke    = "share_revoke"
+)
+
+// Secret access audit outcomes
+const (
+    SecretOutcomeSuccess = "success"
+    SecretOutcomeFailure = "failure"
+)
+
+// SecretAccessEvent records who accessed or changed which secret version, when and from where
+type SecretAccessEvent struct {
+    ID        string    `json:"id" gorm:"primaryKey;type:text"`
+    RealmID   string    `json:"realm_id" gorm:"not null;type:text;index"`
+    SecretID  string    `json:"secret_id" gorm:"not null;type:text;index"`
+    Version   int       `json:"version" gorm:"not null;default:0"`
+    Action    string    `json:"action" gorm:"not null;type:text;index"`
+    Outcome   string    `json:"outcome" gorm:"not null;type:text;index"`
+    Error     string    `json:"error,omitempty" gorm:"type:text"`
+    UserID    string    `json:"user_id" gorm:"type:text;index"`
+    Username  string    `json:"username" gorm:"type:text"`
+    ClientIP  string    `json:"client_ip" gorm:"type:text"`
+    CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// SecretVersionTransition records one atomic move of a secret's version pointers,
+// e.g. a promotion of the pending version or a rollback to the previous one
+type SecretVersionTransition struct {
+    ID           string    `json:"id" gorm:"primaryKey;type:text"`
+    RealmID      string    `json:"realm_id" gorm:"not null;type:text;index"`
+    SecretID     string    `json:"secret_id" gorm:"not null;type:text;index"`
+    Action       string    `json:"action" gorm:"not null;type:text"` // activate, promote, rollback, discard_pending
+    FromCurrent  int       `json:"from_current"`
+    FromPrevious int       `json:"from_previous"`
+    FromPending  int       `json:"from_pending"`
+    ToCurrent    int       `json:"to_current"`
+    ToPrevious   int       `json:"to_previous"`
+    ToPending    int       `json:"to_pending"`
+    PerformedBy  string    `json:"performed_by" gorm:"type:text"`
+    CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// SecretShare is a time and view limited link that reveals one secret version without authentication.
+// Only the SHA-256 hash of the share token is stored; the token itself is returned once on creation.
+type SecretShare struct {
+    ID        string     `json:"id" gorm:"primaryKey;type:text"`
+    RealmID   string     `json:"realm_id" gorm:"not null;type:text;index"`
+    SecretID  string     `json:"secret_id" gorm:"not null;type:text;index"`
+    Version   int        `json:"version" gorm:"not null"`
+    TokenHash string     `json:"-" gorm:"not null;type:text;uniqueIndex"`
+    MaxViews  int        `json:"max_views" gorm:"not null;default:1"`
+    ViewCount int        `json:"view_count" gorm:"not null;default:0"`
+    ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+    BurnedAt  *time.Time `json:"burned_at,omitempty"`
+    CreatedBy string     `json:"created_by" gorm:"type:text"`
+    CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// IsActive reports whether the share can still be revealed at the given time
+func (s *SecretShare) IsActive(now time.Time) bool {
+    return s.BurnedAt == nil && s.ViewCount < s.MaxViews && now.Before(s.ExpiresAt)
+}
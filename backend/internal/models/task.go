@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ type TaskStatus string
 const (
 	TaskStatusPending   TaskStatus = "pending"
 	TaskStatusRunning   TaskStatus = "running"
+	TaskStatusPaused    TaskStatus = "paused"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
 )
@@ -31,14 +33,18 @@ type Task struct {
 	EndTime      *time.Time `json:"end_time"`
 	Tags         string     `json:"tags" gorm:"type:text"`
 
+	ActualMinutes int `json:"actual_minutes" gorm:"default:0"` // logged work time, summed from TaskWorkLog
+
 	// Repeat task fields
 	IsRepeating      bool       `json:"is_repeating" gorm:"default:false"`
-	RepeatPattern    string     `json:"repeat_pattern" gorm:"type:text"`      // daily, weekly, monthly, yearly, custom
+	RepeatPattern    string     `json:"repeat_pattern" gorm:"type:text"`      // daily, weekly, monthly, yearly or an RRULE like FREQ=MONTHLY;BYDAY=-1FR
 	RepeatInterval   int        `json:"repeat_interval" gorm:"default:1"`     // every N days/weeks/months
 	RepeatDaysOfWeek string     `json:"repeat_days_of_week" gorm:"type:text"` // comma-separated: mon,tue,wed
 	RepeatDayOfMonth int        `json:"repeat_day_of_month" gorm:"default:0"` // for monthly: 1-31, 0=same day
 	RepeatEndDate    *time.Time `json:"repeat_end_date"`                      // when to stop repeating
 	RepeatCount      int        `json:"repeat_count" gorm:"default:0"`        // max occurrences, 0=infinite
+	RepeatTimezone   string     `json:"repeat_timezone" gorm:"type:text"`     // IANA zone the pattern is expanded in, empty=schedule time's zone
+	RepeatExDates    string     `json:"repeat_exdates" gorm:"type:text"`      // comma-separated excluded occurrences (EXDATE)
 
 	// Reminder generation settings
 	GenerateReminders      bool   `json:"generate_reminders" gorm:"default:false"`
@@ -49,6 +55,13 @@ type Task struct {
 	// Tracking
 	ParentTaskID  *string `json:"parent_task_id" gorm:"type:text;index"` // for generated task instances
 	InstanceCount int     `json:"instance_count" gorm:"default:0"`       // how many instances generated
+	ExternalUID   string  `json:"external_uid" gorm:"type:text;index"`   // iCalendar UID of an imported event
+
+	// Subtasks, kept apart from repeat instances which use ParentTaskID
+	ParentID     *string `json:"parent_id" gorm:"type:text;index"`   // parent of a subtask
+	SubtaskCount int     `json:"subtask_count" gorm:"default:0"`     // number of direct subtasks
+	Progress     int     `json:"progress" gorm:"default:0"`          // percent complete, rolled up from subtasks
+	AutoComplete bool    `json:"auto_complete" gorm:"default:false"` // complete once all subtasks are completed
 
 	CreatedBy string         `json:"created_by" gorm:"type:text"`
 	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
@@ -67,6 +80,21 @@ func (t *Task) IsParentTask() bool {
 	return t.IsRepeating && t.ParentTaskID == nil
 }
 
+// IsScheduled returns false for tasks created without a schedule time, which are left to the scheduler
+func (t *Task) IsScheduled() bool {
+	return !t.ScheduleTime.IsZero()
+}
+
+// IsSubtask returns true if this task is part of a larger task
+func (t *Task) IsSubtask() bool {
+	return t.ParentID != nil
+}
+
+// HasSubtasks returns true if Minutes and Progress are rolled up from subtasks
+func (t *Task) HasSubtasks() bool {
+	return t.SubtaskCount > 0
+}
+
 // IsTaskInstance returns true if this is an instance of a repeating task
 func (t *Task) IsTaskInstance() bool {
 	return t.ParentTaskID != nil
@@ -92,12 +120,54 @@ func (t *Task) ShouldGenerateReminders() bool {
 	return t.GenerateReminders && t.ReminderMethods != ""
 }
 
-// GetNextOccurrence calculates the next occurrence date based on repeat pattern
+// maxExDateSkips bounds how many excluded occurrences GetNextOccurrence skips in a row
+const maxExDateSkips = 1000
+
+// GetRepeatLocation returns the time zone the repeat pattern is expanded in. Without
+// RepeatTimezone the location of ref is used, which keeps the behaviour of existing tasks.
+func (t *Task) GetRepeatLocation(ref time.Time) *time.Location {
+	if t.RepeatTimezone != "" {
+		if loc, err := time.LoadLocation(t.RepeatTimezone); err == nil {
+			return loc
+		}
+	}
+	return ref.Location()
+}
+
+// GetNextOccurrence calculates the next occurrence date based on repeat pattern.
+// Occurrences keep their wall clock time in the repeat location across DST changes,
+// and occurrences listed in RepeatExDates are skipped.
 func (t *Task) GetNextOccurrence(fromDate time.Time) *time.Time {
 	if !t.IsRepeating {
 		return nil
 	}
+	if t.RepeatCount > 0 && t.InstanceCount >= t.RepeatCount {
+		return nil
+	}
 
+	loc := t.GetRepeatLocation(fromDate)
+	exdates, _ := ParseExDates(t.RepeatExDates, loc)
+
+	current := fromDate.In(loc)
+	for i := 0; i < maxExDateSkips; i++ {
+		nextDate := t.nextPatternOccurrence(current)
+		if nextDate == nil {
+			return nil
+		}
+		// Check if we've exceeded the end date
+		if t.RepeatEndDate != nil && nextDate.After(*t.RepeatEndDate) {
+			return nil
+		}
+		if !isExcluded(exdates, *nextDate) {
+			return nextDate
+		}
+		current = *nextDate
+	}
+	return nil
+}
+
+// nextPatternOccurrence returns the occurrence following fromDate, ignoring end date and exdates
+func (t *Task) nextPatternOccurrence(fromDate time.Time) *time.Time {
 	var nextDate time.Time
 
 	switch t.RepeatPattern {
@@ -114,20 +184,29 @@ func (t *Task) GetNextOccurrence(fromDate time.Time) *time.Time {
 	case "yearly":
 		nextDate = fromDate.AddDate(t.RepeatInterval, 0, 0)
 	default:
-		return nil
-	}
-
-	// Check if we've exceeded the end date or count
-	if t.RepeatEndDate != nil && nextDate.After(*t.RepeatEndDate) {
-		return nil
-	}
-	if t.RepeatCount > 0 && t.InstanceCount >= t.RepeatCount {
-		return nil
+		if !IsRRule(t.RepeatPattern) {
+			return nil
+		}
+		rule, err := ParseRRule(t.RepeatPattern)
+		if err != nil {
+			return nil
+		}
+		// The RRULE is anchored at the parent's schedule time; instances inherit it
+		return rule.After(t.ScheduleTime.In(fromDate.Location()), fromDate)
 	}
 
 	return &nextDate
 }
 
+func isExcluded(exdates []ExDate, occurrence time.Time) bool {
+	for _, exdate := range exdates {
+		if exdate.Excludes(occurrence) {
+			return true
+		}
+	}
+	return false
+}
+
 // getNextWeeklyOccurrence handles weekly recurrence with specific days
 func (t *Task) getNextWeeklyOccurrence(fromDate time.Time) time.Time {
 	daysOfWeek := t.GetRepeatDaysOfWeek()
@@ -189,6 +268,13 @@ type Reminder struct {
 	Tags          string         `json:"tags" gorm:"type:text"`
 	RemindMethods string         `json:"remind_methods" gorm:"type:text"` // Comma-separated: email,im,webhook
 	RemindTargets string         `json:"remind_targets" gorm:"type:text"` // JSON or comma-separated targets
+	Escalation    string         `json:"escalation" gorm:"type:text"`     // JSON array of EscalationStep
+	DeliverAfter  *time.Time     `json:"deliver_after"`                   // held back by the notification preference until then
+	NotifiedAt    *time.Time     `json:"notified_at"`                     // when the reminder was sent; it stays active until acknowledged
+	Escalated     int            `json:"escalated" gorm:"default:0"`      // number of escalation steps sent
+	AckedAt       *time.Time     `json:"acked_at"`
+	AckedBy       string         `json:"acked_by" gorm:"type:text"`
+	SnoozeCount   int            `json:"snooze_count" gorm:"default:0"`
 	CreatedBy     string         `json:"created_by" gorm:"type:text"`
 	CreatedAt     time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedBy     string         `json:"updated_by" gorm:"type:text"`
@@ -196,6 +282,56 @@ type Reminder struct {
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// EscalationStep resends a reminder that has not been acknowledged AfterMinutes after it was
+// first sent, through other methods or to other targets
+type EscalationStep struct {
+	AfterMinutes int    `json:"after_minutes"`
+	Methods      string `json:"methods"` // Comma-separated, defaults to the reminder's methods
+	Targets      string `json:"targets"` // Comma-separated usernames or email addresses, defaults to the creator
+}
+
+// GetEscalation returns the escalation steps of the reminder
+func (r *Reminder) GetEscalation() ([]EscalationStep, error) {
+	if r.Escalation == "" {
+		return nil, nil
+	}
+	var steps []EscalationStep
+	if err := json.Unmarshal([]byte(r.Escalation), &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// SetEscalation sets the escalation steps of the reminder
+func (r *Reminder) SetEscalation(steps []EscalationStep) error {
+	if len(steps) == 0 {
+		r.Escalation = ""
+		return nil
+	}
+	data, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+	r.Escalation = string(data)
+	return nil
+}
+
+// NextEscalation returns the escalation step that is due at now, if any
+func (r *Reminder) NextEscalation(now time.Time) (*EscalationStep, bool) {
+	if r.Status != "active" || r.NotifiedAt == nil || r.AckedAt != nil {
+		return nil, false
+	}
+	steps, err := r.GetEscalation()
+	if err != nil || r.Escalated >= len(steps) {
+		return nil, false
+	}
+	step := steps[r.Escalated]
+	if now.Before(r.NotifiedAt.Add(time.Duration(step.AfterMinutes) * time.Minute)) {
+		return nil, false
+	}
+	return &step, true
+}
+
 // TaskReminder represents the mapping between tasks and reminders
 type TaskReminder struct {
 	ID         string         `json:"id" gorm:"primaryKey;type:text"`
@@ -213,3 +349,48 @@ type TaskReminder struct {
 func (TaskReminder) TableName() string {
 	return "task_reminders"
 }
+
+// DependencyFinishToStart means the successor cannot start before the predecessor is completed
+const DependencyFinishToStart = "finish_to_start"
+
+// TaskDependency is an edge of a task dependency graph: SuccessorID depends on PredecessorID
+type TaskDependency struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:text"`
+	RealmID       string    `json:"realm_id" gorm:"not null;type:text;index"`
+	PredecessorID string    `json:"predecessor_id" gorm:"not null;type:text;uniqueIndex:idx_task_dependency_edge"`
+	SuccessorID   string    `json:"successor_id" gorm:"not null;type:text;uniqueIndex:idx_task_dependency_edge;index"`
+	Type          string    `json:"type" gorm:"not null;type:text;default:'finish_to_start'"`
+	CreatedBy     string    `json:"created_by" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for TaskDependency
+func (TaskDependency) TableName() string {
+	return "task_dependencies"
+}
+
+// TaskWorkLog is one interval of work on a task. Intervals are opened when a task starts or
+// resumes and closed when it is paused, completed or failed; EndedAt is nil while open.
+type TaskWorkLog struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:text"`
+	RealmID   string     `json:"realm_id" gorm:"not null;type:text;index"`
+	TaskID    string     `json:"task_id" gorm:"not null;type:text;index"`
+	StartedAt time.Time  `json:"started_at" gorm:"not null;index"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Seconds   int        `json:"seconds" gorm:"default:0"`    // length of a closed interval
+	Manual    bool       `json:"manual" gorm:"default:false"` // added afterwards rather than tracked
+	Note      string     `json:"note" gorm:"type:text"`
+	CreatedBy string     `json:"created_by" gorm:"type:text"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for TaskWorkLog
+func (TaskWorkLog) TableName() string {
+	return "task_work_logs"
+}
+
+// IsOpen returns true while work on the task is in progress
+func (l *TaskWorkLog) IsOpen() bool {
+	return l.EndedAt == nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookChannelGeneric marks endpoints that receive the signed JSON payload of the webhook
+// method; other channels are chat apps, e.g. slack or feishu
+const WebhookChannelGeneric = "webhook"
+
+// WebhookEndpoint is a URL of a user that receives reminders sent with the webhook method, or
+// the incoming webhook of a chat app. Requests are signed with the endpoint's secret.
+type WebhookEndpoint struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:text"`
+	RealmID   string         `json:"realm_id" gorm:"not null;type:text;index"`
+	UserID    string         `json:"user_id" gorm:"not null;type:text;index"`
+	Name      string         `json:"name" gorm:"not null;type:text"`
+	Channel   string         `json:"channel" gorm:"not null;type:text;default:'webhook'"` // webhook, slack, dingtalk, wecom or feishu
+	URL       string         `json:"url" gorm:"not null;type:text"`
+	Secret    string         `json:"-" gorm:"type:text"` // HMAC-SHA256 signing key, only returned when it is created
+	Enabled   bool           `json:"enabled" gorm:"not null;default:true"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName returns the table name for WebhookEndpoint
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook. The attempts of a delivery
+// share its DeliveryID, which is also sent to the receiver.
+type WebhookDelivery struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:text"`
+	RealmID      string    `json:"realm_id" gorm:"not null;type:text;index"`
+	UserID       string    `json:"user_id" gorm:"type:text;index"` // the user the event was sent for
+	DeliveryID   string    `json:"delivery_id" gorm:"not null;type:text;index"`
+	EndpointID   string    `json:"endpoint_id" gorm:"type:text;index"` // empty for URLs given in a reminder's targets
+	ReminderID   string    `json:"reminder_id" gorm:"type:text;index"`
+	Event        string    `json:"event" gorm:"not null;type:text"`
+	URL          string    `json:"url" gorm:"not null;type:text"`
+	Attempt      int       `json:"attempt" gorm:"not null"`
+	StatusCode   int       `json:"status_code"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error" gorm:"type:text"`
+	ResponseBody string    `json:"-" gorm:"type:text"` // truncated; not returned, as it may come from any host
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName returns the table name for WebhookDelivery
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
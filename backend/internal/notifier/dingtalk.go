@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DingTalkChannel posts markdown messages to DingTalk robot webhooks
+type DingTalkChannel struct{}
+
+// Request builds a markdown message. With a secret, the timestamp in milliseconds and its
+// signature are added to the URL as DingTalk requires.
+func (DingTalkChannel) Request(webhookURL, secret string, msg Message, now time.Time) (string, []byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": msg.Title,
+			"text":  msg.Text,
+		},
+	})
+	if err != nil || secret == "" {
+		return webhookURL, body, err
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", nil, err
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = query.Encode()
+	return u.String(), body, nil
+}
+
+// Check reads the errcode DingTalk returns with status 200
+func (DingTalkChannel) Check(statusCode int, body []byte) error {
+	return checkErrCode(statusCode, body)
+}
+
+// checkErrCode checks the {"errcode": 0, "errmsg": "ok"} responses of DingTalk and WeCom
+func checkErrCode(statusCode int, body []byte) error {
+	if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", statusCode, excerpt(body))
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("unexpected response: %s", excerpt(body))
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("error %d: %s", result.ErrCode, excerpt([]byte(result.ErrMsg)))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FeishuChannel posts interactive cards to Feishu (Lark) custom bot webhooks
+type FeishuChannel struct{}
+
+type feishuText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+// Request builds a card with the title as header and the text as lark_md. With a secret, the
+// timestamp in seconds and its signature are added to the body as Feishu requires.
+func (FeishuChannel) Request(webhookURL, secret string, msg Message, now time.Time) (string, []byte, error) {
+	payload := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"config": map[string]bool{"wide_screen_mode": true},
+			"header": map[string]interface{}{
+				"template": "blue",
+				"title":    feishuText{Tag: "plain_text", Content: msg.Title},
+			},
+			"elements": []map[string]interface{}{
+				{"tag": "div", "text": feishuText{Tag: "lark_md", Content: msg.Text}},
+			},
+		},
+	}
+	if secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		// Feishu uses "<timestamp>\n<secret>" as the key and signs an empty message
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+		payload["timestamp"] = timestamp
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	body, err := json.Marshal(payload)
+	return webhookURL, body, err
+}
+
+// Check reads the code Feishu returns with status 200
+func (FeishuChannel) Check(statusCode int, body []byte) error {
+	if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", statusCode, excerpt(body))
+	}
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("unexpected response: %s", excerpt(body))
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("error %d: %s", result.Code, excerpt([]byte(result.Msg)))
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/netguard"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	maxResponseBytes = 4096
+	// maxErrorBytes caps the part of a response quoted in errors, which end up in the outbox
+	maxErrorBytes = 200
+)
+
+// Message is a notification rendered for a chat channel
+type Message struct {
+	Title string
+	Text  string // in the markup of the channel
+}
+
+// Channel adapts messages to the incoming webhook format of a chat app
+type Channel interface {
+	// Request returns the URL and the JSON body that post msg to the incoming webhook at
+	// webhookURL; secret signs the request for apps that support it
+	Request(webhookURL, secret string, msg Message, now time.Time) (string, []byte, error)
+	// Check returns an error if the response of the webhook reports a failure
+	Check(statusCode int, body []byte) error
+}
+
+// channels holds the supported chat channels by the name used in remind methods
+var channels = map[string]Channel{
+	"slack":    SlackChannel{},
+	"dingtalk": DingTalkChannel{},
+	"wecom":    WeComChannel{},
+	"feishu":   FeishuChannel{},
+}
+
+// IsChannel reports whether name is a supported chat channel
+func IsChannel(name string) bool {
+	_, ok := channels[name]
+	return ok
+}
+
+// ChannelNames returns the names of the supported chat channels
+func ChannelNames() []string {
+	names := make([]string, 0, len(channels))
+	for name := range channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// builtinMethods are the remind methods besides the chat channels: email, the user's webhooks,
+// and im or message for every configured chat channel
+var builtinMethods = []string{"email", "webhook", "im", "message"}
+
+// IsMethod reports whether reminders can be sent through the remind method name
+func IsMethod(name string) bool {
+	for _, method := range builtinMethods {
+		if method == name {
+			return true
+		}
+	}
+	return IsChannel(name)
+}
+
+// MethodNames returns the names of the remind methods
+func MethodNames() []string {
+	return append(append([]string{}, builtinMethods...), ChannelNames()...)
+}
+
+// Notifier renders notifications with the templates of each channel and posts them to
+// incoming webhooks
+type Notifier struct {
+	templates *Templates
+	client    *http.Client
+}
+
+// NewNotifier creates a notifier with the templates of notifier.template_file, or the built-in
+// templates if it is not set
+func NewNotifier() (*Notifier, error) {
+	templates, err := LoadTemplates(viper.GetString("notifier.template_file"))
+	if err != nil {
+		return nil, err
+	}
+	timeout := viper.GetDuration("notifier.timeout")
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Notifier{templates: templates, client: netguard.NewClient(timeout)}, nil
+}
+
+// Notify renders a notification for channel with data and posts it to the webhook
+func (n *Notifier) Notify(channel, webhookURL, secret string, data map[string]interface{}) error {
+	adapter, ok := channels[channel]
+	if !ok {
+		return fmt.Errorf("unknown channel %q", channel)
+	}
+	msg, err := n.templates.Render(channel, data)
+	if err != nil {
+		return err
+	}
+	url, body, err := adapter.Request(webhookURL, secret, msg, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to build %s message: %w", channel, netguard.RedactError(err))
+	}
+
+	resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s webhook: %w", channel, netguard.RedactError(err))
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err := adapter.Check(resp.StatusCode, response); err != nil {
+		return fmt.Errorf("%s webhook: %w", channel, err)
+	}
+	return nil
+}
+
+// excerpt returns the start of a response for an error message, cut at maxErrorBytes on a
+// character boundary and with control characters escaped
+func excerpt(body []byte) string {
+	if len(body) <= maxErrorBytes {
+		return strings.Trim(fmt.Sprintf("%q", body), `"`)
+	}
+	cut := maxErrorBytes
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return strings.Trim(fmt.Sprintf("%q", body[:cut]), `"`) + "..."
+}
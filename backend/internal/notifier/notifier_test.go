@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates_Render(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	data := map[string]interface{}{
+		"Name":       "Stand-up",
+		"Content":    "Daily stand-up in room 4",
+		"RemindTime": "2025-03-10 09:45",
+		"Tags":       "team",
+		"Username":   "alice",
+		"Escalation": 0,
+	}
+	for _, channel := range ChannelNames() {
+		msg, err := templates.Render(channel, data)
+		require.NoError(t, err, channel)
+		assert.Equal(t, "🔔 Stand-up", msg.Title, channel)
+		assert.Contains(t, msg.Text, "Daily stand-up in room 4", channel)
+		assert.Contains(t, msg.Text, "2025-03-10 09:45", channel)
+		assert.NotContains(t, msg.Text, "scalation", channel)
+	}
+
+	data["Escalation"] = 2
+	msg, err := templates.Render("dingtalk", data)
+	require.NoError(t, err)
+	assert.Contains(t, msg.Text, "**Escalation**: 2")
+
+	_, err = templates.Render("sms", data)
+	assert.Error(t, err)
+}
+
+func TestChannels_Request(t *testing.T) {
+	msg := Message{Title: "🔔 Stand-up", Text: "Daily stand-up"}
+	now := time.Unix(1700000000, 0)
+
+	_, body, err := SlackChannel{}.Request("https://hooks.slack.com/services/T/B/X", "", msg, now)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text": "🔔 Stand-up", "blocks": [
+		{"type": "header", "text": {"type": "plain_text", "text": "🔔 Stand-up"}},
+		{"type": "section", "text": {"type": "mrkdwn", "text": "Daily stand-up"}}
+	]}`, string(body))
+
+	signedURL, body, err := DingTalkChannel{}.Request("https://oapi.dingtalk.com/robot/send?access_token=abc", "SEC123", msg, now)
+	require.NoError(t, err)
+	u, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", u.Query().Get("access_token"))
+	assert.Equal(t, "1700000000000", u.Query().Get("timestamp"))
+	assert.NotEmpty(t, u.Query().Get("sign"))
+	assert.JSONEq(t, `{"msgtype": "markdown", "markdown": {"title": "🔔 Stand-up", "text": "Daily stand-up"}}`, string(body))
+
+	_, body, err = WeComChannel{}.Request("https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=k", "", msg, now)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"msgtype": "markdown", "markdown": {"content": "Daily stand-up"}}`, string(body))
+
+	_, body, err = FeishuChannel{}.Request("https://open.feishu.cn/open-apis/bot/v2/hook/x", "SEC123", msg, now)
+	require.NoError(t, err)
+	var card map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &card))
+	assert.Equal(t, "interactive", card["msg_type"])
+	assert.Equal(t, "1700000000", card["timestamp"])
+	assert.NotEmpty(t, card["sign"])
+}
+
+func TestChannels_Check(t *testing.T) {
+	assert.NoError(t, SlackChannel{}.Check(200, []byte("ok")))
+	assert.Error(t, SlackChannel{}.Check(404, []byte("no_service")))
+
+	assert.NoError(t, DingTalkChannel{}.Check(200, []byte(`{"errcode":0,"errmsg":"ok"}`)))
+	assert.ErrorContains(t, DingTalkChannel{}.Check(200, []byte(`{"errcode":310000,"errmsg":"sign not match"}`)), "sign not match")
+	assert.ErrorContains(t, WeComChannel{}.Check(200, []byte(`{"errcode":93000,"errmsg":"invalid webhook url"}`)), "93000")
+
+	assert.NoError(t, FeishuChannel{}.Check(200, []byte(`{"code":0,"msg":"success"}`)))
+	assert.ErrorContains(t, FeishuChannel{}.Check(200, []byte(`{"code":19021,"msg":"sign match fail"}`)), "sign match fail")
+
+	// Long bodies, e.g. HTML error pages, are cut so they do not fill the outbox
+	page := "<html>\n" + strings.Repeat("é", maxResponseBytes) + "</html>"
+	err := SlackChannel{}.Check(502, []byte(page))
+	assert.ErrorContains(t, err, `unexpected status 502: <html>\n`)
+	assert.Less(t, len(err.Error()), 2*maxErrorBytes)
+	assert.True(t, utf8.ValidString(err.Error()))
+	err = checkErrCode(200, []byte(page))
+	assert.Less(t, len(err.Error()), 2*maxErrorBytes)
+}
+
+func TestIsMethod(t *testing.T) {
+	for _, method := range MethodNames() {
+		assert.True(t, IsMethod(method), method)
+	}
+	assert.Contains(t, MethodNames(), "message")
+	assert.Contains(t, MethodNames(), "slack")
+	assert.False(t, IsMethod("sms"))
+	assert.False(t, IsMethod(""))
+}
@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SlackChannel posts Block Kit messages to Slack incoming webhooks
+type SlackChannel struct{}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+// Request builds a message with a header block and a mrkdwn section
+func (SlackChannel) Request(webhookURL, secret string, msg Message, now time.Time) (string, []byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"text": msg.Title, // shown in notifications
+		"blocks": []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: msg.Title}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: msg.Text}},
+		},
+	})
+	return webhookURL, body, err
+}
+
+// Check accepts any 2xx response; Slack answers errors with 4xx and a plain text reason
+func (SlackChannel) Check(statusCode int, body []byte) error {
+	if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", statusCode, excerpt(body))
+	}
+	return nil
+}
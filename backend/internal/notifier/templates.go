@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed templates.yaml
+var defaultTemplates []byte
+
+// TemplateConfig is the YAML format of the chat notification templates
+type TemplateConfig struct {
+	Templates map[string]struct {
+		Title string `yaml:"title"`
+		Body  string `yaml:"body"`
+	} `yaml:"templates"`
+}
+
+// Templates holds the parsed title and body template of each channel
+type Templates struct {
+	titles map[string]*template.Template
+	bodies map[string]*template.Template
+}
+
+// LoadTemplates parses the built-in templates, overridden by the channels defined in the
+// file at path if it is not empty
+func LoadTemplates(path string) (*Templates, error) {
+	templates := &Templates{
+		titles: make(map[string]*template.Template),
+		bodies: make(map[string]*template.Template),
+	}
+	if err := templates.parse(defaultTemplates); err != nil {
+		return nil, fmt.Errorf("failed to parse built-in notifier templates: %w", err)
+	}
+	if path == "" {
+		return templates, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifier templates: %w", err)
+	}
+	if err := templates.parse(data); err != nil {
+		return nil, fmt.Errorf("failed to parse notifier templates %s: %w", path, err)
+	}
+	return templates, nil
+}
+
+func (t *Templates) parse(data []byte) error {
+	var config TemplateConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	for channel, tmpl := range config.Templates {
+		if !IsChannel(channel) {
+			return fmt.Errorf("unknown channel %q", channel)
+		}
+		title, err := template.New(channel + "_title").Parse(tmpl.Title)
+		if err != nil {
+			return fmt.Errorf("title of %s: %w", channel, err)
+		}
+		body, err := template.New(channel + "_body").Parse(tmpl.Body)
+		if err != nil {
+			return fmt.Errorf("body of %s: %w", channel, err)
+		}
+		t.titles[channel] = title
+		t.bodies[channel] = body
+	}
+	return nil
+}
+
+// Render renders the message of a channel with data
+func (t *Templates) Render(channel string, data map[string]interface{}) (Message, error) {
+	title, ok := t.titles[channel]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for channel %q", channel)
+	}
+	var titleBuf, bodyBuf bytes.Buffer
+	if err := title.Execute(&titleBuf, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s title: %w", channel, err)
+	}
+	if err := t.bodies[channel].Execute(&bodyBuf, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s body: %w", channel, err)
+	}
+	return Message{
+		Title: strings.TrimSpace(titleBuf.String()),
+		Text:  strings.TrimSpace(bodyBuf.String()),
+	}, nil
+}
@@ -0,0 +1,26 @@
+package notifier
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WeComChannel posts markdown messages to WeCom (WeChat Work) group robot webhooks
+type WeComChannel struct{}
+
+// Request builds a markdown message; WeCom robots have no title, so the template puts it
+// into the text, and no signing
+func (WeComChannel) Request(webhookURL, secret string, msg Message, now time.Time) (string, []byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": msg.Text,
+		},
+	})
+	return webhookURL, body, err
+}
+
+// Check reads the errcode WeCom returns with status 200
+func (WeComChannel) Check(statusCode int, body []byte) error {
+	return checkErrCode(statusCode, body)
+}
@@ -0,0 +1,183 @@
+package pomodoro
+
+import (
+	"errors"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrSessionStateChanged is returned when a session changed status between reading and updating it
+var ErrSessionStateChanged = errors.New("pomodoro session was changed concurrently")
+
+// PomodoroRepository provides data access for PomodoroSession entities
+type PomodoroRepository struct {
+	db *gorm.DB
+}
+
+// NewPomodoroRepository creates a new pomodoro repository
+func NewPomodoroRepository(db *gorm.DB) *PomodoroRepository {
+	return &PomodoroRepository{db: db}
+}
+
+// Create creates a new pomodoro session. The unique index on active sessions rejects a second
+// running or paused session of the same user.
+func (r *PomodoroRepository) Create(session *models.PomodoroSession) error {
+	session.Active = session.ActiveFlag()
+	return r.db.Create(session).Error
+}
+
+// GetByID retrieves one of a user's sessions
+func (r *PomodoroRepository) GetByID(realmID, userID, id string) (*models.PomodoroSession, error) {
+	var session models.PomodoroSession
+	err := r.db.Where("id = ? AND realm_id = ? AND user_id = ?", id, realmID, userID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActive retrieves the running or paused session of a user
+func (r *PomodoroRepository) GetActive(realmID, userID string) (*models.PomodoroSession, error) {
+	var session models.PomodoroSession
+	err := r.db.Where("realm_id = ? AND user_id = ? AND status IN ?", realmID, userID,
+		[]string{models.PomodoroStatusRunning, models.PomodoroStatusPaused}).
+		Order("started_at DESC").
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateFrom saves a session only if its status is still fromStatus, so that two clients
+// cannot both pause, resume or finish the same session
+func (r *PomodoroRepository) UpdateFrom(session *models.PomodoroSession, fromStatus string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return updateFrom(tx, session, fromStatus)
+	})
+}
+
+// Complete finishes a session and adds its focus minutes to the linked daily checklist item
+// in the same transaction
+func (r *PomodoroRepository) Complete(session *models.PomodoroSession, fromStatus string, minutes int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := updateFrom(tx, session, fromStatus); err != nil {
+			return err
+		}
+		if session.DailyItemID == nil || minutes <= 0 {
+			return nil
+		}
+		return tx.Model(&models.DailyChecklistItem{}).
+			Where("id = ?", *session.DailyItemID).
+			Updates(map[string]interface{}{
+				"actual_time": gorm.Expr("actual_time + ?", minutes),
+				"updated_at":  time.Now(),
+			}).Error
+	})
+}
+
+func updateFrom(tx *gorm.DB, session *models.PomodoroSession, fromStatus string) error {
+	session.Active = session.ActiveFlag()
+	result := tx.Model(&models.PomodoroSession{}).
+		Where("id = ? AND status = ?", session.ID, fromStatus).
+		Updates(map[string]interface{}{
+			"status":            session.Status,
+			"active":            session.Active,
+			"paused_at":         session.PausedAt,
+			"paused_seconds":    session.PausedSeconds,
+			"ended_at":          session.EndedAt,
+			"focus_seconds":     session.FocusSeconds,
+			"interruption_type": session.InterruptionType,
+			"interrupt_reason":  session.InterruptReason,
+			"notes":             session.Notes,
+			"updated_at":        time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionStateChanged
+	}
+	return nil
+}
+
+// ListParams filters a user's sessions
+type ListParams struct {
+	TaskID      string
+	DailyItemID string
+	Status      string
+	Since       *time.Time
+	Until       *time.Time
+	Page        int
+	PageSize    int
+}
+
+// List returns a user's sessions, newest first
+func (r *PomodoroRepository) List(realmID, userID string, params ListParams) ([]models.PomodoroSession, int64, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.PageSize <= 0 || params.PageSize > 100 {
+		params.PageSize = 20
+	}
+
+	var sessions []models.PomodoroSession
+	var total int64
+
+	q := r.filtered(realmID, userID, params)
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := q.Order("started_at DESC").
+		Offset((params.Page - 1) * params.PageSize).
+		Limit(params.PageSize).
+		Find(&sessions).Error; err != nil {
+		return nil, 0, err
+	}
+	return sessions, total, nil
+}
+
+// ListEnded returns every finished session of a user in a time range, for statistics
+func (r *PomodoroRepository) ListEnded(realmID, userID string, since, until time.Time) ([]models.PomodoroSession, error) {
+	var sessions []models.PomodoroSession
+	err := r.filtered(realmID, userID, ListParams{Since: &since, Until: &until}).
+		Where("status IN ?", []string{models.PomodoroStatusCompleted, models.PomodoroStatusInterrupted}).
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *PomodoroRepository) filtered(realmID, userID string, params ListParams) *gorm.DB {
+	q := r.db.Model(&models.PomodoroSession{}).Where("realm_id = ? AND user_id = ?", realmID, userID)
+	if params.TaskID != "" {
+		q = q.Where("task_id = ?", params.TaskID)
+	}
+	if params.DailyItemID != "" {
+		q = q.Where("daily_item_id = ?", params.DailyItemID)
+	}
+	if params.Status != "" {
+		q = q.Where("status = ?", params.Status)
+	}
+	if params.Since != nil {
+		q = q.Where("started_at >= ?", *params.Since)
+	}
+	if params.Until != nil {
+		q = q.Where("started_at < ?", *params.Until)
+	}
+	return q
+}
+
+// TaskExists reports whether a task exists in the realm
+func (r *PomodoroRepository) TaskExists(realmID, id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Task{}).Where("id = ? AND realm_id = ?", id, realmID).Count(&count).Error
+	return count > 0, err
+}
+
+// DailyItemExists reports whether a daily checklist item exists in the realm
+func (r *PomodoroRepository) DailyItemExists(realmID, id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.DailyChecklistItem{}).Where("id = ? AND realm_id = ?", id, realmID).Count(&count).Error
+	return count > 0, err
+}
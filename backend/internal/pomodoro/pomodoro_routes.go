@@ -0,0 +1,212 @@
+package pomodoro
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"gorm.io/gorm"
+)
+
+// RegisterPomodoroRoutes registers HTTP endpoints for pomodoro sessions
+func RegisterPomodoroRoutes(router *gin.Engine, service *PomodoroService, middleware *auth.AuthMiddleware) {
+	group := router.Group("/api/v1/pomodoro")
+	group.Use(middleware.Authenticate())
+
+	// GET /api/v1/pomodoro - List the current user's sessions
+	group.GET("", func(c *gin.Context) {
+		params := ListParams{
+			TaskID:      c.Query("task_id"),
+			DailyItemID: c.Query("daily_item_id"),
+			Status:      c.Query("status"),
+			Page:        parseIntDefault(c.Query("page"), 1),
+			PageSize:    parseIntDefault(c.Query("page_size"), 20),
+		}
+		if dateStr := c.Query("date"); dateStr != "" {
+			date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+				return
+			}
+			until := date.AddDate(0, 0, 1)
+			params.Since, params.Until = &date, &until
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		items, total, err := service.ListSessions(realmID, userID, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items":     items,
+			"total":     total,
+			"page":      params.Page,
+			"page_size": params.PageSize,
+		})
+	})
+
+	// POST /api/v1/pomodoro - Start a session
+	group.POST("", func(c *gin.Context) {
+		var req StartSessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.StartSession(req, realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, session)
+	})
+
+	// GET /api/v1/pomodoro/active - Get the running or paused session
+	group.GET("/active", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.GetActiveSession(realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"session":       session,
+			"focus_seconds": int(session.FocusTime(time.Now()) / time.Second),
+		})
+	})
+
+	// GET /api/v1/pomodoro/stats - Summarize finished sessions, by default of today
+	group.GET("/stats", func(c *gin.Context) {
+		now := time.Now()
+		since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		until := since.AddDate(0, 0, 1)
+		for param, target := range map[string]*time.Time{"from": &since, "to": &until} {
+			if value := c.Query(param); value != "" {
+				date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date. Use YYYY-MM-DD"})
+					return
+				}
+				*target = date
+			}
+		}
+		if c.Query("to") != "" {
+			// "to" is inclusive
+			until = until.AddDate(0, 0, 1)
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		stats, err := service.GetStats(realmID, userID, since, until)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+
+	// GET /api/v1/pomodoro/:id - Get a session
+	group.GET("/:id", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.GetSession(c.Param("id"), realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+
+	// POST /api/v1/pomodoro/:id/pause - Pause a running session
+	group.POST("/:id/pause", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.PauseSession(c.Param("id"), realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+
+	// POST /api/v1/pomodoro/:id/resume - Resume a paused session
+	group.POST("/:id/resume", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.ResumeSession(c.Param("id"), realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+
+	// POST /api/v1/pomodoro/:id/complete - Complete a session
+	group.POST("/:id/complete", func(c *gin.Context) {
+		var req FinishSessionRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.CompleteSession(c.Param("id"), req, realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+
+	// POST /api/v1/pomodoro/:id/interrupt - Interrupt a session with a reason
+	group.POST("/:id/interrupt", func(c *gin.Context) {
+		var req InterruptSessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		session, err := service.InterruptSession(c.Param("id"), req, realmID, userID)
+		if err != nil {
+			c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+}
+
+// sessionErrorStatus maps service errors to HTTP status codes
+func sessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrActiveSession), errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrSessionStateChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func parseIntDefault(value string, defaultVal int) int {
+	if value == "" {
+		return defaultVal
+	}
+	out, err := strconv.Atoi(value)
+	if err != nil || out <= 0 {
+		return defaultVal
+	}
+	return out
+}
@@ -0,0 +1,281 @@
+package pomodoro
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPlannedMinutes = 25
+	maxPlannedMinutes     = 180
+)
+
+var (
+	// ErrActiveSession is returned when starting a session while another one is running or paused
+	ErrActiveSession = errors.New("another pomodoro session is already active")
+	// ErrInvalidTransition is returned for actions that do not apply to the session's status
+	ErrInvalidTransition = errors.New("invalid pomodoro session transition")
+)
+
+// PomodoroService contains business logic for pomodoro sessions
+type PomodoroService struct {
+	repo *PomodoroRepository
+}
+
+// NewPomodoroService creates a new pomodoro service
+func NewPomodoroService(db *gorm.DB) *PomodoroService {
+	return &PomodoroService{
+		repo: NewPomodoroRepository(db),
+	}
+}
+
+// StartSessionRequest starts a session, optionally linked to a task or a daily checklist item
+type StartSessionRequest struct {
+	TaskID         *string `json:"task_id"`
+	DailyItemID    *string `json:"daily_item_id"`
+	PlannedMinutes int     `json:"planned_minutes"` // defaults to 25
+	Notes          string  `json:"notes"`
+}
+
+// FinishSessionRequest carries optional notes when completing a session
+type FinishSessionRequest struct {
+	Notes string `json:"notes"`
+}
+
+// InterruptSessionRequest records why a session was interrupted
+type InterruptSessionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	Type   string `json:"type"` // internal or external, defaults to external
+	Notes  string `json:"notes"`
+}
+
+// PomodoroStats summarizes the finished sessions of a period
+type PomodoroStats struct {
+	Since               time.Time      `json:"since"`
+	Until               time.Time      `json:"until"`
+	CompletedSessions   int            `json:"completed_sessions"`
+	InterruptedSessions int            `json:"interrupted_sessions"`
+	FocusMinutes        int            `json:"focus_minutes"`
+	InterruptionTypes   map[string]int `json:"interruption_types"`
+}
+
+// StartSession starts a new session for the user
+func (s *PomodoroService) StartSession(req StartSessionRequest, realmID, userID string) (*models.PomodoroSession, error) {
+	if strings.TrimSpace(realmID) == "" || strings.TrimSpace(userID) == "" {
+		return nil, errors.New("realm_id and user_id are required")
+	}
+	if req.TaskID != nil && req.DailyItemID != nil {
+		return nil, errors.New("a session can be linked to a task or a daily item, not both")
+	}
+	if req.PlannedMinutes == 0 {
+		req.PlannedMinutes = defaultPlannedMinutes
+	}
+	if req.PlannedMinutes < 1 || req.PlannedMinutes > maxPlannedMinutes {
+		return nil, fmt.Errorf("planned_minutes must be between 1 and %d", maxPlannedMinutes)
+	}
+
+	if req.TaskID != nil {
+		exists, err := s.repo.TaskExists(realmID, *req.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("task %s not found", *req.TaskID)
+		}
+	}
+	if req.DailyItemID != nil {
+		exists, err := s.repo.DailyItemExists(realmID, *req.DailyItemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daily item: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("daily item %s not found", *req.DailyItemID)
+		}
+	}
+
+	if _, err := s.repo.GetActive(realmID, userID); err == nil {
+		return nil, ErrActiveSession
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get active session: %w", err)
+	}
+
+	session := &models.PomodoroSession{
+		ID:             uuid.NewString(),
+		RealmID:        realmID,
+		UserID:         userID,
+		TaskID:         req.TaskID,
+		DailyItemID:    req.DailyItemID,
+		PlannedMinutes: req.PlannedMinutes,
+		Status:         models.PomodoroStatusRunning,
+		StartedAt:      time.Now(),
+		Notes:          req.Notes,
+	}
+	if err := s.repo.Create(session); err != nil {
+		// A concurrent start got in between the check and the insert
+		if _, activeErr := s.repo.GetActive(realmID, userID); activeErr == nil {
+			return nil, ErrActiveSession
+		}
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
+
+// GetSession returns one of the user's sessions
+func (s *PomodoroService) GetSession(id, realmID, userID string) (*models.PomodoroSession, error) {
+	return s.repo.GetByID(realmID, userID, id)
+}
+
+// GetActiveSession returns the user's running or paused session
+func (s *PomodoroService) GetActiveSession(realmID, userID string) (*models.PomodoroSession, error) {
+	return s.repo.GetActive(realmID, userID)
+}
+
+// ListSessions returns the user's sessions
+func (s *PomodoroService) ListSessions(realmID, userID string, params ListParams) ([]models.PomodoroSession, int64, error) {
+	return s.repo.List(realmID, userID, params)
+}
+
+// PauseSession pauses a running session
+func (s *PomodoroService) PauseSession(id, realmID, userID string) (*models.PomodoroSession, error) {
+	session, err := s.repo.GetByID(realmID, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != models.PomodoroStatusRunning {
+		return nil, fmt.Errorf("%w: cannot pause a %s session", ErrInvalidTransition, session.Status)
+	}
+
+	now := time.Now()
+	session.Status = models.PomodoroStatusPaused
+	session.PausedAt = &now
+	if err := s.repo.UpdateFrom(session, models.PomodoroStatusRunning); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ResumeSession resumes a paused session
+func (s *PomodoroService) ResumeSession(id, realmID, userID string) (*models.PomodoroSession, error) {
+	session, err := s.repo.GetByID(realmID, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != models.PomodoroStatusPaused {
+		return nil, fmt.Errorf("%w: cannot resume a %s session", ErrInvalidTransition, session.Status)
+	}
+
+	closePause(session, time.Now())
+	session.Status = models.PomodoroStatusRunning
+	if err := s.repo.UpdateFrom(session, models.PomodoroStatusPaused); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// CompleteSession completes a running or paused session. Its focus time is added to the
+// linked daily checklist item's actual time.
+func (s *PomodoroService) CompleteSession(id string, req FinishSessionRequest, realmID, userID string) (*models.PomodoroSession, error) {
+	session, err := s.repo.GetByID(realmID, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsActive() {
+		return nil, fmt.Errorf("%w: cannot complete a %s session", ErrInvalidTransition, session.Status)
+	}
+
+	fromStatus := session.Status
+	endSession(session, models.PomodoroStatusCompleted, time.Now())
+	if req.Notes != "" {
+		session.Notes = req.Notes
+	}
+	if err := s.repo.Complete(session, fromStatus, focusMinutes(session)); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// InterruptSession ends a running or paused session early. Interrupted sessions keep their
+// focus time for statistics but do not count towards actual time.
+func (s *PomodoroService) InterruptSession(id string, req InterruptSessionRequest, realmID, userID string) (*models.PomodoroSession, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, errors.New("reason is required")
+	}
+	if req.Type == "" {
+		req.Type = models.PomodoroInterruptionExternal
+	}
+	if req.Type != models.PomodoroInterruptionInternal && req.Type != models.PomodoroInterruptionExternal {
+		return nil, errors.New("type must be internal or external")
+	}
+
+	session, err := s.repo.GetByID(realmID, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsActive() {
+		return nil, fmt.Errorf("%w: cannot interrupt a %s session", ErrInvalidTransition, session.Status)
+	}
+
+	fromStatus := session.Status
+	endSession(session, models.PomodoroStatusInterrupted, time.Now())
+	session.InterruptionType = req.Type
+	session.InterruptReason = strings.TrimSpace(req.Reason)
+	if req.Notes != "" {
+		session.Notes = req.Notes
+	}
+	if err := s.repo.UpdateFrom(session, fromStatus); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetStats summarizes the user's finished sessions that started in [since, until)
+func (s *PomodoroService) GetStats(realmID, userID string, since, until time.Time) (*PomodoroStats, error) {
+	sessions, err := s.repo.ListEnded(realmID, userID, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &PomodoroStats{Since: since, Until: until, InterruptionTypes: map[string]int{}}
+	for _, session := range sessions {
+		switch session.Status {
+		case models.PomodoroStatusCompleted:
+			stats.CompletedSessions++
+			stats.FocusMinutes += focusMinutes(&session)
+		case models.PomodoroStatusInterrupted:
+			stats.InterruptedSessions++
+			stats.InterruptionTypes[session.InterruptionType]++
+		}
+	}
+	return stats, nil
+}
+
+// closePause adds a pause that ends now to the accumulated pause time
+func closePause(session *models.PomodoroSession, now time.Time) {
+	if session.PausedAt != nil {
+		session.PausedSeconds += int(now.Sub(*session.PausedAt) / time.Second)
+		session.PausedAt = nil
+	}
+}
+
+// endSession freezes the focus time of a session and moves it to a final status
+func endSession(session *models.PomodoroSession, status string, now time.Time) {
+	closePause(session, now)
+	focus := now.Sub(session.StartedAt) - time.Duration(session.PausedSeconds)*time.Second
+	if focus < 0 {
+		focus = 0
+	}
+	session.FocusSeconds = int(focus / time.Second)
+	session.Status = status
+	session.EndedAt = &now
+}
+
+// focusMinutes rounds the focus time of a finished session to whole minutes
+func focusMinutes(session *models.PomodoroSession) int {
+	return (session.FocusSeconds + 30) / 60
+}
@@ -0,0 +1,62 @@
+package pomodoro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func TestEndSession_ExcludesPauses(t *testing.T) {
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	pausedAt := start.Add(10 * time.Minute)
+	session := &models.PomodoroSession{
+		Status:        models.PomodoroStatusPaused,
+		StartedAt:     start,
+		PausedAt:      &pausedAt,
+		PausedSeconds: 120, // an earlier two minute pause
+	}
+
+	// Still paused when completed: the open pause of five minutes is closed first
+	end := pausedAt.Add(5 * time.Minute)
+	endSession(session, models.PomodoroStatusCompleted, end)
+
+	assert.Equal(t, models.PomodoroStatusCompleted, session.Status)
+	assert.Nil(t, session.PausedAt)
+	assert.Equal(t, 420, session.PausedSeconds)
+	assert.Equal(t, 480, session.FocusSeconds)
+	assert.Equal(t, &end, session.EndedAt)
+	assert.Equal(t, 8, focusMinutes(session))
+}
+
+func TestFocusMinutes_Rounds(t *testing.T) {
+	assert.Equal(t, 0, focusMinutes(&models.PomodoroSession{FocusSeconds: 29}))
+	assert.Equal(t, 1, focusMinutes(&models.PomodoroSession{FocusSeconds: 30}))
+	assert.Equal(t, 25, focusMinutes(&models.PomodoroSession{FocusSeconds: 25*60 + 10}))
+}
+
+func TestStartSession_OneActivePerUser(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.PomodoroSession{})
+	s := NewPomodoroService(db)
+
+	session, err := s.StartSession(StartSessionRequest{}, "realm", "alice")
+	require.NoError(t, err)
+	_, err = s.StartSession(StartSessionRequest{}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrActiveSession)
+
+	// A start that passed the check concurrently is rejected by the unique index
+	assert.Error(t, s.repo.Create(&models.PomodoroSession{
+		ID: "racing", RealmID: "realm", UserID: "alice", Status: models.PomodoroStatusRunning, StartedAt: time.Now(),
+	}))
+
+	// Other users, and the same user once the session ended, can start one
+	_, err = s.StartSession(StartSessionRequest{}, "realm", "bob")
+	require.NoError(t, err)
+	endSession(session, models.PomodoroStatusInterrupted, time.Now())
+	require.NoError(t, s.repo.UpdateFrom(session, models.PomodoroStatusRunning))
+	_, err = s.StartSession(StartSessionRequest{}, "realm", "alice")
+	require.NoError(t, err)
+}
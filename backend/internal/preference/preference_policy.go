@@ -0,0 +1,142 @@
+package preference
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+const (
+	// UrgentTag marks reminders that are always delivered right away
+	UrgentTag         = "urgent"
+	defaultDigestTime = 8 * 60
+)
+
+// Action is what to do with a due reminder
+type Action int
+
+const (
+	// DeliverNow sends the reminder right away
+	DeliverNow Action = iota
+	// Defer holds the reminder back until a later time, e.g. the end of quiet hours
+	Defer
+	// Digest sends the reminder together with the user's other digest reminders
+	Digest
+)
+
+// Delivery is the decision on how to deliver a due reminder
+type Delivery struct {
+	Action  Action
+	Methods string    // the methods to send the reminder with
+	At      time.Time // the time to hold the reminder back until for Defer
+}
+
+// Decide applies a user's preference to a due reminder. Without a preference the reminder is
+// delivered as it is. Urgent reminders, tagged urgent or by a tag override, are always
+// delivered right away; others wait for the end of quiet hours and, in daily digest mode, for
+// the digest time. Snoozed reminders skip the digest, since they were asked for at that time.
+func Decide(pref *models.NotificationPreference, reminder *models.Reminder, now time.Time) Delivery {
+	delivery := Delivery{Action: DeliverNow, Methods: reminder.RemindMethods}
+	if pref == nil {
+		return delivery
+	}
+	if strings.TrimSpace(delivery.Methods) == "" {
+		delivery.Methods = pref.Channels
+	}
+
+	urgent := false
+	overrides, _ := pref.GetTagOverrides()
+	methodsOverridden := false
+	for _, tag := range strings.Split(reminder.Tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == UrgentTag {
+			urgent = true
+		}
+		for _, override := range overrides {
+			if override.Tag != tag {
+				continue
+			}
+			if override.Urgent {
+				urgent = true
+			}
+			if override.Methods != "" && !methodsOverridden {
+				delivery.Methods = override.Methods
+				methodsOverridden = true
+			}
+		}
+	}
+	if urgent {
+		return delivery
+	}
+
+	loc := pref.Location()
+	local := now.In(loc)
+	if until, quiet := quietUntil(pref, local); quiet {
+		delivery.Action = Defer
+		delivery.At = until
+		return delivery
+	}
+
+	if pref.DigestMode == models.DigestModeDaily && reminder.SnoozeCount == 0 {
+		digestAt, _ := parseClock(pref.DigestTime, defaultDigestTime)
+		deliverAt := reminder.RemindTime
+		if reminder.DeliverAfter != nil {
+			deliverAt = *reminder.DeliverAfter
+		}
+		deliverAt = deliverAt.In(loc)
+		if deliverAt.Hour()*60+deliverAt.Minute() == digestAt && deliverAt.Second() == 0 {
+			delivery.Action = Digest
+			return delivery
+		}
+		delivery.Action = Defer
+		delivery.At = nextClock(local, digestAt)
+	}
+	return delivery
+}
+
+// quietUntil reports whether local is within the quiet hours and when they end
+func quietUntil(pref *models.NotificationPreference, local time.Time) (time.Time, bool) {
+	if pref.QuietStart == "" || pref.QuietEnd == "" {
+		return time.Time{}, false
+	}
+	start, err1 := parseClock(pref.QuietStart, 0)
+	end, err2 := parseClock(pref.QuietEnd, 0)
+	if err1 != nil || err2 != nil || start == end {
+		return time.Time{}, false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	quiet := (start < end && minute >= start && minute < end) ||
+		(start > end && (minute >= start || minute < end))
+	if !quiet {
+		return time.Time{}, false
+	}
+	return nextClock(local, end), true
+}
+
+// nextClock returns the next time after local at the given minute of the day
+func nextClock(local time.Time, minuteOfDay int) time.Time {
+	year, month, day := local.Date()
+	next := time.Date(year, month, day, minuteOfDay/60, minuteOfDay%60, 0, 0, local.Location())
+	if !next.After(local) {
+		next = time.Date(year, month, day+1, minuteOfDay/60, minuteOfDay%60, 0, 0, local.Location())
+	}
+	return next
+}
+
+// parseClock parses HH:MM into minutes since midnight
+func parseClock(value string, defaultVal int) (int, error) {
+	if value == "" {
+		return defaultVal, nil
+	}
+	hh, mm, ok := strings.Cut(value, ":")
+	hours, err1 := strconv.Atoi(hh)
+	minutes, err2 := strconv.Atoi(mm)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return hours*60 + minutes, nil
+}
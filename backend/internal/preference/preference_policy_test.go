@@ -0,0 +1,68 @@
+package preference
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+func TestDecide_QuietHours(t *testing.T) {
+	pref := &models.NotificationPreference{Timezone: "UTC", QuietStart: "22:00", QuietEnd: "07:30"}
+	reminder := &models.Reminder{RemindMethods: "email", Tags: "home"}
+
+	now := time.Date(2025, 3, 10, 23, 15, 0, 0, time.UTC)
+	delivery := Decide(pref, reminder, now)
+	assert.Equal(t, Defer, delivery.Action)
+	assert.Equal(t, time.Date(2025, 3, 11, 7, 30, 0, 0, time.UTC), delivery.At)
+
+	now = time.Date(2025, 3, 11, 6, 0, 0, 0, time.UTC)
+	delivery = Decide(pref, reminder, now)
+	assert.Equal(t, Defer, delivery.Action)
+	assert.Equal(t, time.Date(2025, 3, 11, 7, 30, 0, 0, time.UTC), delivery.At)
+
+	now = time.Date(2025, 3, 11, 7, 30, 0, 0, time.UTC)
+	assert.Equal(t, DeliverNow, Decide(pref, reminder, now).Action)
+
+	assert.Equal(t, DeliverNow, Decide(nil, reminder, now).Action)
+}
+
+func TestDecide_UrgentAndOverrides(t *testing.T) {
+	pref := &models.NotificationPreference{Timezone: "UTC", QuietStart: "22:00", QuietEnd: "07:00", Channels: "email"}
+	require.NoError(t, pref.SetTagOverrides([]models.TagOverride{
+		{Tag: "oncall", Methods: "slack,webhook", Urgent: true},
+		{Tag: "family", Methods: "wecom"},
+	}))
+	now := time.Date(2025, 3, 10, 23, 0, 0, 0, time.UTC)
+
+	delivery := Decide(pref, &models.Reminder{Tags: "urgent"}, now)
+	assert.Equal(t, DeliverNow, delivery.Action)
+	assert.Equal(t, "email", delivery.Methods)
+
+	delivery = Decide(pref, &models.Reminder{RemindMethods: "email", Tags: "work, oncall"}, now)
+	assert.Equal(t, DeliverNow, delivery.Action)
+	assert.Equal(t, "slack,webhook", delivery.Methods)
+
+	delivery = Decide(pref, &models.Reminder{RemindMethods: "email", Tags: "family"}, now)
+	assert.Equal(t, Defer, delivery.Action)
+	assert.Equal(t, "wecom", delivery.Methods)
+}
+
+func TestDecide_DailyDigest(t *testing.T) {
+	pref := &models.NotificationPreference{Timezone: "UTC", DigestMode: models.DigestModeDaily, DigestTime: "09:00"}
+	now := time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)
+
+	reminder := &models.Reminder{RemindMethods: "email", RemindTime: now}
+	delivery := Decide(pref, reminder, now)
+	assert.Equal(t, Defer, delivery.Action)
+	assert.Equal(t, time.Date(2025, 3, 11, 9, 0, 0, 0, time.UTC), delivery.At)
+
+	reminder.DeliverAfter = &delivery.At
+	assert.Equal(t, Digest, Decide(pref, reminder, delivery.At.Add(30*time.Second)).Action)
+	assert.Equal(t, now, reminder.RemindTime)
+
+	snoozed := &models.Reminder{RemindMethods: "email", RemindTime: now, SnoozeCount: 1}
+	assert.Equal(t, DeliverNow, Decide(pref, snoozed, now).Action)
+}
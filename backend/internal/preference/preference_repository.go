@@ -0,0 +1,30 @@
+package preference
+
+import (
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+// PreferenceRepository provides data access for notification preferences
+type PreferenceRepository struct {
+	db *gorm.DB
+}
+
+// NewPreferenceRepository creates a new preference repository
+func NewPreferenceRepository(db *gorm.DB) *PreferenceRepository {
+	return &PreferenceRepository{db: db}
+}
+
+// GetByUser retrieves the preference of a user
+func (r *PreferenceRepository) GetByUser(userID string) (*models.NotificationPreference, error) {
+	var pref models.NotificationPreference
+	if err := r.db.Where("user_id = ?", userID).First(&pref).Error; err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// Save creates or updates a preference
+func (r *PreferenceRepository) Save(pref *models.NotificationPreference) error {
+	return r.db.Save(pref).Error
+}
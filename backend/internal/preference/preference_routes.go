@@ -0,0 +1,44 @@
+package preference
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+)
+
+// RegisterPreferenceRoutes registers HTTP endpoints for the current user's notification preference
+func RegisterPreferenceRoutes(router *gin.Engine, service *PreferenceService, middleware *auth.AuthMiddleware) {
+	group := router.Group("/api/v1/notification-preferences")
+	group.Use(middleware.Authenticate())
+
+	// GET /api/v1/notification-preferences - Get the current user's preference
+	group.GET("", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		pref, err := service.GetPreference(realmID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, pref)
+	})
+
+	// PUT /api/v1/notification-preferences - Set the current user's preference
+	group.PUT("", func(c *gin.Context) {
+		var req PreferenceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		pref, err := service.SetPreference(req, realmID, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, pref)
+	})
+}
@@ -0,0 +1,147 @@
+package preference
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
+	"gorm.io/gorm"
+)
+
+// PreferenceService manages the notification preferences of users
+type PreferenceService struct {
+	repo *PreferenceRepository
+}
+
+// NewPreferenceService creates a new preference service
+func NewPreferenceService(db *gorm.DB) *PreferenceService {
+	return &PreferenceService{
+		repo: NewPreferenceRepository(db),
+	}
+}
+
+// PreferenceRequest defines the allowed input for setting a notification preference
+type PreferenceRequest struct {
+	Channels     string               `json:"channels"`
+	Timezone     string               `json:"timezone"`
+	QuietStart   string               `json:"quiet_start"`
+	QuietEnd     string               `json:"quiet_end"`
+	DigestMode   string               `json:"digest_mode"`
+	DigestTime   string               `json:"digest_time"`
+	TagOverrides []models.TagOverride `json:"tag_overrides"`
+	EmailDigest  string               `json:"email_digest"`
+}
+
+// GetPreference returns a user's preference, or the defaults if none was set
+func (s *PreferenceService) GetPreference(realmID, userID string) (*models.NotificationPreference, error) {
+	pref, err := s.repo.GetByUser(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.NotificationPreference{RealmID: realmID, UserID: userID, DigestMode: models.DigestModeOff, EmailDigest: models.EmailDigestOff}, nil
+	}
+	return pref, err
+}
+
+// SetPreference validates and saves a user's preference
+func (s *PreferenceService) SetPreference(req PreferenceRequest, realmID, userID string) (*models.NotificationPreference, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	pref, err := s.repo.GetByUser(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pref = &models.NotificationPreference{ID: uuid.NewString(), RealmID: realmID, UserID: userID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	pref.Channels = req.Channels
+	pref.Timezone = req.Timezone
+	pref.QuietStart = req.QuietStart
+	pref.QuietEnd = req.QuietEnd
+	pref.DigestMode = req.DigestMode
+	if pref.DigestMode == "" {
+		pref.DigestMode = models.DigestModeOff
+	}
+	pref.DigestTime = req.DigestTime
+	pref.EmailDigest = req.EmailDigest
+	if pref.EmailDigest == "" {
+		pref.EmailDigest = models.EmailDigestOff
+	}
+	if err := pref.SetTagOverrides(req.TagOverrides); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Save(pref); err != nil {
+		return nil, fmt.Errorf("failed to save notification preference: %w", err)
+	}
+	return pref, nil
+}
+
+func validate(req PreferenceRequest) error {
+	if err := validateMethods("channels", req.Channels); err != nil {
+		return err
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %s", req.Timezone)
+		}
+	}
+	if (req.QuietStart == "") != (req.QuietEnd == "") {
+		return errors.New("quiet_start and quiet_end must be set together")
+	}
+	if _, err := parseClock(req.QuietStart, 0); err != nil {
+		return fmt.Errorf("quiet_start: %w", err)
+	}
+	if _, err := parseClock(req.QuietEnd, 0); err != nil {
+		return fmt.Errorf("quiet_end: %w", err)
+	}
+
+	switch req.DigestMode {
+	case "", models.DigestModeOff, models.DigestModeDaily:
+	default:
+		return fmt.Errorf("digest_mode must be %s or %s", models.DigestModeOff, models.DigestModeDaily)
+	}
+	digestAt, err := parseClock(req.DigestTime, defaultDigestTime)
+	if err != nil {
+		return fmt.Errorf("digest_time: %w", err)
+	}
+	if req.DigestMode == models.DigestModeDaily && req.QuietStart != "" {
+		day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		pref := &models.NotificationPreference{QuietStart: req.QuietStart, QuietEnd: req.QuietEnd}
+		if _, quiet := quietUntil(pref, day.Add(time.Duration(digestAt)*time.Minute)); quiet {
+			return errors.New("digest_time must be outside the quiet hours")
+		}
+	}
+
+	switch req.EmailDigest {
+	case "", models.EmailDigestOff, models.EmailDigestDaily, models.EmailDigestWeekly:
+	default:
+		return fmt.Errorf("email_digest must be %s, %s or %s", models.EmailDigestOff, models.EmailDigestDaily, models.EmailDigestWeekly)
+	}
+
+	for i, override := range req.TagOverrides {
+		if strings.TrimSpace(override.Tag) == "" {
+			return fmt.Errorf("tag override %d needs a tag", i+1)
+		}
+		if err := validateMethods("methods of tag "+override.Tag, override.Methods); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateMethods(field, methods string) error {
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.TrimSpace(method)
+		switch {
+		case method == "", notifier.IsMethod(method):
+		default:
+			return fmt.Errorf("%s: unknown method %q", field, method)
+		}
+	}
+	return nil
+}
@@ -37,6 +37,19 @@ func (r *ReminderRepository) Update(reminder *models.Reminder) error {
 	return r.db.Save(reminder).Error
 }
 
+// UpdateIfStatus updates the given columns of a reminder only while it still has the status it
+// was read with, like the reminder jobs do, so that a concurrent change, e.g. a sent escalation
+// step, is not overwritten. It reports false when the reminder was changed in the meantime.
+func (r *ReminderRepository) UpdateIfStatus(id, status string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&models.Reminder{}).
+		Where("id = ? AND status = ?", id, status).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (r *ReminderRepository) Delete(id string) error {
 	return r.db.Delete(&models.Reminder{}, "id = ?", id).Error
 }
@@ -128,3 +141,17 @@ func (r *ReminderRepository) GetByTimeRange(realmID string, startTime, endTime t
 
 	return reminders, total, nil
 }
+
+// FindPending returns the pending reminder a user created under a name for a remind time,
+// allowing for the precision the database stores times with
+func (r *ReminderRepository) FindPending(realmID, createdBy, name string, remindTime time.Time) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := r.db.Where("realm_id = ? AND created_by = ? AND name = ? AND status = ?", realmID, createdBy, name, "pending").
+		Where("remind_time BETWEEN ? AND ?", remindTime.Add(-time.Second), remindTime.Add(time.Second)).
+		Order("remind_time ASC").
+		First(&reminder).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
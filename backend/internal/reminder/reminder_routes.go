@@ -1,12 +1,14 @@
 package reminder
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
 )
 
 // RegisterRoutes registers HTTP endpoints for managing reminders
@@ -199,28 +201,68 @@ func RegisterRoutes(router *gin.Engine, service *ReminderService, middleware *au
 		c.JSON(http.StatusOK, updated)
 	})
 
-	// POST /api/v1/reminders/:id/snooze - Snooze a reminder
+	// POST /api/v1/reminders/:id/snooze - Snooze a reminder for some minutes or until a given time
 	group.POST("/:id/snooze", func(c *gin.Context) {
 		id := c.Param("id")
 
 		var req struct {
-			RemindTime time.Time `json:"remind_time" binding:"required"`
+			Minutes    int        `json:"minutes"`
+			RemindTime *time.Time `json:"remind_time"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if (req.Minutes == 0) == (req.RemindTime == nil) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "either minutes or remind_time is required"})
+			return
+		}
 
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
 		updater, _ := auth.GetCurrentUsername(c)
-		updated, err := service.SnoozeReminder(id, req.RemindTime, updater)
+		var updated *models.Reminder
+		var err error
+		if req.RemindTime != nil {
+			updated, err = service.SnoozeReminder(id, realmID, userID, *req.RemindTime, updater)
+		} else {
+			updated, err = service.SnoozeReminderFor(id, realmID, userID, req.Minutes, updater)
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(reminderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	})
+
+	// POST /api/v1/reminders/:id/acknowledge - Acknowledge a sent reminder and stop its escalation
+	group.POST("/:id/acknowledge", func(c *gin.Context) {
+		id := c.Param("id")
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		updater, _ := auth.GetCurrentUsername(c)
+
+		updated, err := service.AcknowledgeReminder(id, realmID, userID, updater)
+		if err != nil {
+			c.AbortWithStatusJSON(reminderErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, updated)
 	})
 }
 
+// reminderErrorStatus maps an error of a reminder action to an HTTP status
+func reminderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrReminderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrReminderChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 // parseIntDefault parses string to int with default value
 func parseIntDefault(str string, defaultValue int) int {
 	if str == "" {
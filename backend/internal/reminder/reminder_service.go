@@ -2,11 +2,26 @@ package reminder
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
+	"gorm.io/gorm"
+)
+
+const (
+	maxEscalationSteps = 5
+	maxSnoozeMinutes   = 24 * 60
+)
+
+var (
+	// ErrReminderNotFound is returned for reminders that do not exist or belong to someone else
+	ErrReminderNotFound = errors.New("reminder not found")
+	// ErrReminderChanged is returned when a reminder was sent or changed while it was being updated
+	ErrReminderChanged = errors.New("reminder was changed concurrently, reload and retry")
 )
 
 // ReminderService contains business logic for reminders
@@ -20,23 +35,25 @@ func NewReminderService(repo *ReminderRepository) *ReminderService {
 
 // CreateReminderRequest defines the allowed input for creating a reminder
 type CreateReminderRequest struct {
-	Name          string    `json:"name" binding:"required"`
-	Content       string    `json:"content" binding:"required"`
-	RemindTime    time.Time `json:"remind_time" binding:"required"`
-	Tags          string    `json:"tags"`
-	RemindMethods string    `json:"remind_methods"` // Comma-separated: email,im,webhook
-	RemindTargets string    `json:"remind_targets"` // JSON or comma-separated targets
+	Name          string                  `json:"name" binding:"required"`
+	Content       string                  `json:"content" binding:"required"`
+	RemindTime    time.Time               `json:"remind_time" binding:"required"`
+	Tags          string                  `json:"tags"`
+	RemindMethods string                  `json:"remind_methods"` // Comma-separated: email,im,webhook
+	RemindTargets string                  `json:"remind_targets"` // JSON or comma-separated targets
+	Escalation    []models.EscalationStep `json:"escalation"`     // resends while the reminder is not acknowledged
 }
 
 // UpdateReminderRequest defines the allowed input for updating a reminder
 type UpdateReminderRequest struct {
-	Name          string     `json:"name"`
-	Content       string     `json:"content"`
-	Status        string     `json:"status"`
-	RemindTime    *time.Time `json:"remind_time"`
-	Tags          string     `json:"tags"`
-	RemindMethods string     `json:"remind_methods"` // Comma-separated: email,im,webhook
-	RemindTargets string     `json:"remind_targets"` // JSON or comma-separated targets
+	Name          string                   `json:"name"`
+	Content       string                   `json:"content"`
+	Status        string                   `json:"status"`
+	RemindTime    *time.Time               `json:"remind_time"`
+	Tags          string                   `json:"tags"`
+	RemindMethods string                   `json:"remind_methods"` // Comma-separated: email,im,webhook
+	RemindTargets string                   `json:"remind_targets"` // JSON or comma-separated targets
+	Escalation    *[]models.EscalationStep `json:"escalation"`     // replaces the escalation steps; an empty list removes them
 }
 
 func (s *ReminderService) CreateFromInput(req CreateReminderRequest, realmID, createdBy string) (*models.Reminder, error) {
@@ -53,6 +70,10 @@ func (s *ReminderService) CreateFromInput(req CreateReminderRequest, realmID, cr
 		return nil, errors.New("remind_time must be in the future")
 	}
 
+	if err := validateEscalation(req.Escalation); err != nil {
+		return nil, err
+	}
+
 	reminder := &models.Reminder{
 		ID:            uuid.NewString(),
 		RealmID:       realmID,
@@ -68,6 +89,9 @@ func (s *ReminderService) CreateFromInput(req CreateReminderRequest, realmID, cr
 		UpdatedBy:     createdBy,
 		UpdatedAt:     time.Now(),
 	}
+	if err := reminder.SetEscalation(req.Escalation); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.Create(reminder); err != nil {
 		return nil, err
@@ -102,6 +126,7 @@ func (s *ReminderService) UpdateFromInput(id string, req UpdateReminderRequest,
 			return nil, errors.New("remind_time must be in the future for pending reminders")
 		}
 		existing.RemindTime = *req.RemindTime
+		existing.DeliverAfter = nil
 	}
 	if req.Tags != "" {
 		existing.Tags = req.Tags
@@ -112,6 +137,14 @@ func (s *ReminderService) UpdateFromInput(id string, req UpdateReminderRequest,
 	if req.RemindTargets != "" {
 		existing.RemindTargets = req.RemindTargets
 	}
+	if req.Escalation != nil {
+		if err := validateEscalation(*req.Escalation); err != nil {
+			return nil, err
+		}
+		if err := existing.SetEscalation(*req.Escalation); err != nil {
+			return nil, err
+		}
+	}
 
 	existing.UpdatedBy = updatedBy
 	existing.UpdatedAt = time.Now()
@@ -157,6 +190,11 @@ func (s *ReminderService) GetOverdueReminders(realmID string, limit int) ([]mode
 	return s.repo.GetOverdue(realmID, limit)
 }
 
+// FindPendingReminder returns the pending reminder a user created under a name for a remind time
+func (s *ReminderService) FindPendingReminder(realmID, createdBy, name string, remindTime time.Time) (*models.Reminder, error) {
+	return s.repo.FindPending(realmID, createdBy, name, remindTime)
+}
+
 // GetRemindersByTimeRange returns reminders within a time range
 func (s *ReminderService) GetRemindersByTimeRange(realmID string, startTime, endTime time.Time, page, pageSize int) ([]models.Reminder, int64, error) {
 	if startTime.After(endTime) {
@@ -183,9 +221,10 @@ func (s *ReminderService) MarkAsCompleted(id, updatedBy string) (*models.Reminde
 	return existing, nil
 }
 
-// SnoozeReminder postpones a reminder to a new time
-func (s *ReminderService) SnoozeReminder(id string, newRemindTime time.Time, updatedBy string) (*models.Reminder, error) {
-	existing, err := s.repo.GetByID(id)
+// SnoozeReminder postpones a pending or unacknowledged reminder of the user to a new time. A
+// snoozed reminder is sent again, and its escalation starts over.
+func (s *ReminderService) SnoozeReminder(id, realmID, userID string, newRemindTime time.Time, updatedBy string) (*models.Reminder, error) {
+	existing, err := s.getOwnReminder(id, realmID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -198,13 +237,93 @@ func (s *ReminderService) SnoozeReminder(id string, newRemindTime time.Time, upd
 		return nil, errors.New("new remind_time must be in the future")
 	}
 
-	existing.RemindTime = newRemindTime
-	existing.UpdatedBy = updatedBy
-	existing.UpdatedAt = time.Now()
+	return s.updateIfStatus(existing, map[string]interface{}{
+		"remind_time":   newRemindTime,
+		"deliver_after": nil,
+		"status":        "pending",
+		"notified_at":   nil,
+		"escalated":     0,
+		"snooze_count":  gorm.Expr("snooze_count + 1"),
+		"updated_by":    updatedBy,
+		"updated_at":    time.Now(),
+	})
+}
 
-	if err := s.repo.Update(existing); err != nil {
+// SnoozeReminderFor postpones a reminder of the user by the given number of minutes from now
+func (s *ReminderService) SnoozeReminderFor(id, realmID, userID string, minutes int, updatedBy string) (*models.Reminder, error) {
+	if minutes < 1 || minutes > maxSnoozeMinutes {
+		return nil, fmt.Errorf("minutes must be between 1 and %d", maxSnoozeMinutes)
+	}
+	return s.SnoozeReminder(id, realmID, userID, time.Now().Add(time.Duration(minutes)*time.Minute), updatedBy)
+}
+
+// AcknowledgeReminder confirms that a sent reminder of the user was seen, which completes it
+// and stops its escalation
+func (s *ReminderService) AcknowledgeReminder(id, realmID, userID, acknowledgedBy string) (*models.Reminder, error) {
+	existing, err := s.getOwnReminder(id, realmID, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	return existing, nil
+	switch existing.Status {
+	case "pending":
+		return nil, errors.New("cannot acknowledge a reminder that has not been sent yet")
+	case "cancelled":
+		return nil, errors.New("cannot acknowledge a cancelled reminder")
+	}
+	if existing.AckedAt != nil {
+		return existing, nil
+	}
+
+	now := time.Now()
+	return s.updateIfStatus(existing, map[string]interface{}{
+		"status":     "completed",
+		"acked_at":   now,
+		"acked_by":   acknowledgedBy,
+		"updated_by": acknowledgedBy,
+		"updated_at": now,
+	})
+}
+
+// getOwnReminder returns a reminder the user created in the realm; other reminders are reported
+// as not found, so that their existence is not revealed
+func (s *ReminderService) getOwnReminder(id, realmID, userID string) (*models.Reminder, error) {
+	existing, err := s.repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && (existing.RealmID != realmID || existing.CreatedBy != userID)) {
+		return nil, ErrReminderNotFound
+	}
+	return existing, err
+}
+
+// updateIfStatus applies updates to a reminder that still has the status it was read with and
+// returns the updated reminder
+func (s *ReminderService) updateIfStatus(existing *models.Reminder, updates map[string]interface{}) (*models.Reminder, error) {
+	updated, err := s.repo.UpdateIfStatus(existing.ID, existing.Status, updates)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrReminderChanged
+	}
+	return s.repo.GetByID(existing.ID)
+}
+
+// validateEscalation checks that escalation steps use known methods and fire one after another
+func validateEscalation(steps []models.EscalationStep) error {
+	if len(steps) > maxEscalationSteps {
+		return fmt.Errorf("a reminder can have at most %d escalation steps", maxEscalationSteps)
+	}
+	previous := 0
+	for i, step := range steps {
+		if step.AfterMinutes <= previous {
+			return fmt.Errorf("escalation step %d: after_minutes must be greater than %d", i+1, previous)
+		}
+		previous = step.AfterMinutes
+		for _, method := range strings.Split(step.Methods, ",") {
+			if method = strings.TrimSpace(method); method != "" && !notifier.IsMethod(method) {
+				return fmt.Errorf("escalation step %d: unknown method %q", i+1, method)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,156 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestValidateEscalation(t *testing.T) {
+	assert.NoError(t, validateEscalation(nil))
+	assert.NoError(t, validateEscalation([]models.EscalationStep{
+		{AfterMinutes: 10, Methods: "im"},
+		{AfterMinutes: 30, Methods: "email, webhook", Targets: "alice,ops@example.com"},
+	}))
+
+	assert.ErrorContains(t, validateEscalation([]models.EscalationStep{{AfterMinutes: 0}}), "after_minutes")
+	assert.ErrorContains(t, validateEscalation([]models.EscalationStep{
+		{AfterMinutes: 30},
+		{AfterMinutes: 30},
+	}), "step 2")
+	assert.ErrorContains(t, validateEscalation([]models.EscalationStep{{AfterMinutes: 5, Methods: "sms"}}), `unknown method "sms"`)
+}
+
+func TestNextEscalation(t *testing.T) {
+	notifiedAt := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	reminder := &models.Reminder{Status: "active", NotifiedAt: &notifiedAt}
+	require.NoError(t, reminder.SetEscalation([]models.EscalationStep{
+		{AfterMinutes: 10, Methods: "im"},
+		{AfterMinutes: 30, Targets: "alice"},
+	}))
+
+	_, due := reminder.NextEscalation(notifiedAt.Add(9 * time.Minute))
+	assert.False(t, due)
+
+	step, due := reminder.NextEscalation(notifiedAt.Add(10 * time.Minute))
+	require.True(t, due)
+	assert.Equal(t, "im", step.Methods)
+
+	reminder.Escalated = 1
+	_, due = reminder.NextEscalation(notifiedAt.Add(20 * time.Minute))
+	assert.False(t, due)
+	step, due = reminder.NextEscalation(notifiedAt.Add(45 * time.Minute))
+	require.True(t, due)
+	assert.Equal(t, "alice", step.Targets)
+
+	// All steps sent
+	reminder.Escalated = 2
+	_, due = reminder.NextEscalation(notifiedAt.Add(time.Hour))
+	assert.False(t, due)
+
+	// Acknowledged reminders are not escalated
+	reminder.Escalated = 0
+	reminder.AckedAt = &notifiedAt
+	_, due = reminder.NextEscalation(notifiedAt.Add(time.Hour))
+	assert.False(t, due)
+}
+
+func newTestReminderService(t *testing.T) (*ReminderService, *gorm.DB) {
+	db := testutil.NewTestDB(t, &models.Reminder{})
+	return NewReminderService(&ReminderRepository{db: db}), db
+}
+
+// createSentReminder stores a reminder of alice that was sent and escalated once
+func createSentReminder(t *testing.T, db *gorm.DB) *models.Reminder {
+	notifiedAt := time.Now().Add(-20 * time.Minute)
+	deliverAfter := notifiedAt
+	reminder := &models.Reminder{ID: "r1", RealmID: "realm", Name: "Standup", Content: "Standup",
+		RemindTime: notifiedAt.Add(-time.Hour), DeliverAfter: &deliverAfter, Status: "active",
+		NotifiedAt: &notifiedAt, Escalated: 1, CreatedBy: "u-alice"}
+	require.NoError(t, db.Create(reminder).Error)
+	return reminder
+}
+
+func TestSnoozeReminder(t *testing.T) {
+	s, db := newTestReminderService(t)
+	createSentReminder(t, db)
+
+	// Only the owner can snooze it
+	_, err := s.SnoozeReminderFor("r1", "realm", "u-bob", 10, "bob")
+	assert.ErrorIs(t, err, ErrReminderNotFound)
+	_, err = s.SnoozeReminderFor("r1", "other", "u-alice", 10, "alice")
+	assert.ErrorIs(t, err, ErrReminderNotFound)
+	_, err = s.SnoozeReminderFor("missing", "realm", "u-alice", 10, "alice")
+	assert.ErrorIs(t, err, ErrReminderNotFound)
+
+	// Snoozing re-arms the reminder: it is sent again and escalates from the first step
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	snoozed, err := s.SnoozeReminder("r1", "realm", "u-alice", until, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", snoozed.Status)
+	assert.True(t, snoozed.RemindTime.Equal(until))
+	assert.Nil(t, snoozed.DeliverAfter)
+	assert.Nil(t, snoozed.NotifiedAt)
+	assert.Zero(t, snoozed.Escalated)
+	assert.Equal(t, 1, snoozed.SnoozeCount)
+
+	snoozed, err = s.SnoozeReminderFor("r1", "realm", "u-alice", 10, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2, snoozed.SnoozeCount)
+
+	_, err = s.SnoozeReminderFor("r1", "realm", "u-alice", 0, "alice")
+	assert.ErrorContains(t, err, "minutes")
+	_, err = s.SnoozeReminder("r1", "realm", "u-alice", time.Now().Add(-time.Minute), "alice")
+	assert.ErrorContains(t, err, "future")
+}
+
+func TestAcknowledgeReminder(t *testing.T) {
+	s, db := newTestReminderService(t)
+	createSentReminder(t, db)
+
+	_, err := s.AcknowledgeReminder("r1", "realm", "u-bob", "bob")
+	assert.ErrorIs(t, err, ErrReminderNotFound)
+
+	acked, err := s.AcknowledgeReminder("r1", "realm", "u-alice", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", acked.Status)
+	assert.Equal(t, "alice", acked.AckedBy)
+	require.NotNil(t, acked.AckedAt)
+	assert.Equal(t, 1, acked.Escalated)
+
+	// A second acknowledgement changes nothing; a completed reminder cannot be snoozed
+	again, err := s.AcknowledgeReminder("r1", "realm", "u-alice", "alice")
+	require.NoError(t, err)
+	assert.True(t, again.AckedAt.Equal(*acked.AckedAt))
+	_, err = s.SnoozeReminderFor("r1", "realm", "u-alice", 10, "alice")
+	assert.ErrorContains(t, err, "completed")
+
+	pending := &models.Reminder{ID: "r2", RealmID: "realm", Name: "Later", Content: "Later",
+		RemindTime: time.Now().Add(time.Hour), Status: "pending", CreatedBy: "u-alice"}
+	require.NoError(t, db.Create(pending).Error)
+	_, err = s.AcknowledgeReminder("r2", "realm", "u-alice", "alice")
+	assert.ErrorContains(t, err, "not been sent")
+}
+
+func TestAcknowledgeReminder_Changed(t *testing.T) {
+	s, db := newTestReminderService(t)
+	createSentReminder(t, db)
+
+	// The reminder was read while active, then snoozed before the acknowledgement was written
+	stale, err := s.getOwnReminder("r1", "realm", "u-alice")
+	require.NoError(t, err)
+	_, err = s.SnoozeReminderFor("r1", "realm", "u-alice", 10, "alice")
+	require.NoError(t, err)
+
+	_, err = s.updateIfStatus(stale, map[string]interface{}{"status": "completed", "escalated": 1})
+	assert.ErrorIs(t, err, ErrReminderChanged)
+	current, err := s.GetReminder("r1")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", current.Status)
+	assert.Zero(t, current.Escalated)
+}
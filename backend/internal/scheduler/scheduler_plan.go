@@ -0,0 +1,304 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// WorkingHours is the part of the week tasks may be scheduled in
+type WorkingHours struct {
+	Start    string   `json:"start"`    // HH:MM, defaults to 09:00
+	End      string   `json:"end"`      // HH:MM, defaults to 18:00
+	Days     []string `json:"days"`     // mon, tue, ..., defaults to mon-fri
+	Timezone string   `json:"timezone"` // IANA zone, defaults to the server's zone
+}
+
+// workingWeek is the parsed form of WorkingHours
+type workingWeek struct {
+	loc        *time.Location
+	start, end int // minutes after midnight
+	days       map[time.Weekday]bool
+}
+
+func (h WorkingHours) parse() (*workingWeek, error) {
+	week := &workingWeek{loc: time.Local, days: make(map[time.Weekday]bool)}
+	if h.Timezone != "" {
+		loc, err := time.LoadLocation(h.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %s", h.Timezone)
+		}
+		week.loc = loc
+	}
+
+	var err error
+	if week.start, err = parseClock(h.Start, 9*60); err != nil {
+		return nil, fmt.Errorf("invalid working_hours.start: %w", err)
+	}
+	if week.end, err = parseClock(h.End, 18*60); err != nil {
+		return nil, fmt.Errorf("invalid working_hours.end: %w", err)
+	}
+	if week.end <= week.start {
+		return nil, fmt.Errorf("working_hours.end must be after working_hours.start")
+	}
+
+	days := h.Days
+	if len(days) == 0 {
+		days = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+	for _, day := range days {
+		weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+		if !ok {
+			return nil, fmt.Errorf("invalid working day %q, use sun, mon, tue, wed, thu, fri or sat", day)
+		}
+		week.days[weekday] = true
+	}
+	return week, nil
+}
+
+// parseClock parses HH:MM into minutes after midnight; 24:00 is allowed as an end
+func parseClock(value string, defaultVal int) (int, error) {
+	if value == "" {
+		return defaultVal, nil
+	}
+	hh, mm, ok := strings.Cut(value, ":")
+	hours, err1 := strconv.Atoi(hh)
+	minutes, err2 := strconv.Atoi(mm)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return hours*60 + minutes, nil
+}
+
+// interval is a half-open time range [start, end)
+type interval struct {
+	start, end time.Time
+}
+
+func (i interval) duration() time.Duration {
+	return i.end.Sub(i.start)
+}
+
+// windows returns the working time between from and until
+func (w *workingWeek) windows(from, until time.Time) []interval {
+	var out []interval
+	local := from.In(w.loc)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.loc); day.Before(until); day = day.AddDate(0, 0, 1) {
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		window := interval{
+			start: time.Date(day.Year(), day.Month(), day.Day(), 0, w.start, 0, 0, w.loc),
+			end:   time.Date(day.Year(), day.Month(), day.Day(), 0, w.end, 0, 0, w.loc),
+		}
+		if window.start.Before(from) {
+			window.start = from
+		}
+		if window.end.After(until) {
+			window.end = until
+		}
+		if window.end.After(window.start) {
+			out = append(out, window)
+		}
+	}
+	return out
+}
+
+// subtract removes the busy intervals from the free ones
+func subtract(free, busy []interval) []interval {
+	sort.Slice(busy, func(i, j int) bool { return busy[i].start.Before(busy[j].start) })
+
+	var out []interval
+	for _, slot := range free {
+		for _, b := range busy {
+			if !b.end.After(slot.start) || !b.start.Before(slot.end) {
+				continue
+			}
+			if b.start.After(slot.start) {
+				out = append(out, interval{start: slot.start, end: b.start})
+			}
+			slot.start = b.end
+			if !slot.end.After(slot.start) {
+				break
+			}
+		}
+		if slot.end.After(slot.start) {
+			out = append(out, slot)
+		}
+	}
+	return out
+}
+
+// planConstraint holds what a candidate task waits for
+type planConstraint struct {
+	notBefore    time.Time // end of the latest scheduled predecessor
+	predecessors []string  // predecessors that are planned in the same run
+	blockedBy    string    // name of a predecessor that cannot be scheduled at all
+}
+
+// planner places tasks into free slots, first fit in order of urgency
+type planner struct {
+	now   time.Time
+	loc   *time.Location
+	free  []interval
+	gap   time.Duration
+	until time.Time
+}
+
+// plan schedules the tasks. Tasks due earlier go first; among tasks due on the same day the
+// higher priority goes first. Successors are only placed after their predecessors end.
+func (p *planner) plan(tasks []models.Task, constraints map[string]planConstraint) ([]PlannedTask, []UnplacedTask) {
+	pending := append([]models.Task(nil), tasks...)
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := pending[i], pending[j]
+		dayA, dayB := dayOf(a.Deadline, p.loc), dayOf(b.Deadline, p.loc)
+		if !dayA.Equal(dayB) {
+			return dayA.Before(dayB)
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !a.Deadline.Equal(b.Deadline) {
+			return a.Deadline.Before(b.Deadline)
+		}
+		return a.ID < b.ID
+	})
+
+	var planned []PlannedTask
+	var unplaced []UnplacedTask
+	ends := make(map[string]time.Time) // planned end of placed tasks
+	failed := make(map[string]string)  // names of tasks that could not be placed
+	decided := make(map[string]bool, len(pending))
+	for len(decided) < len(pending) {
+		progressed := false
+		for _, t := range pending {
+			if decided[t.ID] {
+				continue
+			}
+			constraint := constraints[t.ID]
+			ready := true
+			for _, predecessorID := range constraint.predecessors {
+				if !decided[predecessorID] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			decided[t.ID] = true
+			progressed = true
+			notBefore := constraint.notBefore
+			blockedBy := constraint.blockedBy
+			for _, predecessorID := range constraint.predecessors {
+				if name, ok := failed[predecessorID]; ok && blockedBy == "" {
+					blockedBy = name
+				}
+				if end := ends[predecessorID]; end.After(notBefore) {
+					notBefore = end
+				}
+			}
+
+			var reason string
+			if blockedBy != "" {
+				reason = fmt.Sprintf("predecessor %q cannot be scheduled", blockedBy)
+			} else if item, ok := p.place(t, notBefore); ok {
+				planned = append(planned, item)
+				ends[t.ID] = item.EndTime
+				break
+			} else {
+				reason = p.explain(t)
+			}
+			failed[t.ID] = t.Name
+			unplaced = append(unplaced, UnplacedTask{TaskID: t.ID, Name: t.Name, Reason: reason})
+			break
+		}
+		if !progressed {
+			// Only reachable with a dependency cycle
+			for _, t := range pending {
+				if !decided[t.ID] {
+					decided[t.ID] = true
+					unplaced = append(unplaced, UnplacedTask{TaskID: t.ID, Name: t.Name, Reason: "dependency cycle"})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(planned, func(i, j int) bool { return planned[i].ScheduleTime.Before(planned[j].ScheduleTime) })
+	return planned, unplaced
+}
+
+// place reserves the first free slot that fits the task before its deadline
+func (p *planner) place(t models.Task, notBefore time.Time) (PlannedTask, bool) {
+	length := time.Duration(t.Minutes) * time.Minute
+	for i, slot := range p.free {
+		start := slot.start
+		if notBefore.After(start) {
+			start = notBefore
+		}
+		end := start.Add(length)
+		if end.After(slot.end) || end.After(t.Deadline) {
+			continue
+		}
+
+		var rest []interval
+		if start.After(slot.start) {
+			rest = append(rest, interval{start: slot.start, end: start})
+		}
+		if after := end.Add(p.gap); slot.end.After(after) {
+			rest = append(rest, interval{start: after, end: slot.end})
+		}
+		p.free = append(p.free[:i], append(rest, p.free[i+1:]...)...)
+
+		item := PlannedTask{
+			TaskID:       t.ID,
+			Name:         t.Name,
+			Priority:     t.Priority,
+			Minutes:      t.Minutes,
+			Deadline:     t.Deadline,
+			ScheduleTime: start,
+			EndTime:      end,
+		}
+		if t.IsScheduled() {
+			item.PreviousScheduleTime = &t.ScheduleTime
+		}
+		return item, true
+	}
+	return PlannedTask{}, false
+}
+
+// explain says why a task did not fit
+func (p *planner) explain(t models.Task) string {
+	if !t.Deadline.After(p.now) {
+		return "deadline has passed"
+	}
+	var longest time.Duration
+	for _, slot := range p.free {
+		if slot.duration() > longest {
+			longest = slot.duration()
+		}
+	}
+	if time.Duration(t.Minutes)*time.Minute > longest {
+		return fmt.Sprintf("needs %d minutes but the longest free slot is %d minutes, consider splitting it into subtasks",
+			t.Minutes, int(longest/time.Minute))
+	}
+	if t.Deadline.After(p.until) {
+		return "no free slot within the planning horizon"
+	}
+	return "no free slot before the deadline"
+}
+
+func dayOf(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+func TestWorkingWeek_Windows(t *testing.T) {
+	week, err := WorkingHours{Start: "09:00", End: "12:30", Days: []string{"mon", "wed"}, Timezone: "UTC"}.parse()
+	require.NoError(t, err)
+
+	// Monday 2025-03-10 10:00 until Thursday 2025-03-13 00:00
+	from := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	windows := week.windows(from, from.Add(62*time.Hour))
+	require.Len(t, windows, 2)
+	assert.Equal(t, from, windows[0].start)
+	assert.Equal(t, time.Date(2025, 3, 10, 12, 30, 0, 0, time.UTC), windows[0].end)
+	assert.Equal(t, time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC), windows[1].start)
+
+	_, err = WorkingHours{Start: "18:00", End: "09:00"}.parse()
+	assert.Error(t, err)
+	_, err = WorkingHours{Days: []string{"someday"}}.parse()
+	assert.Error(t, err)
+	_, err = WorkingHours{Start: "9am"}.parse()
+	assert.Error(t, err)
+}
+
+func TestSubtract(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2025, 3, 10, hour, minute, 0, 0, time.UTC) }
+	free := []interval{{start: at(9, 0), end: at(12, 0)}}
+	busy := []interval{
+		{start: at(10, 30), end: at(11, 0)},
+		{start: at(8, 0), end: at(9, 30)},
+		{start: at(10, 40), end: at(10, 50)}, // inside another busy interval
+	}
+
+	assert.Equal(t, []interval{
+		{start: at(9, 30), end: at(10, 30)},
+		{start: at(11, 0), end: at(12, 0)},
+	}, subtract(free, busy))
+}
+
+func TestPlanner_Plan(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC) }
+	p := &planner{
+		now: at(10, 8),
+		loc: time.UTC,
+		free: []interval{
+			{start: at(10, 9), end: at(10, 12)},
+			{start: at(11, 9), end: at(11, 12)},
+		},
+		until: at(12, 0),
+	}
+	tasks := []models.Task{
+		{ID: "later", Name: "Later", Priority: 3, Minutes: 60, Deadline: at(20, 0)},
+		{ID: "low", Name: "Low", Priority: 1, Minutes: 60, Deadline: at(10, 18)},
+		{ID: "high", Name: "High", Priority: 3, Minutes: 60, Deadline: at(10, 18)},
+		{ID: "after-high", Name: "After high", Priority: 2, Minutes: 60, Deadline: at(11, 18)},
+		{ID: "too-long", Name: "Too long", Priority: 2, Minutes: 240, Deadline: at(11, 18)},
+		{ID: "blocked", Name: "Blocked", Priority: 2, Minutes: 30, Deadline: at(11, 18)},
+		{ID: "overdue", Name: "Overdue", Priority: 2, Minutes: 30, Deadline: at(9, 18)},
+	}
+	constraints := map[string]planConstraint{
+		"after-high": {predecessors: []string{"high"}, notBefore: at(10, 11)},
+		"blocked":    {predecessors: []string{"too-long"}},
+	}
+
+	planned, unplaced := p.plan(tasks, constraints)
+
+	starts := make(map[string]time.Time)
+	for _, item := range planned {
+		starts[item.TaskID] = item.ScheduleTime
+		assert.Nil(t, item.PreviousScheduleTime)
+	}
+	// Same deadline day: higher priority first
+	assert.Equal(t, at(10, 9), starts["high"])
+	assert.Equal(t, at(10, 10), starts["low"])
+	// Waits for its scheduled predecessor outside the plan
+	assert.Equal(t, at(10, 11), starts["after-high"])
+	assert.Equal(t, at(11, 9), starts["later"])
+	assert.Len(t, planned, 4)
+
+	reasons := make(map[string]string)
+	for _, item := range unplaced {
+		reasons[item.TaskID] = item.Reason
+	}
+	assert.Equal(t, "deadline has passed", reasons["overdue"])
+	assert.Contains(t, reasons["too-long"], "longest free slot is 180 minutes")
+	assert.Equal(t, `predecessor "Too long" cannot be scheduled`, reasons["blocked"])
+}
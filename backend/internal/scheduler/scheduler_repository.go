@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
+	"gorm.io/gorm"
+)
+
+// SchedulerRepository provides read access to the tasks and reminders a schedule is planned around
+type SchedulerRepository struct {
+	db *gorm.DB
+}
+
+func NewSchedulerRepository() *SchedulerRepository {
+	return &SchedulerRepository{db: database.GetDB()}
+}
+
+// FindCandidates returns a user's pending tasks scheduled before the cutoff, which includes
+// every unscheduled task. Repeating task templates and tasks with subtasks are left out, their
+// occurrences and subtasks are what gets scheduled.
+func (r *SchedulerRepository) FindCandidates(realmID, userID string, cutoff time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.Where("realm_id = ? AND created_by = ? AND status = ?", realmID, userID, models.TaskStatusPending).
+		Where("is_repeating = ? OR parent_task_id IS NOT NULL", false).
+		Where("subtask_count = 0 AND schedule_time < ?", cutoff).
+		Order("deadline ASC").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// FindScheduledTasks returns a user's open tasks that start within a time range
+func (r *SchedulerRepository) FindScheduledTasks(realmID, userID string, from, to time.Time) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.Where("realm_id = ? AND created_by = ? AND status IN (?, ?, ?)", realmID, userID,
+		models.TaskStatusPending, models.TaskStatusRunning, models.TaskStatusPaused).
+		Where("subtask_count = 0 AND schedule_time >= ? AND schedule_time < ?", from, to).
+		Order("schedule_time ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// FindReminders returns a user's open reminders within a time range
+func (r *SchedulerRepository) FindReminders(realmID, userID string, from, to time.Time) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Where("realm_id = ? AND created_by = ? AND status IN ?", realmID, userID, []string{"pending", "active"}).
+		Where("remind_time >= ? AND remind_time < ?", from, to).
+		Order("remind_time ASC").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// FindPredecessors returns the unfinished predecessors of the given tasks with the edges to them
+func (r *SchedulerRepository) FindPredecessors(successorIDs []string) ([]models.TaskDependency, []models.Task, error) {
+	var dependencies []models.TaskDependency
+	if len(successorIDs) == 0 {
+		return nil, nil, nil
+	}
+	if err := r.db.Where("successor_id IN ?", successorIDs).Find(&dependencies).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(dependencies) == 0 {
+		return nil, nil, nil
+	}
+
+	ids := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		ids = append(ids, dependency.PredecessorID)
+	}
+	var predecessors []models.Task
+	err := r.db.Where("id IN ? AND status <> ?", ids, models.TaskStatusCompleted).Find(&predecessors).Error
+	return dependencies, predecessors, err
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+)
+
+// RegisterRoutes registers HTTP endpoints for automatic scheduling
+func RegisterRoutes(router *gin.Engine, service *SchedulerService, middleware *auth.AuthMiddleware) {
+	group := router.Group("/api/v1/schedule")
+	group.Use(middleware.Authenticate())
+
+	// POST /api/v1/schedule/plan - Propose schedule times for unscheduled tasks, nothing is saved
+	group.POST("/plan", func(c *gin.Context) {
+		var req PlanRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		plan, err := service.Plan(req, realmID, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, plan)
+	})
+
+	// POST /api/v1/schedule/apply - Save the reviewed items of a plan
+	group.POST("/apply", func(c *gin.Context) {
+		var req ApplyPlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		username, _ := auth.GetCurrentUsername(c)
+		result, err := service.ApplyPlan(req, realmID, userID, username)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/task"
+)
+
+const (
+	defaultHorizonDays     = 14
+	maxHorizonDays         = 90
+	defaultReminderMinutes = 15
+	maxCandidates          = 500
+
+	// scheduledLookback catches tasks that started before the plan and are still running into it
+	scheduledLookback = 24 * time.Hour
+)
+
+// unscheduledCutoff is later than the zero schedule time of unscheduled tasks and earlier than any real one
+var unscheduledCutoff = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SchedulerService proposes schedule times for unscheduled tasks and applies reviewed plans
+type SchedulerService struct {
+	repo        *SchedulerRepository
+	taskService *task.TaskService
+}
+
+func NewSchedulerService(repo *SchedulerRepository, taskService *task.TaskService) *SchedulerService {
+	return &SchedulerService{repo: repo, taskService: taskService}
+}
+
+// PlanRequest describes when tasks may be scheduled
+type PlanRequest struct {
+	WorkingHours    WorkingHours `json:"working_hours"`
+	From            *time.Time   `json:"from"`             // defaults to now
+	Days            int          `json:"days"`             // planning horizon, defaults to 14
+	BreakMinutes    int          `json:"break_minutes"`    // time left free after each planned task
+	ReminderMinutes int          `json:"reminder_minutes"` // time blocked at each reminder, defaults to 15
+	IncludeMissed   bool         `json:"include_missed"`   // also re-plan pending tasks whose schedule time has passed
+}
+
+// PlannedTask is a proposed schedule time for a task
+type PlannedTask struct {
+	TaskID               string     `json:"task_id" binding:"required"`
+	Name                 string     `json:"name"`
+	Priority             int        `json:"priority"`
+	Minutes              int        `json:"minutes"`
+	Deadline             time.Time  `json:"deadline"`
+	ScheduleTime         time.Time  `json:"schedule_time" binding:"required"`
+	EndTime              time.Time  `json:"end_time"`
+	PreviousScheduleTime *time.Time `json:"previous_schedule_time"` // nil for unscheduled tasks
+}
+
+// UnplacedTask is a task the plan has no slot for
+type UnplacedTask struct {
+	TaskID string `json:"task_id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// SchedulePlan is a proposal to review before it is applied
+type SchedulePlan struct {
+	From     time.Time      `json:"from"`
+	Until    time.Time      `json:"until"`
+	Timezone string         `json:"timezone"`
+	Items    []PlannedTask  `json:"items"`
+	Unplaced []UnplacedTask `json:"unplaced"`
+}
+
+// ApplyPlanRequest carries the reviewed items of a plan, possibly edited or trimmed
+type ApplyPlanRequest struct {
+	Items []PlannedTask `json:"items" binding:"required,dive"`
+}
+
+// ApplyResult reports which plan items were applied
+type ApplyResult struct {
+	Applied []string       `json:"applied"`
+	Skipped []UnplacedTask `json:"skipped"`
+}
+
+// Plan proposes a schedule time for each of the user's unscheduled pending tasks. Nothing is
+// saved; the plan is returned for review and applied with ApplyPlan.
+func (s *SchedulerService) Plan(req PlanRequest, realmID, userID string) (*SchedulePlan, error) {
+	week, err := req.WorkingHours.parse()
+	if err != nil {
+		return nil, err
+	}
+	if req.Days == 0 {
+		req.Days = defaultHorizonDays
+	}
+	if req.Days < 1 || req.Days > maxHorizonDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", maxHorizonDays)
+	}
+	if req.BreakMinutes < 0 || req.ReminderMinutes < 0 {
+		return nil, errors.New("break_minutes and reminder_minutes cannot be negative")
+	}
+	if req.ReminderMinutes == 0 {
+		req.ReminderMinutes = defaultReminderMinutes
+	}
+
+	now := time.Now()
+	from := now
+	if req.From != nil && req.From.After(now) {
+		from = *req.From
+	}
+	from = from.Truncate(time.Minute).In(week.loc)
+	until := from.AddDate(0, 0, req.Days)
+
+	cutoff := unscheduledCutoff
+	if req.IncludeMissed {
+		cutoff = now
+	}
+	candidates, err := s.repo.FindCandidates(realmID, userID, cutoff, maxCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	scheduled, err := s.repo.FindScheduledTasks(realmID, userID, from.Add(-scheduledLookback), until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled tasks: %w", err)
+	}
+	reminders, err := s.repo.FindReminders(realmID, userID, from, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminders: %w", err)
+	}
+
+	isCandidate := make(map[string]bool, len(candidates))
+	ids := make([]string, 0, len(candidates))
+	for _, t := range candidates {
+		isCandidate[t.ID] = true
+		ids = append(ids, t.ID)
+	}
+
+	var busy []interval
+	for _, t := range scheduled {
+		if !isCandidate[t.ID] {
+			busy = append(busy, interval{start: t.ScheduleTime, end: t.ScheduleTime.Add(time.Duration(t.Minutes) * time.Minute)})
+		}
+	}
+	for _, r := range reminders {
+		busy = append(busy, interval{start: r.RemindTime, end: r.RemindTime.Add(time.Duration(req.ReminderMinutes) * time.Minute)})
+	}
+
+	constraints, err := s.constraints(ids, isCandidate)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &planner{
+		now:   now,
+		loc:   week.loc,
+		free:  subtract(week.windows(from, until), busy),
+		gap:   time.Duration(req.BreakMinutes) * time.Minute,
+		until: until,
+	}
+	items, unplaced := p.plan(candidates, constraints)
+	return &SchedulePlan{
+		From:     from,
+		Until:    until,
+		Timezone: week.loc.String(),
+		Items:    items,
+		Unplaced: unplaced,
+	}, nil
+}
+
+// constraints collects the unfinished predecessors of the candidate tasks
+func (s *SchedulerService) constraints(ids []string, isCandidate map[string]bool) (map[string]planConstraint, error) {
+	dependencies, predecessors, err := s.repo.FindPredecessors(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task dependencies: %w", err)
+	}
+
+	unfinished := make(map[string]models.Task, len(predecessors))
+	for _, t := range predecessors {
+		unfinished[t.ID] = t
+	}
+	constraints := make(map[string]planConstraint)
+	for _, dependency := range dependencies {
+		predecessor, ok := unfinished[dependency.PredecessorID]
+		if !ok {
+			continue
+		}
+		constraint := constraints[dependency.SuccessorID]
+		switch {
+		case isCandidate[predecessor.ID]:
+			constraint.predecessors = append(constraint.predecessors, predecessor.ID)
+		case predecessor.Status != models.TaskStatusFailed && predecessor.IsScheduled():
+			// Open predecessors are assumed to finish as scheduled
+			end := predecessor.ScheduleTime.Add(time.Duration(predecessor.Minutes) * time.Minute)
+			if end.After(constraint.notBefore) {
+				constraint.notBefore = end
+			}
+		default:
+			// Failed, or unscheduled and not part of this plan
+			constraint.blockedBy = predecessor.Name
+		}
+		constraints[dependency.SuccessorID] = constraint
+	}
+	return constraints, nil
+}
+
+// ApplyPlan saves the schedule times of a reviewed plan. Items whose task changed since the plan
+// was made, or that no longer fit the task, are skipped rather than failing the whole plan.
+func (s *SchedulerService) ApplyPlan(req ApplyPlanRequest, realmID, userID, updatedBy string) (*ApplyResult, error) {
+	if len(req.Items) == 0 {
+		return nil, errors.New("items are required")
+	}
+
+	result := &ApplyResult{Applied: []string{}, Skipped: []UnplacedTask{}}
+	for _, item := range req.Items {
+		name, err := s.applyItem(item, realmID, userID, updatedBy)
+		if err != nil {
+			result.Skipped = append(result.Skipped, UnplacedTask{TaskID: item.TaskID, Name: name, Reason: err.Error()})
+			continue
+		}
+		result.Applied = append(result.Applied, item.TaskID)
+	}
+	return result, nil
+}
+
+func (s *SchedulerService) applyItem(item PlannedTask, realmID, userID, updatedBy string) (string, error) {
+	t, err := s.taskService.GetTask(item.TaskID)
+	if err != nil || t.RealmID != realmID || t.CreatedBy != userID {
+		return item.Name, errors.New("task not found")
+	}
+	if t.Status != models.TaskStatusPending {
+		return t.Name, fmt.Errorf("task is %s", t.Status)
+	}
+	switch {
+	case item.PreviousScheduleTime == nil && t.IsScheduled(),
+		item.PreviousScheduleTime != nil && !item.PreviousScheduleTime.Equal(t.ScheduleTime):
+		return t.Name, errors.New("task was rescheduled since the plan was made")
+	}
+	if item.ScheduleTime.Before(time.Now().Add(-time.Minute)) {
+		return t.Name, errors.New("schedule_time is in the past")
+	}
+	if item.ScheduleTime.Add(time.Duration(t.Minutes) * time.Minute).After(t.Deadline) {
+		return t.Name, errors.New("task would end after its deadline")
+	}
+
+	updated, err := s.taskService.UpdateTask(t.ID, task.UpdateTaskRequest{ScheduleTime: &item.ScheduleTime}, updatedBy)
+	if err != nil {
+		return t.Name, err
+	}
+	if updated.ShouldGenerateReminders() {
+		// Missed tasks already got a reminder for their previous schedule time
+		if item.PreviousScheduleTime == nil {
+			err = s.taskService.GenerateRemindersForTask(updated)
+		} else {
+			err = s.taskService.RescheduleReminders(updated, *item.PreviousScheduleTime)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to generate reminder for task %s: %v\n", updated.ID, err)
+		}
+	}
+	return t.Name, nil
+}
@@ -0,0 +1,168 @@
+package secret
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// KeyProvider wraps and unwraps data encryption keys (DEKs) with a key encryption key (KEK).
+// Implementations decide where KEK material lives: environment variables, a keyring file
+// or an external KMS. The wrapped form is opaque to SecretService and stored as-is in
+// SecretVersion.WrappedDEK together with the KEK version that produced it.
+type KeyProvider interface {
+	// CurrentVersion returns the KEK version new DEKs are wrapped with
+	CurrentVersion() (int, error)
+	// Wrap wraps a DEK with the current KEK and returns the wrapped DEK and the KEK version used
+	Wrap(dek []byte) (string, int, error)
+	// Unwrap recovers a DEK that was wrapped with the given KEK version
+	Unwrap(wrapped string, kekVersion int) ([]byte, error)
+}
+
+// customKEKVersion marks versions wrapped with a user supplied KEK; no provider can unwrap them
+const customKEKVersion = 999
+
+// Key provider types accepted by NewKeyProviderFromConfig
+const (
+	KeyProviderEnv   = "env"
+	KeyProviderFile  = "file"
+	KeyProviderVault = "vault"
+)
+
+// NewKeyProviderFromConfig builds the configured KeyProvider.
+// Environment variables are read first and overridden by the "secret.key_provider" config section:
+//
+//	KEK_PROVIDER            env (default), file or vault
+//	KEK_KEYRING_FILE        keyring path for the file provider
+//	VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE, VAULT_TRANSIT_MOUNT, VAULT_TRANSIT_KEY for the vault provider
+func NewKeyProviderFromConfig() (KeyProvider, error) {
+	providerType := getConfigString("secret.key_provider.type", "KEK_PROVIDER", KeyProviderEnv)
+
+	switch strings.ToLower(providerType) {
+	case KeyProviderEnv:
+		return NewEnvKeyProvider(), nil
+	case KeyProviderFile:
+		path := getConfigString("secret.key_provider.keyring_file", "KEK_KEYRING_FILE", "")
+		if path == "" {
+			return nil, fmt.Errorf("keyring file is required for the %s key provider; set KEK_KEYRING_FILE", KeyProviderFile)
+		}
+		return NewFileKeyProvider(path)
+	case KeyProviderVault:
+		return NewVaultTransitKeyProvider(VaultTransitConfig{
+			Address:   getConfigString("secret.key_provider.vault.address", "VAULT_ADDR", ""),
+			Token:     getConfigString("secret.key_provider.vault.token", "VAULT_TOKEN", ""),
+			Namespace: getConfigString("secret.key_provider.vault.namespace", "VAULT_NAMESPACE", ""),
+			Mount:     getConfigString("secret.key_provider.vault.mount", "VAULT_TRANSIT_MOUNT", "transit"),
+			KeyName:   getConfigString("secret.key_provider.vault.key_name", "VAULT_TRANSIT_KEY", ""),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported key provider: %s", providerType)
+	}
+}
+
+func getConfigString(configKey, envKey, defaultValue string) string {
+	value := defaultValue
+	if v := os.Getenv(envKey); v != "" {
+		value = v
+	}
+	if viper.IsSet(configKey) {
+		value = viper.GetString(configKey)
+	}
+	return value
+}
+
+func getConfigBool(configKey, envKey string, defaultValue bool) bool {
+	value := defaultValue
+	if v, err := strconv.ParseBool(os.Getenv(envKey)); err == nil {
+		value = v
+	}
+	if viper.IsSet(configKey) {
+		value = viper.GetBool(configKey)
+	}
+	return value
+}
+
+// localKeyProvider wraps DEKs locally with AES-256-GCM using KEKs looked up by version
+type localKeyProvider struct {
+	currentVersion func() int
+	loadKEK        func(version int) ([]byte, error)
+}
+
+func (p *localKeyProvider) CurrentVersion() (int, error) {
+	return p.currentVersion(), nil
+}
+
+func (p *localKeyProvider) Wrap(dek []byte) (string, int, error) {
+	version := p.currentVersion()
+	kek, err := p.loadKEK(version)
+	if err != nil {
+		return "", 0, err
+	}
+	defer zeroBytes(kek)
+
+	wrapped, err := wrapDEK(kek, dek)
+	if err != nil {
+		return "", 0, err
+	}
+	return wrapped, version, nil
+}
+
+func (p *localKeyProvider) Unwrap(wrapped string, kekVersion int) ([]byte, error) {
+	kek, err := p.loadKEK(kekVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(kek)
+
+	return unwrapDEK(kek, wrapped)
+}
+
+// NewEnvKeyProvider returns a KeyProvider that reads KEKs from KEK_BASE64_<n> or KEK_<n>,
+// with the current version selected by KEK_VERSION
+func NewEnvKeyProvider() KeyProvider {
+	return &localKeyProvider{
+		currentVersion: parseKEKVersion,
+		loadKEK:        loadKEKByVersionInt,
+	}
+}
+
+func loadKEKByVersionInt(kekVersion int) ([]byte, error) {
+	// Handle custom KEK version
+	if kekVersion == customKEKVersion {
+		return nil, fmt.Errorf("custom KEK version %d requires explicit KEK parameter", customKEKVersion)
+	}
+
+	// Prefer base64-encoded KEK
+	if b64 := os.Getenv("KEK_BASE64_" + strconv.Itoa(kekVersion)); b64 != "" {
+		key, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KEK_BASE64_%d: %w", kekVersion, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("KEK_%d must be 32 bytes for AES-256-GCM, got %d", kekVersion, len(key))
+		}
+		return key, nil
+	}
+	// Fallback: raw key in KEK (must be 32 bytes)
+	if raw := os.Getenv("KEK_" + strconv.Itoa(kekVersion)); raw != "" {
+		key := []byte(raw)
+		if len(key) != 32 {
+			return nil, fmt.Errorf("KEK_%d must be 32 bytes for AES-256-GCM, got %d", kekVersion, len(key))
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("KEK_%d not configured; set KEK_BASE64_%d or KEK_%d", kekVersion, kekVersion, kekVersion)
+}
+
+func parseKEKVersion() int {
+	if v := os.Getenv("KEK_VERSION"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
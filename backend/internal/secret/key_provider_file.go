@@ -0,0 +1,81 @@
+package secret
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileKeyring is the on-disk format read by the file key provider:
+//
+//	current_version: 2
+//	keys:
+//	  1: "<base64 32-byte key>"
+//	  2: "<base64 32-byte key>"
+type FileKeyring struct {
+	CurrentVersion int            `yaml:"current_version"`
+	Keys           map[int]string `yaml:"keys"`
+}
+
+// NewFileKeyProvider loads a keyring file and returns a KeyProvider backed by it.
+// The keys are decoded and validated once, so a broken keyring fails at startup.
+func NewFileKeyProvider(path string) (KeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file %s: %w", path, err)
+	}
+
+	var keyring FileKeyring
+	if err := yaml.Unmarshal(data, &keyring); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring file %s: %w", path, err)
+	}
+
+	keys, err := decodeKeyring(keyring)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyring file %s: %w", path, err)
+	}
+
+	current := keyring.CurrentVersion
+	return &localKeyProvider{
+		currentVersion: func() int { return current },
+		loadKEK: func(version int) ([]byte, error) {
+			key, ok := keys[version]
+			if !ok {
+				return nil, fmt.Errorf("KEK version %d not found in keyring", version)
+			}
+			// Hand out a copy so callers can wipe it
+			return append([]byte(nil), key...), nil
+		},
+	}, nil
+}
+
+func decodeKeyring(keyring FileKeyring) (map[int][]byte, error) {
+	if keyring.CurrentVersion <= 0 {
+		return nil, fmt.Errorf("current_version must be greater than 0")
+	}
+	if keyring.CurrentVersion == customKEKVersion {
+		return nil, fmt.Errorf("KEK version %d is reserved for custom KEKs", customKEKVersion)
+	}
+
+	keys := make(map[int][]byte, len(keyring.Keys))
+	for version, encoded := range keyring.Keys {
+		if version == customKEKVersion {
+			return nil, fmt.Errorf("KEK version %d is reserved for custom KEKs", customKEKVersion)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("KEK version %d is not valid base64: %w", version, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("KEK version %d must be 32 bytes for AES-256-GCM, got %d", version, len(key))
+		}
+		keys[version] = key
+	}
+
+	if _, ok := keys[keyring.CurrentVersion]; !ok {
+		return nil, fmt.Errorf("current_version %d has no key", keyring.CurrentVersion)
+	}
+	return keys, nil
+}
@@ -0,0 +1,225 @@
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// FILE KEYRING TESTS
+// =============================================================================
+
+func writeKeyring(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "keyring.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestFileKeyProvider_WrapUnwrap(t *testing.T) {
+	path := writeKeyring(t, fmt.Sprintf("current_version: 2\nkeys:\n  1: %q\n  2: %q\n", testKey('a'), testKey('b')))
+
+	provider, err := NewFileKeyProvider(path)
+	require.NoError(t, err)
+
+	version, err := provider.CurrentVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	dek := generateRandomBytes(32)
+	wrapped, kekVersion, err := provider.Wrap(dek)
+	require.NoError(t, err)
+	assert.Equal(t, 2, kekVersion)
+
+	unwrapped, err := provider.Unwrap(wrapped, kekVersion)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	// A DEK wrapped with the older key version can still be unwrapped
+	oldKEK, _ := base64.StdEncoding.DecodeString(testKey('a'))
+	oldWrapped, err := wrapDEK(oldKEK, dek)
+	require.NoError(t, err)
+	unwrapped, err = provider.Unwrap(oldWrapped, 1)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	// Unwrapping with the wrong version fails authentication
+	_, err = provider.Unwrap(oldWrapped, 2)
+	assert.Error(t, err)
+
+	_, err = provider.Unwrap(wrapped, 3)
+	assert.ErrorContains(t, err, "not found in keyring")
+}
+
+func TestFileKeyProvider_InvalidKeyrings(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{"missing current version", fmt.Sprintf("keys:\n  1: %q\n", testKey('a')), "current_version must be greater than 0"},
+		{"current version without key", fmt.Sprintf("current_version: 2\nkeys:\n  1: %q\n", testKey('a')), "current_version 2 has no key"},
+		{"short key", "current_version: 1\nkeys:\n  1: \"c2hvcnQ=\"\n", "must be 32 bytes"},
+		{"invalid base64", "current_version: 1\nkeys:\n  1: \"***\"\n", "not valid base64"},
+		{"reserved version", fmt.Sprintf("current_version: 1\nkeys:\n  1: %q\n  999: %q\n", testKey('a'), testKey('b')), "reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFileKeyProvider(writeKeyring(t, tt.content))
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+// =============================================================================
+// ENV PROVIDER TESTS
+// =============================================================================
+
+func TestEnvKeyProvider_WrapUnwrap(t *testing.T) {
+	t.Setenv("KEK_VERSION", "3")
+	t.Setenv("KEK_BASE64_3", testKey('c'))
+
+	provider := NewEnvKeyProvider()
+	dek := generateRandomBytes(32)
+
+	wrapped, kekVersion, err := provider.Wrap(dek)
+	require.NoError(t, err)
+	assert.Equal(t, 3, kekVersion)
+
+	unwrapped, err := provider.Unwrap(wrapped, kekVersion)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	_, err = provider.Unwrap(wrapped, customKEKVersion)
+	assert.ErrorContains(t, err, "requires explicit KEK parameter")
+}
+
+// =============================================================================
+// VAULT TRANSIT TESTS (local stub server)
+// =============================================================================
+
+// newVaultTransitStub emulates the transit encrypt, decrypt and keys endpoints.
+// Ciphertexts are "vault:v<latest>:<base64 plaintext>", which is enough to check the protocol.
+func newVaultTransitStub(t *testing.T, latestVersion int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+
+		var body map[string]string
+		if r.Body != nil {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/secretary":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"latest_version": latestVersion}})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/encrypt/secretary":
+			ciphertext := fmt.Sprintf("vault:v%d:%s", latestVersion, body["plaintext"])
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": ciphertext}})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/decrypt/secretary":
+			parts := strings.SplitN(body["ciphertext"], ":", 3)
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": parts[2]}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestVaultTransitKeyProvider_WrapUnwrap(t *testing.T) {
+	server := newVaultTransitStub(t, 4)
+	defer server.Close()
+
+	provider, err := NewVaultTransitKeyProvider(VaultTransitConfig{
+		Address: server.URL,
+		Token:   "test-token",
+		KeyName: "secretary",
+	})
+	require.NoError(t, err)
+
+	version, err := provider.CurrentVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	dek := generateRandomBytes(32)
+	wrapped, kekVersion, err := provider.Wrap(dek)
+	require.NoError(t, err)
+	assert.Equal(t, 4, kekVersion)
+	assert.True(t, strings.HasPrefix(wrapped, "vault:v4:"))
+
+	unwrapped, err := provider.Unwrap(wrapped, kekVersion)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	_, err = provider.Unwrap(wrapped, 3)
+	assert.ErrorContains(t, err, "expected 3")
+}
+
+func TestVaultTransitKeyProvider_Errors(t *testing.T) {
+	server := newVaultTransitStub(t, 1)
+	defer server.Close()
+
+	provider, err := NewVaultTransitKeyProvider(VaultTransitConfig{
+		Address: server.URL,
+		Token:   "wrong-token",
+		KeyName: "secretary",
+	})
+	require.NoError(t, err)
+
+	_, _, err = provider.Wrap(generateRandomBytes(32))
+	assert.ErrorContains(t, err, "permission denied")
+
+	_, err = NewVaultTransitKeyProvider(VaultTransitConfig{Address: server.URL, Token: "test-token"})
+	assert.ErrorContains(t, err, "key name is required")
+
+	_, err = parseVaultCiphertextVersion("vault:vx:abc")
+	assert.Error(t, err)
+}
+
+// =============================================================================
+// ENVELOPE ENCRYPTION TESTS
+// =============================================================================
+
+func TestEnvelopeEncrypt_RoundTrip(t *testing.T) {
+	path := writeKeyring(t, fmt.Sprintf("current_version: 1\nkeys:\n  1: %q\n", testKey('a')))
+	provider, err := NewFileKeyProvider(path)
+	require.NoError(t, err)
+	service := &SecretService{keys: provider}
+
+	t.Run("Provider KEK", func(t *testing.T) {
+		sealed, err := service.envelopeEncrypt([]byte("s3cr3t"), "")
+		require.NoError(t, err)
+		assert.Equal(t, 1, sealed.KEKVersion)
+
+		dek, err := provider.Unwrap(sealed.WrappedDEK, sealed.KEKVersion)
+		require.NoError(t, err)
+		plaintext, err := decryptVersionWithDEK(sealed, dek)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", plaintext)
+	})
+
+	t.Run("Custom KEK", func(t *testing.T) {
+		sealed, err := service.envelopeEncrypt([]byte("s3cr3t"), "my custom passphrase")
+		require.NoError(t, err)
+		assert.Equal(t, customKEKVersion, sealed.KEKVersion)
+
+		_, err = provider.Unwrap(sealed.WrappedDEK, sealed.KEKVersion)
+		assert.Error(t, err)
+	})
+}
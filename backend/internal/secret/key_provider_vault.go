@@ -0,0 +1,180 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VaultTransitConfig configures the Vault Transit key provider
+type VaultTransitConfig struct {
+	Address   string // e.g. https://vault.example.com:8200
+	Token     string
+	Namespace string // optional, Vault Enterprise namespaces
+	Mount     string // transit secrets engine mount path, defaults to "transit"
+	KeyName   string // name of the transit key used as KEK
+	Timeout   time.Duration
+}
+
+// VaultTransitKeyProvider wraps DEKs with a HashiCorp Vault Transit key so the KEK never
+// leaves Vault. Any server implementing the transit encrypt, decrypt and keys endpoints works.
+type VaultTransitKeyProvider struct {
+	config VaultTransitConfig
+	client *http.Client
+}
+
+// NewVaultTransitKeyProvider validates the configuration and returns a Vault Transit KeyProvider
+func NewVaultTransitKeyProvider(config VaultTransitConfig) (*VaultTransitKeyProvider, error) {
+	if strings.TrimSpace(config.Address) == "" {
+		return nil, errors.New("vault address is required; set VAULT_ADDR")
+	}
+	if strings.TrimSpace(config.Token) == "" {
+		return nil, errors.New("vault token is required; set VAULT_TOKEN")
+	}
+	if strings.TrimSpace(config.KeyName) == "" {
+		return nil, errors.New("vault transit key name is required; set VAULT_TRANSIT_KEY")
+	}
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	config.Address = strings.TrimRight(config.Address, "/")
+	config.Mount = strings.Trim(config.Mount, "/")
+
+	return &VaultTransitKeyProvider{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// CurrentVersion returns the latest version of the transit key
+func (p *VaultTransitKeyProvider) CurrentVersion() (int, error) {
+	var resp struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := p.do(http.MethodGet, "keys/"+p.config.KeyName, nil, &resp); err != nil {
+		return 0, fmt.Errorf("failed to read vault transit key: %w", err)
+	}
+	if resp.Data.LatestVersion <= 0 {
+		return 0, errors.New("vault transit key has no latest_version")
+	}
+	return resp.Data.LatestVersion, nil
+}
+
+// Wrap encrypts the DEK with the latest transit key version and returns the vault ciphertext
+func (p *VaultTransitKeyProvider) Wrap(dek []byte) (string, int, error) {
+	req := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dek)}
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := p.do(http.MethodPost, "encrypt/"+p.config.KeyName, req, &resp); err != nil {
+		return "", 0, fmt.Errorf("failed to wrap DEK with vault: %w", err)
+	}
+
+	version, err := parseVaultCiphertextVersion(resp.Data.Ciphertext)
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.Data.Ciphertext, version, nil
+}
+
+// Unwrap decrypts a vault ciphertext and returns the DEK
+func (p *VaultTransitKeyProvider) Unwrap(wrapped string, kekVersion int) ([]byte, error) {
+	version, err := parseVaultCiphertextVersion(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if version != kekVersion {
+		return nil, fmt.Errorf("wrapped DEK was produced by key version %d, expected %d", version, kekVersion)
+	}
+
+	req := map[string]string{"ciphertext": wrapped}
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := p.do(http.MethodPost, "decrypt/"+p.config.KeyName, req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unwrap DEK with vault: %w", err)
+	}
+
+	dek, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode vault plaintext: %w", err)
+	}
+	return dek, nil
+}
+
+// do sends a request to the transit mount and decodes the JSON response into out
+func (p *VaultTransitKeyProvider) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s", p.config.Address, p.config.Mount, path)
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+// parseVaultCiphertextVersion extracts N from a "vault:vN:<base64>" ciphertext
+func parseVaultCiphertextVersion(ciphertext string) (int, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, errors.New("invalid vault ciphertext format")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid vault ciphertext version %q", parts[1])
+	}
+	return version, nil
+}
@@ -0,0 +1,83 @@
+package secret
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+// maxAuditErrorLength caps the error text stored with an audit event
+const maxAuditErrorLength = 512
+
+// AccessActor identifies who performed a secret operation and from where
+type AccessActor struct {
+	UserID   string
+	Username string
+	RealmID  string
+	ClientIP string
+}
+
+// RecordAccess appends an audit event for a secret operation. The event belongs to the
+// secret's realm, so it shows up in that realm's audit feed whoever performed it; the actor's
+// realm is only used when the secret does not exist. A zero version is resolved to the
+// secret's current version. accessErr marks the event as failed and keeps the (truncated) reason.
+func (s *SecretService) RecordAccess(actor AccessActor, action, secretID string, version int, accessErr error) error {
+	event := s.newAccessEvent(actor, action, secretID, version, accessErr)
+	if err := s.repo.CreateAccessEvent(event); err != nil {
+		return fmt.Errorf("failed to record secret access event: %w", err)
+	}
+	return nil
+}
+
+// newAccessEvent builds the audit event RecordAccess stores
+func (s *SecretService) newAccessEvent(actor AccessActor, action, secretID string, version int, accessErr error) *models.SecretAccessEvent {
+	realmID := actor.RealmID
+	if secret, err := s.repo.GetByIDWithDeleted(secretID); err == nil {
+		realmID = secret.RealmID
+		if version == 0 {
+			version = secret.CurrentVersion
+		}
+	}
+
+	event := &models.SecretAccessEvent{
+		ID:        uuid.NewString(),
+		RealmID:   realmID,
+		SecretID:  secretID,
+		Version:   version,
+		Action:    action,
+		Outcome:   models.SecretOutcomeSuccess,
+		UserID:    actor.UserID,
+		Username:  actor.Username,
+		ClientIP:  actor.ClientIP,
+		CreatedAt: time.Now(),
+	}
+	if accessErr != nil {
+		event.Outcome = models.SecretOutcomeFailure
+		event.Error = accessErr.Error()
+		if len(event.Error) > maxAuditErrorLength {
+			// Cut on a character boundary so the stored text stays valid UTF-8
+			cut := maxAuditErrorLength
+			for cut > 0 && !utf8.RuneStart(event.Error[cut]) {
+				cut--
+			}
+			event.Error = event.Error[:cut]
+		}
+	}
+	return event
+}
+
+// GetAccessEvents returns the audit trail of a single secret
+func (s *SecretService) GetAccessEvents(realmID, secretID string, page, pageSize int) ([]models.SecretAccessEvent, int64, error) {
+	return s.repo.SearchAccessEvents(AccessEventFilter{RealmID: realmID, SecretID: secretID}, page, pageSize)
+}
+
+// SearchAccessEvents returns the realm-wide audit feed with optional filters
+func (s *SecretService) SearchAccessEvents(filter AccessEventFilter, page, pageSize int) ([]models.SecretAccessEvent, int64, error) {
+	if filter.Since != nil && filter.Until != nil && filter.Since.After(*filter.Until) {
+		return nil, 0, fmt.Errorf("since cannot be after until")
+	}
+	return s.repo.SearchAccessEvents(filter, page, pageSize)
+}
@@ -0,0 +1,116 @@
+package secret
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+func TestRecordAccess_SecretRealm(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+
+	// A super admin of another realm reads the secret; the event goes to the secret's realm
+	actor := AccessActor{UserID: "u-root", Username: "root", RealmID: "other", ClientIP: "10.0.0.1"}
+	require.NoError(t, s.RecordAccess(actor, models.SecretActionRead, secret.ID, 0, nil))
+	require.NoError(t, s.RecordAccess(actor, models.SecretActionRead, secret.ID, 1, errors.New(strings.Repeat("x", 1000))))
+
+	events, total, err := s.GetAccessEvents("realm", secret.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	for _, event := range events {
+		assert.Equal(t, "realm", event.RealmID)
+		assert.Equal(t, 1, event.Version)
+		assert.Equal(t, "root", event.Username)
+	}
+	failed, _, err := s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", Outcome: models.SecretOutcomeFailure}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Len(t, failed[0].Error, maxAuditErrorLength)
+
+	// A multi-byte character straddling the cap is dropped rather than split
+	event := s.newAccessEvent(actor, models.SecretActionRead, secret.ID, 1, errors.New(strings.Repeat("x", maxAuditErrorLength-1)+"é"))
+	assert.True(t, utf8.ValidString(event.Error))
+	assert.Equal(t, strings.Repeat("x", maxAuditErrorLength-1), event.Error)
+
+	_, total, err = s.GetAccessEvents("other", secret.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Deleted secrets keep their realm; unknown ones fall back to the actor's
+	require.NoError(t, s.DeleteSecret(secret.ID))
+	require.NoError(t, s.RecordAccess(actor, models.SecretActionDelete, secret.ID, 0, nil))
+	require.NoError(t, s.RecordAccess(actor, models.SecretActionRead, "missing", 0, errors.New("not found")))
+	deleted, _, err := s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", Action: models.SecretActionDelete}, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	missing, _, err := s.GetAccessEvents("other", "missing", 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, missing, 1)
+}
+
+func TestSearchAccessEvents(t *testing.T) {
+	s := newTestSecretService(t)
+	base := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, event := range []models.SecretAccessEvent{
+		{SecretID: "s1", Action: models.SecretActionRead, Outcome: models.SecretOutcomeSuccess, UserID: "alice"},
+		{SecretID: "s1", Action: models.SecretActionUpdate, Outcome: models.SecretOutcomeSuccess, UserID: "bob"},
+		{SecretID: "s2", Action: models.SecretActionRead, Outcome: models.SecretOutcomeFailure, UserID: "alice"},
+	} {
+		event.ID = event.SecretID + event.Action
+		event.RealmID = "realm"
+		event.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, s.repo.CreateAccessEvent(&event))
+	}
+	require.NoError(t, s.repo.CreateAccessEvent(&models.SecretAccessEvent{ID: "foreign", RealmID: "other", SecretID: "s3",
+		Action: models.SecretActionRead, Outcome: models.SecretOutcomeSuccess, CreatedAt: base}))
+
+	events, total, err := s.SearchAccessEvents(AccessEventFilter{RealmID: "realm"}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, "s2read", events[0].ID, "newest first")
+
+	events, _, err = s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", UserID: "alice", Action: models.SecretActionRead}, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	since, until := base.Add(30*time.Minute), base.Add(90*time.Minute)
+	events, _, err = s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", Since: &since, Until: &until}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "s1update", events[0].ID)
+
+	_, _, err = s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", Since: &until, Until: &since}, 1, 10)
+	assert.Error(t, err)
+}
+
+func TestAuditRead_FailClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/secrets/"+secret.ID+"/decrypt", nil)
+	assert.True(t, auditRead(c, s, secret.ID, 0, nil))
+
+	// Without the audit table the read is refused
+	require.NoError(t, s.repo.db.Migrator().DropTable(&models.SecretAccessEvent{}))
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/secrets/"+secret.ID+"/decrypt", nil)
+	assert.False(t, auditRead(c, s, secret.ID, 0, nil))
+	assert.True(t, c.IsAborted())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cr3t")
+}
@@ -0,0 +1,384 @@
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	bundleFormat        = "lazy-rabbit-secret-bundle"
+	bundleFormatVersion = 1
+	bundleKDF           = "scrypt"
+	bundleSaltBytes     = 16
+
+	// MinBundlePassphraseLength is the shortest passphrase accepted for export archives
+	MinBundlePassphraseLength = 12
+)
+
+// scrypt cost parameters for newly sealed bundles; opened bundles use the parameters they were sealed with
+var defaultBundleKDFParams = bundleKDFParams{N: 1 << 15, R: 8, P: 1}
+
+const (
+	// scrypt needs 128*N*r bytes of memory and runs p sequential passes over it; these bounds cap the
+	// cost of an opened bundle, so that a crafted archive cannot make the key derivation use
+	// gigabytes of memory or run for minutes
+	maxBundleKDFN      = 1 << 20
+	maxBundleKDFMemory = 256 << 20
+	maxBundleKDFP      = 4
+)
+
+// SecretBundle is the plaintext content of an export archive. Provider wrapped DEKs are stored
+// unwrapped so that the importing instance can rewrap them under its own KEK; the bundle is
+// therefore only ever written to disk sealed with a passphrase.
+type SecretBundle struct {
+	SourceRealmID string         `json:"source_realm_id"`
+	ExportedAt    time.Time      `json:"exported_at"`
+	Secrets       []BundleSecret `json:"secrets"`
+}
+
+// BundleSecret is a secret with its metadata and version history
+type BundleSecret struct {
+	Name                 string          `json:"name"`
+	Group                string          `json:"group"`
+	Desc                 string          `json:"desc"`
+	Path                 string          `json:"path"`
+	CurrentVersion       int             `json:"current_version"`
+	PreviousVersion      int             `json:"previous_version"`
+	PendingVersion       int             `json:"pending_version"`
+	MaxVersion           int             `json:"max_version"`
+	ExpiresAt            *time.Time      `json:"expires_at,omitempty"`
+	RotationIntervalDays int             `json:"rotation_interval_days"`
+	RotatedAt            *time.Time      `json:"rotated_at,omitempty"`
+	CreatedBy            string          `json:"created_by"`
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedBy            string          `json:"updated_by"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+	Versions             []BundleVersion `json:"versions"`
+}
+
+// BundleVersion is one encrypted secret version. Exactly one of DEK and CustomWrappedDEK is set.
+type BundleVersion struct {
+	Version          int       `json:"version"`
+	Status           string    `json:"status"`
+	CipherAlg        string    `json:"cipher_alg"`
+	CipherText       string    `json:"cipher_text"`
+	Nonce            string    `json:"nonce"`
+	AuthTag          string    `json:"auth_tag"`
+	DEK              string    `json:"dek,omitempty"`                // base64 plaintext DEK of a provider wrapped version
+	CustomWrappedDEK string    `json:"custom_wrapped_dek,omitempty"` // DEK wrapped with a user supplied KEK, kept as is
+	CreatedBy        string    `json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ImportBundleResult summarizes an import
+type ImportBundleResult struct {
+	Imported int      `json:"imported"`
+	Versions int      `json:"versions"`
+	Skipped  []string `json:"skipped,omitempty"` // names that already exist in the target realm
+}
+
+type bundleKDFParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// sealedBundle is the on-disk archive format
+type sealedBundle struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	KDF        string          `json:"kdf"`
+	KDFParams  bundleKDFParams `json:"kdf_params"`
+	Salt       string          `json:"salt"`
+	Nonce      string          `json:"nonce"`
+	AuthTag    string          `json:"auth_tag"`
+	CipherText string          `json:"cipher_text"`
+}
+
+// ExportRealm collects every secret of a realm with its version history. Soft-deleted versions
+// are left out unless a version pointer still references them, so the pointers of an imported
+// secret never dangle. The export fails as a whole if any provider wrapped DEK cannot be
+// unwrapped, so an archive is never partial.
+func (s *SecretService) ExportRealm(realmID string) (*SecretBundle, error) {
+	secrets, err := s.repo.ListByRealm(realmID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	bundle := &SecretBundle{
+		SourceRealmID: realmID,
+		ExportedAt:    time.Now(),
+		Secrets:       make([]BundleSecret, 0, len(secrets)),
+	}
+	for _, secret := range secrets {
+		versions, err := s.exportedVersions(&secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list versions of secret %s: %w", secret.Name, err)
+		}
+
+		item := BundleSecret{
+			Name:                 secret.Name,
+			Group:                secret.Group,
+			Desc:                 secret.Desc,
+			Path:                 secret.Path,
+			CurrentVersion:       secret.CurrentVersion,
+			PreviousVersion:      secret.PreviousVersion,
+			PendingVersion:       secret.PendingVersion,
+			MaxVersion:           secret.MaxVersion,
+			ExpiresAt:            secret.ExpiresAt,
+			RotationIntervalDays: secret.RotationIntervalDays,
+			RotatedAt:            secret.RotatedAt,
+			CreatedBy:            secret.CreatedBy,
+			CreatedAt:            secret.CreatedAt,
+			UpdatedBy:            secret.UpdatedBy,
+			UpdatedAt:            secret.UpdatedAt,
+			Versions:             make([]BundleVersion, 0, len(versions)),
+		}
+		for _, v := range versions {
+			bv := BundleVersion{
+				Version:    v.Version,
+				Status:     v.Status,
+				CipherAlg:  v.CipherAlg,
+				CipherText: v.CipherText,
+				Nonce:      v.Nonce,
+				AuthTag:    v.AuthTag,
+				CreatedBy:  v.CreatedBy,
+				CreatedAt:  v.CreatedAt,
+			}
+			if v.KEKVersion == customKEKVersion {
+				bv.CustomWrappedDEK = v.WrappedDEK
+			} else {
+				dek, err := s.keys.Unwrap(v.WrappedDEK, v.KEKVersion)
+				if err != nil {
+					return nil, fmt.Errorf("failed to unwrap DEK of secret %s v%d: %w", secret.Name, v.Version, err)
+				}
+				bv.DEK = base64.StdEncoding.EncodeToString(dek)
+				zeroBytes(dek)
+			}
+			item.Versions = append(item.Versions, bv)
+		}
+		bundle.Secrets = append(bundle.Secrets, item)
+	}
+	return bundle, nil
+}
+
+// exportedVersions returns the live versions of a secret and the soft-deleted ones its pointers reference
+func (s *SecretService) exportedVersions(secret *models.Secret) ([]models.SecretVersion, error) {
+	versions, err := s.repo.GetSecretVersionsWithDeleted(secret.ID)
+	if err != nil {
+		return nil, err
+	}
+	exported := make([]models.SecretVersion, 0, len(versions))
+	for _, v := range versions {
+		referenced := v.Version == secret.CurrentVersion || v.Version == secret.PreviousVersion || v.Version == secret.PendingVersion
+		if !v.DeletedAt.Valid || referenced {
+			exported = append(exported, v)
+		}
+	}
+	return exported, nil
+}
+
+// ImportBundle restores a bundle into a realm, rewrapping every DEK with the current KEK.
+// Secrets whose name already exists in the realm are skipped; each secret is imported in its
+// own transaction with fresh IDs, its version numbers and pointers are kept.
+func (s *SecretService) ImportBundle(bundle *SecretBundle, realmID string, dryRun bool) (*ImportBundleResult, error) {
+	result := &ImportBundleResult{}
+	for _, item := range bundle.Secrets {
+		exists, err := s.repo.ExistsByName(realmID, item.Name)
+		if err != nil {
+			return result, fmt.Errorf("failed to check secret %s: %w", item.Name, err)
+		}
+		if exists {
+			result.Skipped = append(result.Skipped, item.Name)
+			continue
+		}
+
+		secret := &models.Secret{
+			ID:                   uuid.NewString(),
+			RealmID:              realmID,
+			Name:                 item.Name,
+			Group:                item.Group,
+			Desc:                 item.Desc,
+			Path:                 item.Path,
+			CurrentVersion:       item.CurrentVersion,
+			PreviousVersion:      item.PreviousVersion,
+			PendingVersion:       item.PendingVersion,
+			MaxVersion:           item.MaxVersion,
+			ExpiresAt:            item.ExpiresAt,
+			RotationIntervalDays: item.RotationIntervalDays,
+			RotatedAt:            item.RotatedAt,
+			CreatedBy:            item.CreatedBy,
+			CreatedAt:            item.CreatedAt,
+			UpdatedBy:            item.UpdatedBy,
+			UpdatedAt:            item.UpdatedAt,
+		}
+		versions := make([]models.SecretVersion, 0, len(item.Versions))
+		for _, bv := range item.Versions {
+			v, err := s.importBundleVersion(secret.ID, bv)
+			if err != nil {
+				return result, fmt.Errorf("failed to import secret %s v%d: %w", item.Name, bv.Version, err)
+			}
+			versions = append(versions, *v)
+		}
+
+		if !dryRun {
+			if err := s.repo.CreateWithVersions(secret, versions); err != nil {
+				return result, fmt.Errorf("failed to store secret %s: %w", item.Name, err)
+			}
+		}
+		result.Imported++
+		result.Versions += len(versions)
+	}
+	return result, nil
+}
+
+// importBundleVersion rebuilds a version record, wrapping its DEK with the current KEK
+func (s *SecretService) importBundleVersion(secretID string, bv BundleVersion) (*models.SecretVersion, error) {
+	v := &models.SecretVersion{
+		ID:         uuid.NewString(),
+		SecretID:   secretID,
+		Version:    bv.Version,
+		CipherAlg:  bv.CipherAlg,
+		CipherText: bv.CipherText,
+		Nonce:      bv.Nonce,
+		AuthTag:    bv.AuthTag,
+		Status:     bv.Status,
+		CreatedBy:  bv.CreatedBy,
+		CreatedAt:  bv.CreatedAt,
+	}
+
+	if bv.CustomWrappedDEK != "" {
+		v.WrappedDEK = bv.CustomWrappedDEK
+		v.KEKVersion = customKEKVersion
+		return v, nil
+	}
+
+	dek, err := base64.StdEncoding.DecodeString(bv.DEK)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEK: %w", err)
+	}
+	defer zeroBytes(dek)
+
+	// Decrypting once proves the DEK matches the ciphertext before it is rewrapped
+	if _, err := decryptVersionWithDEK(v, dek); err != nil {
+		return nil, err
+	}
+
+	v.WrappedDEK, v.KEKVersion, err = s.keys.Wrap(dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap DEK: %w", err)
+	}
+	return v, nil
+}
+
+// SealBundle encrypts a bundle with a key derived from the passphrase (scrypt + AES-256-GCM)
+func SealBundle(bundle *SecretBundle, passphrase string) ([]byte, error) {
+	if len(passphrase) < MinBundlePassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinBundlePassphraseLength)
+	}
+
+	plaintext, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	defer zeroBytes(plaintext)
+
+	salt := generateRandomBytes(bundleSaltBytes)
+	key, err := deriveBundleKey(passphrase, salt, defaultBundleKDFParams)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(key)
+
+	ciphertext, nonce, tag, err := encryptAESGCM(key, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+
+	return json.MarshalIndent(sealedBundle{
+		Format:     bundleFormat,
+		Version:    bundleFormatVersion,
+		KDF:        bundleKDF,
+		KDFParams:  defaultBundleKDFParams,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		AuthTag:    base64.StdEncoding.EncodeToString(tag),
+		CipherText: base64.StdEncoding.EncodeToString(ciphertext),
+	}, "", "  ")
+}
+
+// OpenBundle decrypts an archive written by SealBundle
+func OpenBundle(data []byte, passphrase string) (*SecretBundle, error) {
+	var sealed sealedBundle
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("not a secret bundle: %w", err)
+	}
+	if sealed.Format != bundleFormat {
+		return nil, fmt.Errorf("not a secret bundle: unknown format %q", sealed.Format)
+	}
+	if sealed.Version != bundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", sealed.Version)
+	}
+	if sealed.KDF != bundleKDF {
+		return nil, fmt.Errorf("unsupported bundle KDF %q", sealed.KDF)
+	}
+
+	salt, errSalt := base64.StdEncoding.DecodeString(sealed.Salt)
+	nonce, errNonce := base64.StdEncoding.DecodeString(sealed.Nonce)
+	tag, errTag := base64.StdEncoding.DecodeString(sealed.AuthTag)
+	ciphertext, errCipher := base64.StdEncoding.DecodeString(sealed.CipherText)
+	if err := errors.Join(errSalt, errNonce, errTag, errCipher); err != nil {
+		return nil, fmt.Errorf("corrupted bundle: %w", err)
+	}
+
+	if err := sealed.KDFParams.validate(); err != nil {
+		return nil, err
+	}
+	key, err := deriveBundleKey(passphrase, salt, sealed.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(key)
+
+	plaintext, err := decryptAESGCM(key, nonce, ciphertext, tag)
+	if err != nil {
+		return nil, errors.New("failed to decrypt bundle: wrong passphrase or corrupted archive")
+	}
+	defer zeroBytes(plaintext)
+
+	var bundle SecretBundle
+	if err := json.Unmarshal(plaintext, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	return &bundle, nil
+}
+
+// validate rejects scrypt parameters above the cost bounds
+func (p bundleKDFParams) validate() error {
+	if p.N <= 1 || p.N > maxBundleKDFN || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("unsupported bundle KDF parameters: n must be a power of 2 up to %d", maxBundleKDFN)
+	}
+	// compare r against the memory bound by division, so that a huge r cannot overflow 128*N*r
+	if p.R <= 0 || p.R > maxBundleKDFMemory/(128*p.N) {
+		return fmt.Errorf("unsupported bundle KDF parameters: 128*n*r must be positive and up to %d bytes", maxBundleKDFMemory)
+	}
+	if p.P <= 0 || p.P > maxBundleKDFP {
+		return fmt.Errorf("unsupported bundle KDF parameters: p must be between 1 and %d", maxBundleKDFP)
+	}
+	return nil
+}
+
+func deriveBundleKey(passphrase string, salt []byte, params bundleKDFParams) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive bundle key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,140 @@
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpenBundle(t *testing.T) {
+	bundle := &SecretBundle{
+		SourceRealmID: "realm-1",
+		Secrets: []BundleSecret{{
+			Name:           "db-password",
+			Path:           "db/prod/password",
+			CurrentVersion: 1,
+			MaxVersion:     1,
+			Versions:       []BundleVersion{{Version: 1, Status: "active", DEK: "ZGVr"}},
+		}},
+	}
+
+	sealed, err := SealBundle(bundle, "correct horse battery")
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "db-password")
+
+	opened, err := OpenBundle(sealed, "correct horse battery")
+	require.NoError(t, err)
+	assert.Equal(t, bundle.Secrets, opened.Secrets)
+
+	_, err = OpenBundle(sealed, "wrong horse battery")
+	assert.ErrorContains(t, err, "wrong passphrase")
+
+	_, err = SealBundle(bundle, "short")
+	assert.ErrorContains(t, err, "at least")
+
+	_, err = OpenBundle([]byte(`{"format":"zip"}`), "correct horse battery")
+	assert.ErrorContains(t, err, "unknown format")
+}
+
+func TestImportBundleVersion_RewrapsDEK(t *testing.T) {
+	source, err := NewFileKeyProvider(writeKeyring(t, fmt.Sprintf("current_version: 1\nkeys:\n  1: %q\n", testKey('a'))))
+	require.NoError(t, err)
+	target, err := NewFileKeyProvider(writeKeyring(t, fmt.Sprintf("current_version: 5\nkeys:\n  5: %q\n", testKey('z'))))
+	require.NoError(t, err)
+
+	sealed, err := (&SecretService{keys: source}).envelopeEncrypt([]byte("s3cr3t"), "")
+	require.NoError(t, err)
+	dek, err := source.Unwrap(sealed.WrappedDEK, sealed.KEKVersion)
+	require.NoError(t, err)
+
+	bv := BundleVersion{
+		Version:    1,
+		Status:     "active",
+		CipherAlg:  sealed.CipherAlg,
+		CipherText: sealed.CipherText,
+		Nonce:      sealed.Nonce,
+		AuthTag:    sealed.AuthTag,
+		DEK:        base64.StdEncoding.EncodeToString(dek),
+	}
+
+	service := &SecretService{keys: target}
+	imported, err := service.importBundleVersion("secret-1", bv)
+	require.NoError(t, err)
+	assert.Equal(t, 5, imported.KEKVersion)
+
+	rewrapped, err := target.Unwrap(imported.WrappedDEK, imported.KEKVersion)
+	require.NoError(t, err)
+	plaintext, err := decryptVersionWithDEK(imported, rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", plaintext)
+
+	// A DEK that does not match the ciphertext is rejected before rewrapping
+	bv.DEK = base64.StdEncoding.EncodeToString(generateRandomBytes(32))
+	_, err = service.importBundleVersion("secret-1", bv)
+	assert.Error(t, err)
+}
+
+func TestExportRealm_KeepsReferencedVersions(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "v1")
+	_, err := s.CreatePendingVersion(secret.ID, "v2", "", "alice")
+	require.NoError(t, err)
+	_, err = s.PromotePendingVersion(secret.ID, "alice")
+	require.NoError(t, err)
+	_, err = s.CreatePendingVersion(secret.ID, "v3", "", "alice")
+	require.NoError(t, err)
+	_, err = s.DiscardPendingVersion(secret.ID, "alice")
+	require.NoError(t, err)
+	// v1 is deleted while the previous pointer still references it
+	require.NoError(t, s.repo.DeleteSecretVersion(secret.ID, 1))
+
+	bundle, err := s.ExportRealm("realm")
+	require.NoError(t, err)
+	require.Len(t, bundle.Secrets, 1)
+	var exported []int
+	for _, v := range bundle.Secrets[0].Versions {
+		exported = append(exported, v.Version)
+	}
+	assert.Equal(t, []int{2, 1}, exported, "the discarded v3 is left out")
+
+	result, err := s.ImportBundle(bundle, "realm-2", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Versions)
+	imported, err := s.repo.ListByRealm("realm-2")
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	_, err = s.RollbackVersion(imported[0].ID, "alice")
+	require.NoError(t, err)
+	value, err := s.DecryptSecret(imported[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value)
+}
+
+func TestOpenBundle_KDFBounds(t *testing.T) {
+	sealed, err := SealBundle(&SecretBundle{SourceRealmID: "realm-1"}, "correct horse battery")
+	require.NoError(t, err)
+
+	for _, params := range []string{
+		`{"n":2097152,"r":8,"p":1}`,
+		`{"n":1000,"r":8,"p":1}`,
+		`{"n":32768,"r":8,"p":16}`,
+		`{"n":1048576,"r":64,"p":1}`,
+		`{"n":1048576,"r":8,"p":1}`,
+		`{"n":32768,"r":8,"p":64}`,
+		`{"n":32768,"r":1073741824,"p":1073741824}`,
+		`{"n":32768,"r":0,"p":1}`,
+	} {
+		var archive map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(sealed, &archive))
+		archive["kdf_params"] = json.RawMessage(params)
+		tampered, err := json.Marshal(archive)
+		require.NoError(t, err)
+
+		_, err = OpenBundle(tampered, "correct horse battery")
+		assert.ErrorContains(t, err, "unsupported bundle KDF parameters", params)
+	}
+}
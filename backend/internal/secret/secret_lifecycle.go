@@ -0,0 +1,147 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+var (
+	// ErrNoPendingVersion is returned when promoting or discarding without a pending version
+	ErrNoPendingVersion = errors.New("secret has no pending version")
+	// ErrPendingVersionExists is returned when creating a pending version while another one is pending
+	ErrPendingVersionExists = errors.New("secret already has a pending version, promote or discard it first")
+	// ErrNoPreviousVersion is returned when rolling back without a previous version
+	ErrNoPreviousVersion = errors.New("secret has no previous version to roll back to")
+)
+
+// PromotePendingVersion makes the pending version current and keeps the old current version as previous
+func (s *SecretService) PromotePendingVersion(id, updatedBy string) (*models.SecretVersionTransition, error) {
+	secret, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if secret.PendingVersion == 0 {
+		return nil, ErrNoPendingVersion
+	}
+
+	transition := newTransition(secret, models.SecretActionPromote, updatedBy)
+	transition.ToCurrent = secret.PendingVersion
+	transition.ToPrevious = secret.CurrentVersion
+	transition.ToPending = 0
+
+	rotatedAt := transition.CreatedAt
+	move := VersionPointerMove{
+		Transition: transition,
+		Statuses:   map[int]string{secret.CurrentVersion: "deprecated", secret.PendingVersion: "active"},
+		RotatedAt:  &rotatedAt,
+	}
+	if err := s.repo.MoveVersionPointers(move); err != nil {
+		return nil, err
+	}
+	return transition, nil
+}
+
+// RollbackVersion swaps the current and previous versions, so a second rollback undoes the first
+func (s *SecretService) RollbackVersion(id, updatedBy string) (*models.SecretVersionTransition, error) {
+	secret, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if secret.PreviousVersion == 0 {
+		return nil, ErrNoPreviousVersion
+	}
+	if _, err := s.repo.GetSecretVersion(id, secret.PreviousVersion); err != nil {
+		return nil, fmt.Errorf("previous version %d is not available: %w", secret.PreviousVersion, err)
+	}
+
+	transition := newTransition(secret, models.SecretActionRollback, updatedBy)
+	transition.ToCurrent = secret.PreviousVersion
+	transition.ToPrevious = secret.CurrentVersion
+
+	move := VersionPointerMove{
+		Transition: transition,
+		Statuses:   map[int]string{secret.CurrentVersion: "deprecated", secret.PreviousVersion: "active"},
+	}
+	if err := s.repo.MoveVersionPointers(move); err != nil {
+		return nil, err
+	}
+	return transition, nil
+}
+
+// DiscardPendingVersion clears the pending pointer and soft deletes the pending version
+func (s *SecretService) DiscardPendingVersion(id, updatedBy string) (*models.SecretVersionTransition, error) {
+	secret, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if secret.PendingVersion == 0 {
+		return nil, ErrNoPendingVersion
+	}
+
+	transition := newTransition(secret, models.SecretActionDiscardPending, updatedBy)
+	transition.ToPending = 0
+
+	move := VersionPointerMove{
+		Transition:     transition,
+		Statuses:       map[int]string{secret.PendingVersion: "discarded"},
+		DiscardVersion: secret.PendingVersion,
+	}
+	if err := s.repo.MoveVersionPointers(move); err != nil {
+		return nil, err
+	}
+	return transition, nil
+}
+
+// GetPendingVersion returns the pending version of a secret
+func (s *SecretService) GetPendingVersion(id string) (*models.SecretVersion, error) {
+	secret, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if secret.PendingVersion == 0 {
+		return nil, ErrNoPendingVersion
+	}
+	return s.repo.GetSecretVersion(id, secret.PendingVersion)
+}
+
+// DecryptPendingVersion decrypts the pending version so a client can validate a rotated credential
+// before it is promoted. It returns the value and the pending version number.
+func (s *SecretService) DecryptPendingVersion(id string) (string, int, error) {
+	secret, err := s.repo.GetByID(id)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if secret.PendingVersion == 0 {
+		return "", 0, ErrNoPendingVersion
+	}
+	value, err := s.decryptSecretVersion(secret, secret.PendingVersion)
+	return value, secret.PendingVersion, err
+}
+
+// GetVersionTransitions returns the version pointer history of a secret
+func (s *SecretService) GetVersionTransitions(id string) ([]models.SecretVersionTransition, error) {
+	return s.repo.ListTransitions(id)
+}
+
+// newTransition starts a transition from the secret's current pointers; the "to" state
+// defaults to unchanged pointers
+func newTransition(secret *models.Secret, action, performedBy string) *models.SecretVersionTransition {
+	return &models.SecretVersionTransition{
+		ID:           uuid.NewString(),
+		RealmID:      secret.RealmID,
+		SecretID:     secret.ID,
+		Action:       action,
+		FromCurrent:  secret.CurrentVersion,
+		FromPrevious: secret.PreviousVersion,
+		FromPending:  secret.PendingVersion,
+		ToCurrent:    secret.CurrentVersion,
+		ToPrevious:   secret.PreviousVersion,
+		ToPending:    secret.PendingVersion,
+		PerformedBy:  performedBy,
+		CreatedAt:    time.Now(),
+	}
+}
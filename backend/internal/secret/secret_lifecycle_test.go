@@ -0,0 +1,141 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+func TestMoveVersionPointers_StalePromote(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "v1")
+	_, err := s.CreatePendingVersion(secret.ID, "v2", "", "alice")
+	require.NoError(t, err)
+
+	// Two promotes read the same pointers; the first one wins
+	stale, err := s.repo.GetByID(secret.ID)
+	require.NoError(t, err)
+	_, err = s.PromotePendingVersion(secret.ID, "alice")
+	require.NoError(t, err)
+
+	transition := newTransition(stale, models.SecretActionPromote, "bob")
+	transition.ToCurrent = stale.PendingVersion
+	transition.ToPrevious = stale.CurrentVersion
+	transition.ToPending = 0
+	err = s.repo.MoveVersionPointers(VersionPointerMove{
+		Transition: transition,
+		// Statuses the first promote did not set, to tell if any of them were written
+		Statuses: map[int]string{stale.CurrentVersion: "discarded", stale.PendingVersion: "discarded"},
+	})
+	assert.ErrorIs(t, err, ErrVersionPointersChanged)
+
+	// The pointers, statuses and history are those of the first promote
+	current, err := s.repo.GetByID(secret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.CurrentVersion)
+	assert.Equal(t, 1, current.PreviousVersion)
+	assert.Zero(t, current.PendingVersion)
+	versions, err := s.repo.GetSecretVersions(secret.ID)
+	require.NoError(t, err)
+	statuses := make(map[int]string)
+	for _, v := range versions {
+		statuses[v.Version] = v.Status
+	}
+	assert.Equal(t, map[int]string{1: "deprecated", 2: "active"}, statuses)
+	transitions, err := s.GetVersionTransitions(secret.ID)
+	require.NoError(t, err)
+	require.Len(t, transitions, 1)
+	assert.Equal(t, "alice", transitions[0].PerformedBy)
+
+	value, err := s.DecryptSecret(secret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", value)
+}
+
+func TestCreatePendingVersion_StaleCreate(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "v1")
+
+	// Two creates read the same pointers; the first one wins
+	stale, err := s.repo.GetByID(secret.ID)
+	require.NoError(t, err)
+	_, err = s.CreatePendingVersion(secret.ID, "v2", "", "alice")
+	require.NoError(t, err)
+
+	sealed, err := s.envelopeEncrypt([]byte("v2-bob"), "")
+	require.NoError(t, err)
+	sealed.ID = "bob-pending"
+	sealed.SecretID = stale.ID
+	sealed.Version = stale.MaxVersion + 1
+	sealed.Status = "pending"
+	stale.UpdatedBy = "bob"
+	err = s.repo.CreatePendingVersion(stale, sealed)
+	assert.ErrorIs(t, err, ErrVersionPointersChanged)
+
+	current, err := s.repo.GetByID(secret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.MaxVersion)
+	assert.Equal(t, 2, current.PendingVersion)
+	assert.Equal(t, "alice", current.UpdatedBy)
+	versions, err := s.repo.GetSecretVersions(secret.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	// A later create is refused while a version is pending
+	_, err = s.CreatePendingVersion(secret.ID, "v3", "", "alice")
+	assert.ErrorIs(t, err, ErrPendingVersionExists)
+
+	// The unique index rejects a second row for the same version even outside the pointer update
+	assert.Error(t, s.repo.db.Create(sealed).Error)
+}
+
+func TestUpdateFromInput_WhilePending(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "v1")
+	_, err := s.CreatePendingVersion(secret.ID, "v2", "", "alice")
+	require.NoError(t, err)
+
+	// A plain update is refused while a version is pending
+	req := UpdateSecretRequest{Name: "db-password", Group: "db", Path: "db/prod/password", Value: "v3", CurrentValue: "v1"}
+	_, err = s.UpdateFromInput(secret.ID, req, "bob")
+	assert.ErrorIs(t, err, ErrPendingVersionExists)
+
+	// So the promote still makes the pending version current
+	_, err = s.PromotePendingVersion(secret.ID, "alice")
+	require.NoError(t, err)
+	current, err := s.repo.GetByID(secret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.CurrentVersion)
+	assert.Equal(t, 1, current.PreviousVersion)
+	assert.Equal(t, 2, current.MaxVersion)
+	value, err := s.DecryptSecret(secret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", value)
+
+	// An update that read the pointers before the promote does not overwrite them
+	stale := *secret
+	stale.PreviousVersion = stale.CurrentVersion
+	stale.CurrentVersion = stale.MaxVersion + 1
+	stale.MaxVersion = stale.CurrentVersion
+	sealed, err := s.envelopeEncrypt([]byte("v2-bob"), "")
+	require.NoError(t, err)
+	sealed.ID = "bob-update"
+	sealed.SecretID = stale.ID
+	sealed.Version = stale.CurrentVersion
+	sealed.Status = "active"
+	err = s.repo.UpdateWithNewVersion(&stale, sealed)
+	assert.ErrorIs(t, err, ErrVersionPointersChanged)
+
+	// Once promoted, a plain update creates the next version on top of it
+	req.CurrentValue = "v2"
+	updated, err := s.UpdateFromInput(secret.ID, req, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated.CurrentVersion)
+	assert.Equal(t, 2, updated.PreviousVersion)
+	value, err = s.DecryptSecret(secret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "v3", value)
+}
@@ -2,6 +2,7 @@ package secret
 
 import (
 	"errors"
+	"time"
 
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
 	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
@@ -39,6 +40,15 @@ func (r *SecretRepository) GetByID(id string) (*models.Secret, error) {
 	return &secret, nil
 }
 
+// GetByIDWithDeleted returns a secret, whether it was soft-deleted or not
+func (r *SecretRepository) GetByIDWithDeleted(id string) (*models.Secret, error) {
+	var secret models.Secret
+	if err := r.db.Unscoped().First(&secret, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
 func (r *SecretRepository) Update(secret *models.Secret) error {
 	if secret.ID == "" {
 		return errors.New("missing id for update")
@@ -46,27 +56,61 @@ func (r *SecretRepository) Update(secret *models.Secret) error {
 	return r.db.Save(secret).Error
 }
 
-// UpdateWithNewVersion updates a secret and creates a new version in a transaction
+// UpdateWithNewVersion updates a secret and creates a new current version in a transaction. The
+// update is conditional on no version being pending and the current and max pointers still being
+// the ones the new version replaces, so it cannot overwrite a concurrent lifecycle move.
 func (r *SecretRepository) UpdateWithNewVersion(secret *models.Secret, version *models.SecretVersion) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Secret{}).
+			Where("id = ? AND current_version = ? AND max_version = ? AND pending_version = 0",
+				secret.ID, secret.PreviousVersion, version.Version-1).
+			Updates(map[string]interface{}{
+				"name":                   secret.Name,
+				"group":                  secret.Group,
+				"desc":                   secret.Desc,
+				"path":                   secret.Path,
+				"current_version":        secret.CurrentVersion,
+				"previous_version":       secret.PreviousVersion,
+				"max_version":            secret.MaxVersion,
+				"expires_at":             secret.ExpiresAt,
+				"rotation_interval_days": secret.RotationIntervalDays,
+				"rotated_at":             secret.RotatedAt,
+				"updated_by":             secret.UpdatedBy,
+				"updated_at":             secret.UpdatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionPointersChanged
+		}
 		// Update the previous version status to deprecated
 		if err := tx.Model(&models.SecretVersion{}).Where("secret_id = ? AND version = ?", secret.ID, secret.PreviousVersion).Update("status", "deprecated").Error; err != nil {
 			return err
 		}
-		// Update the secret
-		if err := tx.Save(secret).Error; err != nil {
-			return err
-		}
 		// Create the new version
 		return tx.Create(version).Error
 	})
 }
 
-// CreateVersionWithUpdate creates a new version and updates the secret in a transaction
-func (r *SecretRepository) CreateVersionWithUpdate(secret *models.Secret, version *models.SecretVersion) error {
+// CreatePendingVersion creates a pending version and moves the max and pending pointers to it in a
+// transaction. The update is conditional on no version being pending and max_version still being the
+// one the new version follows, so of two racing creates only one succeeds.
+func (r *SecretRepository) CreatePendingVersion(secret *models.Secret, version *models.SecretVersion) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(secret).Error; err != nil {
-			return err
+		result := tx.Model(&models.Secret{}).
+			Where("id = ? AND pending_version = 0 AND max_version = ?", secret.ID, version.Version-1).
+			Updates(map[string]interface{}{
+				"max_version":     version.Version,
+				"pending_version": version.Version,
+				"updated_by":      secret.UpdatedBy,
+				"updated_at":      secret.UpdatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionPointersChanged
 		}
 		return tx.Create(version).Error
 	})
@@ -130,6 +174,15 @@ func (r *SecretRepository) GetSecretVersions(secretID string) ([]models.SecretVe
 	return versions, nil
 }
 
+// GetSecretVersionsWithDeleted returns all versions of a secret, soft-deleted ones included
+func (r *SecretRepository) GetSecretVersionsWithDeleted(secretID string) ([]models.SecretVersion, error) {
+	var versions []models.SecretVersion
+	if err := r.db.Unscoped().Where("secret_id = ?", secretID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
 // DeleteSecretVersion soft deletes a specific version of a secret
 func (r *SecretRepository) DeleteSecretVersion(secretID string, version int) error {
 	return r.db.Where("secret_id = ? AND version = ?", secretID, version).Delete(&models.SecretVersion{}).Error
@@ -143,3 +196,299 @@ func (r *SecretRepository) GetActiveSecretVersion(secretID string) (*models.Secr
 	}
 	return r.GetSecretVersion(secretID, secret.CurrentVersion)
 }
+
+// ListVersionsNotOnKEK returns a batch of versions (including soft-deleted ones) whose DEK
+// is wrapped with a KEK other than kekVersion. Custom KEK versions are excluded.
+// Results are ordered by id and start after afterID for keyset pagination.
+func (r *SecretRepository) ListVersionsNotOnKEK(kekVersion, customKEKVersion int, afterID string, limit int) ([]models.SecretVersion, error) {
+	var versions []models.SecretVersion
+	q := r.db.Unscoped().
+		Where("kek_version <> ? AND kek_version <> ?", kekVersion, customKEKVersion)
+	if afterID != "" {
+		q = q.Where("id > ?", afterID)
+	}
+	if err := q.Order("id ASC").Limit(limit).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// CountVersionsNotOnKEK counts versions whose DEK is wrapped with a KEK other than kekVersion
+func (r *SecretRepository) CountVersionsNotOnKEK(kekVersion, customKEKVersion int) (int64, error) {
+	var total int64
+	err := r.db.Unscoped().Model(&models.SecretVersion{}).
+		Where("kek_version <> ? AND kek_version <> ?", kekVersion, customKEKVersion).
+		Count(&total).Error
+	return total, err
+}
+
+// ErrWrappedDEKChanged is returned when a version was rewrapped by a concurrent rotation or write
+var ErrWrappedDEKChanged = errors.New("secret version DEK was rewrapped concurrently, rerun the rotation")
+
+// RewrappedDEK is the DEK of a version rewrapped from one KEK version to another
+type RewrappedDEK struct {
+	VersionID      string
+	FromKEKVersion int
+	WrappedDEK     string
+	KEKVersion     int
+}
+
+// UpdateWrappedDEKs stores rewrapped DEKs for a batch of versions in a single transaction.
+// Only wrapped_dek and kek_version are written; the ciphertext is never touched. Each update is
+// conditional on the version still being wrapped with the KEK it was rewrapped from, so a DEK
+// rewrapped in the meantime is never overwritten; the batch then fails with ErrWrappedDEKChanged.
+func (r *SecretRepository) UpdateWrappedDEKs(deks []RewrappedDEK) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, d := range deks {
+			result := tx.Unscoped().Model(&models.SecretVersion{}).
+				Where("id = ? AND kek_version = ?", d.VersionID, d.FromKEKVersion).
+				Updates(map[string]interface{}{
+					"wrapped_dek": d.WrappedDEK,
+					"kek_version": d.KEKVersion,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrWrappedDEKChanged
+			}
+		}
+		return nil
+	})
+}
+
+// AccessEventFilter narrows down secret access audit queries
+type AccessEventFilter struct {
+	RealmID  string
+	SecretID string
+	UserID   string
+	Action   string
+	Outcome  string
+	Since    *time.Time
+	Until    *time.Time
+}
+
+// CreateAccessEvent appends an entry to the secret access audit log
+func (r *SecretRepository) CreateAccessEvent(event *models.SecretAccessEvent) error {
+	return r.db.Create(event).Error
+}
+
+// SearchAccessEvents returns audit events matching the filter, newest first
+func (r *SecretRepository) SearchAccessEvents(filter AccessEventFilter, page, pageSize int) ([]models.SecretAccessEvent, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var events []models.SecretAccessEvent
+	var total int64
+
+	q := r.db.Model(&models.SecretAccessEvent{}).Where("realm_id = ?", filter.RealmID)
+	if filter.SecretID != "" {
+		q = q.Where("secret_id = ?", filter.SecretID)
+	}
+	if filter.UserID != "" {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		q = q.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.Since != nil {
+		q = q.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		q = q.Where("created_at <= ?", *filter.Until)
+	}
+
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := q.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// CreateShare stores a new share link
+func (r *SecretRepository) CreateShare(share *models.SecretShare) error {
+	return r.db.Create(share).Error
+}
+
+// GetShare retrieves a share of the given secret
+func (r *SecretRepository) GetShare(secretID, shareID string) (*models.SecretShare, error) {
+	var share models.SecretShare
+	if err := r.db.First(&share, "id = ? AND secret_id = ?", shareID, secretID).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// ListShares returns the shares of a secret, newest first
+func (r *SecretRepository) ListShares(secretID string) ([]models.SecretShare, error) {
+	var shares []models.SecretShare
+	if err := r.db.Where("secret_id = ?", secretID).Order("created_at DESC").Find(&shares).Error; err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// BurnShare marks a share as no longer revealable
+func (r *SecretRepository) BurnShare(shareID string, burnedAt time.Time) error {
+	return r.db.Model(&models.SecretShare{}).
+		Where("id = ? AND burned_at IS NULL", shareID).
+		Update("burned_at", burnedAt).Error
+}
+
+// GetShareByTokenHash retrieves the share identified by the hash of its token
+func (r *SecretRepository) GetShareByTokenHash(tokenHash string) (*models.SecretShare, error) {
+	var share models.SecretShare
+	if err := r.db.First(&share, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// ConsumeShareView counts one view of a share, burning it once the view limit is reached, and
+// stores the audit event of the view in the same transaction, so a view is never taken without
+// being audited. The update is conditional on the view count the share was read with, so of
+// concurrent reveals of the same share only one can claim each view; the others get
+// gorm.ErrRecordNotFound.
+func (r *SecretRepository) ConsumeShareView(share *models.SecretShare, now time.Time, event *models.SecretAccessEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		burn := share.ViewCount+1 >= share.MaxViews
+		updates := map[string]interface{}{"view_count": share.ViewCount + 1}
+		if burn {
+			updates["burned_at"] = now
+		}
+		result := tx.Model(&models.SecretShare{}).
+			Where("id = ? AND view_count = ? AND burned_at IS NULL", share.ID, share.ViewCount).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+
+		share.ViewCount++
+		if burn {
+			share.BurnedAt = &now
+		}
+		return nil
+	})
+}
+
+// ErrVersionPointersChanged is returned when a secret's version pointers were moved by a concurrent request
+var ErrVersionPointersChanged = errors.New("secret version pointers were changed concurrently, reload and retry")
+
+// VersionPointerMove describes an atomic move of a secret's version pointers
+type VersionPointerMove struct {
+	Transition     *models.SecretVersionTransition // from/to pointers and who performed the move
+	Statuses       map[int]string                  // new status per version number
+	DiscardVersion int                             // version to soft delete, 0 for none
+	RotatedAt      *time.Time                      // set when the current version changes to a new value
+}
+
+// MoveVersionPointers moves the version pointers in a transaction. The update is conditional on the
+// pointers still holding the transition's "from" state, so of two racing moves only one succeeds.
+func (r *SecretRepository) MoveVersionPointers(move VersionPointerMove) error {
+	t := move.Transition
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"current_version":  t.ToCurrent,
+			"previous_version": t.ToPrevious,
+			"pending_version":  t.ToPending,
+			"updated_by":       t.PerformedBy,
+			"updated_at":       t.CreatedAt,
+		}
+		if move.RotatedAt != nil {
+			updates["rotated_at"] = *move.RotatedAt
+		}
+
+		result := tx.Model(&models.Secret{}).
+			Where("id = ? AND current_version = ? AND previous_version = ? AND pending_version = ?",
+				t.SecretID, t.FromCurrent, t.FromPrevious, t.FromPending).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionPointersChanged
+		}
+
+		for version, status := range move.Statuses {
+			if err := tx.Model(&models.SecretVersion{}).
+				Where("secret_id = ? AND version = ?", t.SecretID, version).
+				Update("status", status).Error; err != nil {
+				return err
+			}
+		}
+		if move.DiscardVersion > 0 {
+			if err := tx.Where("secret_id = ? AND version = ?", t.SecretID, move.DiscardVersion).
+				Delete(&models.SecretVersion{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(t).Error
+	})
+}
+
+// ListTransitions returns the version pointer history of a secret, newest first
+func (r *SecretRepository) ListTransitions(secretID string) ([]models.SecretVersionTransition, error) {
+	var transitions []models.SecretVersionTransition
+	if err := r.db.Where("secret_id = ?", secretID).Order("created_at DESC").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// ListByRealm returns every secret of a realm ordered by name
+func (r *SecretRepository) ListByRealm(realmID string) ([]models.Secret, error) {
+	var secrets []models.Secret
+	if err := r.db.Where("realm_id = ?", realmID).Order("name ASC").Find(&secrets).Error; err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// ExistsByName reports whether a realm already has a secret with the given name
+func (r *SecretRepository) ExistsByName(realmID, name string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Secret{}).Where("realm_id = ? AND name = ?", realmID, name).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateWithVersions creates a secret together with its whole version history in a transaction
+func (r *SecretRepository) CreateWithVersions(secret *models.Secret, versions []models.SecretVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(secret).Error; err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+		return tx.Create(&versions).Error
+	})
+}
+
+// ResolveRealmID accepts a realm ID or name and returns the realm ID
+func (r *SecretRepository) ResolveRealmID(idOrName string) (string, error) {
+	var realm models.Realm
+	if err := r.db.Where("id = ? OR name = ?", idOrName, idOrName).First(&realm).Error; err != nil {
+		return "", err
+	}
+	return realm.ID, nil
+}
@@ -0,0 +1,156 @@
+package secret
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+const defaultRotateBatchSize = 100
+
+// RotateKEKRequest defines the options for rewrapping DEKs onto the current KEK
+type RotateKEKRequest struct {
+	BatchSize int  `json:"batch_size"`
+	DryRun    bool `json:"dry_run"`
+}
+
+// RotateKEKFailure describes a version that could not be rewrapped
+type RotateKEKFailure struct {
+	VersionID  string `json:"version_id"`
+	SecretID   string `json:"secret_id"`
+	Version    int    `json:"version"`
+	KEKVersion int    `json:"kek_version"`
+	Error      string `json:"error"`
+}
+
+// RotateKEKResult summarizes a KEK rotation run
+type RotateKEKResult struct {
+	TargetKEKVersion int                `json:"target_kek_version"`
+	DryRun           bool               `json:"dry_run"`
+	Total            int64              `json:"total"`
+	Processed        int                `json:"processed"`
+	Rewrapped        int                `json:"rewrapped"`
+	Failed           int                `json:"failed"`
+	Batches          int                `json:"batches"`
+	Failures         []RotateKEKFailure `json:"failures,omitempty"`
+	StartedAt        time.Time          `json:"started_at"`
+	FinishedAt       time.Time          `json:"finished_at"`
+}
+
+// RotateKEKProgress is reported after every batch
+type RotateKEKProgress struct {
+	Batch     int
+	Processed int
+	Rewrapped int
+	Failed    int
+	Total     int64
+}
+
+// RotateKEK unwraps every DEK that is not wrapped with the current KEK and rewraps it
+// with the current one. Each batch is written in its own transaction and only the
+// wrapped DEK and KEK version change, so ciphertexts stay untouched. In dry-run mode
+// the DEKs are unwrapped and rewrapped in memory to prove the old KEKs are available,
+// but nothing is written.
+func (s *SecretService) RotateKEK(req RotateKEKRequest, progress func(RotateKEKProgress)) (*RotateKEKResult, error) {
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRotateBatchSize
+	}
+
+	currentVersion, err := s.keys.CurrentVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current KEK version: %w", err)
+	}
+
+	total, err := s.repo.CountVersionsNotOnKEK(currentVersion, customKEKVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count secret versions: %w", err)
+	}
+
+	result := &RotateKEKResult{
+		TargetKEKVersion: currentVersion,
+		DryRun:           req.DryRun,
+		Total:            total,
+		StartedAt:        time.Now(),
+	}
+
+	afterID := ""
+	for {
+		batch, err := s.repo.ListVersionsNotOnKEK(currentVersion, customKEKVersion, afterID, batchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list secret versions: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+
+		rewrapped := make([]RewrappedDEK, 0, len(batch))
+		for _, v := range batch {
+			newWrapped, err := s.rewrapVersion(v, currentVersion)
+			if err != nil {
+				result.Failed++
+				result.Failures = append(result.Failures, RotateKEKFailure{
+					VersionID:  v.ID,
+					SecretID:   v.SecretID,
+					Version:    v.Version,
+					KEKVersion: v.KEKVersion,
+					Error:      err.Error(),
+				})
+				continue
+			}
+			rewrapped = append(rewrapped, RewrappedDEK{
+				VersionID:      v.ID,
+				FromKEKVersion: v.KEKVersion,
+				WrappedDEK:     newWrapped,
+				KEKVersion:     currentVersion,
+			})
+		}
+
+		if !req.DryRun && len(rewrapped) > 0 {
+			if err := s.repo.UpdateWrappedDEKs(rewrapped); err != nil {
+				return result, fmt.Errorf("failed to store rewrapped batch %d: %w", result.Batches+1, err)
+			}
+		}
+
+		result.Batches++
+		result.Processed += len(batch)
+		result.Rewrapped += len(rewrapped)
+
+		if progress != nil {
+			progress(RotateKEKProgress{
+				Batch:     result.Batches,
+				Processed: result.Processed,
+				Rewrapped: result.Rewrapped,
+				Failed:    result.Failed,
+				Total:     result.Total,
+			})
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+	}
+
+	result.FinishedAt = time.Now()
+	return result, nil
+}
+
+// rewrapVersion unwraps the DEK of a version with its original KEK and wraps it with the current one
+func (s *SecretService) rewrapVersion(v models.SecretVersion, currentVersion int) (string, error) {
+	dek, err := s.keys.Unwrap(v.WrappedDEK, v.KEKVersion)
+	if err != nil {
+		return "", err
+	}
+	defer zeroBytes(dek)
+
+	wrapped, kekVersion, err := s.keys.Wrap(dek)
+	if err != nil {
+		return "", err
+	}
+	if kekVersion != currentVersion {
+		return "", fmt.Errorf("KEK version changed during rotation: expected %d, got %d", currentVersion, kekVersion)
+	}
+	return wrapped, nil
+}
@@ -0,0 +1,181 @@
+package secret
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+// useKeyring replaces the key provider of a service with a file keyring
+func useKeyring(t *testing.T, s *SecretService, content string) {
+	keys, err := NewFileKeyProvider(writeKeyring(t, content))
+	require.NoError(t, err)
+	s.keys = keys
+}
+
+// createRotationSecrets creates n secrets, each with one version wrapped with the current KEK
+func createRotationSecrets(t *testing.T, s *SecretService, n int) []*models.Secret {
+	var secrets []*models.Secret
+	for i := 0; i < n; i++ {
+		secret, err := s.CreateFromInput(CreateSecretRequest{
+			Name:  fmt.Sprintf("password-%d", i),
+			Group: "db",
+			Path:  fmt.Sprintf("db/prod/password-%d", i),
+			Value: fmt.Sprintf("s3cr3t-%d", i),
+		}, "realm", "alice")
+		require.NoError(t, err)
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+// kekVersions returns the KEK version of every version, soft-deleted ones included, by version id
+func kekVersions(t *testing.T, s *SecretService) map[string]int {
+	var versions []models.SecretVersion
+	require.NoError(t, s.repo.db.Unscoped().Find(&versions).Error)
+	result := make(map[string]int)
+	for _, v := range versions {
+		result[v.ID] = v.KEKVersion
+	}
+	return result
+}
+
+func TestRotateKEK_DryRun(t *testing.T) {
+	s := newTestSecretService(t)
+	createRotationSecrets(t, s, 3)
+	useKeyring(t, s, fmt.Sprintf("current_version: 2\nkeys:\n  1: %q\n  2: %q\n", testKey('a'), testKey('b')))
+
+	result, err := s.RotateKEK(RotateKEKRequest{DryRun: true}, nil)
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 2, result.TargetKEKVersion)
+	assert.Equal(t, int64(3), result.Total)
+	assert.Equal(t, 3, result.Rewrapped)
+	assert.Zero(t, result.Failed)
+
+	// Nothing is written
+	for _, kekVersion := range kekVersions(t, s) {
+		assert.Equal(t, 1, kekVersion)
+	}
+}
+
+func TestRotateKEK_Batches(t *testing.T) {
+	s := newTestSecretService(t)
+	secrets := createRotationSecrets(t, s, 5)
+	// Soft-deleted versions are rewrapped too, custom KEK versions are left alone
+	require.NoError(t, s.repo.DeleteSecretVersion(secrets[4].ID, 1))
+	require.NoError(t, s.repo.db.Model(&models.SecretVersion{}).
+		Where("secret_id = ?", secrets[3].ID).Update("kek_version", customKEKVersion).Error)
+	useKeyring(t, s, fmt.Sprintf("current_version: 2\nkeys:\n  1: %q\n  2: %q\n", testKey('a'), testKey('b')))
+
+	var progress []RotateKEKProgress
+	result, err := s.RotateKEK(RotateKEKRequest{BatchSize: 2}, func(p RotateKEKProgress) {
+		progress = append(progress, p)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Total)
+	assert.Equal(t, 2, result.Batches)
+	assert.Equal(t, 4, result.Processed)
+	assert.Equal(t, 4, result.Rewrapped)
+	require.Len(t, progress, 2)
+	assert.Equal(t, 2, progress[0].Processed)
+	assert.Equal(t, 4, progress[1].Processed)
+
+	versions, err := s.repo.ListVersionsNotOnKEK(2, customKEKVersion, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	custom := 0
+	for _, kekVersion := range kekVersions(t, s) {
+		if kekVersion == customKEKVersion {
+			custom++
+			continue
+		}
+		assert.Equal(t, 2, kekVersion)
+	}
+	assert.Equal(t, 1, custom)
+}
+
+func TestRotateKEK_ResumeAfterFailure(t *testing.T) {
+	s := newTestSecretService(t)
+	secrets := createRotationSecrets(t, s, 3)
+	// One version is wrapped with a KEK the keyring does not have yet
+	useKeyring(t, s, fmt.Sprintf("current_version: 3\nkeys:\n  3: %q\n", testKey('c')))
+	lost, err := s.CreateFromInput(CreateSecretRequest{Name: "password-3", Group: "db", Path: "db/prod/password-3", Value: "s3cr3t-3"}, "realm", "alice")
+	require.NoError(t, err)
+	useKeyring(t, s, fmt.Sprintf("current_version: 2\nkeys:\n  1: %q\n  2: %q\n", testKey('a'), testKey('b')))
+
+	result, err := s.RotateKEK(RotateKEKRequest{BatchSize: 2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Total)
+	assert.Equal(t, 3, result.Rewrapped)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, lost.ID, result.Failures[0].SecretID)
+	assert.Equal(t, 3, result.Failures[0].KEKVersion)
+
+	// Once the missing KEK is back, a second run only picks up what is left
+	useKeyring(t, s, fmt.Sprintf("current_version: 2\nkeys:\n  1: %q\n  2: %q\n  3: %q\n", testKey('a'), testKey('b'), testKey('c')))
+	result, err = s.RotateKEK(RotateKEKRequest{BatchSize: 2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Equal(t, 1, result.Rewrapped)
+	assert.Zero(t, result.Failed)
+
+	// Without the old KEKs every secret still decrypts
+	useKeyring(t, s, fmt.Sprintf("current_version: 2\nkeys:\n  2: %q\n", testKey('b')))
+	for i, secret := range append(secrets, lost) {
+		value, err := s.DecryptSecret(secret.ID)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("s3cr3t-%d", i), value)
+	}
+}
+
+func TestUpdateWrappedDEKs_KeepsCiphertext(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createRotationSecrets(t, s, 1)[0]
+	before, err := s.repo.GetSecretVersion(secret.ID, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, s.repo.UpdateWrappedDEKs([]RewrappedDEK{
+		{VersionID: before.ID, FromKEKVersion: before.KEKVersion, WrappedDEK: "rewrapped", KEKVersion: 2},
+	}))
+
+	after, err := s.repo.GetSecretVersion(secret.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "rewrapped", after.WrappedDEK)
+	assert.Equal(t, 2, after.KEKVersion)
+	assert.Equal(t, before.CipherText, after.CipherText)
+}
+
+func TestUpdateWrappedDEKs_StaleRewrap(t *testing.T) {
+	s := newTestSecretService(t)
+	secrets := createRotationSecrets(t, s, 2)
+	first, err := s.repo.GetSecretVersion(secrets[0].ID, 1)
+	require.NoError(t, err)
+	second, err := s.repo.GetSecretVersion(secrets[1].ID, 1)
+	require.NoError(t, err)
+
+	// A concurrent rotation rewrapped the second version onto KEK 2 first
+	require.NoError(t, s.repo.UpdateWrappedDEKs([]RewrappedDEK{
+		{VersionID: second.ID, FromKEKVersion: second.KEKVersion, WrappedDEK: "rewrapped-by-b", KEKVersion: 2},
+	}))
+
+	// A rotation that read both versions on KEK 1 writes neither of them
+	err = s.repo.UpdateWrappedDEKs([]RewrappedDEK{
+		{VersionID: first.ID, FromKEKVersion: first.KEKVersion, WrappedDEK: "rewrapped-by-a", KEKVersion: 2},
+		{VersionID: second.ID, FromKEKVersion: second.KEKVersion, WrappedDEK: "rewrapped-by-a", KEKVersion: 2},
+	})
+	assert.ErrorIs(t, err, ErrWrappedDEKChanged)
+
+	after, err := s.repo.GetSecretVersion(secrets[0].ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, first.WrappedDEK, after.WrappedDEK)
+	assert.Equal(t, first.KEKVersion, after.KEKVersion)
+	after, err = s.repo.GetSecretVersion(secrets[1].ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "rewrapped-by-b", after.WrappedDEK)
+}
@@ -1,15 +1,43 @@
 package secret
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/log"
 )
 
 // RegisterRoutes registers HTTP endpoints for managing secrets
 func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth.AuthMiddleware) {
+	// GET /api/v1/secrets/share/:token - Reveal a shared secret; public, the token is the credential
+	router.GET("/api/v1/secrets/share/:token", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		value, share, err := service.RevealShare(c.Param("token"), c.ClientIP())
+		if errors.Is(err, ErrShareNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ErrSecretExpired) {
+			c.AbortWithStatusJSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.GetLogger().Errorf("Failed to reveal a shared secret: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to reveal shared secret"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"value":           value,
+			"remaining_views": share.MaxViews - share.ViewCount,
+			"expires_at":      share.ExpiresAt,
+		})
+	})
+
 	// Create a specific group for secrets with admin/super_admin restriction
 	group := router.Group("/api/v1/secrets")
 	group.Use(middleware.Authenticate())
@@ -54,9 +82,85 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		auditChange(c, service, models.SecretActionCreate, created.ID, created.CurrentVersion, nil)
 		c.JSON(http.StatusCreated, created)
 	})
 
+	// GET /api/v1/secrets/audit - Realm-wide secret access audit feed
+	group.GET("/audit", func(c *gin.Context) {
+		realmID, realmExists := auth.GetCurrentRealm(c)
+		if !realmExists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		filter := AccessEventFilter{
+			RealmID:  realmID,
+			SecretID: c.Query("secret_id"),
+			UserID:   c.Query("user_id"),
+			Action:   c.Query("action"),
+			Outcome:  c.Query("outcome"),
+		}
+		var err error
+		if filter.Since, err = parseTimeQuery(c.Query("since")); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid since, use RFC3339"})
+			return
+		}
+		if filter.Until, err = parseTimeQuery(c.Query("until")); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid until, use RFC3339"})
+			return
+		}
+		page := parseIntDefault(c.Query("page"), 1)
+		pageSize := parseIntDefault(c.Query("page_size"), 20)
+
+		items, total, err := service.SearchAccessEvents(filter, page, pageSize)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+	})
+
+	// POST /api/v1/secrets/render - Render a template that references secrets by path
+	group.POST("/render", func(c *gin.Context) {
+		var req RenderTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, realmExists := auth.GetCurrentRealm(c); !realmExists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		content, err := service.RenderTemplate(accessActor(c), req.Template, req.Data)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"content": content})
+	})
+
+	// POST /api/v1/secrets/rotate-kek - Rewrap every DEK with the current KEK (super_admin only)
+	group.POST("/rotate-kek", middleware.RequireRole("super_admin"), func(c *gin.Context) {
+		var req RotateKEKRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		result, err := service.RotateKEK(req, nil)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrWrappedDEKChanged) {
+				status = http.StatusConflict
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error(), "result": result})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
 	group.GET("/:id", func(c *gin.Context) {
 		id := c.Param("id")
 		item, err := service.GetSecret(id)
@@ -77,15 +181,19 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 		updater, _ := auth.GetCurrentUsername(c)
 		updated, err := service.UpdateFromInput(id, req, updater)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			auditChange(c, service, models.SecretActionUpdate, id, 0, err)
+			c.AbortWithStatusJSON(lifecycleErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
+		auditChange(c, service, models.SecretActionUpdate, id, updated.CurrentVersion, nil)
 		c.JSON(http.StatusOK, updated)
 	})
 
 	group.DELETE("/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		if err := service.DeleteSecret(id); err != nil {
+		err := service.DeleteSecret(id)
+		auditChange(c, service, models.SecretActionDelete, id, 0, err)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -95,8 +203,11 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 	group.POST("/:id/decrypt", func(c *gin.Context) {
 		id := c.Param("id")
 		value, err := service.DecryptSecret(id)
+		if !auditRead(c, service, id, 0, err) {
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(decryptErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"value": value})
@@ -112,13 +223,78 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 			return
 		}
 		value, err := service.DecryptSecretWithKEK(id, req.KEK)
+		if !auditRead(c, service, id, 0, err) {
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(decryptErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"value": value})
 	})
 
+	// GET /api/v1/secrets/:id/audit - Access audit trail of a secret
+	group.GET("/:id/audit", func(c *gin.Context) {
+		id := c.Param("id")
+		realmID, realmExists := auth.GetCurrentRealm(c)
+		if !realmExists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		page := parseIntDefault(c.Query("page"), 1)
+		pageSize := parseIntDefault(c.Query("page_size"), 20)
+
+		items, total, err := service.GetAccessEvents(realmID, id, page, pageSize)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+	})
+
+	// POST /api/v1/secrets/:id/shares - Create a time and view limited share link
+	group.POST("/:id/shares", func(c *gin.Context) {
+		id := c.Param("id")
+		var req CreateShareRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		creator, _ := auth.GetCurrentUsername(c)
+		share, err := service.CreateShare(id, req, creator)
+		if err != nil {
+			auditChange(c, service, models.SecretActionShare, id, req.Version, err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		auditChange(c, service, models.SecretActionShare, id, share.Version, nil)
+		c.JSON(http.StatusCreated, share)
+	})
+
+	// GET /api/v1/secrets/:id/shares - List share links of a secret
+	group.GET("/:id/shares", func(c *gin.Context) {
+		shares, err := service.GetShares(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"shares": shares})
+	})
+
+	// DELETE /api/v1/secrets/:id/shares/:shareId - Revoke a share link
+	group.DELETE("/:id/shares/:shareId", func(c *gin.Context) {
+		id := c.Param("id")
+		err := service.RevokeShare(id, c.Param("shareId"))
+		auditChange(c, service, models.SecretActionShareRevoke, id, 0, err)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
 	// Version management endpoints
 	group.GET("/:id/versions", func(c *gin.Context) {
 		id := c.Param("id")
@@ -138,8 +314,11 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 			return
 		}
 		value, err := service.DecryptSecretVersion(id, version)
+		if !auditRead(c, service, id, version, err) {
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(decryptErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"value": value})
@@ -160,8 +339,11 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 			return
 		}
 		value, err := service.DecryptSecretVersionWithKEK(id, version, req.KEK)
+		if !auditRead(c, service, id, version, err) {
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(decryptErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"value": value})
@@ -175,8 +357,10 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 			return
 		}
 		updater, _ := auth.GetCurrentUsername(c)
-		if err := service.ActivateSecretVersion(id, version, updater); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		err := service.ActivateSecretVersion(id, version, updater)
+		auditChange(c, service, models.SecretActionActivate, id, version, err)
+		if err != nil {
+			c.AbortWithStatusJSON(lifecycleErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.Status(http.StatusOK)
@@ -189,7 +373,9 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid version number"})
 			return
 		}
-		if err := service.DeleteSecretVersion(id, version); err != nil {
+		err := service.DeleteSecretVersion(id, version)
+		auditChange(c, service, models.SecretActionDeleteVersion, id, version, err)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -209,11 +395,99 @@ func RegisterRoutes(router *gin.Engine, service *SecretService, middleware *auth
 		creator, _ := auth.GetCurrentUsername(c)
 		version, err := service.CreatePendingVersion(id, req.Value, req.KEK, creator)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			auditChange(c, service, models.SecretActionCreatePending, id, 0, err)
+			c.AbortWithStatusJSON(lifecycleErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
+		auditChange(c, service, models.SecretActionCreatePending, id, version.Version, nil)
 		c.JSON(http.StatusCreated, version)
 	})
+
+	// GET /api/v1/secrets/:id/versions/pending - Metadata of the pending version
+	group.GET("/:id/versions/pending", func(c *gin.Context) {
+		version, err := service.GetPendingVersion(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, version)
+	})
+
+	// POST /api/v1/secrets/:id/versions/pending/decrypt - Fetch the pending value to validate it before promotion
+	group.POST("/:id/versions/pending/decrypt", func(c *gin.Context) {
+		id := c.Param("id")
+		value, version, err := service.DecryptPendingVersion(id)
+		if errors.Is(err, ErrNoPendingVersion) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if !auditRead(c, service, id, version, err) {
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"value": value, "version": version})
+	})
+
+	// POST /api/v1/secrets/:id/promote - Make the pending version current
+	group.POST("/:id/promote", func(c *gin.Context) {
+		id := c.Param("id")
+		updater, _ := auth.GetCurrentUsername(c)
+		transition, err := service.PromotePendingVersion(id, updater)
+		respondTransition(c, service, models.SecretActionPromote, id, transition, err)
+	})
+
+	// POST /api/v1/secrets/:id/rollback - Swap the current and previous versions
+	group.POST("/:id/rollback", func(c *gin.Context) {
+		id := c.Param("id")
+		updater, _ := auth.GetCurrentUsername(c)
+		transition, err := service.RollbackVersion(id, updater)
+		respondTransition(c, service, models.SecretActionRollback, id, transition, err)
+	})
+
+	// POST /api/v1/secrets/:id/discard-pending - Drop the pending version
+	group.POST("/:id/discard-pending", func(c *gin.Context) {
+		id := c.Param("id")
+		updater, _ := auth.GetCurrentUsername(c)
+		transition, err := service.DiscardPendingVersion(id, updater)
+		respondTransition(c, service, models.SecretActionDiscardPending, id, transition, err)
+	})
+
+	// GET /api/v1/secrets/:id/transitions - Version pointer history
+	group.GET("/:id/transitions", func(c *gin.Context) {
+		transitions, err := service.GetVersionTransitions(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"transitions": transitions})
+	})
+}
+
+// respondTransition audits a version pointer move and writes the transition as response
+func respondTransition(c *gin.Context, service *SecretService, action, id string, transition *models.SecretVersionTransition, err error) {
+	if err != nil {
+		auditChange(c, service, action, id, 0, err)
+		c.AbortWithStatusJSON(lifecycleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	auditChange(c, service, action, id, transition.ToCurrent, nil)
+	c.JSON(http.StatusOK, transition)
+}
+
+// lifecycleErrorStatus maps a version lifecycle error to an HTTP status
+func lifecycleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrVersionPointersChanged),
+		errors.Is(err, ErrNoPendingVersion),
+		errors.Is(err, ErrPendingVersionExists),
+		errors.Is(err, ErrNoPreviousVersion):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
 }
 
 func parseIntDefault(value string, defaultVal int) int {
@@ -227,3 +501,54 @@ func parseIntDefault(value string, defaultVal int) int {
 	}
 	return out
 }
+
+// accessActor builds the audit actor from the authenticated request
+func accessActor(c *gin.Context) AccessActor {
+	userID, _ := auth.GetCurrentUser(c)
+	username, _ := auth.GetCurrentUsername(c)
+	realmID, _ := auth.GetCurrentRealm(c)
+	return AccessActor{
+		UserID:   userID,
+		Username: username,
+		RealmID:  realmID,
+		ClientIP: c.ClientIP(),
+	}
+}
+
+// auditRead records a secret read before any plaintext is returned. Reads fail closed:
+// if the event cannot be stored the request is aborted and false is returned.
+func auditRead(c *gin.Context, service *SecretService, id string, version int, readErr error) bool {
+	if err := service.RecordAccess(accessActor(c), models.SecretActionRead, id, version, readErr); err != nil {
+		log.GetLogger().Errorf("Refusing secret read of %s: %v", id, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to record secret access"})
+		return false
+	}
+	return true
+}
+
+// auditChange records a secret change; the change already happened, so failures are only logged
+func auditChange(c *gin.Context, service *SecretService, action, id string, version int, changeErr error) {
+	if err := service.RecordAccess(accessActor(c), action, id, version, changeErr); err != nil {
+		log.GetLogger().Errorf("Failed to audit secret %s on %s: %v", action, id, err)
+	}
+}
+
+// parseTimeQuery parses an optional RFC3339 query value
+func parseTimeQuery(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// decryptErrorStatus maps a decryption error to an HTTP status
+func decryptErrorStatus(err error) int {
+	if errors.Is(err, ErrSecretExpired) {
+		return http.StatusGone
+	}
+	return http.StatusInternalServerError
+}
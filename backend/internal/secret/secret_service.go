@@ -8,8 +8,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -17,13 +15,24 @@ import (
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
 )
 
+// ErrSecretExpired is returned when decrypting an expired secret while expired decryption is blocked
+var ErrSecretExpired = errors.New("secret has expired")
+
 // SecretService contains business logic for secrets
 type SecretService struct {
 	repo *SecretRepository
+	keys KeyProvider
+
+	// blockExpiredDecrypt refuses to decrypt secrets past their ExpiresAt
+	blockExpiredDecrypt bool
 }
 
-func NewSecretService(repo *SecretRepository) *SecretService {
-	return &SecretService{repo: repo}
+func NewSecretService(repo *SecretRepository, keys KeyProvider) *SecretService {
+	return &SecretService{
+		repo:                repo,
+		keys:                keys,
+		blockExpiredDecrypt: getConfigBool("secret.expiry.block_decrypt", "SECRET_BLOCK_EXPIRED_DECRYPT", false),
+	}
 }
 
 // CreateSecretRequest defines the allowed input for creating a secret
@@ -34,6 +43,9 @@ type CreateSecretRequest struct {
 	Path  string `json:"path" binding:"required"`
 	Value string `json:"value" binding:"required"`
 	KEK   string `json:"kek"` // Optional custom KEK (32 characters)
+
+	ExpiresAt            *time.Time `json:"expires_at"`             // Optional hard expiry
+	RotationIntervalDays int        `json:"rotation_interval_days"` // Optional rotation policy, 0 disables it
 }
 
 // UpdateSecretRequest defines the allowed input for updating a secret
@@ -45,79 +57,55 @@ type UpdateSecretRequest struct {
 	Value        string `json:"value" binding:"required"`         // New secret value
 	CurrentValue string `json:"current_value" binding:"required"` // Current secret value for verification
 	KEK          string `json:"kek"`                              // Optional custom KEK (32 characters)
+
+	ExpiresAt            *time.Time `json:"expires_at"`             // Replaces the expiry; nil clears it
+	RotationIntervalDays int        `json:"rotation_interval_days"` // Replaces the rotation policy, 0 disables it
 }
 
 func (s *SecretService) CreateFromInput(req CreateSecretRequest, realmID, createdBy string) (*models.Secret, error) {
 	if strings.TrimSpace(realmID) == "" || strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Path) == "" || strings.TrimSpace(req.Group) == "" {
 		return nil, errors.New("realm_id, name, group, path are required")
 	}
-
-	// Validate custom KEK if provided
-	var kek []byte
-	var kekVersion int
-	var err error
-
-	if strings.TrimSpace(req.KEK) != "" {
-		// Use custom KEK - hash it to 32 bytes using SHA-256
-		hash := sha256.Sum256([]byte(req.KEK))
-		kek = hash[:]
-		kekVersion = 999 // Use a special version for custom KEKs
-	} else {
-		// Use environment KEK
-		kek, kekVersion, err = loadKEKFromEnv()
-		if err != nil {
-			return nil, err
-		}
+	if req.RotationIntervalDays < 0 {
+		return nil, errors.New("rotation_interval_days cannot be negative")
 	}
 
 	// Envelope encryption: encrypt value with random DEK, then wrap DEK with KEK
-	dek := generateRandomBytes(32)
-	ciphertext, dataNonce, dataTag, err := encryptAESGCM(dek, []byte(req.Value))
-	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
-	}
-
-	wrappedDEK, wrapNonce, wrapTag, err := encryptAESGCM(kek, dek)
+	sealed, err := s.envelopeEncrypt([]byte(req.Value), req.KEK)
 	if err != nil {
-		return nil, fmt.Errorf("failed to wrap DEK: %w", err)
+		return nil, err
 	}
 
-	// Combine wrap parts into a single field (since model has only WrappedDEK)
-	combinedWrapped := append(append(wrapNonce, wrappedDEK...), wrapTag...)
-
 	// Create the main secret record
+	now := time.Now()
 	secret := &models.Secret{
-		ID:              uuid.NewString(),
-		RealmID:         realmID,
-		Name:            req.Name,
-		Group:           req.Group,
-		Desc:            req.Desc,
-		Path:            req.Path,
-		CurrentVersion:  1,
-		PreviousVersion: 0,
-		PendingVersion:  0,
-		MaxVersion:      1,
-		CreatedBy:       createdBy,
-		CreatedAt:       time.Now(),
-		UpdatedBy:       createdBy,
-		UpdatedAt:       time.Now(),
+		ID:                   uuid.NewString(),
+		RealmID:              realmID,
+		Name:                 req.Name,
+		Group:                req.Group,
+		Desc:                 req.Desc,
+		Path:                 req.Path,
+		CurrentVersion:       1,
+		PreviousVersion:      0,
+		PendingVersion:       0,
+		MaxVersion:           1,
+		ExpiresAt:            req.ExpiresAt,
+		RotationIntervalDays: req.RotationIntervalDays,
+		RotatedAt:            &now,
+		CreatedBy:            createdBy,
+		CreatedAt:            time.Now(),
+		UpdatedBy:            createdBy,
+		UpdatedAt:            time.Now(),
 	}
 
 	// Create the first version record
-	secretVersion := &models.SecretVersion{
-		ID:         uuid.NewString(),
-		SecretID:   secret.ID,
-		Version:    1,
-		CipherAlg:  "aes-256-gcm",
-		CipherText: base64.StdEncoding.EncodeToString(ciphertext),
-		Nonce:      base64.StdEncoding.EncodeToString(dataNonce),
-		AuthTag:    base64.StdEncoding.EncodeToString(dataTag),
-		WrappedDEK: base64.StdEncoding.EncodeToString(combinedWrapped),
-		KEKVersion: kekVersion,
-		Status:     "active",
-		CreatedBy:  createdBy,
-		CreatedAt:  time.Now(),
-	}
+	secretVersion := sealed
+	secretVersion.ID = uuid.NewString()
+	secretVersion.SecretID = secret.ID
+	secretVersion.Version = 1
+	secretVersion.Status = "active"
+	secretVersion.CreatedBy = createdBy
+	secretVersion.CreatedAt = time.Now()
 
 	if err := s.repo.CreateWithVersion(secret, secretVersion); err != nil {
 		return nil, err
@@ -130,15 +118,23 @@ func (s *SecretService) UpdateFromInput(id string, req UpdateSecretRequest, upda
 	if strings.TrimSpace(id) == "" || strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Path) == "" || strings.TrimSpace(req.Group) == "" {
 		return nil, errors.New("id, name, group, path are required")
 	}
+	if req.RotationIntervalDays < 0 {
+		return nil, errors.New("rotation_interval_days cannot be negative")
+	}
 
 	// Get existing secret to preserve realm_id and other metadata
 	existing, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get existing secret: %w", err)
 	}
+	// A plain update would make a new current version behind the pending one's back
+	if existing.PendingVersion != 0 {
+		return nil, ErrPendingVersionExists
+	}
 
-	// SECURITY: Verify the current value before allowing update
-	currentDecrypted, err := s.DecryptSecret(id)
+	// SECURITY: Verify the current value before allowing update.
+	// Expiry is not enforced here, rotating an expired secret must remain possible.
+	currentDecrypted, err := s.decryptSecretVersion(existing, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt existing secret for verification: %w", err)
 	}
@@ -147,54 +143,21 @@ func (s *SecretService) UpdateFromInput(id string, req UpdateSecretRequest, upda
 		return nil, errors.New("current value verification failed - provided current value does not match stored secret")
 	}
 
-	// Validate custom KEK if provided for the NEW secret
-	var kek []byte
-	var kekVersion int
-
-	if strings.TrimSpace(req.KEK) != "" {
-		// Use custom KEK - hash it to 32 bytes using SHA-256
-		hash := sha256.Sum256([]byte(req.KEK))
-		kek = hash[:]
-		kekVersion = 999 // Use a special version for custom KEKs
-	} else {
-		// Use environment KEK
-		kek, kekVersion, err = loadKEKFromEnv()
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	// Envelope encryption: encrypt NEW value with random DEK, then wrap DEK with KEK
-	dek := generateRandomBytes(32)
-	ciphertext, dataNonce, dataTag, err := encryptAESGCM(dek, []byte(req.Value))
-	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt new secret: %w", err)
-	}
-
-	wrappedDEK, wrapNonce, wrapTag, err := encryptAESGCM(kek, dek)
+	sealed, err := s.envelopeEncrypt([]byte(req.Value), req.KEK)
 	if err != nil {
-		return nil, fmt.Errorf("failed to wrap DEK: %w", err)
+		return nil, err
 	}
 
-	// Combine wrap parts into a single field (since model has only WrappedDEK)
-	combinedWrapped := append(append(wrapNonce, wrappedDEK...), wrapTag...)
-
 	// Create new version
 	newVersion := existing.MaxVersion + 1
-	secretVersion := &models.SecretVersion{
-		ID:         uuid.NewString(),
-		SecretID:   existing.ID,
-		Version:    newVersion,
-		CipherAlg:  "aes-256-gcm",
-		CipherText: base64.StdEncoding.EncodeToString(ciphertext),
-		Nonce:      base64.StdEncoding.EncodeToString(dataNonce),
-		AuthTag:    base64.StdEncoding.EncodeToString(dataTag),
-		WrappedDEK: base64.StdEncoding.EncodeToString(combinedWrapped),
-		KEKVersion: kekVersion,
-		Status:     "active",
-		CreatedBy:  updatedBy,
-		CreatedAt:  time.Now(),
-	}
+	secretVersion := sealed
+	secretVersion.ID = uuid.NewString()
+	secretVersion.SecretID = existing.ID
+	secretVersion.Version = newVersion
+	secretVersion.Status = "active"
+	secretVersion.CreatedBy = updatedBy
+	secretVersion.CreatedAt = time.Now()
 
 	// Update the secret metadata and version pointers
 	existing.Name = req.Name
@@ -204,6 +167,10 @@ func (s *SecretService) UpdateFromInput(id string, req UpdateSecretRequest, upda
 	existing.PreviousVersion = existing.CurrentVersion
 	existing.CurrentVersion = newVersion
 	existing.MaxVersion = newVersion
+	existing.ExpiresAt = req.ExpiresAt
+	existing.RotationIntervalDays = req.RotationIntervalDays
+	rotatedAt := time.Now()
+	existing.RotatedAt = &rotatedAt
 	existing.UpdatedBy = updatedBy
 	existing.UpdatedAt = time.Now()
 
@@ -257,7 +224,15 @@ func (s *SecretService) DecryptSecretVersion(id string, version int) (string, er
 	if err != nil {
 		return "", fmt.Errorf("failed to get secret: %w", err)
 	}
+	if err := s.checkExpiry(secret); err != nil {
+		return "", err
+	}
+
+	return s.decryptSecretVersion(secret, version)
+}
 
+// decryptSecretVersion decrypts a version of an already loaded secret with the key provider (0 = current version)
+func (s *SecretService) decryptSecretVersion(secret *models.Secret, version int) (string, error) {
 	// Determine which version to decrypt
 	targetVersion := version
 	if version == 0 {
@@ -265,65 +240,19 @@ func (s *SecretService) DecryptSecretVersion(id string, version int) (string, er
 	}
 
 	// Get the secret version
-	secretVersion, err := s.repo.GetSecretVersion(id, targetVersion)
+	secretVersion, err := s.repo.GetSecretVersion(secret.ID, targetVersion)
 	if err != nil {
 		return "", fmt.Errorf("failed to get secret version %d: %w", targetVersion, err)
 	}
 
-	// Load KEK from environment based on the version's KEK version
-	kek, err := s.loadKEKByVersion(secretVersion.KEKVersion)
-	if err != nil {
-		return "", fmt.Errorf("failed to load KEK: %w", err)
-	}
-
-	// Decode base64 fields
-	ciphertext, err := base64.StdEncoding.DecodeString(secretVersion.CipherText)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
-	}
-
-	nonce, err := base64.StdEncoding.DecodeString(secretVersion.Nonce)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode nonce: %w", err)
-	}
-
-	authTag, err := base64.StdEncoding.DecodeString(secretVersion.AuthTag)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode auth tag: %w", err)
-	}
-
-	wrappedDEK, err := base64.StdEncoding.DecodeString(secretVersion.WrappedDEK)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode wrapped DEK: %w", err)
-	}
-
-	// Extract wrap nonce, wrapped DEK, and wrap tag from combined field
-	// Format: [wrapNonce][wrappedDEK][wrapTag]
-	wrapNonceSize := 12  // AES-GCM nonce size
-	wrappedDEKSize := 32 // AES-256 key size
-	wrapTagSize := 16    // AES-GCM tag size
-
-	if len(wrappedDEK) < wrapNonceSize+wrappedDEKSize+wrapTagSize {
-		return "", errors.New("invalid wrapped DEK format")
-	}
-
-	wrapNonce := wrappedDEK[:wrapNonceSize]
-	wrappedDEKOnly := wrappedDEK[wrapNonceSize : wrapNonceSize+wrappedDEKSize]
-	wrapTag := wrappedDEK[wrapNonceSize+wrappedDEKSize:]
-
-	// Unwrap DEK using KEK
-	dek, err := decryptAESGCM(kek, wrapNonce, wrappedDEKOnly, wrapTag)
+	// Unwrap DEK with the key provider based on the version's KEK version
+	dek, err := s.keys.Unwrap(secretVersion.WrappedDEK, secretVersion.KEKVersion)
 	if err != nil {
 		return "", fmt.Errorf("failed to unwrap DEK: %w", err)
 	}
+	defer zeroBytes(dek)
 
-	// Decrypt the actual secret value using DEK
-	plaintext, err := decryptAESGCM(dek, nonce, ciphertext, authTag)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt secret: %w", err)
-	}
-
-	return string(plaintext), nil
+	return decryptVersionWithDEK(secretVersion, dek)
 }
 
 // DecryptSecretWithKEK decrypts a secret using a custom KEK provided by the user
@@ -337,6 +266,9 @@ func (s *SecretService) DecryptSecretVersionWithKEK(id string, version int, cust
 	if err != nil {
 		return "", fmt.Errorf("failed to get secret: %w", err)
 	}
+	if err := s.checkExpiry(secret); err != nil {
+		return "", err
+	}
 
 	// Determine which version to decrypt
 	targetVersion := version
@@ -354,54 +286,14 @@ func (s *SecretService) DecryptSecretVersionWithKEK(id string, version int, cust
 	hash := sha256.Sum256([]byte(customKEK))
 	kek := hash[:]
 
-	// Decode base64 fields
-	ciphertext, err := base64.StdEncoding.DecodeString(secretVersion.CipherText)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
-	}
-
-	nonce, err := base64.StdEncoding.DecodeString(secretVersion.Nonce)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode nonce: %w", err)
-	}
-
-	authTag, err := base64.StdEncoding.DecodeString(secretVersion.AuthTag)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode auth tag: %w", err)
-	}
-
-	wrappedDEK, err := base64.StdEncoding.DecodeString(secretVersion.WrappedDEK)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode wrapped DEK: %w", err)
-	}
-
-	// Extract wrap nonce, wrapped DEK, and wrap tag from combined field
-	// Format: [wrapNonce][wrappedDEK][wrapTag]
-	wrapNonceSize := 12  // AES-GCM nonce size
-	wrappedDEKSize := 32 // AES-256 key size
-	wrapTagSize := 16    // AES-GCM tag size
-
-	if len(wrappedDEK) < wrapNonceSize+wrappedDEKSize+wrapTagSize {
-		return "", errors.New("invalid wrapped DEK format")
-	}
-
-	wrapNonce := wrappedDEK[:wrapNonceSize]
-	wrappedDEKOnly := wrappedDEK[wrapNonceSize : wrapNonceSize+wrappedDEKSize]
-	wrapTag := wrappedDEK[wrapNonceSize+wrappedDEKSize:]
-
 	// Unwrap DEK using custom KEK
-	dek, err := decryptAESGCM(kek, wrapNonce, wrappedDEKOnly, wrapTag)
+	dek, err := unwrapDEK(kek, secretVersion.WrappedDEK)
 	if err != nil {
 		return "", fmt.Errorf("failed to unwrap DEK with custom KEK: %w", err)
 	}
+	defer zeroBytes(dek)
 
-	// Decrypt the actual secret value using DEK
-	plaintext, err := decryptAESGCM(dek, nonce, ciphertext, authTag)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt secret: %w", err)
-	}
-
-	return string(plaintext), nil
+	return decryptVersionWithDEK(secretVersion, dek)
 }
 
 // GetSecretVersions returns all versions of a secret
@@ -422,13 +314,24 @@ func (s *SecretService) ActivateSecretVersion(id string, version int, updatedBy
 		return fmt.Errorf("version %d does not exist: %w", version, err)
 	}
 
-	// Update version pointers
-	secret.PreviousVersion = secret.CurrentVersion
-	secret.CurrentVersion = version
-	secret.UpdatedBy = updatedBy
-	secret.UpdatedAt = time.Now()
+	if version == secret.CurrentVersion {
+		return fmt.Errorf("version %d is already the current version", version)
+	}
 
-	return s.repo.Update(secret)
+	// Move version pointers atomically; activating the pending version also clears the pending pointer
+	transition := newTransition(secret, models.SecretActionActivate, updatedBy)
+	transition.ToCurrent = version
+	transition.ToPrevious = secret.CurrentVersion
+	if version == secret.PendingVersion {
+		transition.ToPending = 0
+	}
+
+	rotatedAt := transition.CreatedAt
+	return s.repo.MoveVersionPointers(VersionPointerMove{
+		Transition: transition,
+		Statuses:   map[int]string{secret.CurrentVersion: "deprecated", version: "active"},
+		RotatedAt:  &rotatedAt,
+	})
 }
 
 // DeleteSecretVersion marks a specific version as deleted (soft delete)
@@ -442,6 +345,9 @@ func (s *SecretService) DeleteSecretVersion(id string, version int) error {
 	if version == secret.CurrentVersion {
 		return errors.New("cannot delete the current active version")
 	}
+	if version == secret.PendingVersion {
+		return errors.New("cannot delete the pending version, discard it instead")
+	}
 
 	return s.repo.DeleteSecretVersion(id, version)
 }
@@ -452,56 +358,25 @@ func (s *SecretService) CreatePendingVersion(id string, value string, kek string
 	if err != nil {
 		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
-
-	// Validate custom KEK if provided
-	var kekBytes []byte
-	var kekVersion int
-
-	if strings.TrimSpace(kek) != "" {
-		// Use custom KEK - hash it to 32 bytes using SHA-256
-		hash := sha256.Sum256([]byte(kek))
-		kekBytes = hash[:]
-		kekVersion = 999 // Use a special version for custom KEKs
-	} else {
-		// Use environment KEK
-		var err error
-		kekBytes, kekVersion, err = loadKEKFromEnv()
-		if err != nil {
-			return nil, err
-		}
+	if secret.PendingVersion != 0 {
+		return nil, ErrPendingVersionExists
 	}
 
 	// Envelope encryption: encrypt value with random DEK, then wrap DEK with KEK
-	dek := generateRandomBytes(32)
-	ciphertext, dataNonce, dataTag, err := encryptAESGCM(dek, []byte(value))
+	sealed, err := s.envelopeEncrypt([]byte(value), kek)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
-	}
-
-	wrappedDEK, wrapNonce, wrapTag, err := encryptAESGCM(kekBytes, dek)
-	if err != nil {
-		return nil, fmt.Errorf("failed to wrap DEK: %w", err)
+		return nil, err
 	}
 
-	// Combine wrap parts into a single field
-	combinedWrapped := append(append(wrapNonce, wrappedDEK...), wrapTag...)
-
 	// Create new pending version
 	newVersion := secret.MaxVersion + 1
-	secretVersion := &models.SecretVersion{
-		ID:         uuid.NewString(),
-		SecretID:   secret.ID,
-		Version:    newVersion,
-		CipherAlg:  "aes-256-gcm",
-		CipherText: base64.StdEncoding.EncodeToString(ciphertext),
-		Nonce:      base64.StdEncoding.EncodeToString(dataNonce),
-		AuthTag:    base64.StdEncoding.EncodeToString(dataTag),
-		WrappedDEK: base64.StdEncoding.EncodeToString(combinedWrapped),
-		KEKVersion: kekVersion,
-		Status:     "pending",
-		CreatedBy:  createdBy,
-		CreatedAt:  time.Now(),
-	}
+	secretVersion := sealed
+	secretVersion.ID = uuid.NewString()
+	secretVersion.SecretID = secret.ID
+	secretVersion.Version = newVersion
+	secretVersion.Status = "pending"
+	secretVersion.CreatedBy = createdBy
+	secretVersion.CreatedAt = time.Now()
 
 	// Update max version and pending version pointer
 	secret.MaxVersion = newVersion
@@ -509,7 +384,7 @@ func (s *SecretService) CreatePendingVersion(id string, value string, kek string
 	secret.UpdatedBy = createdBy
 	secret.UpdatedAt = time.Now()
 
-	if err := s.repo.CreateVersionWithUpdate(secret, secretVersion); err != nil {
+	if err := s.repo.CreatePendingVersion(secret, secretVersion); err != nil {
 		return nil, err
 	}
 
@@ -518,53 +393,116 @@ func (s *SecretService) CreatePendingVersion(id string, value string, kek string
 
 // --- helpers ---
 
-func loadKEKFromEnv() ([]byte, int, error) {
-	kekVersion := parseKEKVersion()
-	kek, err := loadKEKByVersionInt(kekVersion)
-	return kek, kekVersion, err
+// checkExpiry rejects expired secrets when expired decryption is blocked
+func (s *SecretService) checkExpiry(secret *models.Secret) error {
+	if s.blockExpiredDecrypt && secret.IsExpired(time.Now()) {
+		return fmt.Errorf("%w at %s", ErrSecretExpired, secret.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
 }
 
-// loadKEKByVersion loads a KEK by its version number
-func (s *SecretService) loadKEKByVersion(kekVersion int) ([]byte, error) {
-	kek, err := loadKEKByVersionInt(kekVersion)
-	return kek, err
+// envelopeEncrypt encrypts plaintext with a fresh DEK and wraps the DEK with the custom KEK
+// when one is given, otherwise with the key provider's current KEK. Only the crypto fields
+// of the returned version are set.
+func (s *SecretService) envelopeEncrypt(plaintext []byte, customKEK string) (*models.SecretVersion, error) {
+	dek := generateRandomBytes(32)
+	defer zeroBytes(dek)
+
+	ciphertext, dataNonce, dataTag, err := encryptAESGCM(dek, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	var wrapped string
+	var kekVersion int
+	if strings.TrimSpace(customKEK) != "" {
+		// Use custom KEK - hash it to 32 bytes using SHA-256
+		hash := sha256.Sum256([]byte(customKEK))
+		wrapped, err = wrapDEK(hash[:], dek)
+		kekVersion = customKEKVersion
+	} else {
+		wrapped, kekVersion, err = s.keys.Wrap(dek)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SecretVersion{
+		CipherAlg:  "aes-256-gcm",
+		CipherText: base64.StdEncoding.EncodeToString(ciphertext),
+		Nonce:      base64.StdEncoding.EncodeToString(dataNonce),
+		AuthTag:    base64.StdEncoding.EncodeToString(dataTag),
+		WrappedDEK: wrapped,
+		KEKVersion: kekVersion,
+	}, nil
 }
 
-func loadKEKByVersionInt(kekVersion int) ([]byte, error) {
-	// Handle custom KEK version
-	if kekVersion == 999 {
-		return nil, errors.New("custom KEK version 999 requires explicit KEK parameter")
-	}
-
-	// Prefer base64-encoded KEK
-	if b64 := os.Getenv("KEK_BASE64_" + strconv.Itoa(kekVersion)); b64 != "" {
-		key, err := base64.StdEncoding.DecodeString(b64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid KEK_BASE64_%d: %w", kekVersion, err)
-		}
-		if len(key) != 32 {
-			return nil, fmt.Errorf("KEK_%d must be 32 bytes for AES-256-GCM, got %d", kekVersion, len(key))
-		}
-		return key, nil
-	}
-	// Fallback: raw key in KEK (must be 32 bytes)
-	if raw := os.Getenv("KEK_" + strconv.Itoa(kekVersion)); raw != "" {
-		key := []byte(raw)
-		if len(key) != 32 {
-			return nil, fmt.Errorf("KEK_%d must be 32 bytes for AES-256-GCM, got %d", kekVersion, len(key))
-		}
-		return key, nil
-	}
-	return nil, fmt.Errorf("KEK_%d not configured; set KEK_BASE64_%d or KEK_%d", kekVersion, kekVersion, kekVersion)
+// decryptVersionWithDEK decrypts the ciphertext of a version with an already unwrapped DEK
+func decryptVersionWithDEK(secretVersion *models.SecretVersion, dek []byte) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(secretVersion.CipherText)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(secretVersion.Nonce)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode nonce: %w", err)
+	}
+
+	authTag, err := base64.StdEncoding.DecodeString(secretVersion.AuthTag)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode auth tag: %w", err)
+	}
+
+	plaintext, err := decryptAESGCM(dek, nonce, ciphertext, authTag)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// wrapDEK wraps a DEK with the KEK and encodes it as base64([wrapNonce][wrappedDEK][wrapTag])
+func wrapDEK(kek, dek []byte) (string, error) {
+	wrappedDEK, wrapNonce, wrapTag, err := encryptAESGCM(kek, dek)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap DEK: %w", err)
+	}
+	combinedWrapped := append(append(wrapNonce, wrappedDEK...), wrapTag...)
+	return base64.StdEncoding.EncodeToString(combinedWrapped), nil
+}
+
+// unwrapDEK reverses wrapDEK and returns the plain DEK
+func unwrapDEK(kek []byte, encoded string) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wrapped DEK: %w", err)
+	}
+
+	wrapNonceSize := 12  // AES-GCM nonce size
+	wrappedDEKSize := 32 // AES-256 key size
+	wrapTagSize := 16    // AES-GCM tag size
+
+	if len(wrapped) < wrapNonceSize+wrappedDEKSize+wrapTagSize {
+		return nil, errors.New("invalid wrapped DEK format")
+	}
+
+	wrapNonce := wrapped[:wrapNonceSize]
+	wrappedDEKOnly := wrapped[wrapNonceSize : wrapNonceSize+wrappedDEKSize]
+	wrapTag := wrapped[wrapNonceSize+wrappedDEKSize:]
+
+	dek, err := decryptAESGCM(kek, wrapNonce, wrappedDEKOnly, wrapTag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap DEK: %w", err)
+	}
+	return dek, nil
 }
 
-func parseKEKVersion() int {
-	if v := os.Getenv("KEK_VERSION"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			return n
-		}
+// zeroBytes wipes a sensitive buffer (best-effort)
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
 	}
-	return 1
 }
 
 func generateRandomBytes(n int) []byte {
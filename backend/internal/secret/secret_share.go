@@ -0,0 +1,148 @@
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultShareExpiryMinutes = 60
+	maxShareExpiryMinutes     = 7 * 24 * 60
+	defaultShareMaxViews      = 1
+	maxShareMaxViews          = 100
+	shareTokenBytes           = 32
+)
+
+// ErrShareNotFound is returned for unknown, expired, exhausted and revoked shares alike,
+// so that an unauthenticated caller cannot tell them apart
+var ErrShareNotFound = errors.New("share not found or expired")
+
+// CreateShareRequest describes a new share link; zero values fall back to one view within 60 minutes
+type CreateShareRequest struct {
+	Version          int `json:"version"` // 0 means the current version
+	ExpiresInMinutes int `json:"expires_in_minutes"`
+	MaxViews         int `json:"max_views"`
+}
+
+// CreateShareResponse carries the share token, which is only ever returned here
+type CreateShareResponse struct {
+	models.SecretShare
+	Token string `json:"token"`
+}
+
+// CreateShare creates a share link for a secret version
+func (s *SecretService) CreateShare(id string, req CreateShareRequest, createdBy string) (*CreateShareResponse, error) {
+	if req.ExpiresInMinutes == 0 {
+		req.ExpiresInMinutes = defaultShareExpiryMinutes
+	}
+	if req.ExpiresInMinutes < 0 || req.ExpiresInMinutes > maxShareExpiryMinutes {
+		return nil, fmt.Errorf("expires_in_minutes must be between 1 and %d", maxShareExpiryMinutes)
+	}
+	if req.MaxViews == 0 {
+		req.MaxViews = defaultShareMaxViews
+	}
+	if req.MaxViews < 0 || req.MaxViews > maxShareMaxViews {
+		return nil, fmt.Errorf("max_views must be between 1 and %d", maxShareMaxViews)
+	}
+
+	secret, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	version := req.Version
+	if version == 0 {
+		version = secret.CurrentVersion
+	}
+	secretVersion, err := s.repo.GetSecretVersion(id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret version %d: %w", version, err)
+	}
+	// The server cannot decrypt versions protected by a user supplied KEK, so it cannot share them
+	if secretVersion.KEKVersion == customKEKVersion {
+		return nil, fmt.Errorf("secret version %d is protected by a custom KEK and cannot be shared", version)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(generateRandomBytes(shareTokenBytes))
+	share := models.SecretShare{
+		ID:        uuid.NewString(),
+		RealmID:   secret.RealmID,
+		SecretID:  id,
+		Version:   version,
+		TokenHash: hashShareToken(token),
+		MaxViews:  req.MaxViews,
+		ExpiresAt: time.Now().Add(time.Duration(req.ExpiresInMinutes) * time.Minute),
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateShare(&share); err != nil {
+		return nil, fmt.Errorf("failed to create share: %w", err)
+	}
+
+	return &CreateShareResponse{SecretShare: share, Token: token}, nil
+}
+
+// GetShares lists the shares of a secret
+func (s *SecretService) GetShares(id string) ([]models.SecretShare, error) {
+	return s.repo.ListShares(id)
+}
+
+// RevokeShare burns a share before it expires
+func (s *SecretService) RevokeShare(id, shareID string) error {
+	if _, err := s.repo.GetShare(id, shareID); err != nil {
+		return fmt.Errorf("failed to get share: %w", err)
+	}
+	return s.repo.BurnShare(shareID, time.Now())
+}
+
+// RevealShare consumes one view of a share and returns the decrypted secret value. The view is
+// only claimed once the decryption succeeded, together with its audit event, and the value is
+// only returned once both are stored, so a share can never be revealed more often than allowed
+// or without a trace. A failed decryption is audited and leaves the view count untouched.
+// clientIP is recorded with the event.
+func (s *SecretService) RevealShare(token, clientIP string) (string, *models.SecretShare, error) {
+	if token == "" {
+		return "", nil, ErrShareNotFound
+	}
+
+	now := time.Now()
+	share, err := s.repo.GetShareByTokenHash(hashShareToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !share.IsActive(now)) {
+		return "", nil, ErrShareNotFound
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get share: %w", err)
+	}
+
+	// Decrypt before taking the view, so that a failing decryption does not burn the link
+	actor := AccessActor{Username: "share:" + share.ID, RealmID: share.RealmID, ClientIP: clientIP}
+	value, err := s.DecryptSecretVersion(share.SecretID, share.Version)
+	if err != nil {
+		if auditErr := s.RecordAccess(actor, models.SecretActionShareReveal, share.SecretID, share.Version, err); auditErr != nil {
+			return "", nil, errors.Join(err, auditErr)
+		}
+		return "", share, err
+	}
+
+	event := s.newAccessEvent(actor, models.SecretActionShareReveal, share.SecretID, share.Version, nil)
+	if err := s.repo.ConsumeShareView(share, now, event); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrShareNotFound
+		}
+		return "", nil, fmt.Errorf("failed to consume share: %w", err)
+	}
+	return value, share, nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
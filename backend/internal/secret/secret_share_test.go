@@ -0,0 +1,121 @@
+package secret
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+// newTestSecretService returns a service on an in-memory database with a file keyring
+func newTestSecretService(t *testing.T) *SecretService {
+	db := testutil.NewTestDB(t, &models.Secret{}, &models.SecretVersion{}, &models.SecretShare{}, &models.SecretVersionTransition{}, &models.SecretAccessEvent{})
+	keys, err := NewFileKeyProvider(writeKeyring(t, fmt.Sprintf("current_version: 1\nkeys:\n  1: %q\n", testKey('a'))))
+	require.NoError(t, err)
+	return &SecretService{repo: &SecretRepository{db: db}, keys: keys}
+}
+
+func createTestSecret(t *testing.T, s *SecretService, value string) *models.Secret {
+	secret, err := s.CreateFromInput(CreateSecretRequest{Name: "db-password", Group: "db", Path: "db/prod/password", Value: value}, "realm", "alice")
+	require.NoError(t, err)
+	return secret
+}
+
+func TestRevealShare_ViewLimit(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+
+	share, err := s.CreateShare(secret.ID, CreateShareRequest{MaxViews: 1}, "alice")
+	require.NoError(t, err)
+
+	value, revealed, err := s.RevealShare(share.Token, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value)
+	assert.Equal(t, 1, revealed.ViewCount)
+	assert.NotNil(t, revealed.BurnedAt)
+
+	_, _, err = s.RevealShare(share.Token, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrShareNotFound)
+	_, _, err = s.RevealShare("not-a-token", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrShareNotFound)
+
+	stored, err := s.repo.GetShare(secret.ID, share.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.ViewCount)
+}
+
+func TestRevealShare_Expired(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+
+	share, err := s.CreateShare(secret.ID, CreateShareRequest{MaxViews: 3}, "alice")
+	require.NoError(t, err)
+	require.NoError(t, s.repo.db.Model(&models.SecretShare{}).Where("id = ?", share.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	_, _, err = s.RevealShare(share.Token, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrShareNotFound)
+
+	stored, err := s.repo.GetShare(secret.ID, share.ID)
+	require.NoError(t, err)
+	assert.Zero(t, stored.ViewCount)
+}
+
+func TestRevealShare_AuditedWithView(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+	share, err := s.CreateShare(secret.ID, CreateShareRequest{MaxViews: 2}, "alice")
+	require.NoError(t, err)
+
+	_, _, err = s.RevealShare(share.Token, "10.0.0.1")
+	require.NoError(t, err)
+	events, _, err := s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", Action: models.SecretActionShareReveal}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "share:"+share.ID, events[0].Username)
+	assert.Equal(t, "10.0.0.1", events[0].ClientIP)
+
+	// When the audit event cannot be stored, the view is not taken and nothing is revealed
+	require.NoError(t, s.repo.db.Migrator().DropTable(&models.SecretAccessEvent{}))
+	value, _, err := s.RevealShare(share.Token, "10.0.0.1")
+	assert.Error(t, err)
+	assert.Empty(t, value)
+	stored, err := s.repo.GetShare(secret.ID, share.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.ViewCount)
+	assert.Nil(t, stored.BurnedAt)
+}
+
+func TestRevealShare_DecryptFailureKeepsView(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+	share, err := s.CreateShare(secret.ID, CreateShareRequest{MaxViews: 1}, "alice")
+	require.NoError(t, err)
+
+	// A KEK version the keyring does not have makes the decryption fail
+	require.NoError(t, s.repo.db.Model(&models.SecretVersion{}).Where("secret_id = ?", secret.ID).
+		Update("kek_version", 99).Error)
+	value, _, err := s.RevealShare(share.Token, "10.0.0.1")
+	assert.Error(t, err)
+	assert.Empty(t, value)
+	stored, err := s.repo.GetShare(secret.ID, share.ID)
+	require.NoError(t, err)
+	assert.Zero(t, stored.ViewCount)
+	assert.Nil(t, stored.BurnedAt)
+	events, _, err := s.SearchAccessEvents(AccessEventFilter{RealmID: "realm", Action: models.SecretActionShareReveal}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.SecretOutcomeFailure, events[0].Outcome)
+
+	// Once the key is back, the one-time link still reveals the value
+	require.NoError(t, s.repo.db.Model(&models.SecretVersion{}).Where("secret_id = ?", secret.ID).
+		Update("kek_version", 1).Error)
+	value, _, err = s.RevealShare(share.Token, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value)
+}
@@ -0,0 +1,145 @@
+package secret
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/util"
+)
+
+const (
+	// maxTemplateSize caps the size of a template rendered server-side
+	maxTemplateSize = 64 * 1024
+	// maxTemplateSecrets caps how many distinct secrets a single template may reference
+	maxTemplateSecrets = 100
+)
+
+// templateLimits bound the rendering, as a small template can loop over {{ range N }}
+var templateLimits = util.TemplateLimits{
+	MaxOutput: 1 << 20,
+	MaxSteps:  100000,
+	Timeout:   5 * time.Second,
+}
+
+// RenderTemplateRequest is a text/template that references secrets with {{ secret "path" }}
+// and plain values with {{ .key }}
+type RenderTemplateRequest struct {
+	Template string            `json:"template" binding:"required"`
+	Data     map[string]string `json:"data"`
+}
+
+// RenderTemplate renders a template in the actor's realm. Every secret it references is decrypted
+// and audited as a read on behalf of the actor; rendering stops at the first unknown, ambiguous
+// or unreadable secret, so a partially rendered config is never returned.
+func (s *SecretService) RenderTemplate(actor AccessActor, text string, data map[string]string) (string, error) {
+	if strings.TrimSpace(actor.RealmID) == "" {
+		return "", fmt.Errorf("realm is required")
+	}
+	if len(text) > maxTemplateSize {
+		return "", fmt.Errorf("template exceeds %d bytes", maxTemplateSize)
+	}
+
+	// Values are cached by secret id, so that each secret is decrypted and audited once however
+	// many references point to it
+	values := make(map[string]string)
+	refs := make(map[string]string)
+	funcs := template.FuncMap{
+		"secret": func(ref string) (string, error) {
+			ref = normalizeSecretRef(ref)
+			if id, ok := refs[ref]; ok {
+				return values[id], nil
+			}
+
+			secret, err := s.resolveSecretByPath(actor.RealmID, ref)
+			if err != nil {
+				return "", err
+			}
+			if value, ok := values[secret.ID]; ok {
+				refs[ref] = secret.ID
+				return value, nil
+			}
+			if len(values) >= maxTemplateSecrets {
+				return "", fmt.Errorf("template references more than %d secrets", maxTemplateSecrets)
+			}
+
+			value, err := s.DecryptSecret(secret.ID)
+			if auditErr := s.RecordAccess(actor, models.SecretActionRead, secret.ID, 0, err); auditErr != nil {
+				return "", auditErr
+			}
+			if err != nil {
+				return "", fmt.Errorf("secret %q: %w", ref, err)
+			}
+
+			values[secret.ID] = value
+			refs[ref] = secret.ID
+			return value, nil
+		},
+	}
+
+	return util.RenderTextTemplate("secret-template", text, funcs, util.TemplateData(data), templateLimits)
+}
+
+// resolveSecretByPath finds the secret a template reference points to. The reference is matched
+// exactly against a secret's path first, then as "<path>/<name>".
+func (s *SecretService) resolveSecretByPath(realmID, ref string) (*models.Secret, error) {
+	ref = normalizeSecretRef(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("empty secret reference")
+	}
+
+	matches, err := s.searchExact(realmID, ref, func(secret models.Secret) bool {
+		return strings.Trim(secret.Path, "/") == ref
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		dir, name := path.Split(ref)
+		dir = strings.Trim(dir, "/")
+		matches, err = s.searchExact(realmID, dir, func(secret models.Secret) bool {
+			return strings.Trim(secret.Path, "/") == dir && secret.Name == name
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("secret %q not found", ref)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("secret reference %q is ambiguous, %d secrets match", ref, len(matches))
+	}
+}
+
+// normalizeSecretRef strips surrounding blanks and slashes from a template reference
+func normalizeSecretRef(ref string) string {
+	return strings.Trim(strings.TrimSpace(ref), "/")
+}
+
+// searchExact pages through SecretRepository.Search by path and keeps the exact matches
+func (s *SecretService) searchExact(realmID, pathQuery string, match func(models.Secret) bool) ([]models.Secret, error) {
+	const pageSize = 100
+
+	var matches []models.Secret
+	for page := 1; ; page++ {
+		secrets, total, err := s.repo.Search(realmID, "", "", pathQuery, page, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search secrets: %w", err)
+		}
+		for _, secret := range secrets {
+			if match(secret) {
+				matches = append(matches, secret)
+			}
+		}
+		if int64(page*pageSize) >= total || len(secrets) == 0 {
+			return matches, nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/util"
+)
+
+var templateActor = AccessActor{UserID: "u-alice", Username: "alice", RealmID: "realm"}
+
+func TestRenderTemplate_ResolvesPaths(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+
+	// The path, the path with the name and padded references all point to the same secret
+	out, err := s.RenderTemplate(templateActor,
+		`a={{ secret "db/prod/password" }} b={{ secret "db/prod/password/db-password" }} c={{ secret " /db/prod/password/ " }} user={{ .user }}`,
+		map[string]string{"user": "app"})
+	require.NoError(t, err)
+	assert.Equal(t, "a=s3cr3t b=s3cr3t c=s3cr3t user=app", out)
+
+	// It is decrypted and audited once
+	events, total, err := s.GetAccessEvents("realm", secret.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, models.SecretActionRead, events[0].Action)
+	assert.Equal(t, "alice", events[0].Username)
+}
+
+func TestRenderTemplate_MissingSecret(t *testing.T) {
+	s := newTestSecretService(t)
+	createTestSecret(t, s, "s3cr3t")
+
+	out, err := s.RenderTemplate(templateActor, `a={{ secret "db/prod/password" }} b={{ secret "db/prod/missing" }}`, nil)
+	assert.ErrorContains(t, err, "not found")
+	assert.Empty(t, out)
+
+	_, err = s.RenderTemplate(templateActor, `{{ secret " / " }}`, nil)
+	assert.ErrorContains(t, err, "empty secret reference")
+}
+
+func TestRenderTemplate_AccessDenied(t *testing.T) {
+	s := newTestSecretService(t)
+	secret := createTestSecret(t, s, "s3cr3t")
+
+	// Secrets of other realms are not visible
+	other := AccessActor{UserID: "u-mallory", Username: "mallory", RealmID: "other"}
+	_, err := s.RenderTemplate(other, `{{ secret "db/prod/password" }}`, nil)
+	assert.ErrorContains(t, err, "not found")
+	_, err = s.RenderTemplate(AccessActor{Username: "nobody"}, `{{ secret "db/prod/password" }}`, nil)
+	assert.ErrorContains(t, err, "realm is required")
+
+	// A refused decryption is audited as a failed read
+	s.blockExpiredDecrypt = true
+	require.NoError(t, s.repo.db.Model(&models.Secret{}).Where("id = ?", secret.ID).
+		Update("expires_at", time.Now().Add(-time.Hour)).Error)
+	out, err := s.RenderTemplate(templateActor, `{{ secret "db/prod/password" }}`, nil)
+	assert.ErrorIs(t, err, ErrSecretExpired)
+	assert.Empty(t, out)
+
+	events, total, err := s.GetAccessEvents("realm", secret.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, models.SecretOutcomeFailure, events[0].Outcome)
+}
+
+func TestRenderTemplate_Limits(t *testing.T) {
+	s := newTestSecretService(t)
+	createTestSecret(t, s, "s3cr3t")
+
+	for name, text := range map[string]string{
+		"output":    `{{ range 10000000 }}0123456789{{ end }}`,
+		"loops":     `{{ range 1000 }}{{ range 1000 }}{{ range 1000 }}{{ end }}{{ end }}{{ end }}`,
+		"recursion": `{{ define "a" }}{{ template "a" . }}{{ template "a" . }}{{ end }}{{ template "a" . }}`,
+		"secrets":   `{{ range 1000000 }}{{ secret "db/prod/password" }}{{ end }}`,
+	} {
+		out, err := s.RenderTemplate(templateActor, text, nil)
+		assert.ErrorIs(t, err, util.ErrTemplateLimit, name)
+		assert.Empty(t, out, name)
+	}
+
+	// Loops within the limits still render
+	out, err := s.RenderTemplate(templateActor, `{{ range 3 }}{{ secret "db/prod/password" }};{{ end }}`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t;s3cr3t;s3cr3t;", out)
+}
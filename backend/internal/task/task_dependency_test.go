@@ -0,0 +1,88 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func newDependencyTestService(t *testing.T) *TaskService {
+	db := testutil.NewTestDB(t, &models.Realm{}, &models.Task{}, &models.TaskDependency{})
+	require.NoError(t, db.Create(&models.Realm{ID: "realm", Name: "realm"}).Error)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, db.Create(&models.Task{
+			ID: id, RealmID: "realm", Name: id, Status: models.TaskStatusPending,
+			ScheduleTime: time.Now(), Deadline: time.Now().Add(time.Hour),
+		}).Error)
+	}
+	return NewTaskService(&TaskRepository{db: db}, nil)
+}
+
+func TestAddDependency(t *testing.T) {
+	s := newDependencyTestService(t)
+
+	_, err := s.AddDependency("b", "a", "realm", "alice")
+	require.NoError(t, err)
+	_, err = s.AddDependency("c", "b", "realm", "alice")
+	require.NoError(t, err)
+
+	_, err = s.AddDependency("c", "b", "realm", "alice")
+	assert.ErrorIs(t, err, ErrDependencyExists)
+	_, err = s.AddDependency("a", "c", "realm", "alice")
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+	_, err = s.AddDependency("a", "b", "realm", "alice")
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+
+	// Nothing was recorded for the rejected edges
+	edges, err := s.repo.GetDependenciesFrom([]string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Len(t, edges, 2)
+}
+
+func TestRemoveDependency_OtherRealm(t *testing.T) {
+	s := newDependencyTestService(t)
+	_, err := s.AddDependency("b", "a", "realm", "alice")
+	require.NoError(t, err)
+
+	err = s.RemoveDependency("b", "a", "other-realm")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	edges, err := s.repo.GetDependenciesFrom([]string{"a"})
+	require.NoError(t, err)
+	assert.Len(t, edges, 1)
+
+	require.NoError(t, s.RemoveDependency("b", "a", "realm"))
+	edges, err = s.repo.GetDependenciesFrom([]string{"a"})
+	require.NoError(t, err)
+	assert.Empty(t, edges)
+}
+
+func TestGetDependencyGraph_Realm(t *testing.T) {
+	s := newDependencyTestService(t)
+	_, err := s.AddDependency("b", "a", "realm", "alice")
+	require.NoError(t, err)
+
+	// A task of another realm linked to b, and c reachable only through it
+	require.NoError(t, s.repo.db.Create(&models.Task{
+		ID: "x", RealmID: "other-realm", Name: "x", Status: models.TaskStatusPending,
+		ScheduleTime: time.Now(), Deadline: time.Now().Add(time.Hour),
+	}).Error)
+	require.NoError(t, s.repo.CreateDependency(&models.TaskDependency{ID: "bx", RealmID: "other-realm", PredecessorID: "b", SuccessorID: "x"}))
+	require.NoError(t, s.repo.CreateDependency(&models.TaskDependency{ID: "xc", RealmID: "other-realm", PredecessorID: "x", SuccessorID: "c"}))
+
+	graph, err := s.GetDependencyGraph("a", "realm")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, graph.Order)
+	require.Len(t, graph.Edges, 1)
+	assert.Equal(t, "a", graph.Edges[0].PredecessorID)
+
+	_, err = s.GetDependencyGraph("a", "other-realm")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.GetDependencyGraph("x", "realm")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
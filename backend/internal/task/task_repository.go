@@ -2,10 +2,13 @@ package task
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
 	"github.com/walterfan/lazy-rabbit-secretary/pkg/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TaskRepository provides data access for Task entities
@@ -17,6 +20,11 @@ func NewTaskRepository() *TaskRepository {
 	return &TaskRepository{db: database.GetDB()}
 }
 
+// NewTaskRepositoryWithDB returns a repository on the given database
+func NewTaskRepositoryWithDB(db *gorm.DB) *TaskRepository {
+	return &TaskRepository{db: db}
+}
+
 func (r *TaskRepository) Create(task *models.Task) error {
 	return r.db.Create(task).Error
 }
@@ -91,7 +99,8 @@ func (r *TaskRepository) GetByStatus(realmID string, status models.TaskStatus, p
 // GetUpcoming returns tasks scheduled within a time range
 func (r *TaskRepository) GetUpcoming(realmID string, limit int) ([]models.Task, error) {
 	var tasks []models.Task
-	err := r.db.Where("realm_id = ? AND status IN (?, ?)", realmID, models.TaskStatusPending, models.TaskStatusRunning).
+	err := r.db.Where("realm_id = ? AND status IN (?, ?, ?)", realmID,
+		models.TaskStatusPending, models.TaskStatusRunning, models.TaskStatusPaused).
 		Order("schedule_time ASC").
 		Limit(limit).
 		Find(&tasks).Error
@@ -107,3 +116,192 @@ func (r *TaskRepository) GetOverdue(realmID string, limit int) ([]models.Task, e
 		Find(&tasks).Error
 	return tasks, err
 }
+
+func (r *TaskRepository) CreateDependency(dependency *models.TaskDependency) error {
+	return r.db.Create(dependency).Error
+}
+
+// CreateDependencyChecked records a dependency if check, run in the same transaction, passes.
+// The realm's row is locked for the transaction, so the dependencies of a realm are changed one
+// at a time and two opposite edges added at once cannot both pass a cycle check.
+func (r *TaskRepository) CreateDependencyChecked(dependency *models.TaskDependency, check func(repo *TaskRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var realm models.Realm
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", dependency.RealmID).
+			First(&realm).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock realm %s: %w", dependency.RealmID, err)
+		}
+		if err := check(&TaskRepository{db: tx}); err != nil {
+			return err
+		}
+		return tx.Create(dependency).Error
+	})
+}
+
+// DeleteDependency removes the edge between two tasks
+func (r *TaskRepository) DeleteDependency(predecessorID, successorID string) error {
+	result := r.db.Where("predecessor_id = ? AND successor_id = ?", predecessorID, successorID).
+		Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteDependenciesOf removes every edge touching a task
+func (r *TaskRepository) DeleteDependenciesOf(taskID string) error {
+	return r.db.Where("predecessor_id = ? OR successor_id = ?", taskID, taskID).
+		Delete(&models.TaskDependency{}).Error
+}
+
+// GetDependenciesFrom returns the edges leaving the given predecessors
+func (r *TaskRepository) GetDependenciesFrom(predecessorIDs []string) ([]models.TaskDependency, error) {
+	var dependencies []models.TaskDependency
+	if len(predecessorIDs) == 0 {
+		return dependencies, nil
+	}
+	err := r.db.Where("predecessor_id IN ?", predecessorIDs).Find(&dependencies).Error
+	return dependencies, err
+}
+
+// GetDependenciesTo returns the edges entering the given successors
+func (r *TaskRepository) GetDependenciesTo(successorIDs []string) ([]models.TaskDependency, error) {
+	var dependencies []models.TaskDependency
+	if len(successorIDs) == 0 {
+		return dependencies, nil
+	}
+	err := r.db.Where("successor_id IN ?", successorIDs).Find(&dependencies).Error
+	return dependencies, err
+}
+
+// GetUnfinishedPredecessors returns the predecessors of a task that are not completed yet
+func (r *TaskRepository) GetUnfinishedPredecessors(taskID string) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.Where("id IN (?)", r.db.Model(&models.TaskDependency{}).
+		Select("predecessor_id").
+		Where("successor_id = ? AND type = ?", taskID, models.DependencyFinishToStart)).
+		Where("status <> ?", models.TaskStatusCompleted).
+		Order("schedule_time ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// GetByIDs returns the tasks with the given IDs
+func (r *TaskRepository) GetByIDs(ids []string) ([]models.Task, error) {
+	var tasks []models.Task
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&tasks).Error
+	return tasks, err
+}
+
+// GetSubtasks returns the direct subtasks of a task
+func (r *TaskRepository) GetSubtasks(parentID string) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.Where("parent_id = ?", parentID).
+		Order("schedule_time ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// CreateWorkLog creates a work interval
+func (r *TaskRepository) CreateWorkLog(log *models.TaskWorkLog) error {
+	return r.db.Create(log).Error
+}
+
+// CreateWorkLogChecked creates a work interval if check, run in the same transaction, passes.
+// The task's row is locked for the transaction, so two intervals added at once cannot both pass
+// an overlap check.
+func (r *TaskRepository) CreateWorkLogChecked(log *models.TaskWorkLog, check func(repo *TaskRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", log.TaskID).
+			First(&task).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock task %s: %w", log.TaskID, err)
+		}
+		if err := check(&TaskRepository{db: tx}); err != nil {
+			return err
+		}
+		return tx.Create(log).Error
+	})
+}
+
+// GetOverlappingWorkLog returns the earliest work interval of a task that overlaps [from, to).
+// An interval that has not ended yet overlaps everything after its start.
+func (r *TaskRepository) GetOverlappingWorkLog(taskID string, from, to time.Time) (*models.TaskWorkLog, error) {
+	var log models.TaskWorkLog
+	err := r.db.Where("task_id = ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)", taskID, to, from).
+		Order("started_at ASC").
+		First(&log).Error
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// GetOpenWorkLog returns the work interval of a task that has not ended yet
+func (r *TaskRepository) GetOpenWorkLog(taskID string) (*models.TaskWorkLog, error) {
+	var log models.TaskWorkLog
+	err := r.db.Where("task_id = ? AND ended_at IS NULL", taskID).
+		Order("started_at DESC").
+		First(&log).Error
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// UpdateWorkLog saves a work interval
+func (r *TaskRepository) UpdateWorkLog(log *models.TaskWorkLog) error {
+	return r.db.Save(log).Error
+}
+
+// GetWorkLogs returns the work intervals of a task, oldest first
+func (r *TaskRepository) GetWorkLogs(taskID string) ([]models.TaskWorkLog, error) {
+	var logs []models.TaskWorkLog
+	err := r.db.Where("task_id = ?", taskID).
+		Order("started_at ASC").
+		Find(&logs).Error
+	return logs, err
+}
+
+// SumWorkLogSeconds returns the length of the closed work intervals of a task
+func (r *TaskRepository) SumWorkLogSeconds(taskID string) (int, error) {
+	var total int
+	err := r.db.Model(&models.TaskWorkLog{}).
+		Where("task_id = ? AND ended_at IS NOT NULL", taskID).
+		Select("COALESCE(SUM(seconds), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// UpdateActualMinutes stores the logged work time of a task
+func (r *TaskRepository) UpdateActualMinutes(taskID string, minutes int) error {
+	return r.db.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("actual_minutes", minutes).Error
+}
+
+// DeleteWorkLogsOf deletes the work intervals of a task
+func (r *TaskRepository) DeleteWorkLogsOf(taskID string) error {
+	return r.db.Where("task_id = ?", taskID).Delete(&models.TaskWorkLog{}).Error
+}
+
+// GetCompletedBetween returns the completed tasks of a realm that ended within a time range.
+// Tasks with subtasks are left out since their subtasks carry the estimates and the work.
+func (r *TaskRepository) GetCompletedBetween(realmID string, from, to time.Time) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.Where("realm_id = ? AND status = ? AND subtask_count = 0", realmID, models.TaskStatusCompleted).
+		Where("end_time >= ? AND end_time < ?", from, to).
+		Order("end_time ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
@@ -0,0 +1,34 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func TestGetUpcoming_OpenStatuses(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Task{})
+	now := time.Now()
+	for i, status := range []models.TaskStatus{
+		models.TaskStatusPending, models.TaskStatusRunning, models.TaskStatusPaused,
+		models.TaskStatusCompleted, models.TaskStatusFailed,
+	} {
+		require.NoError(t, db.Create(&models.Task{
+			ID: string(status), RealmID: "realm", Name: string(status), Status: status,
+			ScheduleTime: now.Add(time.Duration(i) * time.Hour), Deadline: now.Add(24 * time.Hour),
+		}).Error)
+	}
+
+	tasks, err := (&TaskRepository{db: db}).GetUpcoming("realm", 10)
+	require.NoError(t, err)
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	assert.Equal(t, []string{"pending", "running", "paused"}, ids)
+}
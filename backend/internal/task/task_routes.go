@@ -1,12 +1,15 @@
 package task
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
 )
 
 // RegisterRoutes registers HTTP endpoints for managing tasks
@@ -116,6 +119,8 @@ func RegisterRoutes(router *gin.Engine, service *TaskService, middleware *auth.A
 			taskStatus = models.TaskStatusPending
 		case "running":
 			taskStatus = models.TaskStatusRunning
+		case "paused":
+			taskStatus = models.TaskStatusPaused
 		case "completed":
 			taskStatus = models.TaskStatusCompleted
 		case "failed":
@@ -216,6 +221,197 @@ func RegisterRoutes(router *gin.Engine, service *TaskService, middleware *auth.A
 		}
 		c.JSON(http.StatusOK, updated)
 	})
+
+	// POST /api/v1/tasks/:id/pause - Pause a running task
+	group.POST("/:id/pause", func(c *gin.Context) {
+		id := c.Param("id")
+		pauser, _ := auth.GetCurrentUsername(c)
+
+		updated, err := service.PauseTask(id, pauser)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	})
+
+	// POST /api/v1/tasks/:id/resume - Resume a paused task
+	group.POST("/:id/resume", func(c *gin.Context) {
+		id := c.Param("id")
+		resumer, _ := auth.GetCurrentUsername(c)
+
+		updated, err := service.ResumeTask(id, resumer)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	})
+
+	// GET /api/v1/tasks/reports/estimates - Compare estimated and logged minutes of completed tasks
+	// per tag and per week. ?from= and ?to= are inclusive YYYY-MM-DD dates, by default the last
+	// 8 weeks; ?timezone= sets the zone days and weeks are counted in.
+	group.GET("/reports/estimates", func(c *gin.Context) {
+		loc := time.Local
+		if tz := c.Query("timezone"); tz != "" {
+			var err error
+			if loc, err = time.LoadLocation(tz); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid timezone: " + tz})
+				return
+			}
+		}
+		now := time.Now().In(loc)
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+		from := to.AddDate(0, 0, -8*7)
+		if value := c.Query("from"); value != "" {
+			date, err := time.ParseInLocation("2006-01-02", value, loc)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid from date. Use YYYY-MM-DD"})
+				return
+			}
+			from = date
+		}
+		if value := c.Query("to"); value != "" {
+			date, err := time.ParseInLocation("2006-01-02", value, loc)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid to date. Use YYYY-MM-DD"})
+				return
+			}
+			to = date.AddDate(0, 0, 1)
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		report, err := service.GetEstimateReport(realmID, from, to)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+
+	// GET /api/v1/tasks/:id/worklogs - List the work intervals of a task
+	group.GET("/:id/worklogs", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		items, err := service.GetWorkLogs(c.Param("id"), realmID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "total": len(items)})
+	})
+
+	// POST /api/v1/tasks/:id/worklogs - Add a work interval that was not tracked
+	group.POST("/:id/worklogs", func(c *gin.Context) {
+		var req AddWorkLogRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		creator, _ := auth.GetCurrentUsername(c)
+		log, err := service.AddWorkLog(c.Param("id"), req, realmID, creator)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, ErrWorkLogOverlap) {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, log)
+	})
+
+	// GET /api/v1/tasks/:id/subtasks - List the direct subtasks of a task
+	group.GET("/:id/subtasks", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		items, err := service.GetSubtasks(c.Param("id"), realmID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "total": len(items)})
+	})
+
+	// POST /api/v1/tasks/:id/subtasks - Create a subtask
+	group.POST("/:id/subtasks", func(c *gin.Context) {
+		var req CreateTaskRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		creator, _ := auth.GetCurrentUser(c)
+		created, err := service.CreateSubtask(c.Param("id"), req, realmID, creator)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	})
+
+	// GET /api/v1/tasks/:id/graph - Get the dependency graph the task belongs to
+	group.GET("/:id/graph", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		graph, err := service.GetDependencyGraph(c.Param("id"), realmID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, graph)
+	})
+
+	// POST /api/v1/tasks/:id/dependencies - Make the task depend on a predecessor
+	group.POST("/:id/dependencies", func(c *gin.Context) {
+		var req AddDependencyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		realmID, _ := auth.GetCurrentRealm(c)
+		creator, _ := auth.GetCurrentUsername(c)
+		dependency, err := service.AddDependency(c.Param("id"), req.PredecessorID, realmID, creator)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrDependencyCycle) || errors.Is(err, ErrDependencyExists) {
+				status = http.StatusConflict
+			} else if errors.Is(err, gorm.ErrRecordNotFound) {
+				status = http.StatusNotFound
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, dependency)
+	})
+
+	// DELETE /api/v1/tasks/:id/dependencies/:predecessorId - Remove a dependency
+	group.DELETE("/:id/dependencies/:predecessorId", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		if err := service.RemoveDependency(c.Param("id"), c.Param("predecessorId"), realmID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "dependency not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
 }
 
 func parseIntDefault(value string, defaultVal int) int {
@@ -8,7 +8,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
 	"github.com/walterfan/lazy-rabbit-secretary/internal/reminder"
+	"gorm.io/gorm"
 )
 
 // TaskService contains business logic for tasks
@@ -24,31 +26,45 @@ func NewTaskService(repo *TaskRepository, reminderService *reminder.ReminderServ
 	}
 }
 
+// WithTx returns a task service that reads and writes tasks in tx, so callers can change tasks
+// as part of their own transaction
+func (s *TaskService) WithTx(tx *gorm.DB) *TaskService {
+	return &TaskService{repo: &TaskRepository{db: tx}, reminderService: s.reminderService}
+}
+
 // CreateTaskRequest defines the allowed input for creating a task
 type CreateTaskRequest struct {
 	Name         string    `json:"name" binding:"required"`
 	Description  string    `json:"description"`
-	Priority     *int      `json:"priority"`   // Optional, defaults to 2 if not provided
-	Difficulty   *int      `json:"difficulty"` // Optional, defaults to 2 if not provided
-	ScheduleTime time.Time `json:"schedule_time" binding:"required"`
+	Priority     *int      `json:"priority"`      // Optional, defaults to 2 if not provided
+	Difficulty   *int      `json:"difficulty"`    // Optional, defaults to 2 if not provided
+	ScheduleTime time.Time `json:"schedule_time"` // Optional, unscheduled tasks are planned by the scheduler
 	Minutes      int       `json:"minutes" binding:"required,min=1"`
 	Deadline     time.Time `json:"deadline" binding:"required"`
 	Tags         string    `json:"tags"`
 
 	// Repeat task fields
 	IsRepeating      bool       `json:"is_repeating"`
-	RepeatPattern    string     `json:"repeat_pattern"`      // daily, weekly, monthly, yearly
+	RepeatPattern    string     `json:"repeat_pattern"`      // daily, weekly, monthly, yearly or an RRULE
 	RepeatInterval   int        `json:"repeat_interval"`     // every N days/weeks/months
 	RepeatDaysOfWeek string     `json:"repeat_days_of_week"` // comma-separated: mon,tue,wed
 	RepeatDayOfMonth int        `json:"repeat_day_of_month"` // for monthly: 1-31, 0=same day
 	RepeatEndDate    *time.Time `json:"repeat_end_date"`     // when to stop repeating
 	RepeatCount      int        `json:"repeat_count"`        // max occurrences, 0=infinite
+	RepeatTimezone   string     `json:"repeat_timezone"`     // IANA zone, e.g. America/New_York
+	RepeatExDates    string     `json:"repeat_exdates"`      // comma-separated excluded occurrences
 
 	// Reminder generation settings
 	GenerateReminders      bool   `json:"generate_reminders"`
 	ReminderAdvanceMinutes int    `json:"reminder_advance_minutes"` // remind N minutes before task
 	ReminderMethods        string `json:"reminder_methods"`         // email,webhook
 	ReminderTargets        string `json:"reminder_targets"`         // notification targets
+
+	ExternalUID string `json:"external_uid"` // iCalendar UID when the task is imported from a calendar
+
+	// Subtask settings
+	ParentID     *string `json:"parent_id"`     // makes the new task a subtask of this task
+	AutoComplete bool    `json:"auto_complete"` // complete this task once all its subtasks are completed
 }
 
 // UpdateTaskRequest defines the allowed input for updating a task
@@ -62,6 +78,7 @@ type UpdateTaskRequest struct {
 	Minutes      *int              `json:"minutes"`
 	Deadline     *time.Time        `json:"deadline"`
 	Tags         string            `json:"tags"`
+	AutoComplete *bool             `json:"auto_complete"`
 }
 
 func (s *TaskService) CreateFromInput(req CreateTaskRequest, realmID, createdBy string) (*models.Task, error) {
@@ -98,11 +115,20 @@ func (s *TaskService) CreateFromInput(req CreateTaskRequest, realmID, createdBy
 
 	// Validate repeat settings
 	if req.IsRepeating {
+		if req.ScheduleTime.IsZero() {
+			return nil, errors.New("schedule_time is required for repeating tasks")
+		}
 		if err := s.validateRepeatSettings(req); err != nil {
 			return nil, err
 		}
 	}
 
+	if req.ParentID != nil {
+		if err := s.validateSubtaskParent(*req.ParentID, realmID, req.IsRepeating); err != nil {
+			return nil, err
+		}
+	}
+
 	task := &models.Task{
 		ID:           uuid.NewString(),
 		RealmID:      realmID,
@@ -124,6 +150,8 @@ func (s *TaskService) CreateFromInput(req CreateTaskRequest, realmID, createdBy
 		RepeatDayOfMonth: req.RepeatDayOfMonth,
 		RepeatEndDate:    req.RepeatEndDate,
 		RepeatCount:      req.RepeatCount,
+		RepeatTimezone:   req.RepeatTimezone,
+		RepeatExDates:    req.RepeatExDates,
 
 		// Reminder fields
 		GenerateReminders:      req.GenerateReminders,
@@ -131,6 +159,11 @@ func (s *TaskService) CreateFromInput(req CreateTaskRequest, realmID, createdBy
 		ReminderMethods:        req.ReminderMethods,
 		ReminderTargets:        req.ReminderTargets,
 
+		ExternalUID: req.ExternalUID,
+
+		ParentID:     req.ParentID,
+		AutoComplete: req.AutoComplete,
+
 		CreatedBy: createdBy,
 		CreatedAt: time.Now(),
 		UpdatedBy: createdBy,
@@ -141,6 +174,12 @@ func (s *TaskService) CreateFromInput(req CreateTaskRequest, realmID, createdBy
 		return nil, err
 	}
 
+	if task.IsSubtask() {
+		if err := s.rollUpSubtasks(*task.ParentID, createdBy); err != nil {
+			fmt.Printf("Warning: Failed to roll up subtasks of task %s: %v\n", *task.ParentID, err)
+		}
+	}
+
 	// If this is a repeating task, generate initial instances
 	if task.IsRepeating {
 		instances, err := s.GenerateTaskInstances(task, 5) // Generate first 5 instances
@@ -160,7 +199,7 @@ func (s *TaskService) CreateFromInput(req CreateTaskRequest, realmID, createdBy
 				}
 			}
 		}
-	} else if task.GenerateReminders {
+	} else if task.GenerateReminders && task.IsScheduled() {
 		// For non-repeating tasks, generate a single reminder
 		if err := s.generateReminderForTask(task); err != nil {
 			fmt.Printf("Warning: Failed to generate reminder for task %s: %v\n", task.ID, err)
@@ -220,9 +259,18 @@ func (s *TaskService) UpdateTask(id string, req UpdateTaskRequest, updatedBy str
 		}
 		task.Difficulty = *req.Difficulty
 	}
+	previousStatus := task.Status
 	if req.Status != "" {
+		// Starting a task requires its predecessors to be completed
+		var unfinished []models.Task
+		if req.Status == models.TaskStatusRunning && task.Status == models.TaskStatusPending {
+			if unfinished, err = s.repo.GetUnfinishedPredecessors(task.ID); err != nil {
+				return nil, fmt.Errorf("failed to check task dependencies: %w", err)
+			}
+		}
+
 		// Validate status transition
-		if err := s.validateStatusTransition(task.Status, req.Status); err != nil {
+		if err := s.validateStatusTransition(task.Status, req.Status, unfinished...); err != nil {
 			return nil, err
 		}
 		task.Status = req.Status
@@ -245,6 +293,9 @@ func (s *TaskService) UpdateTask(id string, req UpdateTaskRequest, updatedBy str
 		task.ScheduleTime = *req.ScheduleTime
 	}
 	if req.Minutes != nil && *req.Minutes > 0 {
+		if task.HasSubtasks() {
+			return nil, errors.New("minutes of a task with subtasks is the sum of its subtasks")
+		}
 		task.Minutes = *req.Minutes
 	}
 	if req.Deadline != nil {
@@ -253,6 +304,12 @@ func (s *TaskService) UpdateTask(id string, req UpdateTaskRequest, updatedBy str
 	if req.Tags != "" {
 		task.Tags = req.Tags
 	}
+	if req.AutoComplete != nil {
+		task.AutoComplete = *req.AutoComplete
+	}
+	if !task.HasSubtasks() {
+		task.Progress = leafProgress(task.Status)
+	}
 
 	task.UpdatedBy = updatedBy
 	task.UpdatedAt = time.Now()
@@ -260,11 +317,53 @@ func (s *TaskService) UpdateTask(id string, req UpdateTaskRequest, updatedBy str
 	if err := s.repo.Update(task); err != nil {
 		return nil, err
 	}
+
+	if task.Status != previousStatus {
+		if err := s.trackWork(task, previousStatus, updatedBy); err != nil {
+			return task, fmt.Errorf("task updated but failed to track work time: %w", err)
+		}
+	}
+	if task.IsSubtask() {
+		if err := s.rollUpSubtasks(*task.ParentID, updatedBy); err != nil {
+			return task, fmt.Errorf("task updated but failed to roll up its parent: %w", err)
+		}
+	}
+	if task.HasSubtasks() && (req.AutoComplete != nil || req.Status != "") {
+		// Turning auto-complete on, or starting a task, may complete it when its subtasks are
+		// already done
+		if err := s.rollUpSubtasks(task.ID, updatedBy); err != nil {
+			return task, fmt.Errorf("failed to roll up subtasks: %w", err)
+		}
+		return s.repo.GetByID(task.ID)
+	}
 	return task, nil
 }
 
 func (s *TaskService) DeleteTask(id string) error {
-	return s.repo.Delete(id)
+	task, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if task.HasSubtasks() {
+		return fmt.Errorf("task has %d subtasks, delete them first", task.SubtaskCount)
+	}
+
+	if err := s.repo.DeleteDependenciesOf(id); err != nil {
+		return fmt.Errorf("failed to delete task dependencies: %w", err)
+	}
+	if err := s.repo.DeleteWorkLogsOf(id); err != nil {
+		return fmt.Errorf("failed to delete task work logs: %w", err)
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	if task.IsSubtask() {
+		if err := s.rollUpSubtasks(*task.ParentID, task.UpdatedBy); err != nil {
+			return fmt.Errorf("task deleted but failed to roll up its parent: %w", err)
+		}
+	}
+	return nil
 }
 
 func (s *TaskService) SearchTasks(realmID, query, status, tags string, priority, difficulty, page, pageSize int) ([]models.Task, int64, error) {
@@ -297,6 +396,20 @@ func (s *TaskService) CompleteTask(id string, completedBy string) (*models.Task,
 	}, completedBy)
 }
 
+// PauseTask pauses a running task, closing its current work interval
+func (s *TaskService) PauseTask(id string, pausedBy string) (*models.Task, error) {
+	return s.UpdateTask(id, UpdateTaskRequest{
+		Status: models.TaskStatusPaused,
+	}, pausedBy)
+}
+
+// ResumeTask resumes a paused task, opening a new work interval
+func (s *TaskService) ResumeTask(id string, resumedBy string) (*models.Task, error) {
+	return s.UpdateTask(id, UpdateTaskRequest{
+		Status: models.TaskStatusRunning,
+	}, resumedBy)
+}
+
 // FailTask marks a task as failed
 func (s *TaskService) FailTask(id string, failedBy string) (*models.Task, error) {
 	return s.UpdateTask(id, UpdateTaskRequest{
@@ -306,7 +419,9 @@ func (s *TaskService) FailTask(id string, failedBy string) (*models.Task, error)
 
 // --- helpers ---
 
-func (s *TaskService) validateStatusTransition(currentStatus, newStatus models.TaskStatus) error {
+// validateStatusTransition checks a status change against the allowed transitions. A task with
+// unfinished finish-to-start predecessors is blocked and cannot be started.
+func (s *TaskService) validateStatusTransition(currentStatus, newStatus models.TaskStatus, unfinishedPredecessors ...models.Task) error {
 	// Define valid status transitions
 	validTransitions := map[models.TaskStatus][]models.TaskStatus{
 		models.TaskStatusPending: {
@@ -314,6 +429,12 @@ func (s *TaskService) validateStatusTransition(currentStatus, newStatus models.T
 			models.TaskStatusFailed,
 		},
 		models.TaskStatusRunning: {
+			models.TaskStatusPaused,
+			models.TaskStatusCompleted,
+			models.TaskStatusFailed,
+		},
+		models.TaskStatusPaused: {
+			models.TaskStatusRunning, // Resume
 			models.TaskStatusCompleted,
 			models.TaskStatusFailed,
 		},
@@ -332,6 +453,9 @@ func (s *TaskService) validateStatusTransition(currentStatus, newStatus models.T
 
 	for _, allowedStatus := range allowed {
 		if newStatus == allowedStatus {
+			if currentStatus == models.TaskStatusPending && newStatus == models.TaskStatusRunning && len(unfinishedPredecessors) > 0 {
+				return blockedError(unfinishedPredecessors)
+			}
 			return nil
 		}
 	}
@@ -345,12 +469,31 @@ func (s *TaskService) validateRepeatSettings(req CreateTaskRequest) error {
 		"daily": true, "weekly": true, "monthly": true, "yearly": true,
 	}
 
-	if !validPatterns[req.RepeatPattern] {
-		return errors.New("repeat_pattern must be one of: daily, weekly, monthly, yearly")
+	if models.IsRRule(req.RepeatPattern) {
+		// INTERVAL, COUNT and UNTIL live in the rule itself
+		if _, err := models.ParseRRule(req.RepeatPattern); err != nil {
+			return fmt.Errorf("invalid repeat_pattern: %w", err)
+		}
+	} else {
+		if !validPatterns[req.RepeatPattern] {
+			return errors.New("repeat_pattern must be one of: daily, weekly, monthly, yearly, or an RRULE such as FREQ=MONTHLY;BYDAY=-1FR")
+		}
+
+		if req.RepeatInterval < 1 {
+			return errors.New("repeat_interval must be at least 1")
+		}
+	}
+
+	loc := req.ScheduleTime.Location()
+	if req.RepeatTimezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(req.RepeatTimezone); err != nil {
+			return fmt.Errorf("invalid repeat_timezone: %s", req.RepeatTimezone)
+		}
 	}
 
-	if req.RepeatInterval < 1 {
-		return errors.New("repeat_interval must be at least 1")
+	if _, err := models.ParseExDates(req.RepeatExDates, loc); err != nil {
+		return fmt.Errorf("invalid repeat_exdates: %w", err)
 	}
 
 	if req.RepeatCount < 0 {
@@ -390,12 +533,11 @@ func (s *TaskService) validateRepeatSettings(req CreateTaskRequest) error {
 			return errors.New("reminder_methods is required when generate_reminders is true")
 		}
 
-		validMethods := map[string]bool{"email": true, "webhook": true}
 		methods := strings.Split(req.ReminderMethods, ",")
 		for _, method := range methods {
 			method = strings.TrimSpace(strings.ToLower(method))
-			if !validMethods[method] {
-				return fmt.Errorf("invalid reminder method: %s. Use: email, webhook", method)
+			if !notifier.IsMethod(method) {
+				return fmt.Errorf("invalid reminder method: %s. Use: %s", method, strings.Join(notifier.MethodNames(), ", "))
 			}
 		}
 
@@ -491,7 +633,7 @@ func (s *TaskService) generateReminderForTask(task *models.Task) error {
 
 	// Create reminder request
 	reminderReq := reminder.CreateReminderRequest{
-		Name:          fmt.Sprintf("Task Reminder: %s", task.Name),
+		Name:          taskReminderName(task),
 		Content:       s.formatReminderContent(task),
 		RemindTime:    reminderTime,
 		Tags:          s.formatReminderTags(task),
@@ -509,6 +651,42 @@ func (s *TaskService) generateReminderForTask(task *models.Task) error {
 	return nil
 }
 
+// RescheduleReminders moves the pending reminder a task got for its previous schedule time to
+// its current one. A task that missed its schedule time has usually been reminded already; it
+// gets a new reminder instead.
+func (s *TaskService) RescheduleReminders(task *models.Task, previous time.Time) error {
+	if !task.ShouldGenerateReminders() {
+		return nil
+	}
+
+	if s.reminderService == nil {
+		return errors.New("reminder service not available")
+	}
+
+	advance := time.Duration(task.ReminderAdvanceMinutes) * time.Minute
+	existing, err := s.reminderService.FindPendingReminder(task.RealmID, task.CreatedBy, taskReminderName(task), previous.Add(-advance))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.generateReminderForTask(task)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find reminder for task %s: %w", task.ID, err)
+	}
+
+	reminderTime := task.ScheduleTime.Add(-advance)
+	if _, err := s.reminderService.UpdateFromInput(existing.ID, reminder.UpdateReminderRequest{
+		Content:    s.formatReminderContent(task),
+		RemindTime: &reminderTime,
+	}, task.UpdatedBy); err != nil {
+		return fmt.Errorf("failed to move reminder %s of task %s: %w", existing.ID, task.ID, err)
+	}
+	return nil
+}
+
+// taskReminderName is the name of the reminders generated for a task
+func taskReminderName(task *models.Task) string {
+	return fmt.Sprintf("Task Reminder: %s", task.Name)
+}
+
 // formatReminderContent creates a formatted reminder message for a task
 func (s *TaskService) formatReminderContent(task *models.Task) string {
 	content := fmt.Sprintf(`You have an upcoming task scheduled:
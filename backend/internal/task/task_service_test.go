@@ -208,6 +208,33 @@ func TestValidateRepeatSettings_Errors(t *testing.T) {
 			},
 			expectedErr: "reminder_advance_minutes cannot be negative",
 		},
+		{
+			name: "Unsupported RRULE part",
+			req: CreateTaskRequest{
+				IsRepeating:   true,
+				RepeatPattern: "FREQ=HOURLY;INTERVAL=2",
+			},
+			expectedErr: "invalid repeat_pattern: unsupported RRULE FREQ",
+		},
+		{
+			name: "Invalid repeat timezone",
+			req: CreateTaskRequest{
+				IsRepeating:    true,
+				RepeatPattern:  "daily",
+				RepeatInterval: 1,
+				RepeatTimezone: "Mars/Olympus_Mons",
+			},
+			expectedErr: "invalid repeat_timezone",
+		},
+		{
+			name: "Invalid exdate",
+			req: CreateTaskRequest{
+				IsRepeating:   true,
+				RepeatPattern: "FREQ=DAILY",
+				RepeatExDates: "next tuesday",
+			},
+			expectedErr: "invalid repeat_exdates",
+		},
 	}
 
 	for _, tt := range tests {
@@ -234,6 +261,9 @@ func TestValidateStatusTransition_ValidTransitions(t *testing.T) {
 		{models.TaskStatusPending, models.TaskStatusFailed},
 		{models.TaskStatusRunning, models.TaskStatusCompleted},
 		{models.TaskStatusRunning, models.TaskStatusFailed},
+		{models.TaskStatusRunning, models.TaskStatusPaused},
+		{models.TaskStatusPaused, models.TaskStatusRunning},
+		{models.TaskStatusPaused, models.TaskStatusCompleted},
 		{models.TaskStatusFailed, models.TaskStatusPending},
 	}
 
@@ -256,6 +286,8 @@ func TestValidateStatusTransition_InvalidTransitions(t *testing.T) {
 		{models.TaskStatusCompleted, models.TaskStatusPending},
 		{models.TaskStatusCompleted, models.TaskStatusFailed},
 		{models.TaskStatusPending, models.TaskStatusCompleted}, // Must go through running
+		{models.TaskStatusPending, models.TaskStatusPaused},
+		{models.TaskStatusCompleted, models.TaskStatusPaused},
 	}
 
 	for _, tt := range invalidTransitions {
@@ -267,6 +299,86 @@ func TestValidateStatusTransition_InvalidTransitions(t *testing.T) {
 	}
 }
 
+func TestValidateStatusTransition_BlockedByPredecessors(t *testing.T) {
+	service := &TaskService{}
+	unfinished := []models.Task{
+		{ID: "build", Name: "Build release", Status: models.TaskStatusRunning},
+		{ID: "notes", Name: "Write release notes", Status: models.TaskStatusPending},
+	}
+
+	err := service.validateStatusTransition(models.TaskStatusPending, models.TaskStatusRunning, unfinished...)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "task is blocked by 2 unfinished predecessor(s)")
+	assert.Contains(t, err.Error(), "Build release (running)")
+
+	// A blocked task can still be given up on
+	assert.NoError(t, service.validateStatusTransition(models.TaskStatusPending, models.TaskStatusFailed, unfinished...))
+	// Resuming is not starting, a predecessor reopened meanwhile does not block it
+	assert.NoError(t, service.validateStatusTransition(models.TaskStatusPaused, models.TaskStatusRunning, unfinished...))
+}
+
+func TestBuildEstimateReport(t *testing.T) {
+	at := func(day, hour int) *time.Time {
+		t := time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	tasks := []models.Task{
+		{ID: "1", Tags: "backend, review", Minutes: 60, ActualMinutes: 90, EndTime: at(10, 12)},
+		{ID: "2", Tags: "backend", Minutes: 30, ActualMinutes: 15, EndTime: at(16, 12)}, // Sunday, still W11
+		{ID: "3", Minutes: 20, ActualMinutes: 40, EndTime: at(17, 12)},
+		// Completed before work was logged: falls back to start and end time
+		{ID: "4", Tags: "review", Minutes: 45, StartTime: at(18, 9), EndTime: at(18, 10)},
+	}
+
+	report := buildEstimateReport(tasks, time.UTC)
+
+	assert.Equal(t, EstimateRow{Key: "total", Tasks: 4, EstimatedMinutes: 155, ActualMinutes: 205, Ratio: 1.32}, report.Total)
+	assert.Equal(t, []EstimateRow{
+		{Key: "backend", Tasks: 2, EstimatedMinutes: 90, ActualMinutes: 105, Ratio: 1.17},
+		{Key: "review", Tasks: 2, EstimatedMinutes: 105, ActualMinutes: 150, Ratio: 1.43},
+		{Key: "untagged", Tasks: 1, EstimatedMinutes: 20, ActualMinutes: 40, Ratio: 2},
+	}, report.ByTag)
+	assert.Equal(t, []EstimateRow{
+		{Key: "2025-W11", Tasks: 2, EstimatedMinutes: 90, ActualMinutes: 105, Ratio: 1.17},
+		{Key: "2025-W12", Tasks: 2, EstimatedMinutes: 65, ActualMinutes: 100, Ratio: 1.54},
+	}, report.ByWeek)
+}
+
+func TestRollUpProgress(t *testing.T) {
+	subtasks := []models.Task{
+		{Minutes: 60, Status: models.TaskStatusCompleted},
+		{Minutes: 30, Status: models.TaskStatusRunning},
+		// A nested subtask tree that is half done
+		{Minutes: 30, Status: models.TaskStatusPending, SubtaskCount: 2, Progress: 50},
+	}
+
+	minutes, progress := rollUpProgress(subtasks)
+	assert.Equal(t, 120, minutes)
+	assert.Equal(t, 62, progress) // (60*100 + 30*0 + 30*50) / 120
+
+	subtasks[1].Status = models.TaskStatusCompleted
+	subtasks[2].Status = models.TaskStatusCompleted
+	_, progress = rollUpProgress(subtasks)
+	assert.Equal(t, 100, progress)
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	base := time.Date(2025, 9, 17, 9, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		{ID: "publish", ScheduleTime: base},
+		{ID: "test", ScheduleTime: base.Add(2 * time.Hour)},
+		{ID: "build", ScheduleTime: base.Add(time.Hour)},
+		{ID: "announce", ScheduleTime: base.Add(-time.Hour)},
+	}
+	edges := []models.TaskDependency{
+		{PredecessorID: "build", SuccessorID: "test"},
+		{PredecessorID: "test", SuccessorID: "publish"},
+		{PredecessorID: "publish", SuccessorID: "announce"},
+	}
+
+	assert.Equal(t, []string{"build", "test", "publish", "announce"}, topologicalOrder(tasks, edges))
+}
+
 // =============================================================================
 // TASK INSTANCE GENERATION TESTS (using models.Task methods)
 // =============================================================================
@@ -402,6 +514,135 @@ func TestTaskInstanceGeneration_EndConditions(t *testing.T) {
 	})
 }
 
+func TestTaskInstanceGeneration_RRulePattern(t *testing.T) {
+	t.Run("Last Friday of the month", func(t *testing.T) {
+		parentTask := &models.Task{
+			ScheduleTime:  time.Date(2025, 9, 26, 16, 0, 0, 0, time.UTC),
+			IsRepeating:   true,
+			RepeatPattern: "FREQ=MONTHLY;BYDAY=-1FR",
+		}
+
+		nextDate := parentTask.GetNextOccurrence(parentTask.ScheduleTime)
+		assert.NotNil(t, nextDate)
+		assert.Equal(t, time.Date(2025, 10, 31, 16, 0, 0, 0, time.UTC), *nextDate)
+
+		nextDate = parentTask.GetNextOccurrence(*nextDate)
+		assert.NotNil(t, nextDate)
+		assert.Equal(t, time.Date(2025, 11, 28, 16, 0, 0, 0, time.UTC), *nextDate)
+	})
+
+	t.Run("Last workday of the month with BYSETPOS", func(t *testing.T) {
+		parentTask := &models.Task{
+			ScheduleTime:  time.Date(2025, 9, 30, 9, 0, 0, 0, time.UTC),
+			IsRepeating:   true,
+			RepeatPattern: "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		}
+
+		// October 2025 ends on a Friday, November 2025 on a Sunday
+		nextDate := parentTask.GetNextOccurrence(parentTask.ScheduleTime)
+		assert.Equal(t, time.Date(2025, 10, 31, 9, 0, 0, 0, time.UTC), *nextDate)
+		nextDate = parentTask.GetNextOccurrence(*nextDate)
+		assert.Equal(t, time.Date(2025, 11, 28, 9, 0, 0, 0, time.UTC), *nextDate)
+	})
+
+	t.Run("COUNT ends the rule", func(t *testing.T) {
+		parentTask := &models.Task{
+			ScheduleTime:  time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC),
+			IsRepeating:   true,
+			RepeatPattern: "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+		}
+
+		var dates []time.Time
+		current := parentTask.ScheduleTime
+		for next := parentTask.GetNextOccurrence(current); next != nil; next = parentTask.GetNextOccurrence(current) {
+			dates = append(dates, *next)
+			current = *next
+		}
+		// COUNT includes the first occurrence at schedule_time
+		assert.Equal(t, []time.Time{
+			time.Date(2025, 9, 18, 9, 0, 0, 0, time.UTC),
+			time.Date(2025, 9, 22, 9, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("EXDATE skips occurrences", func(t *testing.T) {
+		parentTask := &models.Task{
+			ScheduleTime:  time.Date(2025, 12, 22, 9, 0, 0, 0, time.UTC),
+			IsRepeating:   true,
+			RepeatPattern: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			RepeatExDates: "2025-12-25,20251226T090000Z",
+		}
+
+		nextDate := parentTask.GetNextOccurrence(time.Date(2025, 12, 24, 9, 0, 0, 0, time.UTC))
+		assert.Equal(t, time.Date(2025, 12, 29, 9, 0, 0, 0, time.UTC), *nextDate)
+	})
+
+	t.Run("Wall clock is kept across DST", func(t *testing.T) {
+		newYork, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			t.Skip("tzdata not available")
+		}
+
+		for _, pattern := range []string{"weekly", "FREQ=WEEKLY;BYDAY=FR"} {
+			parentTask := &models.Task{
+				ScheduleTime:   time.Date(2025, 10, 31, 13, 0, 0, 0, time.UTC), // 09:00 EDT
+				IsRepeating:    true,
+				RepeatPattern:  pattern,
+				RepeatInterval: 1,
+				RepeatTimezone: "America/New_York",
+			}
+
+			nextDate := parentTask.GetNextOccurrence(parentTask.ScheduleTime)
+			assert.NotNil(t, nextDate, pattern)
+			// DST ends on Nov 2, so 09:00 EST is 14:00 UTC
+			assert.True(t, time.Date(2025, 11, 7, 9, 0, 0, 0, newYork).Equal(*nextDate), pattern)
+			assert.True(t, time.Date(2025, 11, 7, 14, 0, 0, 0, time.UTC).Equal(*nextDate), pattern)
+		}
+	})
+
+	t.Run("Old schedule time still repeats", func(t *testing.T) {
+		for pattern, want := range map[string]time.Time{
+			"FREQ=DAILY":                 time.Date(2025, 3, 11, 9, 0, 0, 0, time.UTC),
+			"FREQ=WEEKLY;BYDAY=MO,FR":    time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC),
+			"FREQ=MONTHLY;BYMONTHDAY=-1": time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC),
+		} {
+			parentTask := &models.Task{
+				ScheduleTime:  time.Date(1990, 1, 1, 9, 0, 0, 0, time.UTC),
+				IsRepeating:   true,
+				RepeatPattern: pattern,
+			}
+
+			nextDate := parentTask.GetNextOccurrence(time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC))
+			if assert.NotNil(t, nextDate, pattern) {
+				assert.Equal(t, want, *nextDate, pattern)
+			}
+		}
+	})
+
+	t.Run("Yearly BYMONTHDAY without BYMONTH repeats every month", func(t *testing.T) {
+		parentTask := &models.Task{
+			ScheduleTime:  time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC),
+			IsRepeating:   true,
+			RepeatPattern: "FREQ=YEARLY;BYMONTHDAY=15",
+		}
+
+		nextDate := parentTask.GetNextOccurrence(parentTask.ScheduleTime)
+		assert.Equal(t, time.Date(2025, 2, 15, 9, 0, 0, 0, time.UTC), *nextDate)
+	})
+
+	t.Run("COUNT includes a schedule time that does not match the rule", func(t *testing.T) {
+		parentTask := &models.Task{
+			ScheduleTime:  time.Date(2025, 9, 17, 9, 0, 0, 0, time.UTC), // a Wednesday
+			IsRepeating:   true,
+			RepeatPattern: "FREQ=WEEKLY;BYDAY=MO;COUNT=2",
+		}
+
+		nextDate := parentTask.GetNextOccurrence(parentTask.ScheduleTime)
+		assert.Equal(t, time.Date(2025, 9, 22, 9, 0, 0, 0, time.UTC), *nextDate)
+		assert.Nil(t, parentTask.GetNextOccurrence(*nextDate))
+	})
+}
+
 // =============================================================================
 // HELPER METHOD TESTS
 // =============================================================================
@@ -0,0 +1,146 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxSubtaskDepth bounds how far a roll-up walks up the task hierarchy
+const maxSubtaskDepth = 32
+
+// CreateSubtask creates a task as a subtask of parentID
+func (s *TaskService) CreateSubtask(parentID string, req CreateTaskRequest, realmID, createdBy string) (*models.Task, error) {
+	req.ParentID = &parentID
+	return s.CreateFromInput(req, realmID, createdBy)
+}
+
+// GetSubtasks returns the direct subtasks of a task. A task outside the current realm is
+// reported as not found.
+func (s *TaskService) GetSubtasks(id, realmID string) ([]models.Task, error) {
+	task, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.RealmID != realmID {
+		return nil, fmt.Errorf("task is not in the current realm: %w", gorm.ErrRecordNotFound)
+	}
+	return s.repo.GetSubtasks(id)
+}
+
+// validateSubtaskParent checks that a new task can be added below parentID. Subtasks are
+// kept apart from repeating tasks: instances do not copy subtasks, so neither side may repeat.
+func (s *TaskService) validateSubtaskParent(parentID, realmID string, repeating bool) error {
+	if repeating {
+		return errors.New("a subtask cannot be a repeating task")
+	}
+	parent, err := s.repo.GetByID(parentID)
+	if err != nil {
+		return fmt.Errorf("failed to get parent task: %w", err)
+	}
+	if parent.RealmID != realmID {
+		return errors.New("parent task must belong to the current realm")
+	}
+	if parent.IsRepeating {
+		return errors.New("subtasks cannot be added to a repeating task")
+	}
+	if parent.Status == models.TaskStatusCompleted {
+		return errors.New("subtasks cannot be added to a completed task")
+	}
+	return nil
+}
+
+// rollUpSubtasks recomputes Minutes, Progress and SubtaskCount of a task from its subtasks,
+// completes it when AutoComplete is set, every subtask is completed and the task is running or
+// paused, then continues with its own parent
+func (s *TaskService) rollUpSubtasks(parentID, updatedBy string) error {
+	id := parentID
+	for depth := 0; id != "" && depth < maxSubtaskDepth; depth++ {
+		parent, err := s.repo.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("failed to get task %s: %w", id, err)
+		}
+		subtasks, err := s.repo.GetSubtasks(parent.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get subtasks of task %s: %w", id, err)
+		}
+
+		previousStatus := parent.Status
+		parent.SubtaskCount = len(subtasks)
+		if len(subtasks) == 0 {
+			parent.Progress = leafProgress(parent.Status)
+		} else {
+			parent.Minutes, parent.Progress = rollUpProgress(subtasks)
+		}
+
+		// Completing follows the status state machine, so only a running or paused task is
+		// completed; a pending or failed one stays open for its owner to decide
+		if parent.AutoComplete && len(subtasks) > 0 && parent.Progress == 100 &&
+			s.validateStatusTransition(parent.Status, models.TaskStatusCompleted) == nil {
+			unfinished, err := s.repo.GetUnfinishedPredecessors(parent.ID)
+			if err != nil {
+				return fmt.Errorf("failed to check task dependencies: %w", err)
+			}
+			// A task blocked by its predecessors stays open even when its subtasks are done
+			if len(unfinished) == 0 {
+				now := time.Now()
+				if parent.StartTime == nil {
+					parent.StartTime = &now
+				}
+				parent.EndTime = &now
+				parent.Status = models.TaskStatusCompleted
+			}
+		}
+
+		parent.UpdatedBy = updatedBy
+		parent.UpdatedAt = time.Now()
+		if err := s.repo.Update(parent); err != nil {
+			return fmt.Errorf("failed to update task %s: %w", id, err)
+		}
+		if parent.Status != previousStatus {
+			if err := s.trackWork(parent, previousStatus, updatedBy); err != nil {
+				return fmt.Errorf("failed to track work time of task %s: %w", id, err)
+			}
+		}
+
+		id = ""
+		if parent.ParentID != nil {
+			id = *parent.ParentID
+		}
+	}
+	return nil
+}
+
+// rollUpProgress returns the total estimated minutes of the subtasks and their completion
+// percentage weighted by minutes. A subtask with subtasks of its own contributes its rolled up progress.
+func rollUpProgress(subtasks []models.Task) (int, int) {
+	totalMinutes := 0
+	weighted := 0
+	for _, subtask := range subtasks {
+		progress := leafProgress(subtask.Status)
+		if subtask.HasSubtasks() && subtask.Status != models.TaskStatusCompleted {
+			progress = subtask.Progress
+		}
+		minutes := subtask.Minutes
+		if minutes < 1 {
+			minutes = 1
+		}
+		totalMinutes += minutes
+		weighted += minutes * progress
+	}
+	if totalMinutes == 0 {
+		return 0, 0
+	}
+	return totalMinutes, weighted / totalMinutes
+}
+
+// leafProgress is the progress of a task without subtasks
+func leafProgress(status models.TaskStatus) int {
+	if status == models.TaskStatusCompleted {
+		return 100
+	}
+	return 0
+}
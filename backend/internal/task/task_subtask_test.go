@@ -0,0 +1,24 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+)
+
+func TestGetSubtasks_OtherRealm(t *testing.T) {
+	s := newDependencyTestService(t)
+	require.NoError(t, s.repo.db.Model(&models.Task{}).Where("id IN ?", []string{"b", "c"}).
+		Update("parent_id", "a").Error)
+
+	subtasks, err := s.GetSubtasks("a", "realm")
+	require.NoError(t, err)
+	assert.Len(t, subtasks, 2)
+
+	_, err = s.GetSubtasks("a", "other-realm")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,257 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxWorkLogDuration caps a manually added interval
+	maxWorkLogDuration = 24 * time.Hour
+	untaggedKey        = "untagged"
+)
+
+// ErrWorkLogOverlap is returned for a work interval that overlaps another interval of the task
+var ErrWorkLogOverlap = errors.New("the interval overlaps another work interval of the task")
+
+// AddWorkLogRequest adds an interval that was worked on but not tracked
+type AddWorkLogRequest struct {
+	StartedAt time.Time `json:"started_at" binding:"required"`
+	EndedAt   time.Time `json:"ended_at" binding:"required"`
+	Note      string    `json:"note"`
+}
+
+// EstimateRow compares estimated and actual minutes of a group of completed tasks
+type EstimateRow struct {
+	Key              string  `json:"key"` // tag, or ISO week such as 2025-W11
+	Tasks            int     `json:"tasks"`
+	EstimatedMinutes int     `json:"estimated_minutes"`
+	ActualMinutes    int     `json:"actual_minutes"`
+	Ratio            float64 `json:"ratio"` // actual / estimated, above 1 means underestimated
+}
+
+// EstimateReport compares estimates with logged work for tasks completed in [From, To)
+type EstimateReport struct {
+	From   time.Time     `json:"from"`
+	To     time.Time     `json:"to"`
+	Total  EstimateRow   `json:"total"`
+	ByTag  []EstimateRow `json:"by_tag"`  // a task counts towards each of its tags
+	ByWeek []EstimateRow `json:"by_week"` // by the week the task was completed in
+}
+
+// trackWork opens or closes work intervals when a task's status changes: running opens an
+// interval, leaving running closes it and updates the task's actual minutes
+func (s *TaskService) trackWork(task *models.Task, previousStatus models.TaskStatus, by string) error {
+	now := time.Now()
+	if previousStatus == models.TaskStatusRunning {
+		open, err := s.repo.GetOpenWorkLog(task.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if open != nil {
+			open.EndedAt = &now
+			open.Seconds = int(now.Sub(open.StartedAt) / time.Second)
+			if err := s.repo.UpdateWorkLog(open); err != nil {
+				return err
+			}
+			if err := s.updateActualMinutes(task); err != nil {
+				return err
+			}
+		}
+	}
+
+	if task.Status == models.TaskStatusRunning {
+		return s.repo.CreateWorkLog(&models.TaskWorkLog{
+			ID:        uuid.NewString(),
+			RealmID:   task.RealmID,
+			TaskID:    task.ID,
+			StartedAt: now,
+			CreatedBy: by,
+		})
+	}
+	return nil
+}
+
+// updateActualMinutes recomputes the actual minutes of a task from its closed work intervals
+func (s *TaskService) updateActualMinutes(task *models.Task) error {
+	seconds, err := s.repo.SumWorkLogSeconds(task.ID)
+	if err != nil {
+		return err
+	}
+	task.ActualMinutes = (seconds + 30) / 60
+	return s.repo.UpdateActualMinutes(task.ID, task.ActualMinutes)
+}
+
+// GetWorkLogs returns the work intervals of a task. A task outside the current realm is reported
+// as not found.
+func (s *TaskService) GetWorkLogs(id, realmID string) ([]models.TaskWorkLog, error) {
+	task, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.RealmID != realmID {
+		return nil, fmt.Errorf("task is not in the current realm: %w", gorm.ErrRecordNotFound)
+	}
+	return s.repo.GetWorkLogs(id)
+}
+
+// AddWorkLog records an interval of work that was not tracked through the status changes. The
+// interval must not overlap the other intervals of the task, including the one being tracked.
+func (s *TaskService) AddWorkLog(id string, req AddWorkLogRequest, realmID, createdBy string) (*models.TaskWorkLog, error) {
+	task, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.RealmID != realmID {
+		return nil, fmt.Errorf("task is not in the current realm: %w", gorm.ErrRecordNotFound)
+	}
+	if task.Status == models.TaskStatusPending {
+		return nil, errors.New("cannot log work on a task that has not been started")
+	}
+	if !req.EndedAt.After(req.StartedAt) {
+		return nil, errors.New("ended_at must be after started_at")
+	}
+	if req.EndedAt.After(time.Now().Add(time.Minute)) {
+		return nil, errors.New("ended_at cannot be in the future")
+	}
+	if req.EndedAt.Sub(req.StartedAt) > maxWorkLogDuration {
+		return nil, fmt.Errorf("a work interval cannot be longer than %d hours", int(maxWorkLogDuration/time.Hour))
+	}
+
+	endedAt := req.EndedAt
+	log := &models.TaskWorkLog{
+		ID:        uuid.NewString(),
+		RealmID:   task.RealmID,
+		TaskID:    task.ID,
+		StartedAt: req.StartedAt,
+		EndedAt:   &endedAt,
+		Seconds:   int(req.EndedAt.Sub(req.StartedAt) / time.Second),
+		Manual:    true,
+		Note:      req.Note,
+		CreatedBy: createdBy,
+	}
+	err = s.repo.CreateWorkLogChecked(log, func(repo *TaskRepository) error {
+		overlap, err := repo.GetOverlappingWorkLog(task.ID, req.StartedAt, req.EndedAt)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check work logs: %w", err)
+		}
+		if overlap.EndedAt == nil {
+			return fmt.Errorf("%w: work is being tracked since %s", ErrWorkLogOverlap, overlap.StartedAt.Format(time.RFC3339))
+		}
+		return fmt.Errorf("%w: work is logged from %s to %s", ErrWorkLogOverlap,
+			overlap.StartedAt.Format(time.RFC3339), overlap.EndedAt.Format(time.RFC3339))
+	})
+	if err != nil {
+		if errors.Is(err, ErrWorkLogOverlap) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create work log: %w", err)
+	}
+	if err := s.updateActualMinutes(task); err != nil {
+		return log, fmt.Errorf("work log created but failed to update actual minutes: %w", err)
+	}
+	return log, nil
+}
+
+// GetEstimateReport compares estimated and actual minutes of the tasks completed in [from, to)
+func (s *TaskService) GetEstimateReport(realmID string, from, to time.Time) (*EstimateReport, error) {
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+	tasks, err := s.repo.GetCompletedBetween(realmID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get completed tasks: %w", err)
+	}
+	report := buildEstimateReport(tasks, from.Location())
+	report.From, report.To = from, to
+	return report, nil
+}
+
+// buildEstimateReport groups completed tasks by tag and by the ISO week they were completed in
+func buildEstimateReport(tasks []models.Task, loc *time.Location) *EstimateReport {
+	report := &EstimateReport{Total: EstimateRow{Key: "total"}}
+	byTag := make(map[string]*EstimateRow)
+	byWeek := make(map[string]*EstimateRow)
+	add := func(rows map[string]*EstimateRow, key string, estimated, actual int) {
+		row, ok := rows[key]
+		if !ok {
+			row = &EstimateRow{Key: key}
+			rows[key] = row
+		}
+		row.Tasks++
+		row.EstimatedMinutes += estimated
+		row.ActualMinutes += actual
+	}
+
+	for _, t := range tasks {
+		actual := actualMinutes(t)
+		report.Total.Tasks++
+		report.Total.EstimatedMinutes += t.Minutes
+		report.Total.ActualMinutes += actual
+
+		tags := splitTags(t.Tags)
+		if len(tags) == 0 {
+			tags = []string{untaggedKey}
+		}
+		for _, tag := range tags {
+			add(byTag, tag, t.Minutes, actual)
+		}
+		if t.EndTime != nil {
+			year, week := t.EndTime.In(loc).ISOWeek()
+			add(byWeek, fmt.Sprintf("%04d-W%02d", year, week), t.Minutes, actual)
+		}
+	}
+
+	report.Total.Ratio = ratio(report.Total)
+	report.ByTag = sortedRows(byTag)
+	report.ByWeek = sortedRows(byWeek)
+	return report
+}
+
+// actualMinutes is the logged work time of a task. Tasks completed before work was logged fall
+// back to the time between starting and completing them.
+func actualMinutes(t models.Task) int {
+	if t.ActualMinutes == 0 && t.StartTime != nil && t.EndTime != nil {
+		return int((t.EndTime.Sub(*t.StartTime) + 30*time.Second) / time.Minute)
+	}
+	return t.ActualMinutes
+}
+
+func splitTags(tags string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func ratio(row EstimateRow) float64 {
+	if row.EstimatedMinutes == 0 {
+		return 0
+	}
+	return float64(int(float64(row.ActualMinutes)/float64(row.EstimatedMinutes)*100+0.5)) / 100
+}
+
+func sortedRows(rows map[string]*EstimateRow) []EstimateRow {
+	out := make([]EstimateRow, 0, len(rows))
+	for _, row := range rows {
+		row.Ratio = ratio(*row)
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func newWorkLogTestService(t *testing.T) *TaskService {
+	db := testutil.NewTestDB(t, &models.Realm{}, &models.Task{}, &models.TaskWorkLog{})
+	require.NoError(t, db.Create(&models.Realm{ID: "realm", Name: "realm"}).Error)
+	require.NoError(t, db.Create(&models.Task{
+		ID: "a", RealmID: "realm", Name: "a", Status: models.TaskStatusRunning,
+		ScheduleTime: time.Now(), Deadline: time.Now().Add(time.Hour),
+	}).Error)
+	return NewTaskService(&TaskRepository{db: db}, nil)
+}
+
+func TestAddWorkLog_Overlap(t *testing.T) {
+	s := newWorkLogTestService(t)
+	now := time.Now().Truncate(time.Second)
+	at := func(hours int) time.Time { return now.Add(time.Duration(hours) * time.Hour) }
+
+	_, err := s.AddWorkLog("a", AddWorkLogRequest{StartedAt: at(-5), EndedAt: at(-4)}, "realm", "alice")
+	require.NoError(t, err)
+
+	_, err = s.AddWorkLog("a", AddWorkLogRequest{StartedAt: at(-6), EndedAt: at(-4)}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrWorkLogOverlap)
+	_, err = s.AddWorkLog("a", AddWorkLogRequest{StartedAt: at(-4).Add(-time.Minute), EndedAt: at(-3)}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrWorkLogOverlap)
+
+	// Adjacent intervals do not overlap
+	_, err = s.AddWorkLog("a", AddWorkLogRequest{StartedAt: at(-4), EndedAt: at(-3)}, "realm", "alice")
+	require.NoError(t, err)
+
+	// The interval being tracked extends to now
+	require.NoError(t, s.repo.CreateWorkLog(&models.TaskWorkLog{
+		ID: "open", RealmID: "realm", TaskID: "a", StartedAt: at(-2),
+	}))
+	_, err = s.AddWorkLog("a", AddWorkLogRequest{StartedAt: at(-1), EndedAt: now}, "realm", "alice")
+	assert.ErrorIs(t, err, ErrWorkLogOverlap)
+
+	logs, err := s.GetWorkLogs("a", "realm")
+	require.NoError(t, err)
+	assert.Len(t, logs, 3)
+}
+
+func TestWorkLogs_OtherRealm(t *testing.T) {
+	s := newWorkLogTestService(t)
+	now := time.Now()
+
+	_, err := s.AddWorkLog("a", AddWorkLogRequest{StartedAt: now.Add(-time.Hour), EndedAt: now}, "other-realm", "mallory")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.GetWorkLogs("a", "other-realm")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	logs, err := s.GetWorkLogs("a", "realm")
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+}
@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"gorm.io/gorm"
+)
+
+// WebhookRepository provides data access for webhook endpoints and their delivery log
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// CreateEndpoint creates a webhook endpoint
+func (r *WebhookRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return r.db.Create(endpoint).Error
+}
+
+// GetEndpoint retrieves one of a user's endpoints
+func (r *WebhookRepository) GetEndpoint(realmID, userID, id string) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := r.db.Where("id = ? AND realm_id = ? AND user_id = ?", id, realmID, userID).First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// GetUserEndpoint retrieves an endpoint of a user in any realm, as reminders find them
+func (r *WebhookRepository) GetUserEndpoint(userID, id string) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// ListEndpoints returns a user's endpoints
+func (r *WebhookRepository) ListEndpoints(realmID, userID string) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Where("realm_id = ? AND user_id = ?", realmID, userID).Order("name ASC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+// GetEnabledEndpoints returns the endpoints of the given channels that receive a user's reminders
+func (r *WebhookRepository) GetEnabledEndpoints(userID string, channels []string) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Where("user_id = ? AND enabled = ? AND channel IN ?", userID, true, channels).
+		Order("name ASC").
+		Find(&endpoints).Error
+	return endpoints, err
+}
+
+// UpdateEndpoint saves an endpoint
+func (r *WebhookRepository) UpdateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return r.db.Save(endpoint).Error
+}
+
+// DeleteEndpoint deletes an endpoint; its delivery log is kept
+func (r *WebhookRepository) DeleteEndpoint(id string) error {
+	return r.db.Delete(&models.WebhookEndpoint{}, "id = ?", id).Error
+}
+
+// CreateDelivery records a delivery attempt
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// DeliveryParams filters the delivery log
+type DeliveryParams struct {
+	EndpointID string
+	ReminderID string
+	DeliveryID string
+	Success    *bool
+	Page       int
+	PageSize   int
+}
+
+// ListDeliveries returns the delivery attempts made for a user, newest first
+func (r *WebhookRepository) ListDeliveries(realmID, userID string, params DeliveryParams) ([]models.WebhookDelivery, int64, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.PageSize <= 0 || params.PageSize > 100 {
+		params.PageSize = 20
+	}
+
+	q := r.db.Model(&models.WebhookDelivery{}).Where("realm_id = ? AND user_id = ?", realmID, userID)
+	if params.EndpointID != "" {
+		q = q.Where("endpoint_id = ?", params.EndpointID)
+	}
+	if params.ReminderID != "" {
+		q = q.Where("reminder_id = ?", params.ReminderID)
+	}
+	if params.DeliveryID != "" {
+		q = q.Where("delivery_id = ?", params.DeliveryID)
+	}
+	if params.Success != nil {
+		q = q.Where("success = ?", *params.Success)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	err := q.Order("created_at DESC").
+		Offset((params.Page - 1) * params.PageSize).
+		Limit(params.PageSize).
+		Find(&deliveries).Error
+	return deliveries, total, err
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/auth"
+	"gorm.io/gorm"
+)
+
+// RegisterWebhookRoutes registers HTTP endpoints for webhook endpoints and their delivery log
+func RegisterWebhookRoutes(router *gin.Engine, service *WebhookService, middleware *auth.AuthMiddleware) {
+	group := router.Group("/api/v1/webhooks")
+	group.Use(middleware.Authenticate())
+
+	// GET /api/v1/webhooks - List the current user's endpoints
+	group.GET("", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		items, err := service.ListEndpoints(realmID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items})
+	})
+
+	// POST /api/v1/webhooks - Create an endpoint; the response holds its secret
+	group.POST("", func(c *gin.Context) {
+		var req CreateEndpointRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		endpoint, secret, err := service.CreateEndpoint(req, realmID, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"endpoint": endpoint, "secret": secret})
+	})
+
+	// GET /api/v1/webhooks/deliveries - Query the delivery log
+	group.GET("/deliveries", func(c *gin.Context) {
+		params := DeliveryParams{
+			EndpointID: c.Query("endpoint_id"),
+			ReminderID: c.Query("reminder_id"),
+			DeliveryID: c.Query("delivery_id"),
+			Page:       parseIntDefault(c.Query("page"), 1),
+			PageSize:   parseIntDefault(c.Query("page_size"), 20),
+		}
+		if successStr := c.Query("success"); successStr != "" {
+			success, err := strconv.ParseBool(successStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "success must be true or false"})
+				return
+			}
+			params.Success = &success
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		items, total, err := service.ListDeliveries(realmID, userID, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items":     items,
+			"total":     total,
+			"page":      params.Page,
+			"page_size": params.PageSize,
+		})
+	})
+
+	// PUT /api/v1/webhooks/:id - Update an endpoint or rotate its secret
+	group.PUT("/:id", func(c *gin.Context) {
+		var req UpdateEndpointRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		endpoint, secret, err := service.UpdateEndpoint(c.Param("id"), req, realmID, userID)
+		if err != nil {
+			c.JSON(endpointErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		response := gin.H{"endpoint": endpoint}
+		if secret != "" {
+			response["secret"] = secret
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	// DELETE /api/v1/webhooks/:id - Delete an endpoint
+	group.DELETE("/:id", func(c *gin.Context) {
+		realmID, _ := auth.GetCurrentRealm(c)
+		userID, _ := auth.GetCurrentUser(c)
+		if err := service.DeleteEndpoint(c.Param("id"), realmID, userID); err != nil {
+			c.JSON(endpointErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint deleted successfully"})
+	})
+}
+
+// endpointErrorStatus maps service errors to HTTP status codes
+func endpointErrorStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func parseIntDefault(value string, defaultVal int) int {
+	if value == "" {
+		return defaultVal
+	}
+	out, err := strconv.Atoi(value)
+	if err != nil || out <= 0 {
+		return defaultVal
+	}
+	return out
+}
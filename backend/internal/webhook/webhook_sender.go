@@ -0,0 +1,348 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/netguard"
+)
+
+const (
+	EventReminderDue       = "reminder.due"
+	EventReminderEscalated = "reminder.escalated"
+
+	// Request headers; the signature is "sha256=" followed by the hex encoded HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the endpoint's secret
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	defaultTimeout     = 10 * time.Second
+	defaultMaxAttempts = 4
+	defaultBackoff     = 2 * time.Second
+	maxResponseBytes   = 1024
+)
+
+// ErrEndpointUnavailable is returned for an endpoint that was deleted or disabled
+var ErrEndpointUnavailable = errors.New("webhook endpoint was deleted or disabled")
+
+// Target is a URL that receives events, with the secret its requests are signed with
+type Target struct {
+	EndpointID string `json:"-"`
+	Channel    string `json:"channel"` // defaults to webhook
+	URL        string `json:"url"`
+	Secret     string `json:"secret"`
+}
+
+// ReminderPayload is the JSON body sent for a reminder
+type ReminderPayload struct {
+	Event      string          `json:"event"`
+	DeliveryID string          `json:"delivery_id"`
+	SentAt     time.Time       `json:"sent_at"`
+	Reminder   ReminderContext `json:"reminder"`
+	User       UserContext     `json:"user"`
+}
+
+// ReminderContext describes the reminder that is sent
+type ReminderContext struct {
+	ID            string    `json:"id"`
+	RealmID       string    `json:"realm_id"`
+	Name          string    `json:"name"`
+	Content       string    `json:"content"`
+	RemindTime    time.Time `json:"remind_time"`
+	Tags          string    `json:"tags"`
+	RemindMethods string    `json:"remind_methods"`
+	Escalation    int       `json:"escalation"` // escalation step, 0 for the first notification
+	CreatedBy     string    `json:"created_by"`
+}
+
+// UserContext describes the user the reminder is sent for
+type UserContext struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// Sender delivers events to webhooks, retrying failed calls with exponential backoff and
+// recording every attempt in the delivery log
+type Sender struct {
+	repo        *WebhookRepository
+	logger      *zap.SugaredLogger
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	sleep       func(time.Duration)
+}
+
+// NewSender creates a webhook sender configured by webhook.timeout, webhook.max_attempts and
+// webhook.initial_backoff
+func NewSender(db *gorm.DB, logger *zap.SugaredLogger) *Sender {
+	timeout := viper.GetDuration("webhook.timeout")
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	maxAttempts := viper.GetInt("webhook.max_attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	backoff := viper.GetDuration("webhook.initial_backoff")
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	return &Sender{
+		repo:        NewWebhookRepository(db),
+		logger:      logger,
+		client:      netguard.NewClient(timeout),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		sleep:       time.Sleep,
+	}
+}
+
+// WithMaxAttempts returns a copy of the sender that makes at most n attempts per delivery, for
+// callers that retry on their own
+func (s *Sender) WithMaxAttempts(n int) *Sender {
+	copied := *s
+	copied.maxAttempts = n
+	return &copied
+}
+
+// SendReminder delivers a reminder to the webhooks given in its targets, or else to the
+// enabled endpoints of the user. The delivery ID lets receivers drop duplicates; a new one is
+// generated when it is empty.
+func (s *Sender) SendReminder(reminder *models.Reminder, user *models.User, deliveryID string) error {
+	targets, err := s.Targets(reminder, user, models.WebhookChannelGeneric)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no webhook configured for reminder %s or user %s", reminder.ID, user.Username)
+	}
+
+	if deliveryID == "" {
+		deliveryID = uuid.NewString()
+	}
+
+	var errs []string
+	for _, target := range targets {
+		if err := s.SendReminderTo(target, reminder, user, deliveryID); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SendReminderTo delivers a reminder to one webhook
+func (s *Sender) SendReminderTo(target Target, reminder *models.Reminder, user *models.User, deliveryID string) error {
+	event := EventReminderDue
+	if reminder.Escalated > 0 {
+		event = EventReminderEscalated
+	}
+
+	payload := ReminderPayload{
+		Event:      event,
+		DeliveryID: deliveryID,
+		SentAt:     time.Now(),
+		Reminder: ReminderContext{
+			ID:            reminder.ID,
+			RealmID:       reminder.RealmID,
+			Name:          reminder.Name,
+			Content:       reminder.Content,
+			RemindTime:    reminder.RemindTime,
+			Tags:          reminder.Tags,
+			RemindMethods: reminder.RemindMethods,
+			Escalation:    reminder.Escalated,
+			CreatedBy:     reminder.CreatedBy,
+		},
+		User: UserContext{ID: user.ID, Username: user.Username, Email: user.Email},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	delivery := models.WebhookDelivery{
+		RealmID:    reminder.RealmID,
+		UserID:     user.ID,
+		DeliveryID: payload.DeliveryID,
+		EndpointID: target.EndpointID,
+		ReminderID: reminder.ID,
+		Event:      event,
+		URL:        target.URL,
+	}
+	return s.Deliver(target, delivery, body)
+}
+
+// Targets returns the webhooks of the given channels that a reminder is sent to: the ones in
+// its targets, or else the enabled endpoints of the user
+func (s *Sender) Targets(reminder *models.Reminder, user *models.User, channels ...string) ([]Target, error) {
+	targets := ReminderTargets(reminder.RemindTargets, channels...)
+	if len(targets) > 0 || user.ID == "" {
+		return targets, nil
+	}
+
+	endpoints, err := s.repo.GetEnabledEndpoints(user.ID, channels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoints: %w", err)
+	}
+	for _, endpoint := range endpoints {
+		targets = append(targets, Target{
+			EndpointID: endpoint.ID,
+			Channel:    endpoint.Channel,
+			URL:        endpoint.URL,
+			Secret:     endpoint.Secret,
+		})
+	}
+	return targets, nil
+}
+
+// EndpointTarget returns the current URL and secret of a user's endpoint, so that a queued
+// delivery follows a changed URL or a rotated secret
+func (s *Sender) EndpointTarget(userID, endpointID string) (Target, error) {
+	endpoint, err := s.repo.GetUserEndpoint(userID, endpointID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Target{}, fmt.Errorf("endpoint %s: %w", endpointID, ErrEndpointUnavailable)
+	}
+	if err != nil {
+		return Target{}, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	if !endpoint.Enabled {
+		return Target{}, fmt.Errorf("endpoint %s: %w", endpointID, ErrEndpointUnavailable)
+	}
+	return Target{EndpointID: endpoint.ID, Channel: endpoint.Channel, URL: endpoint.URL, Secret: endpoint.Secret}, nil
+}
+
+// Deliver posts body to a target until it succeeds or the attempts are used up. Network
+// errors, 429 and 5xx responses are retried; other responses are final.
+func (s *Sender) Deliver(target Target, delivery models.WebhookDelivery, body []byte) error {
+	var lastErr error
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		if attempt > 1 {
+			s.sleep(s.backoff << (attempt - 2))
+		}
+
+		record := delivery
+		record.ID = uuid.NewString()
+		record.Attempt = attempt
+		retry, err := s.post(target, &record, body)
+		record.Success = err == nil
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if logErr := s.repo.CreateDelivery(&record); logErr != nil {
+			s.logger.Warnf("Failed to record webhook delivery %s: %v", record.DeliveryID, logErr)
+		}
+
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return fmt.Errorf("webhook %s: %w", netguard.Host(target.URL), lastErr)
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (s *Sender) post(target Target, record *models.WebhookDelivery, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, netguard.RedactError(err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lazy-rabbit-secretary-webhook")
+	req.Header.Set(HeaderEvent, record.Event)
+	req.Header.Set(HeaderDelivery, record.DeliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if target.Secret != "" {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(target.Secret, timestamp, body))
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	record.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return true, netguard.RedactError(err)
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	record.StatusCode = resp.StatusCode
+	record.ResponseBody = string(response)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ReminderTargets returns the webhooks of the given channels in a reminder's targets, either
+// comma-separated URLs of generic webhooks or JSON such as
+// {"webhooks": [{"url": "https://...", "secret": "...", "channel": "slack"}]}. Other targets,
+// e.g. usernames for email, and URLs of internal hosts are ignored.
+func ReminderTargets(remindTargets string, channels ...string) []Target {
+	wanted := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		wanted[channel] = true
+	}
+
+	var candidates []Target
+	remindTargets = strings.TrimSpace(remindTargets)
+	if strings.HasPrefix(remindTargets, "{") {
+		var config struct {
+			Webhooks []Target `json:"webhooks"`
+		}
+		if err := json.Unmarshal([]byte(remindTargets), &config); err != nil {
+			return nil
+		}
+		candidates = config.Webhooks
+	} else {
+		for _, item := range strings.Split(remindTargets, ",") {
+			candidates = append(candidates, Target{URL: strings.TrimSpace(item)})
+		}
+	}
+
+	var targets []Target
+	for _, target := range candidates {
+		if target.Channel == "" {
+			target.Channel = models.WebhookChannelGeneric
+		}
+		if netguard.CheckURL(target.URL) == nil && wanted[target.Channel] {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/testutil"
+)
+
+func TestReminderTargets(t *testing.T) {
+	assert.Equal(t, []Target{
+		{Channel: "webhook", URL: "https://hooks.example.com/a"},
+		{Channel: "webhook", URL: "http://93.184.216.34:8080/b"},
+	}, ReminderTargets("alice, https://hooks.example.com/a,ops@example.com,http://93.184.216.34:8080/b", "webhook"))
+
+	// Internal hosts are never targets
+	assert.Empty(t, ReminderTargets("http://localhost:8080/b,http://169.254.169.254/latest/meta-data,http://10.0.0.1/c", "webhook"))
+
+	targets := `{"webhooks": [
+		{"url": "https://hooks.example.com/a", "secret": "s3cret"},
+		{"url": "https://hooks.slack.com/services/T/B/X", "channel": "slack"},
+		{"url": "ftp://x"}
+	]}`
+	assert.Equal(t, []Target{{Channel: "webhook", URL: "https://hooks.example.com/a", Secret: "s3cret"}},
+		ReminderTargets(targets, "webhook"))
+	assert.Equal(t, []Target{{Channel: "slack", URL: "https://hooks.slack.com/services/T/B/X"}},
+		ReminderTargets(targets, "slack", "feishu"))
+
+	assert.Empty(t, ReminderTargets("https://hooks.example.com/a", "slack"))
+	assert.Empty(t, ReminderTargets("alice,bob", "webhook"))
+	assert.Empty(t, ReminderTargets(`{"webhooks": `, "webhook"))
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"reminder.due"}`)
+	signature := Sign("s3cret", 1700000000, body)
+
+	// HMAC-SHA256 of `1700000000.{"event":"reminder.due"}`
+	assert.Equal(t, "131405449228594fba636b5640919170a74dc0472979f59c45182faaca87d81d", signature)
+	assert.NotEqual(t, signature, Sign("other", 1700000000, body))
+	assert.NotEqual(t, signature, Sign("s3cret", 1700000001, body))
+}
+
+func TestDeliver(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.WebhookDelivery{})
+
+	// The server answers with the given statuses in turn, then with 200
+	var statuses []int
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		assert.NotEmpty(t, r.Header.Get(HeaderSignature))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var sleeps []time.Duration
+	sender := &Sender{
+		repo:        NewWebhookRepository(db),
+		logger:      zap.NewNop().Sugar(),
+		client:      server.Client(), // the test server is on loopback
+		maxAttempts: 4,
+		backoff:     time.Second,
+		sleep:       func(d time.Duration) { sleeps = append(sleeps, d) },
+	}
+	deliver := func(deliveryID string, responses ...int) ([]models.WebhookDelivery, error) {
+		statuses, sleeps = responses, nil
+		calls.Store(0)
+		err := sender.Deliver(Target{URL: server.URL, Secret: "s3cret"},
+			models.WebhookDelivery{RealmID: "realm", DeliveryID: deliveryID, Event: EventReminderDue, URL: server.URL},
+			[]byte(`{"event":"reminder.due"}`))
+		var records []models.WebhookDelivery
+		require.NoError(t, db.Where("delivery_id = ?", deliveryID).Order("attempt").Find(&records).Error)
+		return records, err
+	}
+
+	// 5xx and 429 are retried with a doubling backoff until one attempt succeeds
+	records, err := deliver("d1", http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusBadGateway)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, sleeps)
+	require.Len(t, records, 4)
+	for i, record := range records {
+		assert.Equal(t, i+1, record.Attempt)
+		assert.Equal(t, i == 3, record.Success)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, records[1].StatusCode)
+	assert.Equal(t, "unexpected status 429", records[1].Error)
+
+	// Other 4xx responses are final
+	records, err = deliver("d2", http.StatusBadRequest)
+	assert.ErrorContains(t, err, "unexpected status 400")
+	assert.Empty(t, sleeps)
+	require.Len(t, records, 1)
+	assert.False(t, records[0].Success)
+
+	// Retries stop after the last attempt
+	records, err = deliver("d3", 500, 500, 500, 500, 500)
+	assert.ErrorContains(t, err, "unexpected status 500")
+	assert.Len(t, sleeps, 3)
+	assert.Len(t, records, 4)
+	assert.EqualValues(t, 4, calls.Load())
+}
+
+func TestEndpointTarget(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.WebhookEndpoint{})
+	sender := NewSender(db, zap.NewNop().Sugar())
+	endpoint := &models.WebhookEndpoint{ID: "e1", RealmID: "realm", UserID: "u1", Name: "ops",
+		Channel: models.WebhookChannelGeneric, URL: "https://hooks.example.com/a", Secret: "old", Enabled: true}
+	require.NoError(t, db.Create(endpoint).Error)
+
+	// A rotated secret and a changed URL apply to deliveries that are already queued
+	require.NoError(t, db.Model(endpoint).Updates(map[string]interface{}{"secret": "new", "url": "https://hooks.example.com/b"}).Error)
+	target, err := sender.EndpointTarget("u1", "e1")
+	require.NoError(t, err)
+	assert.Equal(t, Target{EndpointID: "e1", Channel: "webhook", URL: "https://hooks.example.com/b", Secret: "new"}, target)
+
+	_, err = sender.EndpointTarget("u2", "e1")
+	assert.ErrorIs(t, err, ErrEndpointUnavailable)
+	require.NoError(t, db.Model(endpoint).Update("enabled", false).Error)
+	_, err = sender.EndpointTarget("u1", "e1")
+	assert.ErrorIs(t, err, ErrEndpointUnavailable)
+	require.NoError(t, db.Delete(endpoint).Error)
+	_, err = sender.EndpointTarget("u1", "e1")
+	assert.ErrorIs(t, err, ErrEndpointUnavailable)
+}
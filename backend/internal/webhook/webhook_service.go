@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/models"
+	"github.com/walterfan/lazy-rabbit-secretary/internal/notifier"
+	"github.com/walterfan/lazy-rabbit-secretary/pkg/netguard"
+	"gorm.io/gorm"
+)
+
+// WebhookService manages the webhook endpoints of users and their delivery log
+type WebhookService struct {
+	repo *WebhookRepository
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{
+		repo: NewWebhookRepository(db),
+	}
+}
+
+// CreateEndpointRequest defines the allowed input for creating a webhook endpoint
+type CreateEndpointRequest struct {
+	Name    string `json:"name" binding:"required"`
+	URL     string `json:"url" binding:"required"`
+	Channel string `json:"channel"` // webhook (default), slack, dingtalk, wecom or feishu
+	Secret  string `json:"secret"`  // generated for webhook endpoints when empty
+}
+
+// UpdateEndpointRequest defines the allowed input for updating a webhook endpoint
+type UpdateEndpointRequest struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Enabled      *bool  `json:"enabled"`
+	RotateSecret bool   `json:"rotate_secret"` // generates a new secret
+}
+
+// CreateEndpoint creates an endpoint and returns it with its secret
+func (s *WebhookService) CreateEndpoint(req CreateEndpointRequest, realmID, userID string) (*models.WebhookEndpoint, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if err := validateURL(req.URL); err != nil {
+		return nil, "", err
+	}
+	channel := req.Channel
+	if channel == "" {
+		channel = models.WebhookChannelGeneric
+	}
+	if channel != models.WebhookChannelGeneric && !notifier.IsChannel(channel) {
+		return nil, "", fmt.Errorf("channel must be one of: %s, %s", models.WebhookChannelGeneric, strings.Join(notifier.ChannelNames(), ", "))
+	}
+	// Chat apps generate their own signing secrets, if they use any
+	secret := req.Secret
+	if secret == "" && channel == models.WebhookChannelGeneric {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		ID:      uuid.NewString(),
+		RealmID: realmID,
+		UserID:  userID,
+		Name:    name,
+		Channel: channel,
+		URL:     strings.TrimSpace(req.URL),
+		Secret:  secret,
+		Enabled: true,
+	}
+	if err := s.repo.CreateEndpoint(endpoint); err != nil {
+		return nil, "", fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+	return endpoint, secret, nil
+}
+
+// UpdateEndpoint updates an endpoint; the new secret is returned if it was rotated
+func (s *WebhookService) UpdateEndpoint(id string, req UpdateEndpointRequest, realmID, userID string) (*models.WebhookEndpoint, string, error) {
+	endpoint, err := s.repo.GetEndpoint(realmID, userID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		endpoint.Name = name
+	}
+	if req.URL != "" {
+		if err := validateURL(req.URL); err != nil {
+			return nil, "", err
+		}
+		endpoint.URL = strings.TrimSpace(req.URL)
+	}
+	if req.Enabled != nil {
+		endpoint.Enabled = *req.Enabled
+	}
+	var secret string
+	if req.RotateSecret {
+		if secret, err = generateSecret(); err != nil {
+			return nil, "", err
+		}
+		endpoint.Secret = secret
+	}
+
+	if err := s.repo.UpdateEndpoint(endpoint); err != nil {
+		return nil, "", fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+	return endpoint, secret, nil
+}
+
+// ListEndpoints returns a user's endpoints
+func (s *WebhookService) ListEndpoints(realmID, userID string) ([]models.WebhookEndpoint, error) {
+	return s.repo.ListEndpoints(realmID, userID)
+}
+
+// DeleteEndpoint deletes one of a user's endpoints
+func (s *WebhookService) DeleteEndpoint(id, realmID, userID string) error {
+	if _, err := s.repo.GetEndpoint(realmID, userID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteEndpoint(id)
+}
+
+// ListDeliveries returns the delivery attempts made for a user
+func (s *WebhookService) ListDeliveries(realmID, userID string, params DeliveryParams) ([]models.WebhookDelivery, int64, error) {
+	return s.repo.ListDeliveries(realmID, userID, params)
+}
+
+// validateURL accepts http and https URLs of public hosts
+func validateURL(raw string) error {
+	return netguard.CheckURL(raw)
+}
+
+func generateSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
@@ -46,7 +46,10 @@ func InitDB() error {
 	if skipDbInit != "1" {
 		log.GetLogger().Info("Running database initialization (set SKIP_DB_INIT=1 to skip)")
 
-		// Auto-migrate database schema
+		// Fix up rows the new schema would reject, then auto-migrate database schema
+		if err := models.PrepareMigration(DB); err != nil {
+			return fmt.Errorf("auto-migration failed: %w", err)
+		}
 		if err := DB.AutoMigrate(models.GetAllModels()...); err != nil {
 			return fmt.Errorf("auto-migration failed: %w", err)
 		}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+// NewRedisClient connects to the Redis server in the redis config. It returns nil without an
+// error when no redis.addr is configured, since Redis is optional.
+func NewRedisClient() (*redis.Client, error) {
+	addr := viper.GetString("redis.addr")
+	if addr == "" {
+		return nil, nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: viper.GetString("redis.password"),
+		DB:       viper.GetInt("redis.db"),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", addr, err)
+	}
+	return client, nil
+}
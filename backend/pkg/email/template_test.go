@@ -184,4 +184,56 @@ func TestRegistrationApprovalTemplate(t *testing.T) {
 	t.Logf("Denial body: %s", message.Body)
 }
 
+func TestTaskDigestTemplate(t *testing.T) {
+	configPath := "../config/email_template.yaml"
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		configPath = "../../config/email_template.yaml"
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		t.Skip("Email template config not found, skipping test")
+	}
+
+	manager, err := NewEmailTemplateManager(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create template manager: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"Username":          "alice",
+		"Date":              "Monday, March 10, 2025",
+		"Weekly":            false,
+		"ChecklistItems":    []map[string]string{{"Title": "Write report", "Time": "17:00", "Detail": "A"}},
+		"UpcomingTasks":     []map[string]string{},
+		"OverdueTasks":      []map[string]string{{"Title": "File taxes", "Time": "Fri Mar 7 18:00", "Detail": "priority 3"}},
+		"DueReminders":      []map[string]string{{"Title": "Stand-up", "Time": "Mon Mar 10 09:45", "Detail": ""}},
+		"PendingInboxCount": 4,
+		"ToAddr":            []string{"alice@example.com"},
+	}
+
+	message, err := manager.RenderTemplate("task_digest", data)
+	if err != nil {
+		t.Fatalf("Failed to render daily digest template: %v", err)
+	}
+	for _, want := range []string{"Your daily digest - Monday, March 10, 2025", "- [A] Write report (due 17:00)", "- File taxes, due Fri Mar 7 18:00", "- Mon Mar 10 09:45 Stand-up\n", "4 item(s)"} {
+		if !contains(message.Subject+"\n"+message.Body, want) {
+			t.Errorf("Daily digest should contain %q, got: %s", want, message.Body)
+		}
+	}
+	if contains(message.Body, "Last 7 days") {
+		t.Errorf("Daily digest should not contain statistics, got: %s", message.Body)
+	}
+
+	data["Weekly"] = true
+	data["Stats"] = map[string]interface{}{"TasksCompleted": 12, "TasksFailed": 1, "ChecklistCompleted": 9, "ChecklistPlanned": 10, "CompletionRate": 90, "InboxAdded": 6}
+	message, err = manager.RenderTemplate("task_digest", data)
+	if err != nil {
+		t.Fatalf("Failed to render weekly digest template: %v", err)
+	}
+	if !contains(message.Subject, "Your weekly summary") || !contains(message.Body, "Checklist items completed: 9 of 10 (90%)") {
+		t.Errorf("Weekly digest should contain statistics, got: %s", message.Body)
+	}
+
+	t.Logf("Weekly digest body: %s", message.Body)
+}
+
 // Note: Helper functions contains and containsSubstring are defined in email_sender_test.go
@@ -0,0 +1,118 @@
+// Package netguard keeps outgoing requests to user supplied URLs, such as webhooks, from
+// reaching the server's own network: loopback, private, link-local and other addresses that
+// are not publicly routable are refused.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for addresses that are not publicly routable
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// blockedNetworks are the non-public ranges the net.IP predicates do not cover
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved, including broadcast
+	"64:ff9b::/96",  // NAT64, which embeds IPv4 addresses
+)
+
+// IsPublicIP reports whether ip is publicly routable
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that raw is an absolute http or https URL whose host is not a loopback
+// name or a non-public IP address. Host names are checked again when they are resolved, by
+// the client of NewClient.
+func CheckURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("url host %s: %w", host, ErrBlockedAddress)
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return fmt.Errorf("url host %s: %w", host, ErrBlockedAddress)
+	}
+	return nil
+}
+
+// Host returns the host of raw, or "" when it has none. Errors and logs name only the host of
+// a user supplied URL, as its path and query often hold tokens.
+func Host(raw string) string {
+	if u, err := url.Parse(strings.TrimSpace(raw)); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// RedactError replaces the URL of a *url.Error, which the errors of http.Client carry in full,
+// with its host
+func RedactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	if host := Host(urlErr.URL); host != "" {
+		return fmt.Errorf("%s %s: %w", urlErr.Op, host, urlErr.Err)
+	}
+	return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+}
+
+// NewClient returns an HTTP client that only connects to public addresses. The check runs on
+// the resolved address of every connection, redirects included, so a host name cannot be
+// pointed at an internal address after it was validated. Proxies are not used, as they would
+// connect on the client's behalf.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("dial %s: %w", address, ErrBlockedAddress)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,63 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for _, ip := range []string{"93.184.216.34", "8.8.8.8", "2606:4700:4700::1111"} {
+		assert.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0",
+		"100.64.0.1", "255.255.255.255", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "64:ff9b::a00:1",
+	} {
+		assert.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	assert.NoError(t, CheckURL("https://hooks.example.com/a"))
+	assert.NoError(t, CheckURL(" http://93.184.216.34:8080/a "))
+
+	for _, raw := range []string{
+		"http://localhost:8080/a", "http://api.localhost/a", "http://127.0.0.1/a",
+		"http://169.254.169.254/latest/meta-data", "http://[::1]/a", "http://10.0.0.1/a",
+	} {
+		assert.ErrorIs(t, CheckURL(raw), ErrBlockedAddress, raw)
+	}
+	for _, raw := range []string{"ftp://example.com/a", "/relative", "https://", "not a url"} {
+		err := CheckURL(raw)
+		assert.Error(t, err, raw)
+		assert.False(t, errors.Is(err, ErrBlockedAddress), raw)
+	}
+}
+
+func TestNewClient_RefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second).Get(server.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+}
+
+func TestRedactError(t *testing.T) {
+	err := RedactError(&url.Error{Op: "Post", URL: "https://hooks.slack.com/services/T/B/X?token=s3cret", Err: ErrBlockedAddress})
+	assert.Equal(t, "Post hooks.slack.com: address is not publicly routable", err.Error())
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+
+	plain := errors.New("unexpected status 500")
+	assert.Equal(t, plain, RedactError(plain))
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -59,6 +63,122 @@ func RenderTemplate(template string, data TemplateData) string {
 	return result
 }
 
+// ErrTemplateLimit is returned when rendering a template exceeds its TemplateLimits
+var ErrTemplateLimit = errors.New("template exceeds the rendering limits")
+
+// TemplateLimits bounds the output and the work of RenderTextTemplate; zero fields are unlimited
+type TemplateLimits struct {
+	MaxOutput int           // bytes written
+	MaxSteps  int           // range iterations and template calls
+	Timeout   time.Duration // rendering time
+}
+
+// templateStepFunc is called at the start of every range iteration and template body
+const templateStepFunc = "_step"
+
+// RenderTextTemplate renders a Go text/template with extra template functions.
+// Data keys are available as {{ .key }}; referencing a missing key is an error. Rendering stops
+// with ErrTemplateLimit once it passes one of the limits, e.g. for {{ range 100000000 }}.
+func RenderTextTemplate(name, text string, funcs template.FuncMap, data TemplateData, limits TemplateLimits) (string, error) {
+	budget := &templateBudget{limits: limits}
+	if limits.Timeout > 0 {
+		budget.deadline = time.Now().Add(limits.Timeout)
+	}
+	tmpl, err := template.New(name).Funcs(funcs).
+		Funcs(template.FuncMap{templateStepFunc: budget.step}).
+		Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+	if err := budget.instrument(tmpl); err != nil {
+		return "", err
+	}
+
+	if err := tmpl.Execute(budget, data); err != nil {
+		if errors.Is(err, ErrTemplateLimit) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return budget.out.String(), nil
+}
+
+// templateBudget is the output of a template rendering and counts its work against the limits
+type templateBudget struct {
+	limits   TemplateLimits
+	deadline time.Time
+	out      strings.Builder
+	steps    int
+}
+
+func (b *templateBudget) Write(p []byte) (int, error) {
+	if b.limits.MaxOutput > 0 && b.out.Len()+len(p) > b.limits.MaxOutput {
+		return 0, fmt.Errorf("%w: output is larger than %d bytes", ErrTemplateLimit, b.limits.MaxOutput)
+	}
+	if err := b.checkDeadline(); err != nil {
+		return 0, err
+	}
+	return b.out.Write(p)
+}
+
+func (b *templateBudget) step() (string, error) {
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return "", fmt.Errorf("%w: more than %d loop iterations and template calls", ErrTemplateLimit, b.limits.MaxSteps)
+	}
+	return "", b.checkDeadline()
+}
+
+func (b *templateBudget) checkDeadline() error {
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: rendering takes longer than %s", ErrTemplateLimit, b.limits.Timeout)
+	}
+	return nil
+}
+
+// instrument puts a step call at the start of every template body and range body of tmpl, so
+// loops and recursive templates count against the budget even when they write nothing
+func (b *templateBudget) instrument(tmpl *template.Template) error {
+	stepTree, err := template.New(templateStepFunc).
+		Funcs(template.FuncMap{templateStepFunc: b.step}).
+		Parse("{{" + templateStepFunc + "}}")
+	if err != nil {
+		return err
+	}
+	stepNode := stepTree.Tree.Root.Nodes[0]
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+			n.List.Nodes = append([]parse.Node{stepNode}, n.List.Nodes...)
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		walk(t.Tree.Root)
+		t.Tree.Root.Nodes = append([]parse.Node{stepNode}, t.Tree.Root.Nodes...)
+	}
+	return nil
+}
+
 // RenderPromptTemplate renders both system and user prompts with data
 func (pt *PromptTemplate) RenderPromptTemplate(data TemplateData) (string, string) {
 	systemPrompt := RenderTemplate(pt.SystemPrompt, data)